@@ -7,9 +7,15 @@ import (
 	"go-boilerplate/internal/config"
 	"go-boilerplate/internal/crypto"
 	"go-boilerplate/internal/crypto/portfolio"
+	"go-boilerplate/internal/crypto/pricing"
 	"go-boilerplate/internal/database"
+	"go-boilerplate/internal/infra/audit"
 	infraAuth "go-boilerplate/internal/infra/auth"
 	"go-boilerplate/internal/infra/health"
+	"go-boilerplate/internal/infra/mail"
+	"go-boilerplate/internal/infra/oidc"
+	"go-boilerplate/internal/infra/scheduler"
+	"go-boilerplate/internal/privacy"
 	"go-boilerplate/internal/router"
 	"log/slog"
 	"net/http"
@@ -36,29 +42,237 @@ func main() {
 		os.Exit(1)
 	}
 
+	tokenHasher := infraAuth.NewHMACTokenHasher(cfg.TokenPepper)
+	auditStore := audit.NewGormStore(db)
+
 	// Auto-migrate domain entities
-	if err := db.AutoMigrate(&auth.RefreshToken{}, &portfolio.Portfolio{}, &portfolio.Holding{}); err != nil {
+	if err := auth.Migrate(db, tokenHasher); err != nil {
 		slog.Error("failed to migrate database", "error", err)
 		os.Exit(1)
 	}
+	if err := portfolio.Migrate(db); err != nil {
+		slog.Error("failed to migrate database", "error", err)
+		os.Exit(1)
+	}
+	if err := pricing.Migrate(db); err != nil {
+		slog.Error("failed to migrate database", "error", err)
+		os.Exit(1)
+	}
+	if err := audit.Migrate(db); err != nil {
+		slog.Error("failed to migrate database", "error", err)
+		os.Exit(1)
+	}
+	if err := auth.PromoteAdmins(db, cfg.AdminUsernames); err != nil {
+		slog.Error("failed to promote admin users", "error", err)
+		os.Exit(1)
+	}
 
-	jwtSvc := infraAuth.NewJWTService(cfg.JWTSecret, cfg.JWTExpiryHours)
+	jwtOpts := []infraAuth.Option{
+		infraAuth.WithIssuer(cfg.JWTIssuer),
+		infraAuth.WithAudience(cfg.JWTAudience...),
+		infraAuth.WithLeeway(time.Duration(cfg.JWTLeewaySeconds) * time.Second),
+	}
+	if cfg.JWTSigningKeysDir != "" {
+		keyring, err := infraAuth.LoadKeyringFromDir(cfg.JWTSigningKeysDir, cfg.JWTActiveKeyID)
+		if err != nil {
+			slog.Error("failed to load jwt signing keys", "error", err)
+			os.Exit(1)
+		}
+		jwtOpts = append(jwtOpts, infraAuth.WithKeyring(keyring))
+	}
 
-	e := router.NewRouter(cfg)
+	jwtSvc := infraAuth.NewJWTService(cfg.JWTSecret, cfg.JWTExpiryHours, jwtOpts...)
+	passwordHasher := infraAuth.NewBcryptHasher(cfg.BcryptCost)
+
+	var revocations infraAuth.RevocationStore
+	switch cfg.TokenRevocationStore {
+	case "postgres":
+		if err := infraAuth.MigrateRevocationStore(db); err != nil {
+			slog.Error("failed to migrate database", "error", err)
+			os.Exit(1)
+		}
+		revocations = infraAuth.NewPostgresRevocationStore(db)
+	case "memory":
+		revocations = infraAuth.NewMemoryRevocationStore()
+	default:
+		slog.Error("unknown token revocation store", "store", cfg.TokenRevocationStore)
+		os.Exit(1)
+	}
+
+	var mailer mail.Mailer
+	switch cfg.Mail.Driver {
+	case "smtp":
+		mailer = mail.NewSMTPMailer(mail.SMTPConfig{
+			Host:     cfg.Mail.SMTPHost,
+			Port:     cfg.Mail.SMTPPort,
+			Username: cfg.Mail.SMTPUsername,
+			Password: cfg.Mail.SMTPPassword,
+			From:     cfg.Mail.From,
+		})
+	case "file":
+		mailer = mail.NewFileMailer(cfg.Mail.Dir, cfg.Mail.From)
+	default:
+		slog.Error("unknown mail driver", "driver", cfg.Mail.Driver)
+		os.Exit(1)
+	}
+
+	// Auth domain setup
+	lockoutPolicy := auth.LockoutPolicy{
+		MaxAccountFailures: cfg.Lockout.MaxAccountFailures,
+		MaxIPFailures:      cfg.Lockout.MaxIPFailures,
+		FailureWindow:      time.Duration(cfg.Lockout.FailureWindowMinutes) * time.Minute,
+		BaseLockout:        time.Duration(cfg.Lockout.BaseLockoutSeconds) * time.Second,
+		MaxLockout:         time.Duration(cfg.Lockout.MaxLockoutMinutes) * time.Minute,
+	}
+	mfaSecrets, err := infraAuth.NewAESGCMSecretBox(cfg.MFAEncryptionKey)
+	if err != nil {
+		slog.Error("failed to initialize MFA secret encryption", "error", err)
+		os.Exit(1)
+	}
+
+	oidcProviders := map[string]oidc.Provider{}
+	if cfg.OIDCProvidersFile != "" {
+		providerConfigs, err := oidc.LoadProviderConfigs(cfg.OIDCProvidersFile)
+		if err != nil {
+			slog.Error("failed to load OIDC providers", "error", err)
+			os.Exit(1)
+		}
+		oidcClient := &http.Client{Timeout: 10 * time.Second}
+		for _, providerConfig := range providerConfigs {
+			oidcProviders[providerConfig.Name] = oidc.NewProvider(providerConfig, oidcClient)
+		}
+	}
+
+	authInjector := auth.NewInjector(
+		db,
+		jwtSvc,
+		passwordHasher,
+		tokenHasher,
+		revocations,
+		lockoutPolicy,
+		mfaSecrets,
+		cfg.MFAIssuer,
+		mailer,
+		cfg.AppBaseURL,
+		oidcProviders,
+		auditStore,
+	)
+
+	// Browser clients may keep their tokens in cookies instead
+	var sessionCookies *infraAuth.SessionCookies
+	if cfg.Cookies.Enabled {
+		sessionCookies, err = infraAuth.NewSessionCookies(
+			cfg.Cookies.Domain,
+			cfg.Cookies.Secure,
+			cfg.Cookies.SameSite,
+			jwtSvc.AccessTokenTTL(),
+			auth.RefreshTokenTTL,
+		)
+		if err != nil {
+			slog.Error("invalid auth cookie settings", "error", err)
+			os.Exit(1)
+		}
+	}
+
+	// Accepts access tokens and API keys alike
+	bearerOpts := []infraAuth.MiddlewareOption{
+		infraAuth.WithRevocationStore(revocations),
+		infraAuth.WithAPIKeyAuthenticator(auth.APIKeyAuthenticator(authInjector)),
+		infraAuth.WithAuditLogger(auditStore),
+	}
+	if sessionCookies != nil {
+		bearerOpts = append(bearerOpts, infraAuth.WithAccessTokenCookie())
+	}
+	bearerMiddleware := infraAuth.BearerAuth(jwtSvc, bearerOpts...)
+
+	introspectionClients, err := infraAuth.ParseClientCredentials(cfg.IntrospectionClients)
+	if err != nil {
+		slog.Error("failed to parse introspection clients", "error", err)
+		os.Exit(1)
+	}
+	introspectionAuth := infraAuth.ClientAuth(introspectionClients)
+
+	e, err := router.NewRouter(cfg)
+	if err != nil {
+		slog.Error("failed to create router", "error", err)
+		os.Exit(1)
+	}
+	if sessionCookies != nil {
+		e.Use(infraAuth.CSRF())
+	}
 
 	// Health check endpoints
 	healthHandler := health.NewHealthHandler(db)
 	e.GET("/health/live", healthHandler.Liveness)
 	e.GET("/health/ready", healthHandler.Readiness)
 
-	// Auth domain setup
-	authInjector := auth.NewInjector(db, jwtSvc)
-	auth.RegisterHandlers(e, authInjector)
+	// Public keys for verifying issued tokens
+	e.GET("/.well-known/jwks.json", infraAuth.JWKSHandler(jwtSvc))
+
+	// Auth endpoints
+	auth.RegisterHandlers(e, authInjector, bearerMiddleware, introspectionAuth, sessionCookies)
+
+	// Audit log, readable by administrators only
+	auditHandler := audit.NewHandler(auditStore)
+	e.GET("/admin/audit-events", auditHandler.List, bearerMiddleware, infraAuth.RequirePermission(infraAuth.PermUsersManage))
+
+	// Personal data export and account deletion
+	privacyInjector := privacy.NewInjector(db, passwordHasher, jwtSvc, revocations, auditStore)
+	privacy.RegisterHandlers(e, privacyInjector, bearerMiddleware)
 
 	// Crypto domain setup
 	cryptoGroup := e.Group("/crypto-api")
-	cryptoInjector := crypto.NewInjector(db)
-	crypto.NewHTTPHandlers(cryptoGroup, cryptoInjector, jwtSvc)
+	cryptoInjector := crypto.NewInjector(db, auditStore)
+	crypto.NewHTTPHandlers(cryptoGroup, cryptoInjector, bearerMiddleware)
+
+	// Background housekeeping jobs
+	housekeepingInterval := time.Duration(cfg.Housekeeping.IntervalMinutes) * time.Minute
+	housekeepingJitter := time.Duration(cfg.Housekeeping.JitterSeconds) * time.Second
+
+	sched := scheduler.New()
+	auth.RegisterJobs(
+		sched,
+		authInjector,
+		housekeepingInterval,
+		housekeepingJitter,
+		time.Duration(cfg.Housekeeping.TokenRetentionHours)*time.Hour,
+		cfg.Housekeeping.PurgeBatchSize,
+	)
+	sched.Register(scheduler.Job{
+		Name:     "purge-revoked-access-tokens",
+		Interval: housekeepingInterval,
+		Jitter:   housekeepingJitter,
+		Run:      revocations.PurgeExpired,
+	})
+
+	// Market prices for holdings, when a provider is configured
+	var prices pricing.PriceProvider
+	switch cfg.Pricing.Provider {
+	case "":
+	case "file":
+		prices = pricing.NewFileProvider(cfg.Pricing.File, cfg.Pricing.DefaultCurrency)
+	case "http":
+		prices = pricing.NewHTTPProvider(
+			cfg.Pricing.HTTPURL,
+			cfg.Pricing.HTTPAPIKey,
+			cfg.Pricing.DefaultCurrency,
+			&http.Client{Timeout: 10 * time.Second},
+		)
+	default:
+		slog.Error("unknown price provider", "provider", cfg.Pricing.Provider)
+		os.Exit(1)
+	}
+	if prices != nil {
+		crypto.RegisterJobs(
+			sched,
+			cryptoInjector,
+			prices,
+			time.Duration(cfg.Pricing.RefreshIntervalSeconds)*time.Second,
+			time.Duration(cfg.Pricing.RefreshJitterSeconds)*time.Second,
+		)
+	}
+
+	sched.Start(context.Background())
 
 	// Configure http.Server explicitly for better control and graceful shutdown support in Echo v5
 	server := &http.Server{
@@ -91,6 +305,11 @@ func main() {
 		slog.Error("failed to shutdown server gracefully", "error", err)
 	}
 
+	// Stop background jobs before the database they use is closed
+	if err := sched.Stop(ctx); err != nil {
+		slog.Error("failed to stop scheduler gracefully", "error", err)
+	}
+
 	// Close database connection
 	if err := database.Close(db); err != nil {
 		slog.Error("failed to close database connection", "error", err)
@@ -0,0 +1,62 @@
+package auth
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type ActionTokenRepository interface {
+	Create(ctx context.Context, token *ActionToken) error
+	Consume(ctx context.Context, tokenHash, purpose string) (*ActionToken, error)
+	InvalidateForUser(ctx context.Context, userID uuid.UUID, purpose string) error
+	PurgeExpired(ctx context.Context, cutoff time.Time) (int64, error)
+}
+
+type actionTokenRepository struct {
+	db *gorm.DB
+}
+
+func NewActionTokenRepository(db *gorm.DB) ActionTokenRepository {
+	return &actionTokenRepository{db: db}
+}
+
+func (r *actionTokenRepository) Create(ctx context.Context, token *ActionToken) error {
+	return r.db.WithContext(ctx).Create(token).Error
+}
+
+// Consume marks an unused, unexpired token as used and returns it. Concurrent attempts
+// to use the same token race on the update, so only one of them succeeds.
+func (r *actionTokenRepository) Consume(ctx context.Context, tokenHash, purpose string) (*ActionToken, error) {
+	var tokens []ActionToken
+	now := time.Now()
+	err := r.db.WithContext(ctx).Raw(`
+		UPDATE action_tokens SET used_at = ?
+		WHERE token_hash = ? AND purpose = ? AND used_at IS NULL AND expires_at > ?
+		RETURNING *`, now, tokenHash, purpose, now).
+		Scan(&tokens).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, ErrInvalidToken
+	}
+	return &tokens[0], nil
+}
+
+// InvalidateForUser uses up the user's outstanding tokens for purpose, so only the most
+// recently sent link works.
+func (r *actionTokenRepository) InvalidateForUser(ctx context.Context, userID uuid.UUID, purpose string) error {
+	return r.db.WithContext(ctx).
+		Model(&ActionToken{}).
+		Where("user_id = ? AND purpose = ? AND used_at IS NULL", userID, purpose).
+		Update("used_at", time.Now()).Error
+}
+
+// PurgeExpired deletes tokens that expired before cutoff, used or not.
+func (r *actionTokenRepository) PurgeExpired(ctx context.Context, cutoff time.Time) (int64, error) {
+	result := r.db.WithContext(ctx).Where("expires_at < ?", cutoff).Delete(&ActionToken{})
+	return result.RowsAffected, result.Error
+}
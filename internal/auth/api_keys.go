@@ -0,0 +1,148 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"go-boilerplate/internal/infra/audit"
+	infraAuth "go-boilerplate/internal/infra/auth"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrAPIKeyNotFound = errors.New("API key not found")
+	ErrInvalidScope   = errors.New("scope not granted by your role")
+)
+
+// lastUsedResolution limits how often a busy key's last-used time is written.
+const lastUsedResolution = time.Minute
+
+// CreateAPIKey issues a named key for the user that expires after ttl. Scopes must be a
+// subset of the user's role permissions; none means all of them. The raw key is returned
+// once and cannot be recovered later.
+func (u *usecase) CreateAPIKey(
+	ctx context.Context,
+	userID uuid.UUID,
+	name string,
+	scopes []infraAuth.Permission,
+	ttl time.Duration,
+) (*APIKey, string, error) {
+	user, err := u.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if len(scopes) == 0 {
+		scopes = user.Role.Permissions()
+	}
+	scopes, err = grantScopes(user.Role, scopes)
+	if err != nil {
+		return nil, "", err
+	}
+
+	rawKey, prefix, err := infraAuth.GenerateAPIKey()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to generate API key: %w", err)
+	}
+
+	key := &APIKey{
+		ID:        uuid.New(),
+		UserID:    user.ID,
+		Name:      name,
+		Prefix:    prefix,
+		KeyHash:   u.tokenHasher.Hash(rawKey),
+		Scopes:    scopes,
+		ExpiresAt: time.Now().Add(ttl),
+	}
+	if err := u.apiKeys.Create(ctx, key); err != nil {
+		return nil, "", fmt.Errorf("failed to store API key: %w", err)
+	}
+
+	u.auditLogger.Log(ctx, audit.Event{
+		Action:     ActionAPIKeyCreate,
+		TargetType: auditTargetAPIKey,
+		TargetID:   key.ID.String(),
+		Outcome:    audit.OutcomeSuccess,
+		Metadata:   map[string]any{"user_id": user.ID.String(), "name": name, "scopes": infraAuth.FormatScope(scopes)},
+	})
+
+	return key, rawKey, nil
+}
+
+func (u *usecase) ListAPIKeys(ctx context.Context, userID uuid.UUID) ([]APIKey, error) {
+	keys, err := u.apiKeys.ListByUserID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list API keys: %w", err)
+	}
+	return keys, nil
+}
+
+func (u *usecase) RevokeAPIKey(ctx context.Context, userID, keyID uuid.UUID) error {
+	revoked, err := u.apiKeys.Revoke(ctx, userID, keyID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke API key: %w", err)
+	}
+	if !revoked {
+		return ErrAPIKeyNotFound
+	}
+
+	u.auditLogger.Log(ctx, audit.Event{
+		Action:     ActionAPIKeyRevoke,
+		TargetType: auditTargetAPIKey,
+		TargetID:   keyID.String(),
+		Outcome:    audit.OutcomeSuccess,
+		Metadata:   map[string]any{"user_id": userID.String()},
+	})
+	return nil
+}
+
+// AuthenticateAPIKey implements infraAuth.APIKeyAuthenticator. The owner is reloaded on
+// every request so role changes and deleted accounts take effect immediately, and the
+// key's scopes are narrowed to what the current role still grants.
+func (u *usecase) AuthenticateAPIKey(ctx context.Context, rawKey string) (*infraAuth.APIKeyPrincipal, error) {
+	key, err := u.apiKeys.GetByHash(ctx, u.tokenHasher.Hash(rawKey))
+	if err != nil {
+		if errors.Is(err, ErrAPIKeyNotFound) {
+			return nil, infraAuth.ErrInvalidAPIKey
+		}
+		return nil, fmt.Errorf("failed to get API key: %w", err)
+	}
+
+	now := time.Now()
+	if key.RevokedAt != nil || !key.ExpiresAt.After(now) {
+		return nil, infraAuth.ErrInvalidAPIKey
+	}
+
+	user, err := u.userRepo.GetByID(ctx, key.UserID)
+	if err != nil {
+		if errors.Is(err, ErrUserNotFound) {
+			return nil, infraAuth.ErrInvalidAPIKey
+		}
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) > lastUsedResolution {
+		if err := u.apiKeys.TouchLastUsed(ctx, key.ID, now); err != nil {
+			slog.WarnContext(ctx, "failed to record API key use", "error", err, "key_id", key.ID)
+		}
+	}
+
+	scopes := make([]infraAuth.Permission, 0, len(key.Scopes))
+	for _, scope := range key.Scopes {
+		if user.Role.Can(scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	return &infraAuth.APIKeyPrincipal{
+		KeyID:     key.ID.String(),
+		UserID:    user.ID.String(),
+		Role:      user.Role,
+		Scopes:    scopes,
+		ExpiresAt: key.ExpiresAt,
+	}, nil
+}
@@ -0,0 +1,165 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+	"time"
+
+	"go-boilerplate/internal/infra/audit"
+	infraAuth "go-boilerplate/internal/infra/auth"
+	"go-boilerplate/internal/infra/mail"
+
+	"github.com/google/uuid"
+)
+
+var ErrEmailAlreadyVerified = errors.New("email is already verified")
+
+const (
+	passwordResetTTL     = time.Hour
+	emailVerificationTTL = 48 * time.Hour
+)
+
+// ForgotPassword emails a password reset link if an account uses the address. It reports
+// success either way so the endpoint cannot be used to discover registered emails.
+func (u *usecase) ForgotPassword(ctx context.Context, email string) error {
+	user, err := u.userRepo.GetByEmail(ctx, normalizeIdentifier(email))
+	if err != nil {
+		if errors.Is(err, ErrUserNotFound) {
+			return nil
+		}
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+
+	token, err := u.issueActionToken(ctx, user.ID, PurposePasswordReset, passwordResetTTL)
+	if err != nil {
+		return err
+	}
+
+	return u.mailer.Send(ctx, mail.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hi %s,\n\n"+
+			"Follow this link within the next hour to choose a new password:\n\n%s\n\n"+
+			"If you did not ask to reset your password you can ignore this email.\n",
+			user.Username, u.actionLink("/reset-password", token)),
+	})
+}
+
+// ResetPassword sets a new password using a token from ForgotPassword and logs the user
+// out everywhere, in case the old password was compromised.
+func (u *usecase) ResetPassword(ctx context.Context, tokenStr, password string) error {
+	token, err := u.actionTokens.Consume(ctx, u.tokenHasher.Hash(tokenStr), PurposePasswordReset)
+	if err != nil {
+		if errors.Is(err, ErrInvalidToken) {
+			return err
+		}
+		return fmt.Errorf("failed to use reset token: %w", err)
+	}
+
+	passwordHash, err := u.hasher.Hash(password)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	if err := u.userRepo.UpdatePassword(ctx, token.UserID, passwordHash); err != nil {
+		if errors.Is(err, ErrUserNotFound) {
+			return ErrInvalidToken
+		}
+		return fmt.Errorf("failed to update password: %w", err)
+	}
+
+	u.auditUser(ctx, ActionPasswordReset, audit.OutcomeSuccess, token.UserID, nil)
+
+	return u.RevokeAllSessions(ctx, token.UserID)
+}
+
+// VerifyEmail confirms the user's address using a token from the verification email.
+func (u *usecase) VerifyEmail(ctx context.Context, tokenStr string) error {
+	token, err := u.actionTokens.Consume(ctx, u.tokenHasher.Hash(tokenStr), PurposeEmailVerification)
+	if err != nil {
+		if errors.Is(err, ErrInvalidToken) {
+			return err
+		}
+		return fmt.Errorf("failed to use verification token: %w", err)
+	}
+
+	if err := u.userRepo.MarkEmailVerified(ctx, token.UserID); err != nil {
+		if errors.Is(err, ErrUserNotFound) {
+			return ErrInvalidToken
+		}
+		return fmt.Errorf("failed to verify email: %w", err)
+	}
+
+	u.auditUser(ctx, ActionEmailVerify, audit.OutcomeSuccess, token.UserID, nil)
+	return nil
+}
+
+// SendVerificationEmail sends a new verification link, invalidating earlier ones.
+func (u *usecase) SendVerificationEmail(ctx context.Context, userID uuid.UUID) error {
+	user, err := u.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user.EmailVerifiedAt != nil {
+		return ErrEmailAlreadyVerified
+	}
+	return u.sendVerificationEmail(ctx, user)
+}
+
+func (u *usecase) sendVerificationEmail(ctx context.Context, user *User) error {
+	token, err := u.issueActionToken(ctx, user.ID, PurposeEmailVerification, emailVerificationTTL)
+	if err != nil {
+		return err
+	}
+
+	return u.mailer.Send(ctx, mail.Message{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf("Hi %s,\n\n"+
+			"Confirm this is your email address by following this link:\n\n%s\n",
+			user.Username, u.actionLink("/verify-email", token)),
+	})
+}
+
+// issueActionToken replaces the user's outstanding tokens for purpose with a new one and
+// returns its raw value, which only ever leaves the server in the email.
+func (u *usecase) issueActionToken(ctx context.Context, userID uuid.UUID, purpose string, ttl time.Duration) (string, error) {
+	if err := u.actionTokens.InvalidateForUser(ctx, userID, purpose); err != nil {
+		return "", fmt.Errorf("failed to invalidate previous tokens: %w", err)
+	}
+
+	tokenStr, err := infraAuth.GenerateOpaqueToken()
+	if err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+
+	token := &ActionToken{
+		ID:        uuid.New(),
+		UserID:    userID,
+		Purpose:   purpose,
+		TokenHash: u.tokenHasher.Hash(tokenStr),
+		ExpiresAt: time.Now().Add(ttl),
+	}
+	if err := u.actionTokens.Create(ctx, token); err != nil {
+		return "", fmt.Errorf("failed to store token: %w", err)
+	}
+
+	return tokenStr, nil
+}
+
+// actionLink builds the front-end URL that receives an emailed token.
+func (u *usecase) actionLink(path, token string) string {
+	return strings.TrimRight(u.appBaseURL, "/") + path + "?token=" + url.QueryEscape(token)
+}
+
+// sendWelcomeVerification is called after registration. Mail delivery problems are logged
+// rather than failing the registration; the user can ask for a new link later.
+func (u *usecase) sendWelcomeVerification(ctx context.Context, user *User) {
+	if err := u.sendVerificationEmail(ctx, user); err != nil {
+		slog.ErrorContext(ctx, "failed to send verification email", "error", err, "user_id", user.ID)
+	}
+}
@@ -3,21 +3,184 @@ package auth
 import (
 	"time"
 
+	infraAuth "go-boilerplate/internal/infra/auth"
+
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
+// User represents a registered account that can authenticate against the API.
+type User struct {
+	ID           uuid.UUID      `gorm:"type:uuid;primaryKey"`
+	Username     string         `gorm:"type:varchar(50);uniqueIndex;not null"`
+	Email        string         `gorm:"type:varchar(255);uniqueIndex;not null"`
+	PasswordHash string         `gorm:"not null"`
+	Role         infraAuth.Role `gorm:"type:varchar(20);not null;default:'user'"`
+	// EmailVerifiedAt is set once the user follows the link sent to Email.
+	EmailVerifiedAt *time.Time
+	// TOTPSecret is the encrypted authenticator secret. It is stored at enrollment but only
+	// required at login once MFAEnabledAt is set; TOTPLastStep prevents code replay.
+	TOTPSecret   string `gorm:"type:varchar(255)"`
+	TOTPLastStep int64  `gorm:"not null;default:0"`
+	MFAEnabledAt *time.Time
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	DeletedAt    gorm.DeletedAt `gorm:"index"`
+}
+
+func (User) TableName() string {
+	return "users"
+}
+
+// MFAEnabled reports whether logging in requires a second factor.
+func (u *User) MFAEnabled() bool {
+	return u.MFAEnabledAt != nil
+}
+
+// RecoveryCode is a single-use code that stands in for a TOTP code when the user has lost
+// their authenticator. Only a keyed hash of the code is stored.
+type RecoveryCode struct {
+	ID        uuid.UUID `gorm:"type:uuid;primaryKey"`
+	UserID    uuid.UUID `gorm:"type:uuid;index;not null"`
+	CodeHash  string    `gorm:"uniqueIndex;not null"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+func (RecoveryCode) TableName() string {
+	return "mfa_recovery_codes"
+}
+
 // RefreshToken represents a stored refresh token to allow for revocation and rotation.
+// Every rotation revokes the presented token and issues a child in the same family,
+// so a revoked token being presented again indicates it was stolen and replayed.
+// Only a keyed hash of the token is stored; the raw value exists solely on the client.
+// A family corresponds to one login session; the live token carries the session's client metadata.
+// Scopes are the permissions the session's access tokens are restricted to; empty means
+// the user's whole role.
 type RefreshToken struct {
+	ID               uuid.UUID              `gorm:"type:uuid;primaryKey"`
+	UserID           uuid.UUID              `gorm:"type:uuid;index;not null"`
+	FamilyID         uuid.UUID              `gorm:"type:uuid;index;not null;default:gen_random_uuid()"`
+	ParentID         *uuid.UUID             `gorm:"type:uuid"`
+	TokenHash        string                 `gorm:"column:token;uniqueIndex;not null"`
+	UserAgent        string                 `gorm:"type:varchar(512)"`
+	IPAddress        string                 `gorm:"type:varchar(45)"`
+	Scopes           []infraAuth.Permission `gorm:"type:text;serializer:json"`
+	SessionStartedAt time.Time              `gorm:"not null;default:CURRENT_TIMESTAMP"`
+	LastUsedAt       time.Time              `gorm:"not null;default:CURRENT_TIMESTAMP"`
+	ExpiresAt        time.Time              `gorm:"not null"`
+	RevokedAt        *time.Time
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+	DeletedAt        gorm.DeletedAt `gorm:"index"`
+}
+
+func (RefreshToken) TableName() string {
+	return "refresh_tokens"
+}
+
+// APIKey is a long-lived credential for scripts and bots. Prefix is the public part of the
+// key shown in listings; only a keyed hash of the whole key is stored. Scopes restrict the
+// key to a subset of the owner's role permissions.
+type APIKey struct {
+	ID         uuid.UUID              `gorm:"type:uuid;primaryKey"`
+	UserID     uuid.UUID              `gorm:"type:uuid;index;not null"`
+	Name       string                 `gorm:"type:varchar(100);not null"`
+	Prefix     string                 `gorm:"type:varchar(32);uniqueIndex;not null"`
+	KeyHash    string                 `gorm:"uniqueIndex;not null"`
+	Scopes     []infraAuth.Permission `gorm:"type:text;serializer:json;not null"`
+	ExpiresAt  time.Time              `gorm:"not null"`
+	LastUsedAt *time.Time
+	RevokedAt  *time.Time
+	CreatedAt  time.Time
+}
+
+func (APIKey) TableName() string {
+	return "api_keys"
+}
+
+// Action token purposes.
+const (
+	PurposePasswordReset     = "password_reset"
+	PurposeEmailVerification = "email_verification"
+)
+
+// ActionToken is a single-use, expiring token emailed to a user to prove they control
+// their address, for example to reset a password. Like refresh tokens, only a keyed
+// hash is stored.
+type ActionToken struct {
 	ID        uuid.UUID `gorm:"type:uuid;primaryKey"`
-	UserID    string    `gorm:"index;not null"`
-	Token     string    `gorm:"uniqueIndex;not null"`
+	UserID    uuid.UUID `gorm:"type:uuid;index;not null"`
+	Purpose   string    `gorm:"type:varchar(32);not null"`
+	TokenHash string    `gorm:"uniqueIndex;not null"`
 	ExpiresAt time.Time `gorm:"not null"`
+	UsedAt    *time.Time
 	CreatedAt time.Time
-	UpdatedAt time.Time
-	DeletedAt gorm.DeletedAt `gorm:"index"`
 }
 
-func (RefreshToken) TableName() string {
-	return "refresh_tokens"
+func (ActionToken) TableName() string {
+	return "action_tokens"
+}
+
+// ClientInfo describes the client a session was started or last used from.
+type ClientInfo struct {
+	UserAgent string
+	IPAddress string
+}
+
+// Session is a user's login on one device, identified by its refresh token family.
+type Session struct {
+	ID         uuid.UUID
+	UserAgent  string
+	IPAddress  string
+	CreatedAt  time.Time
+	LastUsedAt time.Time
+	ExpiresAt  time.Time
+}
+
+// LoginAttempt tracks consecutive failed logins for one throttling key, either an
+// account ("account:<username>") or a client address ("ip:<address>").
+type LoginAttempt struct {
+	Key           string    `gorm:"type:varchar(128);primaryKey"`
+	Failures      int       `gorm:"not null;default:0"`
+	LastFailureAt time.Time `gorm:"not null"`
+	LockedUntil   *time.Time
+	UpdatedAt     time.Time
+}
+
+func (LoginAttempt) TableName() string {
+	return "login_attempts"
+}
+
+// ExternalIdentity links a user to their account at an OpenID Connect provider. The
+// provider's subject identifier, not the email address, is what identifies them there.
+type ExternalIdentity struct {
+	ID          uuid.UUID `gorm:"type:uuid;primaryKey"`
+	UserID      uuid.UUID `gorm:"type:uuid;index;not null"`
+	Provider    string    `gorm:"type:varchar(50);uniqueIndex:idx_external_identities_provider_subject;not null"`
+	Subject     string    `gorm:"type:varchar(255);uniqueIndex:idx_external_identities_provider_subject;not null"`
+	Email       string    `gorm:"type:varchar(255)"`
+	LastLoginAt time.Time `gorm:"not null"`
+	CreatedAt   time.Time
+}
+
+func (ExternalIdentity) TableName() string {
+	return "external_identities"
+}
+
+// OIDCLoginState is an authorization request in flight. It is looked up by a keyed hash of
+// the state parameter when the provider redirects back, and used at most once.
+type OIDCLoginState struct {
+	StateHash    string    `gorm:"primaryKey"`
+	Provider     string    `gorm:"type:varchar(50);not null"`
+	Nonce        string    `gorm:"not null"`
+	CodeVerifier string    `gorm:"not null"`
+	Cookie       bool      `gorm:"not null;default:false"`
+	ExpiresAt    time.Time `gorm:"index;not null"`
+	CreatedAt    time.Time
+}
+
+func (OIDCLoginState) TableName() string {
+	return "oidc_login_states"
 }
@@ -0,0 +1,53 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type ExternalIdentityRepository interface {
+	Create(ctx context.Context, identity *ExternalIdentity) error
+	GetByProviderSubject(ctx context.Context, provider, subject string) (*ExternalIdentity, error)
+	TouchLastLogin(ctx context.Context, id uuid.UUID, at time.Time) error
+}
+
+type externalIdentityRepository struct {
+	db *gorm.DB
+}
+
+func NewExternalIdentityRepository(db *gorm.DB) ExternalIdentityRepository {
+	return &externalIdentityRepository{db: db}
+}
+
+func (r *externalIdentityRepository) Create(ctx context.Context, identity *ExternalIdentity) error {
+	err := r.db.WithContext(ctx).Create(identity).Error
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return ErrIdentityAlreadyLinked
+	}
+	return err
+}
+
+func (r *externalIdentityRepository) GetByProviderSubject(ctx context.Context, provider, subject string) (*ExternalIdentity, error) {
+	var identity ExternalIdentity
+	err := r.db.WithContext(ctx).
+		Where("provider = ? AND subject = ?", provider, subject).
+		First(&identity).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrIdentityNotFound
+		}
+		return nil, err
+	}
+	return &identity, nil
+}
+
+func (r *externalIdentityRepository) TouchLastLogin(ctx context.Context, id uuid.UUID, at time.Time) error {
+	return r.db.WithContext(ctx).
+		Model(&ExternalIdentity{}).
+		Where("id = ?", id).
+		Update("last_login_at", at).Error
+}
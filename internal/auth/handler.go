@@ -2,27 +2,201 @@ package auth
 
 import (
 	"errors"
+	infraAuth "go-boilerplate/internal/infra/auth"
+	"go-boilerplate/internal/infra/oidc"
 	"go-boilerplate/pkg/response"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/labstack/echo/v5"
 )
 
 type Handler struct {
 	usecase Usecase
+	// cookies is nil unless cookie mode is enabled.
+	cookies *infraAuth.SessionCookies
 }
 
-func NewHandler(e *echo.Echo, usecase Usecase) {
-	h := &Handler{usecase: usecase}
+func NewHandler(
+	e *echo.Echo,
+	usecase Usecase,
+	bearerMiddleware, introspectionAuth echo.MiddlewareFunc,
+	cookies *infraAuth.SessionCookies,
+) {
+	h := &Handler{usecase: usecase, cookies: cookies}
 
 	authGroup := e.Group("/auth")
+	authGroup.POST("/register", h.Register)
 	authGroup.POST("/login", h.Login)
 	authGroup.POST("/refresh", h.RefreshToken)
 	authGroup.POST("/logout", h.Logout)
+	authGroup.POST("/logout-all", h.LogoutAll, bearerMiddleware, infraAuth.RequireFullAccess)
+	authGroup.GET("/me", h.Me, bearerMiddleware)
+
+	// For other backend services, which authenticate as clients rather than users
+	authGroup.POST("/introspect", h.Introspect, introspectionAuth)
+
+	authGroup.POST("/password/forgot", h.ForgotPassword)
+	authGroup.POST("/password/reset", h.ResetPassword)
+	authGroup.POST("/verify-email", h.VerifyEmail)
+	authGroup.POST("/verify-email/resend", h.ResendVerificationEmail, bearerMiddleware)
+
+	mfa := authGroup.Group("/mfa")
+	mfa.POST("/verify", h.VerifyMFA)
+	mfa.POST("/enroll", h.EnrollMFA, bearerMiddleware, infraAuth.RequireFullAccess)
+	mfa.POST("/enable", h.EnableMFA, bearerMiddleware, infraAuth.RequireFullAccess)
+	mfa.POST("/disable", h.DisableMFA, bearerMiddleware, infraAuth.RequireFullAccess)
+
+	sso := authGroup.Group("/oidc/:provider")
+	sso.GET("/login", h.StartOIDCLogin)
+	sso.GET("/callback", h.OIDCCallback)
+
+	// Keys and scoped tokens cannot mint or manage keys, so a leaked credential cannot
+	// entrench itself or widen its own scopes.
+	apiKeys := authGroup.Group("/api-keys", bearerMiddleware, infraAuth.RequireFullAccess)
+	apiKeys.POST("", h.CreateAPIKey)
+	apiKeys.GET("", h.ListAPIKeys)
+	apiKeys.DELETE("/:id", h.RevokeAPIKey)
+
+	// Ending sessions is account management, so keys and scoped tokens cannot do it either.
+	sessions := authGroup.Group("/sessions", bearerMiddleware, infraAuth.RequireFullAccess)
+	sessions.GET("", h.ListSessions)
+	sessions.DELETE("/:id", h.RevokeSession)
+
+	// Admin endpoints
+	users := authGroup.Group("/users", bearerMiddleware, infraAuth.RequirePermission(infraAuth.PermUsersManage))
+	users.PUT("/:id/role", h.UpdateRole)
+	users.POST("/:id/revoke-sessions", h.RevokeUserSessions)
+
+	authGroup.POST("/impersonate/:userId", h.Impersonate,
+		bearerMiddleware, infraAuth.RequirePermission(infraAuth.PermUsersManage), infraAuth.RequireFullAccess)
+
+	lockouts := authGroup.Group("/lockouts", bearerMiddleware, infraAuth.RequirePermission(infraAuth.PermUsersManage))
+	lockouts.GET("", h.ListLockouts)
+	lockouts.DELETE("/:key", h.ClearLockout)
+}
+
+// RegisterRequest limits Password to the 72 bytes bcrypt hashes, which fewer characters
+// than that fill when they are not ASCII.
+type RegisterRequest struct {
+	Username string `json:"username" validate:"required,min=3,max=50,alphanum"`
+	Email    string `json:"email" validate:"required,email,max=255"`
+	Password string `json:"password" validate:"required,min=8,maxbytes=72"`
+}
+
+type UserResponse struct {
+	ID        uuid.UUID      `json:"id"`
+	Username  string         `json:"username"`
+	Email     string         `json:"email"`
+	Role      infraAuth.Role `json:"role"`
+	CreatedAt time.Time      `json:"created_at"`
+}
+
+func (h *Handler) Register(c *echo.Context) error {
+	var req RegisterRequest
+	if err := c.Bind(&req); err != nil {
+		return response.BadRequest(c, "invalid request body")
+	}
+
+	if err := c.Validate(&req); err != nil {
+		return response.BadRequest(c, err.Error())
+	}
+
+	user, err := h.usecase.Register(c.Request().Context(), req.Username, req.Email, req.Password)
+	if err != nil {
+		if errors.Is(err, ErrUserAlreadyExists) {
+			return response.Conflict(c, err.Error())
+		}
+		c.Logger().Error("failed to register user", "error", err)
+		return response.InternalServerError(c, "failed to register user")
+	}
+
+	return response.Created(c, "registration successful", UserResponse{
+		ID:        user.ID,
+		Username:  user.Username,
+		Email:     user.Email,
+		Role:      user.Role,
+		CreatedAt: user.CreatedAt,
+	})
+}
+
+type ProfileResponse struct {
+	ID             uuid.UUID              `json:"id"`
+	Username       string                 `json:"username"`
+	Email          string                 `json:"email"`
+	EmailVerified  bool                   `json:"email_verified"`
+	Role           infraAuth.Role         `json:"role"`
+	Permissions    []infraAuth.Permission `json:"permissions"`
+	Scopes         []infraAuth.Permission `json:"scopes,omitempty"`
+	MFAEnabled     bool                   `json:"mfa_enabled"`
+	AuthMethod     string                 `json:"auth_method"`
+	ImpersonatorID string                 `json:"impersonator_id,omitempty"`
+	ReadOnly       bool                   `json:"read_only,omitempty"`
+	CreatedAt      time.Time              `json:"created_at"`
 }
 
+// Me returns the authenticated user's profile and what the current credential may do.
+func (h *Handler) Me(c *echo.Context) error {
+	userID, err := infraAuth.UserIDFromContext(c)
+	if err != nil {
+		return response.InternalServerError(c, "invalid user context")
+	}
+
+	user, err := h.usecase.GetUser(c.Request().Context(), userID)
+	if err != nil {
+		if errors.Is(err, ErrUserNotFound) {
+			return response.NotFound(c, err.Error())
+		}
+		return response.InternalServerError(c, "failed to get user")
+	}
+
+	scopes, _ := infraAuth.ScopesFromContext(c)
+	impersonatorID, _ := infraAuth.ImpersonatorIDFromContext(c)
+	readOnly, _ := c.Get("read_only").(bool)
+	return response.Success(c, "success get profile", ProfileResponse{
+		ID:             user.ID,
+		Username:       user.Username,
+		Email:          user.Email,
+		EmailVerified:  user.EmailVerifiedAt != nil,
+		Role:           user.Role,
+		Permissions:    user.Role.Permissions(),
+		Scopes:         scopes,
+		MFAEnabled:     user.MFAEnabled(),
+		AuthMethod:     infraAuth.AuthMethodFromContext(c),
+		ImpersonatorID: impersonatorID,
+		ReadOnly:       readOnly,
+		CreatedAt:      user.CreatedAt,
+	})
+}
+
+// Introspect answers RFC 7662 token introspection requests. The token is read from the
+// form body and the result is the bare RFC document rather than the API's envelope.
+func (h *Handler) Introspect(c *echo.Context) error {
+	token := c.FormValue("token")
+	if token == "" {
+		return response.BadRequest(c, "token is required")
+	}
+
+	result, err := h.usecase.Introspect(c.Request().Context(), token)
+	if err != nil {
+		c.Logger().Error("failed to introspect token", "error", err, "client_id", infraAuth.ClientIDFromContext(c))
+		return response.InternalServerError(c, "failed to introspect token")
+	}
+
+	c.Response().Header().Set("Cache-Control", "no-store")
+	return c.JSON(http.StatusOK, result)
+}
+
+// LoginRequest optionally restricts the issued tokens to some of the role's permissions.
+// Browser clients set Cookie to receive the tokens as cookies instead.
 type LoginRequest struct {
-	Username string `json:"username" validate:"required"`
-	Password string `json:"password" validate:"required"`
+	Username string   `json:"username" validate:"required,max=50"`
+	Password string   `json:"password" validate:"required"`
+	Scopes   []string `json:"scopes" validate:"dive,permission"`
+	Cookie   bool     `json:"cookie"`
 }
 
 func (h *Handler) Login(c *echo.Context) error {
@@ -34,23 +208,316 @@ func (h *Handler) Login(c *echo.Context) error {
 	if err := c.Validate(&req); err != nil {
 		return response.BadRequest(c, err.Error())
 	}
+	if req.Cookie && h.cookies == nil {
+		return response.BadRequest(c, "cookie mode is not enabled")
+	}
 
-	accessToken, refreshToken, err := h.usecase.Login(c.Request().Context(), req.Username, req.Password)
+	result, err := h.usecase.Login(c.Request().Context(), req.Username, req.Password, toPermissions(req.Scopes), clientInfo(c))
 	if err != nil {
-		if errors.Is(err, ErrInvalidCredentials) {
+		var locked *LockedError
+		switch {
+		case errors.As(err, &locked):
+			return tooManyAttempts(c, locked)
+		case errors.Is(err, ErrInvalidCredentials):
 			return response.Unauthorized(c, err.Error())
+		case errors.Is(err, ErrInvalidScope):
+			return response.Forbidden(c, err.Error())
+		default:
+			return response.InternalServerError(c, err.Error())
+		}
+	}
+
+	if result.MFAToken != "" {
+		return response.Success(c, "mfa required", map[string]interface{}{
+			"mfa_required": true,
+			"mfa_token":    result.MFAToken,
+		})
+	}
+
+	return h.respondWithTokens(c, "login successful", result.AccessToken, result.RefreshToken, req.Cookie)
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" validate:"required,email,max=255"`
+}
+
+func (h *Handler) ForgotPassword(c *echo.Context) error {
+	var req ForgotPasswordRequest
+	if err := c.Bind(&req); err != nil {
+		return response.BadRequest(c, "invalid request body")
+	}
+
+	if err := c.Validate(&req); err != nil {
+		return response.BadRequest(c, err.Error())
+	}
+
+	if err := h.usecase.ForgotPassword(c.Request().Context(), req.Email); err != nil {
+		return response.InternalServerError(c, "failed to send password reset email")
+	}
+
+	return response.Success(c, "if an account uses that email, a reset link has been sent", nil)
+}
+
+// ResetPasswordRequest limits Password to bcrypt's 72 bytes, as RegisterRequest does.
+type ResetPasswordRequest struct {
+	Token    string `json:"token" validate:"required"`
+	Password string `json:"password" validate:"required,min=8,maxbytes=72"`
+}
+
+func (h *Handler) ResetPassword(c *echo.Context) error {
+	var req ResetPasswordRequest
+	if err := c.Bind(&req); err != nil {
+		return response.BadRequest(c, "invalid request body")
+	}
+
+	if err := c.Validate(&req); err != nil {
+		return response.BadRequest(c, err.Error())
+	}
+
+	if err := h.usecase.ResetPassword(c.Request().Context(), req.Token, req.Password); err != nil {
+		if errors.Is(err, ErrInvalidToken) {
+			return response.BadRequest(c, "invalid or expired reset token")
+		}
+		return response.InternalServerError(c, "failed to reset password")
+	}
+
+	return response.Success(c, "password reset; please log in again", nil)
+}
+
+type VerifyEmailRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+func (h *Handler) VerifyEmail(c *echo.Context) error {
+	var req VerifyEmailRequest
+	if err := c.Bind(&req); err != nil {
+		return response.BadRequest(c, "invalid request body")
+	}
+
+	if err := c.Validate(&req); err != nil {
+		return response.BadRequest(c, err.Error())
+	}
+
+	if err := h.usecase.VerifyEmail(c.Request().Context(), req.Token); err != nil {
+		if errors.Is(err, ErrInvalidToken) {
+			return response.BadRequest(c, "invalid or expired verification token")
+		}
+		return response.InternalServerError(c, "failed to verify email")
+	}
+
+	return response.Success(c, "email verified", nil)
+}
+
+func (h *Handler) ResendVerificationEmail(c *echo.Context) error {
+	userID, err := infraAuth.UserIDFromContext(c)
+	if err != nil {
+		return response.InternalServerError(c, "invalid user context")
+	}
+
+	if err := h.usecase.SendVerificationEmail(c.Request().Context(), userID); err != nil {
+		if errors.Is(err, ErrEmailAlreadyVerified) {
+			return response.Conflict(c, err.Error())
+		}
+		return response.InternalServerError(c, "failed to send verification email")
+	}
+
+	return response.Success(c, "verification email sent", nil)
+}
+
+type MFAVerifyRequest struct {
+	MFAToken string `json:"mfa_token" validate:"required"`
+	Code     string `json:"code" validate:"required,max=32"`
+	Cookie   bool   `json:"cookie"`
+}
+
+// StartOIDCLogin redirects the browser to the identity provider's login page. Browser
+// clients add ?cookie=true to have the callback set the tokens as cookies.
+func (h *Handler) StartOIDCLogin(c *echo.Context) error {
+	cookie := c.QueryParam("cookie") == "true"
+	if cookie && h.cookies == nil {
+		return response.BadRequest(c, "cookie mode is not enabled")
+	}
+
+	authURL, err := h.usecase.StartOIDCLogin(c.Request().Context(), c.Param("provider"), cookie)
+	if err != nil {
+		if errors.Is(err, ErrUnknownProvider) {
+			return response.NotFound(c, err.Error())
+		}
+		c.Logger().Error("failed to start OIDC login", "error", err)
+		return response.InternalServerError(c, "identity provider is unavailable")
+	}
+
+	return c.Redirect(http.StatusFound, authURL)
+}
+
+// OIDCCallback is where the identity provider sends the browser back to after login.
+func (h *Handler) OIDCCallback(c *echo.Context) error {
+	if providerErr := c.QueryParam("error"); providerErr != "" {
+		return response.Unauthorized(c, "identity provider returned an error: "+providerErr)
+	}
+
+	state, code := c.QueryParam("state"), c.QueryParam("code")
+	if state == "" || code == "" {
+		return response.BadRequest(c, "state and code are required")
+	}
+
+	result, err := h.usecase.CompleteOIDCLogin(c.Request().Context(), c.Param("provider"), state, code, clientInfo(c))
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrUnknownProvider):
+			return response.NotFound(c, err.Error())
+		case errors.Is(err, ErrInvalidOIDCState):
+			return response.BadRequest(c, err.Error())
+		case errors.Is(err, oidc.ErrExchangeFailed), errors.Is(err, oidc.ErrInvalidIDToken), errors.Is(err, ErrInvalidCredentials):
+			return response.Unauthorized(c, "single sign-on failed")
+		case errors.Is(err, ErrOIDCEmailNotVerified):
+			return response.Forbidden(c, err.Error())
+		case errors.Is(err, ErrOIDCAccountConflict):
+			return response.Conflict(c, err.Error())
+		default:
+			c.Logger().Error("failed to complete OIDC login", "error", err)
+			return response.InternalServerError(c, "failed to complete login")
 		}
-		return response.InternalServerError(c, err.Error())
 	}
 
-	return response.Success(c, "login successful", map[string]string{
-		"access_token":  accessToken,
-		"refresh_token": refreshToken,
+	if result.MFAToken != "" {
+		return response.Success(c, "mfa required", map[string]interface{}{
+			"mfa_required": true,
+			"mfa_token":    result.MFAToken,
+		})
+	}
+
+	// Cookie mode may have been switched off since the login started.
+	return h.respondWithTokens(c, "login successful", result.AccessToken, result.RefreshToken, result.Cookie && h.cookies != nil)
+}
+
+func (h *Handler) VerifyMFA(c *echo.Context) error {
+	var req MFAVerifyRequest
+	if err := c.Bind(&req); err != nil {
+		return response.BadRequest(c, "invalid request body")
+	}
+
+	if err := c.Validate(&req); err != nil {
+		return response.BadRequest(c, err.Error())
+	}
+	if req.Cookie && h.cookies == nil {
+		return response.BadRequest(c, "cookie mode is not enabled")
+	}
+
+	result, err := h.usecase.VerifyMFA(c.Request().Context(), req.MFAToken, req.Code, clientInfo(c))
+	if err != nil {
+		var locked *LockedError
+		switch {
+		case errors.As(err, &locked):
+			return tooManyAttempts(c, locked)
+		case errors.Is(err, ErrInvalidToken) || errors.Is(err, ErrInvalidMFACode):
+			return response.Unauthorized(c, err.Error())
+		case errors.Is(err, ErrInvalidScope):
+			return response.Forbidden(c, err.Error())
+		default:
+			return response.InternalServerError(c, "failed to verify MFA code")
+		}
+	}
+
+	return h.respondWithTokens(c, "login successful", result.AccessToken, result.RefreshToken, req.Cookie)
+}
+
+type MFAEnrollResponse struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+}
+
+func (h *Handler) EnrollMFA(c *echo.Context) error {
+	userID, err := infraAuth.UserIDFromContext(c)
+	if err != nil {
+		return response.InternalServerError(c, "invalid user context")
+	}
+
+	enrollment, err := h.usecase.EnrollMFA(c.Request().Context(), userID)
+	if err != nil {
+		if errors.Is(err, ErrMFAAlreadyEnabled) {
+			return response.Conflict(c, err.Error())
+		}
+		return response.InternalServerError(c, "failed to enroll MFA")
+	}
+
+	return response.Success(c, "scan the provisioning URI and confirm with a code", MFAEnrollResponse{
+		Secret:          enrollment.Secret,
+		ProvisioningURI: enrollment.ProvisioningURI,
 	})
 }
 
+type MFACodeRequest struct {
+	Code string `json:"code" validate:"required,max=32"`
+}
+
+func (h *Handler) EnableMFA(c *echo.Context) error {
+	userID, err := infraAuth.UserIDFromContext(c)
+	if err != nil {
+		return response.InternalServerError(c, "invalid user context")
+	}
+
+	var req MFACodeRequest
+	if err := c.Bind(&req); err != nil {
+		return response.BadRequest(c, "invalid request body")
+	}
+
+	if err := c.Validate(&req); err != nil {
+		return response.BadRequest(c, err.Error())
+	}
+
+	recoveryCodes, err := h.usecase.EnableMFA(c.Request().Context(), userID, req.Code)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrMFAAlreadyEnabled):
+			return response.Conflict(c, err.Error())
+		case errors.Is(err, ErrMFANotEnrolled) || errors.Is(err, ErrInvalidMFACode):
+			return response.BadRequest(c, err.Error())
+		default:
+			return response.InternalServerError(c, "failed to enable MFA")
+		}
+	}
+
+	return response.Success(c, "MFA enabled; store the recovery codes somewhere safe", map[string][]string{
+		"recovery_codes": recoveryCodes,
+	})
+}
+
+func (h *Handler) DisableMFA(c *echo.Context) error {
+	userID, err := infraAuth.UserIDFromContext(c)
+	if err != nil {
+		return response.InternalServerError(c, "invalid user context")
+	}
+
+	var req MFACodeRequest
+	if err := c.Bind(&req); err != nil {
+		return response.BadRequest(c, "invalid request body")
+	}
+
+	if err := c.Validate(&req); err != nil {
+		return response.BadRequest(c, err.Error())
+	}
+
+	if err := h.usecase.DisableMFA(c.Request().Context(), userID, req.Code); err != nil {
+		switch {
+		case errors.Is(err, ErrMFANotEnabled):
+			return response.BadRequest(c, err.Error())
+		case errors.Is(err, ErrInvalidMFACode):
+			return response.Unauthorized(c, err.Error())
+		default:
+			return response.InternalServerError(c, "failed to disable MFA")
+		}
+	}
+
+	return response.Success(c, "MFA disabled", nil)
+}
+
+// RefreshRequest is used by refresh and logout. Scopes, only read by refresh, narrow the
+// session's scopes further. Cookie-mode clients leave RefreshToken empty and send the
+// cookie instead.
 type RefreshRequest struct {
-	RefreshToken string `json:"refresh_token" validate:"required"`
+	RefreshToken string   `json:"refresh_token"`
+	Scopes       []string `json:"scopes" validate:"dive,permission"`
 }
 
 func (h *Handler) RefreshToken(c *echo.Context) error {
@@ -63,17 +530,26 @@ func (h *Handler) RefreshToken(c *echo.Context) error {
 		return response.BadRequest(c, err.Error())
 	}
 
-	accessToken, err := h.usecase.RefreshToken(c.Request().Context(), req.RefreshToken)
+	presented, cookieMode := h.refreshTokenFrom(c, req.RefreshToken)
+	if presented == "" {
+		return response.BadRequest(c, "refresh_token is required")
+	}
+
+	accessToken, refreshToken, err := h.usecase.RefreshToken(c.Request().Context(), presented, toPermissions(req.Scopes), clientInfo(c))
 	if err != nil {
-		if errors.Is(err, ErrInvalidToken) || errors.Is(err, ErrTokenExpired) {
+		if errors.Is(err, ErrInvalidToken) || errors.Is(err, ErrTokenExpired) || errors.Is(err, ErrTokenReused) {
+			if cookieMode {
+				h.cookies.Clear(c)
+			}
 			return response.Unauthorized(c, err.Error())
 		}
+		if errors.Is(err, ErrInvalidScope) {
+			return response.Forbidden(c, err.Error())
+		}
 		return response.InternalServerError(c, err.Error())
 	}
 
-	return response.Success(c, "token refreshed", map[string]string{
-		"access_token": accessToken,
-	})
+	return h.respondWithTokens(c, "token refreshed", accessToken, refreshToken, cookieMode)
 }
 
 func (h *Handler) Logout(c *echo.Context) error {
@@ -86,9 +562,399 @@ func (h *Handler) Logout(c *echo.Context) error {
 		return response.BadRequest(c, err.Error())
 	}
 
-	if err := h.usecase.Logout(c.Request().Context(), req.RefreshToken); err != nil {
+	refreshToken, cookieMode := h.refreshTokenFrom(c, req.RefreshToken)
+	if refreshToken == "" {
+		return response.BadRequest(c, "refresh_token is required")
+	}
+
+	// The access token is optional: clients that still hold one get it revoked as well.
+	accessToken, ok := infraAuth.ExtractBearerToken(c.Request())
+	if !ok && cookieMode {
+		accessToken, _ = infraAuth.AccessTokenFromCookie(c.Request())
+	}
+
+	if err := h.usecase.Logout(c.Request().Context(), refreshToken, accessToken); err != nil {
 		return response.InternalServerError(c, "failed to logout")
 	}
 
+	if cookieMode {
+		h.cookies.Clear(c)
+	}
 	return response.Success(c, "logout successful", nil)
 }
+
+func (h *Handler) LogoutAll(c *echo.Context) error {
+	userID, err := infraAuth.UserIDFromContext(c)
+	if err != nil {
+		return response.InternalServerError(c, "invalid user context")
+	}
+
+	if err := h.usecase.RevokeAllSessions(c.Request().Context(), userID); err != nil {
+		return response.InternalServerError(c, "failed to logout")
+	}
+
+	if _, ok := infraAuth.AccessTokenFromCookie(c.Request()); ok && h.cookies != nil {
+		h.cookies.Clear(c)
+	}
+	return response.Success(c, "logged out of all sessions", nil)
+}
+
+func (h *Handler) RevokeUserSessions(c *echo.Context) error {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return response.BadRequest(c, "invalid user id")
+	}
+
+	if err := h.usecase.RevokeAllSessions(c.Request().Context(), userID); err != nil {
+		return response.InternalServerError(c, "failed to revoke sessions")
+	}
+
+	return response.Success(c, "user sessions revoked", nil)
+}
+
+type UpdateRoleRequest struct {
+	Role string `json:"role" validate:"required,oneof=admin user readonly"`
+}
+
+func (h *Handler) UpdateRole(c *echo.Context) error {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return response.BadRequest(c, "invalid user id")
+	}
+
+	var req UpdateRoleRequest
+	if err := c.Bind(&req); err != nil {
+		return response.BadRequest(c, "invalid request body")
+	}
+
+	if err := c.Validate(&req); err != nil {
+		return response.BadRequest(c, err.Error())
+	}
+
+	if err := h.usecase.UpdateRole(c.Request().Context(), userID, infraAuth.Role(req.Role)); err != nil {
+		switch {
+		case errors.Is(err, ErrUserNotFound):
+			return response.NotFound(c, err.Error())
+		case errors.Is(err, ErrInvalidRole):
+			return response.BadRequest(c, err.Error())
+		default:
+			return response.InternalServerError(c, "failed to update role")
+		}
+	}
+
+	return response.Success(c, "role updated", nil)
+}
+
+// ImpersonateRequest is optional; impersonation is read-only unless ReadOnly is false.
+type ImpersonateRequest struct {
+	ReadOnly *bool `json:"read_only"`
+}
+
+type ImpersonateResponse struct {
+	AccessToken    string    `json:"access_token"`
+	ExpiresAt      time.Time `json:"expires_at"`
+	ReadOnly       bool      `json:"read_only"`
+	ImpersonatorID uuid.UUID `json:"impersonator_id"`
+	UserID         uuid.UUID `json:"user_id"`
+}
+
+// Impersonate issues the admin a short-lived access token for another user, for support
+// staff to see what the user sees. No refresh token is issued.
+func (h *Handler) Impersonate(c *echo.Context) error {
+	adminID, err := infraAuth.UserIDFromContext(c)
+	if err != nil {
+		return response.InternalServerError(c, "invalid user context")
+	}
+
+	targetID, err := uuid.Parse(c.Param("userId"))
+	if err != nil {
+		return response.BadRequest(c, "invalid user id")
+	}
+
+	var req ImpersonateRequest
+	if err := c.Bind(&req); err != nil {
+		return response.BadRequest(c, "invalid request body")
+	}
+	readOnly := req.ReadOnly == nil || *req.ReadOnly
+
+	result, err := h.usecase.Impersonate(c.Request().Context(), adminID, targetID, readOnly)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrUserNotFound):
+			return response.NotFound(c, err.Error())
+		case errors.Is(err, ErrCannotImpersonate):
+			return response.Forbidden(c, err.Error())
+		default:
+			return response.InternalServerError(c, "failed to impersonate user")
+		}
+	}
+
+	return response.Success(c, "impersonation started", ImpersonateResponse{
+		AccessToken:    result.AccessToken,
+		ExpiresAt:      result.ExpiresAt,
+		ReadOnly:       result.ReadOnly,
+		ImpersonatorID: adminID,
+		UserID:         targetID,
+	})
+}
+
+type SessionResponse struct {
+	ID         uuid.UUID `json:"id"`
+	UserAgent  string    `json:"user_agent"`
+	IPAddress  string    `json:"ip_address"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+func (h *Handler) ListSessions(c *echo.Context) error {
+	userID, err := infraAuth.UserIDFromContext(c)
+	if err != nil {
+		return response.InternalServerError(c, "invalid user context")
+	}
+
+	sessions, err := h.usecase.ListSessions(c.Request().Context(), userID)
+	if err != nil {
+		return response.InternalServerError(c, "failed to list sessions")
+	}
+
+	responseData := make([]SessionResponse, len(sessions))
+	for i, session := range sessions {
+		responseData[i] = SessionResponse{
+			ID:         session.ID,
+			UserAgent:  session.UserAgent,
+			IPAddress:  session.IPAddress,
+			CreatedAt:  session.CreatedAt,
+			LastUsedAt: session.LastUsedAt,
+			ExpiresAt:  session.ExpiresAt,
+		}
+	}
+
+	return response.Success(c, "success get sessions", responseData)
+}
+
+func (h *Handler) RevokeSession(c *echo.Context) error {
+	userID, err := infraAuth.UserIDFromContext(c)
+	if err != nil {
+		return response.InternalServerError(c, "invalid user context")
+	}
+
+	sessionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return response.BadRequest(c, "invalid session id")
+	}
+
+	if err := h.usecase.RevokeSession(c.Request().Context(), userID, sessionID); err != nil {
+		if errors.Is(err, ErrSessionNotFound) {
+			return response.NotFound(c, err.Error())
+		}
+		return response.InternalServerError(c, "failed to revoke session")
+	}
+
+	return response.Success(c, "session revoked", nil)
+}
+
+type LockoutResponse struct {
+	Key           string     `json:"key"`
+	Failures      int        `json:"failures"`
+	LastFailureAt time.Time  `json:"last_failure_at"`
+	LockedUntil   *time.Time `json:"locked_until"`
+}
+
+func (h *Handler) ListLockouts(c *echo.Context) error {
+	attempts, err := h.usecase.ListLockouts(c.Request().Context())
+	if err != nil {
+		return response.InternalServerError(c, "failed to list lockouts")
+	}
+
+	responseData := make([]LockoutResponse, len(attempts))
+	for i, attempt := range attempts {
+		responseData[i] = LockoutResponse{
+			Key:           attempt.Key,
+			Failures:      attempt.Failures,
+			LastFailureAt: attempt.LastFailureAt,
+			LockedUntil:   attempt.LockedUntil,
+		}
+	}
+
+	return response.Success(c, "success get lockouts", responseData)
+}
+
+func (h *Handler) ClearLockout(c *echo.Context) error {
+	// Keys contain colons (and IPv6 addresses more of them), so clients escape them.
+	key, err := url.PathUnescape(c.Param("key"))
+	if err != nil {
+		return response.BadRequest(c, "invalid lockout key")
+	}
+
+	if err := h.usecase.ClearLockout(c.Request().Context(), key); err != nil {
+		if errors.Is(err, ErrLockoutNotFound) {
+			return response.NotFound(c, err.Error())
+		}
+		return response.InternalServerError(c, "failed to clear lockout")
+	}
+
+	return response.Success(c, "lockout cleared", nil)
+}
+
+// defaultAPIKeyLifetimeDays applies when a key is created without an explicit lifetime.
+const defaultAPIKeyLifetimeDays = 90
+
+type CreateAPIKeyRequest struct {
+	Name          string   `json:"name" validate:"required,max=100"`
+	Scopes        []string `json:"scopes" validate:"dive,permission"`
+	ExpiresInDays int      `json:"expires_in_days" validate:"omitempty,min=1,max=365"`
+}
+
+type APIKeyResponse struct {
+	ID         uuid.UUID              `json:"id"`
+	Name       string                 `json:"name"`
+	Prefix     string                 `json:"prefix"`
+	Scopes     []infraAuth.Permission `json:"scopes"`
+	ExpiresAt  time.Time              `json:"expires_at"`
+	LastUsedAt *time.Time             `json:"last_used_at"`
+	CreatedAt  time.Time              `json:"created_at"`
+}
+
+type CreateAPIKeyResponse struct {
+	APIKeyResponse
+	// Key is only ever returned here.
+	Key string `json:"key"`
+}
+
+func (h *Handler) CreateAPIKey(c *echo.Context) error {
+	userID, err := infraAuth.UserIDFromContext(c)
+	if err != nil {
+		return response.InternalServerError(c, "invalid user context")
+	}
+
+	var req CreateAPIKeyRequest
+	if err := c.Bind(&req); err != nil {
+		return response.BadRequest(c, "invalid request body")
+	}
+
+	if err := c.Validate(&req); err != nil {
+		return response.BadRequest(c, err.Error())
+	}
+
+	days := req.ExpiresInDays
+	if days == 0 {
+		days = defaultAPIKeyLifetimeDays
+	}
+
+	key, rawKey, err := h.usecase.CreateAPIKey(c.Request().Context(), userID, req.Name, toPermissions(req.Scopes), time.Duration(days)*24*time.Hour)
+	if err != nil {
+		if errors.Is(err, ErrInvalidScope) {
+			return response.Forbidden(c, err.Error())
+		}
+		return response.InternalServerError(c, "failed to create API key")
+	}
+
+	return response.Created(c, "API key created; copy it now, it will not be shown again", CreateAPIKeyResponse{
+		APIKeyResponse: toAPIKeyResponse(*key),
+		Key:            rawKey,
+	})
+}
+
+func (h *Handler) ListAPIKeys(c *echo.Context) error {
+	userID, err := infraAuth.UserIDFromContext(c)
+	if err != nil {
+		return response.InternalServerError(c, "invalid user context")
+	}
+
+	keys, err := h.usecase.ListAPIKeys(c.Request().Context(), userID)
+	if err != nil {
+		return response.InternalServerError(c, "failed to list API keys")
+	}
+
+	responseData := make([]APIKeyResponse, len(keys))
+	for i, key := range keys {
+		responseData[i] = toAPIKeyResponse(key)
+	}
+
+	return response.Success(c, "success get API keys", responseData)
+}
+
+func (h *Handler) RevokeAPIKey(c *echo.Context) error {
+	userID, err := infraAuth.UserIDFromContext(c)
+	if err != nil {
+		return response.InternalServerError(c, "invalid user context")
+	}
+
+	keyID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return response.BadRequest(c, "invalid API key id")
+	}
+
+	if err := h.usecase.RevokeAPIKey(c.Request().Context(), userID, keyID); err != nil {
+		if errors.Is(err, ErrAPIKeyNotFound) {
+			return response.NotFound(c, err.Error())
+		}
+		return response.InternalServerError(c, "failed to revoke API key")
+	}
+
+	return response.Success(c, "API key revoked", nil)
+}
+
+func toAPIKeyResponse(key APIKey) APIKeyResponse {
+	return APIKeyResponse{
+		ID:         key.ID,
+		Name:       key.Name,
+		Prefix:     key.Prefix,
+		Scopes:     key.Scopes,
+		ExpiresAt:  key.ExpiresAt,
+		LastUsedAt: key.LastUsedAt,
+		CreatedAt:  key.CreatedAt,
+	}
+}
+
+func toPermissions(scopes []string) []infraAuth.Permission {
+	perms := make([]infraAuth.Permission, len(scopes))
+	for i, scope := range scopes {
+		perms[i] = infraAuth.Permission(scope)
+	}
+	return perms
+}
+
+// respondWithTokens hands a new token pair to the client: in the body or, in cookie mode,
+// as cookies with only the CSRF token in the body.
+func (h *Handler) respondWithTokens(c *echo.Context, message, accessToken, refreshToken string, cookieMode bool) error {
+	if !cookieMode {
+		return response.Success(c, message, map[string]string{
+			"access_token":  accessToken,
+			"refresh_token": refreshToken,
+		})
+	}
+
+	csrfToken, err := h.cookies.Set(c, accessToken, refreshToken)
+	if err != nil {
+		c.Logger().Error("failed to set session cookies", "error", err)
+		return response.InternalServerError(c, "failed to start session")
+	}
+	return response.Success(c, message, map[string]string{
+		"csrf_token": csrfToken,
+	})
+}
+
+// refreshTokenFrom returns the refresh token from the request body or, failing that,
+// the cookie. cookieMode reports the latter, so the response goes out as cookies too.
+func (h *Handler) refreshTokenFrom(c *echo.Context, fromBody string) (token string, cookieMode bool) {
+	if fromBody != "" || h.cookies == nil {
+		return fromBody, false
+	}
+	token, ok := infraAuth.RefreshTokenFromCookie(c.Request())
+	return token, ok
+}
+
+// tooManyAttempts rejects a locked-out login and tells the client when to retry.
+func tooManyAttempts(c *echo.Context, locked *LockedError) error {
+	c.Response().Header().Set("Retry-After", strconv.Itoa(locked.RetryAfterSeconds()))
+	return response.TooManyRequests(c, locked.Error())
+}
+
+func clientInfo(c *echo.Context) ClientInfo {
+	return ClientInfo{
+		UserAgent: c.Request().UserAgent(),
+		IPAddress: c.RealIP(),
+	}
+}
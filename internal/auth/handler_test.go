@@ -0,0 +1,233 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go-boilerplate/internal/config"
+	infraAuth "go-boilerplate/internal/infra/auth"
+	"go-boilerplate/internal/router"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func passThrough(next echo.HandlerFunc) echo.HandlerFunc {
+	return next
+}
+
+func TestLoginHandler_LockoutKeyIgnoresSpoofedForwardedFor(t *testing.T) {
+	tests := []struct {
+		name           string
+		trustedProxies []string
+		remoteAddr     string
+		forwardedFor   string
+		wantKey        string
+	}{
+		{
+			name:         "direct client cannot pick its address",
+			remoteAddr:   "203.0.113.7:51234",
+			forwardedFor: "198.51.100.99",
+			wantKey:      "ip:203.0.113.7",
+		},
+		{
+			name:           "untrusted peer cannot pick its address",
+			trustedProxies: []string{"10.0.0.0/8"},
+			remoteAddr:     "203.0.113.7:51234",
+			forwardedFor:   "198.51.100.99",
+			wantKey:        "ip:203.0.113.7",
+		},
+		{
+			name:           "trusted proxy forwards the client's address",
+			trustedProxies: []string{"10.0.0.0/8"},
+			remoteAddr:     "10.0.0.2:443",
+			forwardedFor:   "198.51.100.99, 10.0.0.3",
+			wantKey:        "ip:198.51.100.99",
+		},
+		{
+			name:           "trusted proxy does not vouch for addresses the client added",
+			trustedProxies: []string{"10.0.0.0/8"},
+			remoteAddr:     "10.0.0.2:443",
+			forwardedFor:   "192.0.2.1, 203.0.113.7",
+			wantKey:        "ip:203.0.113.7",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			e, err := router.NewRouter(&config.Config{MaxRequestPerSecond: 100, TrustedProxies: tt.trustedProxies})
+			require.NoError(t, err)
+			lockouts := new(MockLoginAttemptRepository)
+			NewHandler(e, newTestUsecaseWithLockouts(new(MockRepository), new(MockUserRepository), lockouts), passThrough, passThrough, nil)
+
+			var keys []string
+			lockouts.On("Find", mock.Anything, mock.Anything).
+				Return([]LoginAttempt{}, errors.New("stop after the lookup")).
+				Run(func(args mock.Arguments) {
+					keys = args.Get(1).([]string)
+				})
+
+			req := httptest.NewRequest(http.MethodPost, "/auth/login", strings.NewReader(`{"username": "alice", "password": "password123"}`))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			req.Header.Set(echo.HeaderXForwardedFor, tt.forwardedFor)
+			req.RemoteAddr = tt.remoteAddr
+			rec := httptest.NewRecorder()
+
+			// Act
+			e.ServeHTTP(rec, req)
+
+			// Assert
+			assert.Equal(t, []string{"account:alice", tt.wantKey}, keys)
+		})
+	}
+}
+
+func TestOIDCCallbackHandler_CookieMode(t *testing.T) {
+	tests := []struct {
+		name        string
+		cookie      bool
+		wantCookies bool
+	}{
+		{name: "login started for a header client", cookie: false},
+		{name: "login started in cookie mode", cookie: true, wantCookies: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			d := newTestDeps()
+			cookies, err := infraAuth.NewSessionCookies("", true, "strict", time.Hour, 24*time.Hour)
+			require.NoError(t, err)
+			e, err := router.NewRouter(&config.Config{MaxRequestPerSecond: 100})
+			require.NoError(t, err)
+			NewHandler(e, d.usecase(), passThrough, passThrough, cookies)
+
+			user := &User{ID: uuid.New(), Username: "alice", Role: infraAuth.RoleUser}
+			identity := &ExternalIdentity{ID: uuid.New(), UserID: user.ID, Provider: "corp", Subject: "sub-1"}
+			d.oidcStates.On("Consume", mock.Anything, testTokenHasher.Hash("state-1")).Return(&OIDCLoginState{
+				Provider:     "corp",
+				Nonce:        "nonce-1",
+				CodeVerifier: "verifier-1",
+				Cookie:       tt.cookie,
+			}, nil)
+			d.oidcProvider.On("Exchange", mock.Anything, "code-1", "verifier-1", "nonce-1").
+				Return(oidcClaims("sub-1", "alice@example.com", true), nil)
+			d.identities.On("GetByProviderSubject", mock.Anything, "corp", "sub-1").Return(identity, nil)
+			d.identities.On("TouchLastLogin", mock.Anything, identity.ID, mock.Anything).Return(nil)
+			d.userRepo.On("GetByID", mock.Anything, user.ID).Return(user, nil)
+			d.repo.On("Create", mock.Anything, mock.Anything).Return(nil)
+
+			req := httptest.NewRequest(http.MethodGet, "/auth/oidc/corp/callback?state=state-1&code=code-1", nil)
+			rec := httptest.NewRecorder()
+
+			// Act
+			e.ServeHTTP(rec, req)
+
+			// Assert
+			require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+			set := map[string]bool{}
+			for _, cookie := range rec.Result().Cookies() {
+				set[cookie.Name] = true
+			}
+			if tt.wantCookies {
+				assert.True(t, set[infraAuth.AccessTokenCookie])
+				assert.True(t, set[infraAuth.RefreshTokenCookie])
+				assert.Contains(t, rec.Body.String(), `"csrf_token"`)
+				assert.NotContains(t, rec.Body.String(), `"access_token"`)
+				return
+			}
+			assert.Empty(t, set)
+			assert.Contains(t, rec.Body.String(), `"access_token"`)
+			assert.Contains(t, rec.Body.String(), `"refresh_token"`)
+		})
+	}
+}
+
+func TestStartOIDCLoginHandler_CookieModeDisabled(t *testing.T) {
+	// Arrange
+	d := newTestDeps()
+	e, err := router.NewRouter(&config.Config{MaxRequestPerSecond: 100})
+	require.NoError(t, err)
+	NewHandler(e, d.usecase(), passThrough, passThrough, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/auth/oidc/corp/login?cookie=true", nil)
+	rec := httptest.NewRecorder()
+
+	// Act
+	e.ServeHTTP(rec, req)
+
+	// Assert
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	d.oidcStates.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+}
+
+func TestRegisterHandler_PasswordOverBcryptLimit(t *testing.T) {
+	// Arrange
+	d := newTestDeps()
+	e, err := router.NewRouter(&config.Config{MaxRequestPerSecond: 100})
+	require.NoError(t, err)
+	NewHandler(e, d.usecase(), passThrough, passThrough, nil)
+
+	// 40 characters, but 80 bytes: more than bcrypt hashes.
+	password := strings.Repeat("é", 40)
+	body := `{"username": "alice", "email": "alice@example.com", "password": "` + password + `"}`
+	req := httptest.NewRequest(http.MethodPost, "/auth/register", strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+
+	// Act
+	e.ServeHTTP(rec, req)
+
+	// Assert
+	assert.Equal(t, http.StatusBadRequest, rec.Code, rec.Body.String())
+	d.userRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+}
+
+func TestResetPasswordHandler_PasswordOverBcryptLimit(t *testing.T) {
+	// Arrange
+	d := newTestDeps()
+	e, err := router.NewRouter(&config.Config{MaxRequestPerSecond: 100})
+	require.NoError(t, err)
+	NewHandler(e, d.usecase(), passThrough, passThrough, nil)
+
+	body := `{"token": "reset-token", "password": "` + strings.Repeat("密", 30) + `"}`
+	req := httptest.NewRequest(http.MethodPost, "/auth/password/reset", strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+
+	// Act
+	e.ServeHTTP(rec, req)
+
+	// Assert
+	assert.Equal(t, http.StatusBadRequest, rec.Code, rec.Body.String())
+	d.actionTokens.AssertNotCalled(t, "Consume", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestLoginHandler_UsernameTooLong(t *testing.T) {
+	// Arrange
+	e, err := router.NewRouter(&config.Config{MaxRequestPerSecond: 100})
+	require.NoError(t, err)
+	lockouts := new(MockLoginAttemptRepository)
+	NewHandler(e, newTestUsecaseWithLockouts(new(MockRepository), new(MockUserRepository), lockouts), passThrough, passThrough, nil)
+
+	// Longer than any username, and than a lockout key can hold.
+	body := `{"username": "` + strings.Repeat("a", 130) + `", "password": "password123"}`
+	req := httptest.NewRequest(http.MethodPost, "/auth/login", strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+
+	// Act
+	e.ServeHTTP(rec, req)
+
+	// Assert
+	assert.Equal(t, http.StatusBadRequest, rec.Code, rec.Body.String())
+	lockouts.AssertNotCalled(t, "Find", mock.Anything, mock.Anything)
+}
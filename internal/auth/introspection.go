@@ -0,0 +1,105 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	infraAuth "go-boilerplate/internal/infra/auth"
+
+	"github.com/google/uuid"
+)
+
+// Introspect describes a bearer credential to another service (RFC 7662). Malformed,
+// expired and revoked credentials, and those of deleted users, are all reported as
+// inactive without saying why.
+func (u *usecase) Introspect(ctx context.Context, token string) (*infraAuth.IntrospectionResponse, error) {
+	inactive := &infraAuth.IntrospectionResponse{Active: false}
+
+	if infraAuth.IsAPIKey(token) {
+		principal, err := u.AuthenticateAPIKey(ctx, token)
+		if err != nil {
+			if errors.Is(err, infraAuth.ErrInvalidAPIKey) {
+				return inactive, nil
+			}
+			return nil, err
+		}
+
+		user, err := u.activeUser(ctx, principal.UserID)
+		if err != nil || user == nil {
+			return inactive, err
+		}
+
+		return &infraAuth.IntrospectionResponse{
+			Active:    true,
+			Scope:     infraAuth.FormatScope(principal.Scopes),
+			ClientID:  principal.KeyID,
+			Username:  user.Username,
+			TokenType: "Bearer",
+			ExpiresAt: principal.ExpiresAt.Unix(),
+			Subject:   principal.UserID,
+			Role:      principal.Role,
+		}, nil
+	}
+
+	claims, err := u.jwtSvc.ValidateToken(token)
+	if err != nil {
+		return inactive, nil
+	}
+
+	revoked, err := u.revocations.IsRevoked(ctx, claims)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check token revocation: %w", err)
+	}
+	if revoked {
+		return inactive, nil
+	}
+
+	user, err := u.activeUser(ctx, claims.UserID)
+	if err != nil || user == nil {
+		return inactive, err
+	}
+
+	scopes, scoped := claims.Scopes()
+	if !scoped {
+		scopes = claims.Role.Permissions()
+	}
+
+	return &infraAuth.IntrospectionResponse{
+		Active:    true,
+		Scope:     infraAuth.FormatScope(scopes),
+		Username:  user.Username,
+		TokenType: "Bearer",
+		ExpiresAt: claims.ExpiresAt.Unix(),
+		IssuedAt:  claims.IssuedAt.Unix(),
+		Subject:   claims.UserID,
+		Audience:  claims.Audience,
+		Issuer:    claims.Issuer,
+		TokenID:   claims.ID,
+		Role:      claims.Role,
+		Act:       claims.Act,
+		ReadOnly:  claims.ReadOnly,
+	}, nil
+}
+
+// GetUser returns the user's profile.
+func (u *usecase) GetUser(ctx context.Context, userID uuid.UUID) (*User, error) {
+	return u.userRepo.GetByID(ctx, userID)
+}
+
+// activeUser returns the user a credential was issued to, or nil if they no longer exist.
+func (u *usecase) activeUser(ctx context.Context, userID string) (*User, error) {
+	id, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, nil
+	}
+
+	user, err := u.userRepo.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, ErrUserNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	return user, nil
+}
@@ -0,0 +1,81 @@
+package auth
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"go-boilerplate/internal/infra/scheduler"
+)
+
+// NewRefreshTokenPurgeJob hard-deletes refresh tokens that have been expired, revoked or
+// logged out for longer than retention. Retention should be at least the refresh token
+// lifetime so revoked tokens stay around long enough for reuse detection.
+func NewRefreshTokenPurgeJob(repo Repository, interval, jitter, retention time.Duration, batchSize int) scheduler.Job {
+	return scheduler.Job{
+		Name:     "purge-refresh-tokens",
+		Interval: interval,
+		Jitter:   jitter,
+		Run: func(ctx context.Context) error {
+			purged, err := repo.PurgeExpired(ctx, time.Now().Add(-retention), batchSize)
+			if err != nil {
+				return err
+			}
+			slog.InfoContext(ctx, "purged refresh tokens", "count", purged)
+			return nil
+		},
+	}
+}
+
+// NewLoginAttemptPurgeJob deletes failed-login counters that have gone quiet for longer
+// than the failure window and are no longer locked.
+func NewLoginAttemptPurgeJob(lockouts LoginAttemptRepository, interval, jitter, window time.Duration) scheduler.Job {
+	return scheduler.Job{
+		Name:     "purge-login-attempts",
+		Interval: interval,
+		Jitter:   jitter,
+		Run: func(ctx context.Context) error {
+			purged, err := lockouts.PurgeStale(ctx, time.Now().Add(-window))
+			if err != nil {
+				return err
+			}
+			slog.InfoContext(ctx, "purged login attempts", "count", purged)
+			return nil
+		},
+	}
+}
+
+// NewActionTokenPurgeJob deletes password reset and email verification tokens that
+// expired more than retention ago.
+func NewActionTokenPurgeJob(actionTokens ActionTokenRepository, interval, jitter, retention time.Duration) scheduler.Job {
+	return scheduler.Job{
+		Name:     "purge-action-tokens",
+		Interval: interval,
+		Jitter:   jitter,
+		Run: func(ctx context.Context) error {
+			purged, err := actionTokens.PurgeExpired(ctx, time.Now().Add(-retention))
+			if err != nil {
+				return err
+			}
+			slog.InfoContext(ctx, "purged action tokens", "count", purged)
+			return nil
+		},
+	}
+}
+
+// NewOIDCStatePurgeJob deletes single sign-on attempts that were never completed.
+func NewOIDCStatePurgeJob(states OIDCStateRepository, interval, jitter time.Duration) scheduler.Job {
+	return scheduler.Job{
+		Name:     "purge-oidc-login-states",
+		Interval: interval,
+		Jitter:   jitter,
+		Run: func(ctx context.Context) error {
+			purged, err := states.PurgeExpired(ctx, time.Now())
+			if err != nil {
+				return err
+			}
+			slog.InfoContext(ctx, "purged OIDC login states", "count", purged)
+			return nil
+		},
+	}
+}
@@ -0,0 +1,138 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"time"
+)
+
+var (
+	ErrTooManyAttempts = errors.New("too many failed login attempts")
+	ErrLockoutNotFound = errors.New("lockout not found")
+)
+
+// LockoutPolicy controls how failed logins are throttled. A threshold of zero disables
+// throttling for that key type.
+type LockoutPolicy struct {
+	// MaxAccountFailures is how many failures an account may accumulate before it is locked.
+	MaxAccountFailures int
+	// MaxIPFailures is how many failures a client address may accumulate, across all
+	// accounts, before it is locked.
+	MaxIPFailures int
+	// FailureWindow is how long a key must go without failures for its count to reset.
+	FailureWindow time.Duration
+	// BaseLockout is the lockout applied when a threshold is first reached; every further
+	// failure doubles it, up to MaxLockout.
+	BaseLockout time.Duration
+	MaxLockout  time.Duration
+}
+
+// lockoutFor returns how long a key with the given number of failures is locked for.
+func (p LockoutPolicy) lockoutFor(failures, threshold int) time.Duration {
+	if threshold <= 0 || failures < threshold {
+		return 0
+	}
+
+	lockout := p.BaseLockout
+	for i := threshold; i < failures && lockout < p.MaxLockout; i++ {
+		lockout *= 2
+	}
+	return min(lockout, p.MaxLockout)
+}
+
+// LockedError is returned by Login while the account or client address is locked out.
+type LockedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LockedError) Error() string {
+	return ErrTooManyAttempts.Error()
+}
+
+func (e *LockedError) Is(target error) bool {
+	return target == ErrTooManyAttempts
+}
+
+// RetryAfterSeconds rounds the remaining lockout up to whole seconds for the Retry-After header.
+func (e *LockedError) RetryAfterSeconds() int {
+	return max(1, int(math.Ceil(e.RetryAfter.Seconds())))
+}
+
+// loginKey identifies something failed logins are counted against.
+type loginKey struct {
+	key       string
+	threshold int
+}
+
+func (u *usecase) loginKeys(username string, client ClientInfo) []loginKey {
+	keys := []loginKey{{key: "account:" + username, threshold: u.lockoutPolicy.MaxAccountFailures}}
+	if client.IPAddress != "" {
+		keys = append(keys, loginKey{key: "ip:" + client.IPAddress, threshold: u.lockoutPolicy.MaxIPFailures})
+	}
+	return keys
+}
+
+// checkLockout returns a LockedError if any of the keys is currently locked.
+func (u *usecase) checkLockout(ctx context.Context, keys []loginKey, now time.Time) error {
+	names := make([]string, len(keys))
+	for i, k := range keys {
+		names[i] = k.key
+	}
+
+	attempts, err := u.lockouts.Find(ctx, names)
+	if err != nil {
+		return fmt.Errorf("failed to load login attempts: %w", err)
+	}
+
+	var retryAfter time.Duration
+	for _, attempt := range attempts {
+		if attempt.LockedUntil != nil && attempt.LockedUntil.After(now) {
+			retryAfter = max(retryAfter, attempt.LockedUntil.Sub(now))
+		}
+	}
+	if retryAfter > 0 {
+		return &LockedError{RetryAfter: retryAfter}
+	}
+	return nil
+}
+
+// recordFailedLogin counts a failed login against every key and locks those that reached
+// their threshold. It returns the error Login should report.
+func (u *usecase) recordFailedLogin(ctx context.Context, keys []loginKey, now time.Time) error {
+	windowStart := now.Add(-u.lockoutPolicy.FailureWindow)
+
+	var retryAfter time.Duration
+	for _, k := range keys {
+		if k.threshold <= 0 {
+			continue
+		}
+
+		attempt, err := u.lockouts.RecordFailure(ctx, k.key, now, windowStart)
+		if err != nil {
+			return fmt.Errorf("failed to record login attempt: %w", err)
+		}
+
+		lockout := u.lockoutPolicy.lockoutFor(attempt.Failures, k.threshold)
+		if lockout == 0 {
+			continue
+		}
+
+		if err := u.lockouts.Lock(ctx, k.key, now.Add(lockout)); err != nil {
+			return fmt.Errorf("failed to lock %s: %w", k.key, err)
+		}
+		slog.WarnContext(ctx, "security event: login locked out",
+			"key", k.key,
+			"failures", attempt.Failures,
+			"lockout", lockout,
+		)
+		retryAfter = max(retryAfter, lockout)
+	}
+
+	if retryAfter > 0 {
+		return &LockedError{RetryAfter: retryAfter}
+	}
+	return ErrInvalidCredentials
+}
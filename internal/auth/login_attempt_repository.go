@@ -0,0 +1,85 @@
+package auth
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type LoginAttemptRepository interface {
+	Find(ctx context.Context, keys []string) ([]LoginAttempt, error)
+	RecordFailure(ctx context.Context, key string, now, windowStart time.Time) (*LoginAttempt, error)
+	Lock(ctx context.Context, key string, until time.Time) error
+	Delete(ctx context.Context, key string) (bool, error)
+	ListActive(ctx context.Context, now, windowStart time.Time) ([]LoginAttempt, error)
+	PurgeStale(ctx context.Context, windowStart time.Time) (int64, error)
+}
+
+type loginAttemptRepository struct {
+	db *gorm.DB
+}
+
+func NewLoginAttemptRepository(db *gorm.DB) LoginAttemptRepository {
+	return &loginAttemptRepository{db: db}
+}
+
+func (r *loginAttemptRepository) Find(ctx context.Context, keys []string) ([]LoginAttempt, error) {
+	var attempts []LoginAttempt
+	err := r.db.WithContext(ctx).Where("key IN ?", keys).Find(&attempts).Error
+	return attempts, err
+}
+
+// RecordFailure atomically counts one more failure against key. The count starts over
+// when the previous failure and any lockout both ended before windowStart.
+func (r *loginAttemptRepository) RecordFailure(ctx context.Context, key string, now, windowStart time.Time) (*LoginAttempt, error) {
+	var attempt LoginAttempt
+	err := r.db.WithContext(ctx).Raw(`
+		INSERT INTO login_attempts (key, failures, last_failure_at, updated_at)
+		VALUES (?, 1, ?, ?)
+		ON CONFLICT (key) DO UPDATE SET
+			failures = CASE
+				WHEN login_attempts.last_failure_at < ?
+					AND (login_attempts.locked_until IS NULL OR login_attempts.locked_until < ?)
+				THEN 1
+				ELSE login_attempts.failures + 1
+			END,
+			last_failure_at = EXCLUDED.last_failure_at,
+			updated_at = EXCLUDED.updated_at
+		RETURNING *`, key, now, now, windowStart, windowStart).
+		Scan(&attempt).Error
+	if err != nil {
+		return nil, err
+	}
+	return &attempt, nil
+}
+
+func (r *loginAttemptRepository) Lock(ctx context.Context, key string, until time.Time) error {
+	return r.db.WithContext(ctx).
+		Model(&LoginAttempt{}).
+		Where("key = ?", key).
+		Update("locked_until", until).Error
+}
+
+func (r *loginAttemptRepository) Delete(ctx context.Context, key string) (bool, error) {
+	result := r.db.WithContext(ctx).Where("key = ?", key).Delete(&LoginAttempt{})
+	return result.RowsAffected > 0, result.Error
+}
+
+// ListActive returns keys that are locked or have failures inside the current window.
+func (r *loginAttemptRepository) ListActive(ctx context.Context, now, windowStart time.Time) ([]LoginAttempt, error) {
+	var attempts []LoginAttempt
+	err := r.db.WithContext(ctx).
+		Where("locked_until > ? OR last_failure_at >= ?", now, windowStart).
+		Order("last_failure_at DESC").
+		Find(&attempts).Error
+	return attempts, err
+}
+
+// PurgeStale deletes keys whose count would be reset by their next failure anyway.
+func (r *loginAttemptRepository) PurgeStale(ctx context.Context, windowStart time.Time) (int64, error) {
+	result := r.db.WithContext(ctx).
+		Where("last_failure_at < ? AND (locked_until IS NULL OR locked_until < ?)", windowStart, windowStart).
+		Delete(&LoginAttempt{})
+	return result.RowsAffected, result.Error
+}
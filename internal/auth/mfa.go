@@ -0,0 +1,295 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go-boilerplate/internal/infra/audit"
+	infraAuth "go-boilerplate/internal/infra/auth"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrInvalidMFACode    = errors.New("invalid MFA code")
+	ErrMFAAlreadyEnabled = errors.New("MFA is already enabled")
+	ErrMFANotEnabled     = errors.New("MFA is not enabled")
+	ErrMFANotEnrolled    = errors.New("MFA enrollment has not been started")
+)
+
+const (
+	// totpSkew accepts codes from one step either side of now to absorb clock drift.
+	totpSkew = 1
+
+	recoveryCodeCount  = 10
+	recoveryCodeLength = 10
+)
+
+var recoveryCodeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// MFAEnrollment is what the user needs to add the account to an authenticator app.
+type MFAEnrollment struct {
+	Secret          string
+	ProvisioningURI string
+}
+
+// EnrollMFA generates a new authenticator secret for the user. MFA is not enforced until
+// the user proves the authenticator works by calling EnableMFA with a code from it.
+func (u *usecase) EnrollMFA(ctx context.Context, userID uuid.UUID) (*MFAEnrollment, error) {
+	user, err := u.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.MFAEnabled() {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	secret, err := infraAuth.GenerateTOTPSecret()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate TOTP secret: %w", err)
+	}
+
+	encrypted, err := u.secrets.Seal(secret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt TOTP secret: %w", err)
+	}
+
+	if err := u.userRepo.SetTOTPSecret(ctx, user.ID, encrypted); err != nil {
+		return nil, fmt.Errorf("failed to store TOTP secret: %w", err)
+	}
+
+	return &MFAEnrollment{
+		Secret:          secret,
+		ProvisioningURI: infraAuth.TOTPProvisioningURI(u.mfaIssuer, user.Username, secret),
+	}, nil
+}
+
+// EnableMFA turns on MFA once code shows the enrolled authenticator works and returns
+// the recovery codes, which are only ever shown this once.
+func (u *usecase) EnableMFA(ctx context.Context, userID uuid.UUID, code string) ([]string, error) {
+	user, err := u.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.MFAEnabled() {
+		return nil, ErrMFAAlreadyEnabled
+	}
+	if user.TOTPSecret == "" {
+		return nil, ErrMFANotEnrolled
+	}
+
+	step, err := u.validateTOTP(user, code)
+	if err != nil {
+		return nil, err
+	}
+
+	codes, err := u.replaceRecoveryCodes(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := u.userRepo.EnableMFA(ctx, user.ID, step); err != nil {
+		return nil, fmt.Errorf("failed to enable MFA: %w", err)
+	}
+
+	u.auditUser(ctx, ActionMFAEnable, audit.OutcomeSuccess, user.ID, nil)
+
+	return codes, nil
+}
+
+// DisableMFA turns MFA off after checking a current TOTP or recovery code, so a stolen
+// access token alone cannot remove the second factor.
+func (u *usecase) DisableMFA(ctx context.Context, userID uuid.UUID, code string) error {
+	user, err := u.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !user.MFAEnabled() {
+		return ErrMFANotEnabled
+	}
+
+	if err := u.verifySecondFactor(ctx, user, code); err != nil {
+		if errors.Is(err, ErrInvalidMFACode) {
+			u.auditUser(ctx, ActionMFADisable, audit.OutcomeFailure, user.ID, map[string]any{"reason": "wrong_mfa_code"})
+		}
+		return err
+	}
+
+	if err := u.userRepo.DisableMFA(ctx, user.ID); err != nil {
+		return fmt.Errorf("failed to disable MFA: %w", err)
+	}
+	if err := u.recoveryCodes.DeleteByUserID(ctx, user.ID); err != nil {
+		return fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+
+	u.auditUser(ctx, ActionMFADisable, audit.OutcomeSuccess, user.ID, nil)
+
+	return nil
+}
+
+// VerifyMFA completes a login started by Login: it checks the challenge token and the
+// second factor and then issues the token pair. Wrong codes count towards the same
+// lockout as wrong passwords, and each challenge can only be redeemed once.
+func (u *usecase) VerifyMFA(ctx context.Context, mfaToken, code string, client ClientInfo) (*LoginResult, error) {
+	claims, err := u.jwtSvc.ValidateMFAChallenge(mfaToken)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	revoked, err := u.revocations.IsRevoked(ctx, claims)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check challenge revocation: %w", err)
+	}
+	if revoked {
+		return nil, ErrInvalidToken
+	}
+
+	userID, err := uuid.Parse(claims.UserID)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	user, err := u.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, ErrUserNotFound) {
+			return nil, ErrInvalidToken
+		}
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	if !user.MFAEnabled() {
+		return nil, ErrInvalidToken
+	}
+
+	keys := u.loginKeys(user.Username, client)
+	now := time.Now()
+	if err := u.checkLockout(ctx, keys, now); err != nil {
+		u.auditLoginFailure(ctx, user.Username, user, loginMethodMFA, "locked_out")
+		return nil, err
+	}
+
+	if err := u.verifySecondFactor(ctx, user, code); err != nil {
+		if !errors.Is(err, ErrInvalidMFACode) {
+			return nil, err
+		}
+		u.auditLoginFailure(ctx, user.Username, user, loginMethodMFA, "wrong_mfa_code")
+		if err := u.recordFailedLogin(ctx, keys, now); !errors.Is(err, ErrInvalidCredentials) {
+			return nil, err
+		}
+		return nil, ErrInvalidMFACode
+	}
+
+	expiresAt := claims.ExpiresAt.Add(revocationGrace)
+	if err := u.revocations.RevokeToken(ctx, claims.ID, expiresAt); err != nil {
+		return nil, fmt.Errorf("failed to consume MFA challenge: %w", err)
+	}
+
+	// The role may have changed since the challenge was issued.
+	requested, _ := claims.Scopes()
+	scopes, err := grantScopes(user.Role, requested)
+	if err != nil {
+		return nil, err
+	}
+
+	return u.completeLogin(ctx, user, keys, scopes, loginMethodMFA, client)
+}
+
+// verifySecondFactor accepts either a TOTP code or an unused recovery code.
+func (u *usecase) verifySecondFactor(ctx context.Context, user *User, code string) error {
+	code = strings.TrimSpace(code)
+	if !isTOTPCode(code) {
+		used, err := u.recoveryCodes.Consume(ctx, user.ID, u.tokenHasher.Hash(normalizeRecoveryCode(code)))
+		if err != nil {
+			return fmt.Errorf("failed to use recovery code: %w", err)
+		}
+		if !used {
+			return ErrInvalidMFACode
+		}
+		return nil
+	}
+
+	step, err := u.validateTOTP(user, code)
+	if err != nil {
+		return err
+	}
+
+	advanced, err := u.userRepo.AdvanceTOTPStep(ctx, user.ID, step)
+	if err != nil {
+		return fmt.Errorf("failed to record TOTP step: %w", err)
+	}
+	if !advanced {
+		// The code was already used, or a later one has been.
+		return ErrInvalidMFACode
+	}
+	return nil
+}
+
+func (u *usecase) validateTOTP(user *User, code string) (int64, error) {
+	secret, err := u.secrets.Open(user.TOTPSecret)
+	if err != nil {
+		return 0, fmt.Errorf("failed to decrypt TOTP secret: %w", err)
+	}
+
+	step, ok, err := infraAuth.ValidateTOTPCode(secret, strings.TrimSpace(code), time.Now(), totpSkew)
+	if err != nil {
+		return 0, fmt.Errorf("failed to validate TOTP code: %w", err)
+	}
+	if !ok || step <= user.TOTPLastStep {
+		return 0, ErrInvalidMFACode
+	}
+	return step, nil
+}
+
+func (u *usecase) replaceRecoveryCodes(ctx context.Context, userID uuid.UUID) ([]string, error) {
+	codes := make([]string, recoveryCodeCount)
+	records := make([]RecoveryCode, recoveryCodeCount)
+	for i := range codes {
+		code, err := generateRecoveryCode()
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate recovery code: %w", err)
+		}
+		codes[i] = code
+		records[i] = RecoveryCode{
+			ID:       uuid.New(),
+			UserID:   userID,
+			CodeHash: u.tokenHasher.Hash(normalizeRecoveryCode(code)),
+		}
+	}
+
+	if err := u.recoveryCodes.Replace(ctx, userID, records); err != nil {
+		return nil, fmt.Errorf("failed to store recovery codes: %w", err)
+	}
+	return codes, nil
+}
+
+// generateRecoveryCode returns a code like "k3f9a-p2xq7" carrying 50 bits of entropy.
+func generateRecoveryCode() (string, error) {
+	b := make([]byte, 7)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	code := strings.ToLower(recoveryCodeEncoding.EncodeToString(b))[:recoveryCodeLength]
+	return code[:recoveryCodeLength/2] + "-" + code[recoveryCodeLength/2:], nil
+}
+
+// normalizeRecoveryCode lets users type recovery codes without the dash or in upper case.
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
+
+func isTOTPCode(code string) bool {
+	if len(code) != 6 {
+		return false
+	}
+	for _, r := range code {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
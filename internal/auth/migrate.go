@@ -0,0 +1,84 @@
+package auth
+
+import (
+	"fmt"
+
+	"go-boilerplate/internal/database"
+	infraAuth "go-boilerplate/internal/infra/auth"
+
+	"gorm.io/gorm"
+)
+
+// hashedTokenLength is the length of a hex-encoded HMAC-SHA256 digest.
+const hashedTokenLength = 64
+
+// Migrate creates or updates the tables owned by the auth domain.
+func Migrate(db *gorm.DB, tokenHasher infraAuth.TokenHasher) error {
+	if err := db.AutoMigrate(&User{}, &RefreshToken{}, &LoginAttempt{}, &RecoveryCode{}, &ActionToken{}, &APIKey{}, &ExternalIdentity{}, &OIDCLoginState{}); err != nil {
+		return err
+	}
+
+	// Rows issued before real accounts existed point at users that were never stored,
+	// so the constraint is added without validating them.
+	if err := database.EnsureForeignKey(db, "refresh_tokens", "user_id", "users", "id"); err != nil {
+		return err
+	}
+	if err := database.EnsureForeignKey(db, "mfa_recovery_codes", "user_id", "users", "id"); err != nil {
+		return err
+	}
+	if err := database.EnsureForeignKey(db, "action_tokens", "user_id", "users", "id"); err != nil {
+		return err
+	}
+	if err := database.EnsureForeignKey(db, "api_keys", "user_id", "users", "id"); err != nil {
+		return err
+	}
+	if err := database.EnsureForeignKey(db, "external_identities", "user_id", "users", "id"); err != nil {
+		return err
+	}
+
+	return hashPlaintextRefreshTokens(db, tokenHasher)
+}
+
+// PromoteAdmins grants the admin role to the given existing usernames. It bootstraps the
+// first administrators, who can then manage everyone else's role through the API.
+func PromoteAdmins(db *gorm.DB, usernames []string) error {
+	if len(usernames) == 0 {
+		return nil
+	}
+
+	normalized := make([]string, len(usernames))
+	for i, username := range usernames {
+		normalized[i] = normalizeIdentifier(username)
+	}
+
+	return db.Model(&User{}).
+		Where("username IN ?", normalized).
+		Update("role", infraAuth.RoleAdmin).Error
+}
+
+// hashPlaintextRefreshTokens replaces refresh tokens stored before hashing was introduced
+// with their keyed hash, so existing sessions keep working after the upgrade.
+// Legacy tokens were UUID strings, which never collide in length with a digest,
+// making the migration safe to run on every start-up.
+func hashPlaintextRefreshTokens(db *gorm.DB, tokenHasher infraAuth.TokenHasher) error {
+	var legacy []RefreshToken
+	err := db.Unscoped().
+		Where("LENGTH(token) <> ?", hashedTokenLength).
+		Find(&legacy).Error
+	if err != nil {
+		return fmt.Errorf("failed to load plaintext refresh tokens: %w", err)
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, token := range legacy {
+			err := tx.Unscoped().
+				Model(&RefreshToken{}).
+				Where("id = ?", token.ID).
+				Update("token", tokenHasher.Hash(token.TokenHash)).Error
+			if err != nil {
+				return fmt.Errorf("failed to hash refresh token %s: %w", token.ID, err)
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,242 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"strings"
+	"time"
+
+	infraAuth "go-boilerplate/internal/infra/auth"
+	"go-boilerplate/internal/infra/oidc"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrUnknownProvider       = errors.New("unknown identity provider")
+	ErrInvalidOIDCState      = errors.New("invalid or expired login state")
+	ErrIdentityNotFound      = errors.New("external identity not found")
+	ErrIdentityAlreadyLinked = errors.New("external identity is already linked")
+	ErrOIDCEmailNotVerified  = errors.New("identity provider did not supply a verified email address")
+	ErrOIDCAccountConflict   = errors.New("an account with this email already exists; log in and verify its email first")
+)
+
+// oidcLoginTTL bounds how long the user has to log in at the provider.
+const oidcLoginTTL = 10 * time.Minute
+
+// maxUsernameAttempts is how many generated usernames are tried for a new SSO user.
+const maxUsernameAttempts = 5
+
+// StartOIDCLogin begins an authorization code flow with PKCE and returns the provider URL
+// to send the user to. State, nonce and code verifier are kept server side so the callback
+// needs nothing but what the provider sends back, including whether the session is to
+// be delivered as cookies.
+func (u *usecase) StartOIDCLogin(ctx context.Context, providerName string, cookie bool) (string, error) {
+	provider, ok := u.oidcProviders[providerName]
+	if !ok {
+		return "", ErrUnknownProvider
+	}
+
+	state, err := infraAuth.GenerateOpaqueToken()
+	if err != nil {
+		return "", fmt.Errorf("failed to generate state: %w", err)
+	}
+	nonce, err := infraAuth.GenerateOpaqueToken()
+	if err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	verifier, challenge, err := oidc.GeneratePKCE()
+	if err != nil {
+		return "", fmt.Errorf("failed to generate PKCE verifier: %w", err)
+	}
+
+	authURL, err := provider.AuthCodeURL(ctx, state, nonce, challenge)
+	if err != nil {
+		return "", fmt.Errorf("failed to build authorization URL: %w", err)
+	}
+
+	loginState := &OIDCLoginState{
+		StateHash:    u.tokenHasher.Hash(state),
+		Provider:     providerName,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		Cookie:       cookie,
+		ExpiresAt:    time.Now().Add(oidcLoginTTL),
+	}
+	if err := u.oidcStates.Create(ctx, loginState); err != nil {
+		return "", fmt.Errorf("failed to store login state: %w", err)
+	}
+
+	return authURL, nil
+}
+
+// CompleteOIDCLogin redeems the authorization code the provider redirected back with,
+// resolves the local user for the verified identity and starts a session for them.
+// Accounts with MFA enabled still have to pass their second factor.
+func (u *usecase) CompleteOIDCLogin(ctx context.Context, providerName, state, code string, client ClientInfo) (*LoginResult, error) {
+	provider, ok := u.oidcProviders[providerName]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+
+	loginState, err := u.oidcStates.Consume(ctx, u.tokenHasher.Hash(state))
+	if err != nil {
+		if errors.Is(err, ErrInvalidOIDCState) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to load login state: %w", err)
+	}
+	if loginState.Provider != providerName {
+		return nil, ErrInvalidOIDCState
+	}
+
+	claims, err := provider.Exchange(ctx, code, loginState.CodeVerifier, loginState.Nonce)
+	if err != nil {
+		if errors.Is(err, oidc.ErrInvalidIDToken) {
+			u.auditLoginFailure(ctx, "", nil, loginMethodOIDC, "invalid_id_token")
+		}
+		return nil, err
+	}
+
+	user, err := u.userForIdentity(ctx, providerName, claims)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrOIDCEmailNotVerified):
+			u.auditLoginFailure(ctx, claims.Email, nil, loginMethodOIDC, "email_not_verified")
+		case errors.Is(err, ErrOIDCAccountConflict):
+			u.auditLoginFailure(ctx, claims.Email, nil, loginMethodOIDC, "account_conflict")
+		}
+		return nil, err
+	}
+
+	if user.MFAEnabled() {
+		mfaToken, err := u.jwtSvc.GenerateMFAChallenge(user.ID.String(), nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate MFA challenge: %w", err)
+		}
+		return &LoginResult{MFAToken: mfaToken}, nil
+	}
+
+	result, err := u.completeLogin(ctx, user, u.loginKeys(user.Username, client), nil, loginMethodOIDC, client)
+	if err != nil {
+		return nil, err
+	}
+	result.Cookie = loginState.Cookie
+	return result, nil
+}
+
+// userForIdentity returns the user linked to the external identity, linking or creating
+// one on first login. An existing account is only linked by email when both the provider
+// and we have verified that address; otherwise whoever controls the provider account
+// could take over a local account registered with someone else's address.
+func (u *usecase) userForIdentity(ctx context.Context, providerName string, claims *oidc.IDTokenClaims) (*User, error) {
+	now := time.Now()
+
+	identity, err := u.identities.GetByProviderSubject(ctx, providerName, claims.Subject)
+	if err == nil {
+		if err := u.identities.TouchLastLogin(ctx, identity.ID, now); err != nil {
+			slog.WarnContext(ctx, "failed to record external identity login", "error", err, "identity_id", identity.ID)
+		}
+		user, err := u.userRepo.GetByID(ctx, identity.UserID)
+		if err != nil {
+			if errors.Is(err, ErrUserNotFound) {
+				return nil, ErrInvalidCredentials
+			}
+			return nil, fmt.Errorf("failed to get user: %w", err)
+		}
+		return user, nil
+	}
+	if !errors.Is(err, ErrIdentityNotFound) {
+		return nil, fmt.Errorf("failed to get external identity: %w", err)
+	}
+
+	email := normalizeIdentifier(claims.Email)
+	if email == "" || !claims.EmailVerified {
+		return nil, ErrOIDCEmailNotVerified
+	}
+
+	user, err := u.userRepo.GetByEmail(ctx, email)
+	switch {
+	case err == nil:
+		if user.EmailVerifiedAt == nil {
+			return nil, ErrOIDCAccountConflict
+		}
+	case errors.Is(err, ErrUserNotFound):
+		user, err = u.createOIDCUser(ctx, claims, email, now)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	identity = &ExternalIdentity{
+		ID:          uuid.New(),
+		UserID:      user.ID,
+		Provider:    providerName,
+		Subject:     claims.Subject,
+		Email:       email,
+		LastLoginAt: now,
+	}
+	if err := u.identities.Create(ctx, identity); err != nil {
+		return nil, fmt.Errorf("failed to link external identity: %w", err)
+	}
+
+	return user, nil
+}
+
+// createOIDCUser registers a user without a password; they can only log in through the
+// provider until they set one with a password reset.
+func (u *usecase) createOIDCUser(ctx context.Context, claims *oidc.IDTokenClaims, email string, now time.Time) (*User, error) {
+	base := usernameBase(claims.PreferredUsername)
+	if base == "" {
+		base = usernameBase(strings.SplitN(email, "@", 2)[0])
+	}
+
+	for attempt := 0; attempt < maxUsernameAttempts; attempt++ {
+		username := base
+		if attempt > 0 || len(username) < 3 {
+			suffix, err := rand.Int(rand.Reader, big.NewInt(1_000_000))
+			if err != nil {
+				return nil, fmt.Errorf("failed to generate username: %w", err)
+			}
+			username = fmt.Sprintf("%s%06d", base, suffix.Int64())
+		}
+
+		user := &User{
+			ID:              uuid.New(),
+			Username:        username,
+			Email:           email,
+			Role:            infraAuth.RoleUser,
+			EmailVerifiedAt: &now,
+		}
+		err := u.userRepo.Create(ctx, user)
+		if err == nil {
+			return user, nil
+		}
+		if !errors.Is(err, ErrUserAlreadyExists) {
+			return nil, fmt.Errorf("failed to create user: %w", err)
+		}
+	}
+
+	return nil, fmt.Errorf("failed to create user: %w", ErrUserAlreadyExists)
+}
+
+// usernameBase keeps the lowercase letters and digits of s, so generated usernames pass
+// the same validation as registered ones.
+func usernameBase(s string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(s) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+		}
+		if b.Len() == 40 {
+			break
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,50 @@
+package auth
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type OIDCStateRepository interface {
+	Create(ctx context.Context, state *OIDCLoginState) error
+	Consume(ctx context.Context, stateHash string) (*OIDCLoginState, error)
+	PurgeExpired(ctx context.Context, cutoff time.Time) (int64, error)
+}
+
+type oidcStateRepository struct {
+	db *gorm.DB
+}
+
+func NewOIDCStateRepository(db *gorm.DB) OIDCStateRepository {
+	return &oidcStateRepository{db: db}
+}
+
+func (r *oidcStateRepository) Create(ctx context.Context, state *OIDCLoginState) error {
+	return r.db.WithContext(ctx).Create(state).Error
+}
+
+// Consume deletes an unexpired state and returns it, so a callback can only be
+// completed once even if it is replayed concurrently.
+func (r *oidcStateRepository) Consume(ctx context.Context, stateHash string) (*OIDCLoginState, error) {
+	var states []OIDCLoginState
+	err := r.db.WithContext(ctx).Raw(`
+		DELETE FROM oidc_login_states
+		WHERE state_hash = ? AND expires_at > ?
+		RETURNING *`, stateHash, time.Now()).
+		Scan(&states).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(states) == 0 {
+		return nil, ErrInvalidOIDCState
+	}
+	return &states[0], nil
+}
+
+// PurgeExpired deletes login attempts that were abandoned before cutoff.
+func (r *oidcStateRepository) PurgeExpired(ctx context.Context, cutoff time.Time) (int64, error) {
+	result := r.db.WithContext(ctx).Where("expires_at < ?", cutoff).Delete(&OIDCLoginState{})
+	return result.RowsAffected, result.Error
+}
@@ -4,15 +4,20 @@ import (
 	"context"
 	"time"
 
+	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
 type Repository interface {
 	Create(ctx context.Context, token *RefreshToken) error
-	GetByToken(ctx context.Context, tokenStr string) (*RefreshToken, error)
-	DeleteByToken(ctx context.Context, tokenStr string) error
-	DeleteByUserID(ctx context.Context, userID string) error
-	DeleteExpired(ctx context.Context) error
+	GetByToken(ctx context.Context, tokenHash string) (*RefreshToken, error)
+	DeleteByToken(ctx context.Context, tokenHash string) error
+	Revoke(ctx context.Context, id uuid.UUID) (bool, error)
+	RevokeFamily(ctx context.Context, familyID uuid.UUID) error
+	RevokeUserFamily(ctx context.Context, userID, familyID uuid.UUID) (bool, error)
+	ListActiveByUserID(ctx context.Context, userID uuid.UUID) ([]RefreshToken, error)
+	DeleteByUserID(ctx context.Context, userID uuid.UUID) error
+	PurgeExpired(ctx context.Context, cutoff time.Time, batchSize int) (int64, error)
 }
 
 type repository struct {
@@ -27,23 +32,89 @@ func (r *repository) Create(ctx context.Context, token *RefreshToken) error {
 	return r.db.WithContext(ctx).Create(token).Error
 }
 
-func (r *repository) GetByToken(ctx context.Context, tokenStr string) (*RefreshToken, error) {
+func (r *repository) GetByToken(ctx context.Context, tokenHash string) (*RefreshToken, error) {
 	var token RefreshToken
-	err := r.db.WithContext(ctx).Where("token = ?", tokenStr).First(&token).Error
+	err := r.db.WithContext(ctx).Where("token = ?", tokenHash).First(&token).Error
 	if err != nil {
 		return nil, err
 	}
 	return &token, nil
 }
 
-func (r *repository) DeleteByToken(ctx context.Context, tokenStr string) error {
-	return r.db.WithContext(ctx).Where("token = ?", tokenStr).Delete(&RefreshToken{}).Error
+func (r *repository) DeleteByToken(ctx context.Context, tokenHash string) error {
+	return r.db.WithContext(ctx).Where("token = ?", tokenHash).Delete(&RefreshToken{}).Error
 }
 
-func (r *repository) DeleteByUserID(ctx context.Context, userID string) error {
+// Revoke marks a token as revoked. It reports false when the token had already been
+// revoked, which lets callers detect two requests racing to rotate the same token.
+func (r *repository) Revoke(ctx context.Context, id uuid.UUID) (bool, error) {
+	result := r.db.WithContext(ctx).
+		Model(&RefreshToken{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (r *repository) RevokeFamily(ctx context.Context, familyID uuid.UUID) error {
+	return r.db.WithContext(ctx).
+		Model(&RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// RevokeUserFamily revokes a session only if it belongs to the user, reporting whether
+// any live token was found.
+func (r *repository) RevokeUserFamily(ctx context.Context, userID, familyID uuid.UUID) (bool, error) {
+	result := r.db.WithContext(ctx).
+		Model(&RefreshToken{}).
+		Where("user_id = ? AND family_id = ? AND revoked_at IS NULL", userID, familyID).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// ListActiveByUserID returns the live token of each of the user's sessions, most recently used first.
+func (r *repository) ListActiveByUserID(ctx context.Context, userID uuid.UUID) ([]RefreshToken, error) {
+	var tokens []RefreshToken
+	err := r.db.WithContext(ctx).
+		Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("last_used_at DESC").
+		Find(&tokens).Error
+	if err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+func (r *repository) DeleteByUserID(ctx context.Context, userID uuid.UUID) error {
 	return r.db.WithContext(ctx).Where("user_id = ?", userID).Delete(&RefreshToken{}).Error
 }
 
-func (r *repository) DeleteExpired(ctx context.Context) error {
-	return r.db.WithContext(ctx).Where("expires_at < ?", time.Now()).Delete(&RefreshToken{}).Error
+// PurgeExpired permanently removes tokens that expired, were revoked or were soft-deleted
+// before cutoff. Rows are deleted batchSize at a time so a large backlog does not hold
+// long locks on the table. It returns the total number of rows removed.
+func (r *repository) PurgeExpired(ctx context.Context, cutoff time.Time, batchSize int) (int64, error) {
+	var total int64
+	for {
+		result := r.db.WithContext(ctx).Exec(`
+			DELETE FROM refresh_tokens
+			WHERE id IN (
+				SELECT id FROM refresh_tokens
+				WHERE expires_at < ? OR revoked_at < ? OR deleted_at < ?
+				LIMIT ?
+			)`, cutoff, cutoff, cutoff, batchSize)
+		if result.Error != nil {
+			return total, result.Error
+		}
+
+		total += result.RowsAffected
+		if result.RowsAffected < int64(batchSize) {
+			return total, nil
+		}
+	}
 }
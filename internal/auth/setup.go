@@ -1,29 +1,139 @@
 package auth
 
 import (
+	"time"
+
+	"go-boilerplate/internal/infra/audit"
 	infraAuth "go-boilerplate/internal/infra/auth"
+	"go-boilerplate/internal/infra/mail"
+	"go-boilerplate/internal/infra/oidc"
+	"go-boilerplate/internal/infra/scheduler"
 
 	"github.com/labstack/echo/v5"
 	"github.com/samber/do"
 	"gorm.io/gorm"
 )
 
-func NewInjector(db *gorm.DB, jwtSvc infraAuth.JWTService) *do.Injector {
+func NewInjector(
+	db *gorm.DB,
+	jwtSvc infraAuth.JWTService,
+	hasher infraAuth.PasswordHasher,
+	tokenHasher infraAuth.TokenHasher,
+	revocations infraAuth.RevocationStore,
+	lockoutPolicy LockoutPolicy,
+	secrets infraAuth.SecretBox,
+	mfaIssuer string,
+	mailer mail.Mailer,
+	appBaseURL string,
+	oidcProviders map[string]oidc.Provider,
+	auditLogger audit.AuditLogger,
+) *do.Injector {
 	injector := do.New()
 
+	do.ProvideValue(injector, lockoutPolicy)
+
 	do.Provide(injector, func(i *do.Injector) (Repository, error) {
 		return NewRepository(db), nil
 	})
 
+	do.Provide(injector, func(i *do.Injector) (UserRepository, error) {
+		return NewUserRepository(db), nil
+	})
+
+	do.Provide(injector, func(i *do.Injector) (LoginAttemptRepository, error) {
+		return NewLoginAttemptRepository(db), nil
+	})
+
+	do.Provide(injector, func(i *do.Injector) (RecoveryCodeRepository, error) {
+		return NewRecoveryCodeRepository(db), nil
+	})
+
+	do.Provide(injector, func(i *do.Injector) (ActionTokenRepository, error) {
+		return NewActionTokenRepository(db), nil
+	})
+
+	do.Provide(injector, func(i *do.Injector) (APIKeyRepository, error) {
+		return NewAPIKeyRepository(db), nil
+	})
+
+	do.Provide(injector, func(i *do.Injector) (OIDCStateRepository, error) {
+		return NewOIDCStateRepository(db), nil
+	})
+
+	do.Provide(injector, func(i *do.Injector) (ExternalIdentityRepository, error) {
+		return NewExternalIdentityRepository(db), nil
+	})
+
 	do.Provide(injector, func(i *do.Injector) (Usecase, error) {
 		repo := do.MustInvoke[Repository](i)
-		return NewUsecase(repo, jwtSvc), nil
+		userRepo := do.MustInvoke[UserRepository](i)
+		lockouts := do.MustInvoke[LoginAttemptRepository](i)
+		policy := do.MustInvoke[LockoutPolicy](i)
+		recoveryCodes := do.MustInvoke[RecoveryCodeRepository](i)
+		actionTokens := do.MustInvoke[ActionTokenRepository](i)
+		apiKeys := do.MustInvoke[APIKeyRepository](i)
+		oidcStates := do.MustInvoke[OIDCStateRepository](i)
+		identities := do.MustInvoke[ExternalIdentityRepository](i)
+		return NewUsecase(
+			repo,
+			userRepo,
+			jwtSvc,
+			hasher,
+			tokenHasher,
+			revocations,
+			lockouts,
+			policy,
+			recoveryCodes,
+			secrets,
+			mfaIssuer,
+			actionTokens,
+			mailer,
+			appBaseURL,
+			apiKeys,
+			oidcProviders,
+			oidcStates,
+			identities,
+			auditLogger,
+		), nil
 	})
 
 	return injector
 }
 
-func RegisterHandlers(e *echo.Echo, injector *do.Injector) {
+// APIKeyAuthenticator returns the authenticator BearerAuth uses to accept API keys.
+func APIKeyAuthenticator(injector *do.Injector) infraAuth.APIKeyAuthenticator {
+	return do.MustInvoke[Usecase](injector)
+}
+
+// RegisterHandlers mounts the auth endpoints. cookies is nil unless browser clients may
+// ask for their tokens as cookies.
+func RegisterHandlers(
+	e *echo.Echo,
+	injector *do.Injector,
+	bearerMiddleware, introspectionAuth echo.MiddlewareFunc,
+	cookies *infraAuth.SessionCookies,
+) {
 	usecase := do.MustInvoke[Usecase](injector)
-	NewHandler(e, usecase)
+	NewHandler(e, usecase, bearerMiddleware, introspectionAuth, cookies)
+}
+
+// RegisterJobs adds the auth domain's housekeeping jobs to the scheduler.
+func RegisterJobs(
+	sched *scheduler.Scheduler,
+	injector *do.Injector,
+	interval, jitter, retention time.Duration,
+	batchSize int,
+) {
+	repo := do.MustInvoke[Repository](injector)
+	sched.Register(NewRefreshTokenPurgeJob(repo, interval, jitter, retention, batchSize))
+
+	actionTokens := do.MustInvoke[ActionTokenRepository](injector)
+	sched.Register(NewActionTokenPurgeJob(actionTokens, interval, jitter, retention))
+
+	oidcStates := do.MustInvoke[OIDCStateRepository](injector)
+	sched.Register(NewOIDCStatePurgeJob(oidcStates, interval, jitter))
+
+	lockouts := do.MustInvoke[LoginAttemptRepository](injector)
+	policy := do.MustInvoke[LockoutPolicy](injector)
+	sched.Register(NewLoginAttemptPurgeJob(lockouts, interval, jitter, policy.FailureWindow))
 }
@@ -4,9 +4,16 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
 	"time"
 
+	"go-boilerplate/internal/infra/audit"
 	infraAuth "go-boilerplate/internal/infra/auth"
+	"go-boilerplate/internal/infra/mail"
+	"go-boilerplate/internal/infra/oidc"
 
 	"github.com/google/uuid"
 )
@@ -15,72 +22,602 @@ var (
 	ErrInvalidCredentials = errors.New("invalid credentials")
 	ErrTokenExpired       = errors.New("token expired")
 	ErrInvalidToken       = errors.New("invalid token")
+	ErrUserNotFound       = errors.New("user not found")
+	ErrUserAlreadyExists  = errors.New("user already exists")
+	ErrTokenReused        = errors.New("refresh token reuse detected")
+	ErrInvalidRole        = errors.New("invalid role")
+	ErrSessionNotFound    = errors.New("session not found")
 )
 
+// RefreshTokenTTL is how long a refresh token stays usable after it is issued.
+const RefreshTokenTTL = 7 * 24 * time.Hour
+
+// maxUserAgentLength matches the width of the refresh_tokens.user_agent column.
+const maxUserAgentLength = 512
+
+// revocationGrace keeps revocations around a little past token expiry so tokens
+// accepted within the validator's clock-skew leeway stay revoked.
+const revocationGrace = 5 * time.Minute
+
 type Usecase interface {
-	Login(ctx context.Context, username, password string) (string, string, error)
-	RefreshToken(ctx context.Context, refreshTokenStr string) (string, error)
-	Logout(ctx context.Context, refreshTokenStr string) error
+	Register(ctx context.Context, username, email, password string) (*User, error)
+	Login(ctx context.Context, username, password string, scopes []infraAuth.Permission, client ClientInfo) (*LoginResult, error)
+	RefreshToken(ctx context.Context, refreshTokenStr string, scopes []infraAuth.Permission, client ClientInfo) (string, string, error)
+	Logout(ctx context.Context, refreshTokenStr, accessToken string) error
+	ListSessions(ctx context.Context, userID uuid.UUID) ([]Session, error)
+	RevokeSession(ctx context.Context, userID, sessionID uuid.UUID) error
+	RevokeAllSessions(ctx context.Context, userID uuid.UUID) error
+	UpdateRole(ctx context.Context, userID uuid.UUID, role infraAuth.Role) error
+	ListLockouts(ctx context.Context) ([]LoginAttempt, error)
+	ClearLockout(ctx context.Context, key string) error
+	EnrollMFA(ctx context.Context, userID uuid.UUID) (*MFAEnrollment, error)
+	EnableMFA(ctx context.Context, userID uuid.UUID, code string) ([]string, error)
+	DisableMFA(ctx context.Context, userID uuid.UUID, code string) error
+	VerifyMFA(ctx context.Context, mfaToken, code string, client ClientInfo) (*LoginResult, error)
+	ForgotPassword(ctx context.Context, email string) error
+	ResetPassword(ctx context.Context, token, password string) error
+	VerifyEmail(ctx context.Context, token string) error
+	SendVerificationEmail(ctx context.Context, userID uuid.UUID) error
+	CreateAPIKey(ctx context.Context, userID uuid.UUID, name string, scopes []infraAuth.Permission, ttl time.Duration) (*APIKey, string, error)
+	ListAPIKeys(ctx context.Context, userID uuid.UUID) ([]APIKey, error)
+	RevokeAPIKey(ctx context.Context, userID, keyID uuid.UUID) error
+	StartOIDCLogin(ctx context.Context, provider string, cookie bool) (string, error)
+	CompleteOIDCLogin(ctx context.Context, provider, state, code string, client ClientInfo) (*LoginResult, error)
+	Introspect(ctx context.Context, token string) (*infraAuth.IntrospectionResponse, error)
+	GetUser(ctx context.Context, userID uuid.UUID) (*User, error)
+	Impersonate(ctx context.Context, adminID, targetID uuid.UUID, readOnly bool) (*Impersonation, error)
+	infraAuth.APIKeyAuthenticator
+}
+
+// LoginResult is the outcome of a successful password check. Either the token pair is
+// set, or the account uses MFA and only MFAToken is set, to be redeemed with VerifyMFA.
+// Cookie reports a single sign-on login that was started in cookie mode, since the
+// provider's redirect back carries no request body to ask for it.
+type LoginResult struct {
+	AccessToken  string
+	RefreshToken string
+	MFAToken     string
+	Cookie       bool
 }
 
 type usecase struct {
-	repo   Repository
-	jwtSvc infraAuth.JWTService
+	repo        Repository
+	userRepo    UserRepository
+	jwtSvc      infraAuth.JWTService
+	hasher      infraAuth.PasswordHasher
+	tokenHasher infraAuth.TokenHasher
+	revocations infraAuth.RevocationStore
+
+	lockouts      LoginAttemptRepository
+	lockoutPolicy LockoutPolicy
+
+	recoveryCodes RecoveryCodeRepository
+	secrets       infraAuth.SecretBox
+	mfaIssuer     string
+
+	actionTokens ActionTokenRepository
+	mailer       mail.Mailer
+	appBaseURL   string
+
+	apiKeys APIKeyRepository
+
+	oidcProviders map[string]oidc.Provider
+	oidcStates    OIDCStateRepository
+	identities    ExternalIdentityRepository
+
+	auditLogger audit.AuditLogger
+
+	// dummyHash is compared against when a username does not exist so that
+	// unknown and known usernames take roughly the same time to reject.
+	dummyHashOnce sync.Once
+	dummyHash     string
 }
 
-func NewUsecase(repo Repository, jwtSvc infraAuth.JWTService) Usecase {
+func NewUsecase(
+	repo Repository,
+	userRepo UserRepository,
+	jwtSvc infraAuth.JWTService,
+	hasher infraAuth.PasswordHasher,
+	tokenHasher infraAuth.TokenHasher,
+	revocations infraAuth.RevocationStore,
+	lockouts LoginAttemptRepository,
+	lockoutPolicy LockoutPolicy,
+	recoveryCodes RecoveryCodeRepository,
+	secrets infraAuth.SecretBox,
+	mfaIssuer string,
+	actionTokens ActionTokenRepository,
+	mailer mail.Mailer,
+	appBaseURL string,
+	apiKeys APIKeyRepository,
+	oidcProviders map[string]oidc.Provider,
+	oidcStates OIDCStateRepository,
+	identities ExternalIdentityRepository,
+	auditLogger audit.AuditLogger,
+) Usecase {
 	return &usecase{
-		repo:   repo,
-		jwtSvc: jwtSvc,
+		repo:          repo,
+		userRepo:      userRepo,
+		jwtSvc:        jwtSvc,
+		hasher:        hasher,
+		tokenHasher:   tokenHasher,
+		revocations:   revocations,
+		lockouts:      lockouts,
+		lockoutPolicy: lockoutPolicy,
+		recoveryCodes: recoveryCodes,
+		secrets:       secrets,
+		mfaIssuer:     mfaIssuer,
+		actionTokens:  actionTokens,
+		mailer:        mailer,
+		appBaseURL:    appBaseURL,
+		apiKeys:       apiKeys,
+		oidcProviders: oidcProviders,
+		oidcStates:    oidcStates,
+		identities:    identities,
+		auditLogger:   auditLogger,
 	}
 }
 
-func (u *usecase) Login(ctx context.Context, username, password string) (string, string, error) {
-	// In a real app, validate credentials against the DB
-	// For this boilerplate, we'll use a mock success
-	if username != "admin" || password != "admin" {
-		return "", "", ErrInvalidCredentials
+func (u *usecase) Register(ctx context.Context, username, email, password string) (*User, error) {
+	passwordHash, err := u.hasher.Hash(password)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	user := &User{
+		ID:           uuid.New(),
+		Username:     normalizeIdentifier(username),
+		Email:        normalizeIdentifier(email),
+		PasswordHash: passwordHash,
+		Role:         infraAuth.RoleUser,
+	}
+
+	if err := u.userRepo.Create(ctx, user); err != nil {
+		if errors.Is(err, ErrUserAlreadyExists) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to create user: %w", err)
 	}
 
-	userID := "7ea078fa-aac0-4364-8f5f-ba69b136b8f7"
-	accessToken, refreshTokenStr, err := u.jwtSvc.GeneratePair(userID)
+	u.sendWelcomeVerification(ctx, user)
+
+	return user, nil
+}
+
+// Login verifies the credentials and starts a new session, or returns an MFA challenge
+// when the account has a second factor. Failures are counted per account and per client
+// address; once either is locked out Login returns a LockedError without checking the
+// password, even if it is correct. Scopes, when given, restrict the issued tokens to part
+// of the user's role permissions.
+func (u *usecase) Login(ctx context.Context, username, password string, scopes []infraAuth.Permission, client ClientInfo) (*LoginResult, error) {
+	username = normalizeIdentifier(username)
+	keys := u.loginKeys(username, client)
+
+	now := time.Now()
+	if err := u.checkLockout(ctx, keys, now); err != nil {
+		u.auditLoginFailure(ctx, username, nil, loginMethodPassword, "locked_out")
+		return nil, err
+	}
+
+	user, err := u.userRepo.GetByUsername(ctx, username)
 	if err != nil {
-		return "", "", fmt.Errorf("failed to generate token pair: %w", err)
+		if errors.Is(err, ErrUserNotFound) {
+			u.compareDummyHash(password)
+			u.auditLoginFailure(ctx, username, nil, loginMethodPassword, "unknown_user")
+			return nil, u.recordFailedLogin(ctx, keys, now)
+		}
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	// Users created through single sign-on have no password until they set one.
+	if user.PasswordHash == "" {
+		u.compareDummyHash(password)
+		u.auditLoginFailure(ctx, username, user, loginMethodPassword, "no_password")
+		return nil, u.recordFailedLogin(ctx, keys, now)
+	}
+
+	if err := u.hasher.Compare(user.PasswordHash, password); err != nil {
+		if errors.Is(err, infraAuth.ErrPasswordMismatch) {
+			u.auditLoginFailure(ctx, username, user, loginMethodPassword, "wrong_password")
+			return nil, u.recordFailedLogin(ctx, keys, now)
+		}
+		return nil, fmt.Errorf("failed to verify password: %w", err)
+	}
+
+	scopes, err = grantScopes(user.Role, scopes)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.MFAEnabled() {
+		// Failures are only forgiven once the second factor is verified too.
+		mfaToken, err := u.jwtSvc.GenerateMFAChallenge(user.ID.String(), scopes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate MFA challenge: %w", err)
+		}
+		return &LoginResult{MFAToken: mfaToken}, nil
 	}
 
+	return u.completeLogin(ctx, user, keys, scopes, loginMethodPassword, client)
+}
+
+// completeLogin clears the account's failed attempts and starts a new session restricted
+// to scopes, which the caller has checked against the user's role, then records the
+// login in the audit log under method.
+func (u *usecase) completeLogin(
+	ctx context.Context,
+	user *User,
+	keys []loginKey,
+	scopes []infraAuth.Permission,
+	method string,
+	client ClientInfo,
+) (*LoginResult, error) {
+	// Only the account's failures are forgiven; clearing the address too would let an
+	// attacker reset it between guesses by logging into an account of their own.
+	if _, err := u.lockouts.Delete(ctx, keys[0].key); err != nil {
+		return nil, fmt.Errorf("failed to clear login attempts: %w", err)
+	}
+
+	sessionID := uuid.New()
+	accessToken, refreshTokenStr, err := u.jwtSvc.GeneratePair(sessionSubject(user, scopes, sessionID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate token pair: %w", err)
+	}
+
+	now := time.Now()
 	refreshToken := &RefreshToken{
-		ID:        uuid.New(),
-		UserID:    userID,
-		Token:     refreshTokenStr,
-		ExpiresAt: time.Now().Add(7 * 24 * time.Hour), // 7 days
+		ID:               uuid.New(),
+		UserID:           user.ID,
+		FamilyID:         sessionID,
+		TokenHash:        u.tokenHasher.Hash(refreshTokenStr),
+		Scopes:           scopes,
+		UserAgent:        truncate(client.UserAgent, maxUserAgentLength),
+		IPAddress:        client.IPAddress,
+		SessionStartedAt: now,
+		LastUsedAt:       now,
+		ExpiresAt:        now.Add(RefreshTokenTTL),
 	}
 
 	if err := u.repo.Create(ctx, refreshToken); err != nil {
-		return "", "", fmt.Errorf("failed to store refresh token: %w", err)
+		return nil, fmt.Errorf("failed to store refresh token: %w", err)
 	}
 
-	return accessToken, refreshTokenStr, nil
+	u.auditLogin(ctx, user, method)
+
+	return &LoginResult{AccessToken: accessToken, RefreshToken: refreshTokenStr}, nil
 }
 
-func (u *usecase) RefreshToken(ctx context.Context, refreshTokenStr string) (string, error) {
-	token, err := u.repo.GetByToken(ctx, refreshTokenStr)
+// RefreshToken rotates the refresh token. The new pair keeps the session's scopes unless
+// narrower ones are requested; a session can never widen its scopes, and requested scopes
+// must be granted by the user's role as at login.
+func (u *usecase) RefreshToken(ctx context.Context, refreshTokenStr string, scopes []infraAuth.Permission, client ClientInfo) (string, string, error) {
+	tokenHash := u.tokenHasher.Hash(refreshTokenStr)
+	token, err := u.repo.GetByToken(ctx, tokenHash)
 	if err != nil {
-		return "", ErrInvalidToken
+		return "", "", ErrInvalidToken
+	}
+
+	if token.RevokedAt != nil {
+		u.revokeFamilyOnReuse(ctx, token)
+		return "", "", ErrTokenReused
 	}
 
 	if token.ExpiresAt.Before(time.Now()) {
-		_ = u.repo.DeleteByToken(ctx, refreshTokenStr)
-		return "", ErrTokenExpired
+		_ = u.repo.DeleteByToken(ctx, tokenHash)
+		u.auditUser(ctx, ActionTokenRefresh, audit.OutcomeFailure, token.UserID, map[string]any{
+			"reason":     "expired",
+			"session_id": token.FamilyID.String(),
+		})
+		return "", "", ErrTokenExpired
+	}
+
+	// Reload the user so role changes and deleted accounts take effect on the next refresh.
+	user, err := u.userRepo.GetByID(ctx, token.UserID)
+	if err != nil {
+		if errors.Is(err, ErrUserNotFound) {
+			return "", "", ErrInvalidToken
+		}
+		return "", "", fmt.Errorf("failed to get user: %w", err)
+	}
+
+	// Scopes are settled before the refresh token is spent, so a request for scopes that
+	// cannot be granted leaves the session as it was.
+	if len(scopes) > 0 {
+		if scopes, err = grantScopes(user.Role, scopes); err != nil {
+			return "", "", err
+		}
+		for _, scope := range scopes {
+			if len(token.Scopes) > 0 && !slices.Contains(token.Scopes, scope) {
+				return "", "", fmt.Errorf("%w: %s", ErrInvalidScope, scope)
+			}
+		}
+	} else if len(token.Scopes) > 0 {
+		// Like API keys, a session loses scopes its user's role no longer grants.
+		if scopes = narrowScopes(user.Role, token.Scopes); len(scopes) == 0 {
+			return "", "", ErrInvalidScope
+		}
+	}
+
+	revoked, err := u.repo.Revoke(ctx, token.ID)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to revoke refresh token: %w", err)
+	}
+	if !revoked {
+		// Another request rotated this token between our read and our write.
+		u.revokeFamilyOnReuse(ctx, token)
+		return "", "", ErrTokenReused
+	}
+
+	accessToken, newRefreshTokenStr, err := u.jwtSvc.GeneratePair(sessionSubject(user, scopes, token.FamilyID))
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate token pair: %w", err)
+	}
+
+	now := time.Now()
+	parentID := token.ID
+	newRefreshToken := &RefreshToken{
+		ID:               uuid.New(),
+		UserID:           token.UserID,
+		FamilyID:         token.FamilyID,
+		ParentID:         &parentID,
+		TokenHash:        u.tokenHasher.Hash(newRefreshTokenStr),
+		Scopes:           scopes,
+		UserAgent:        truncate(client.UserAgent, maxUserAgentLength),
+		IPAddress:        client.IPAddress,
+		SessionStartedAt: token.SessionStartedAt,
+		LastUsedAt:       now,
+		ExpiresAt:        now.Add(RefreshTokenTTL),
+	}
+
+	if err := u.repo.Create(ctx, newRefreshToken); err != nil {
+		return "", "", fmt.Errorf("failed to store refresh token: %w", err)
+	}
+
+	u.auditUser(ctx, ActionTokenRefresh, audit.OutcomeSuccess, token.UserID, map[string]any{
+		"session_id": token.FamilyID.String(),
+	})
+
+	return accessToken, newRefreshTokenStr, nil
+}
+
+// revokeFamilyOnReuse revokes every token descended from the same login after a
+// revoked token was replayed, forcing both the attacker and the victim to log in again.
+func (u *usecase) revokeFamilyOnReuse(ctx context.Context, token *RefreshToken) {
+	slog.WarnContext(ctx, "security event: refresh token reuse detected",
+		"user_id", token.UserID,
+		"family_id", token.FamilyID,
+		"token_id", token.ID,
+	)
+	u.auditUser(ctx, ActionTokenRefresh, audit.OutcomeFailure, token.UserID, map[string]any{
+		"reason":     "token_reused",
+		"session_id": token.FamilyID.String(),
+	})
+
+	if err := u.repo.RevokeFamily(ctx, token.FamilyID); err != nil {
+		slog.ErrorContext(ctx, "failed to revoke refresh token family",
+			"error", err,
+			"family_id", token.FamilyID,
+		)
+	}
+	expiresAt := time.Now().Add(u.jwtSvc.AccessTokenTTL() + revocationGrace)
+	if err := u.revocations.RevokeSession(ctx, token.FamilyID.String(), expiresAt); err != nil {
+		slog.ErrorContext(ctx, "failed to revoke the session's access tokens",
+			"error", err,
+			"family_id", token.FamilyID,
+		)
+	}
+}
+
+// Logout deletes the refresh token and, when the caller presents its access token,
+// revokes that too so it cannot be used for the rest of its lifetime.
+func (u *usecase) Logout(ctx context.Context, refreshTokenStr, accessToken string) error {
+	// Without a valid access token the refresh token is deleted blind, and the event
+	// cannot name the user.
+	var userID string
+	if accessToken != "" {
+		if claims, err := u.jwtSvc.ValidateToken(accessToken); err == nil {
+			userID = claims.UserID
+			expiresAt := claims.ExpiresAt.Add(revocationGrace)
+			if err := u.revocations.RevokeToken(ctx, claims.ID, expiresAt); err != nil {
+				return fmt.Errorf("failed to revoke access token: %w", err)
+			}
+		}
+	}
+
+	if err := u.repo.DeleteByToken(ctx, u.tokenHasher.Hash(refreshTokenStr)); err != nil {
+		return err
 	}
 
-	accessToken, err := u.jwtSvc.GenerateToken(token.UserID)
+	u.auditLogger.Log(ctx, audit.Event{
+		ActorID:    userID,
+		Action:     ActionLogout,
+		TargetType: auditTargetUser,
+		TargetID:   userID,
+		Outcome:    audit.OutcomeSuccess,
+	})
+	return nil
+}
+
+func (u *usecase) ListSessions(ctx context.Context, userID uuid.UUID) ([]Session, error) {
+	tokens, err := u.repo.ListActiveByUserID(ctx, userID)
 	if err != nil {
-		return "", fmt.Errorf("failed to generate access token: %w", err)
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+
+	sessions := make([]Session, len(tokens))
+	for i, token := range tokens {
+		sessions[i] = Session{
+			ID:         token.FamilyID,
+			UserAgent:  token.UserAgent,
+			IPAddress:  token.IPAddress,
+			CreatedAt:  token.SessionStartedAt,
+			LastUsedAt: token.LastUsedAt,
+			ExpiresAt:  token.ExpiresAt,
+		}
 	}
 
-	return accessToken, nil
+	return sessions, nil
+}
+
+// RevokeSession ends one of the user's sessions. Its refresh token and the access
+// tokens already issued to it stop working immediately.
+func (u *usecase) RevokeSession(ctx context.Context, userID, sessionID uuid.UUID) error {
+	revoked, err := u.repo.RevokeUserFamily(ctx, userID, sessionID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+	if !revoked {
+		return ErrSessionNotFound
+	}
+
+	expiresAt := time.Now().Add(u.jwtSvc.AccessTokenTTL() + revocationGrace)
+	if err := u.revocations.RevokeSession(ctx, sessionID.String(), expiresAt); err != nil {
+		return fmt.Errorf("failed to revoke access tokens: %w", err)
+	}
+
+	u.auditLogger.Log(ctx, audit.Event{
+		Action:     ActionSessionRevoke,
+		TargetType: auditTargetSession,
+		TargetID:   sessionID.String(),
+		Outcome:    audit.OutcomeSuccess,
+		Metadata:   map[string]any{"user_id": userID.String()},
+	})
+	return nil
+}
+
+// RevokeAllSessions logs the user out everywhere: every refresh token is deleted and
+// every access token issued so far is rejected until it would have expired anyway.
+func (u *usecase) RevokeAllSessions(ctx context.Context, userID uuid.UUID) error {
+	if err := u.endSessions(ctx, userID); err != nil {
+		return err
+	}
+
+	u.auditUser(ctx, ActionLogoutAll, audit.OutcomeSuccess, userID, nil)
+	return nil
+}
+
+// endSessions deletes the user's refresh tokens and revokes every access token issued
+// to them so far.
+func (u *usecase) endSessions(ctx context.Context, userID uuid.UUID) error {
+	if err := u.repo.DeleteByUserID(ctx, userID); err != nil {
+		return fmt.Errorf("failed to delete refresh tokens: %w", err)
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(u.jwtSvc.AccessTokenTTL() + revocationGrace)
+	if err := u.revocations.RevokeUser(ctx, userID.String(), now, expiresAt); err != nil {
+		return fmt.Errorf("failed to revoke access tokens: %w", err)
+	}
+	return nil
+}
+
+// UpdateRole changes the user's role. Tokens carry the role they were issued with, so
+// when it changes the user's sessions are ended and they have to log in again.
+func (u *usecase) UpdateRole(ctx context.Context, userID uuid.UUID, role infraAuth.Role) error {
+	if !role.Valid() {
+		return ErrInvalidRole
+	}
+	user, err := u.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if err := u.userRepo.UpdateRole(ctx, userID, role); err != nil {
+		return err
+	}
+	if user.Role != role {
+		if err := u.endSessions(ctx, userID); err != nil {
+			return err
+		}
+	}
+
+	u.auditUser(ctx, ActionRoleChange, audit.OutcomeSuccess, userID, map[string]any{"role": string(role)})
+	return nil
+}
+
+// ListLockouts returns the login throttling keys that are locked or have recent failures.
+func (u *usecase) ListLockouts(ctx context.Context) ([]LoginAttempt, error) {
+	now := time.Now()
+	attempts, err := u.lockouts.ListActive(ctx, now, now.Add(-u.lockoutPolicy.FailureWindow))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list lockouts: %w", err)
+	}
+	return attempts, nil
+}
+
+// ClearLockout forgets the failures recorded against key, lifting any lockout.
+func (u *usecase) ClearLockout(ctx context.Context, key string) error {
+	deleted, err := u.lockouts.Delete(ctx, key)
+	if err != nil {
+		return fmt.Errorf("failed to clear lockout: %w", err)
+	}
+	if !deleted {
+		return ErrLockoutNotFound
+	}
+
+	u.auditLogger.Log(ctx, audit.Event{
+		Action:     ActionLockoutClear,
+		TargetType: auditTargetLoginKey,
+		TargetID:   key,
+		Outcome:    audit.OutcomeSuccess,
+	})
+	return nil
+}
+
+func (u *usecase) compareDummyHash(password string) {
+	u.dummyHashOnce.Do(func() {
+		u.dummyHash, _ = u.hasher.Hash(uuid.NewString())
+	})
+	_ = u.hasher.Compare(u.dummyHash, password)
+}
+
+func subjectOf(user *User, scopes []infraAuth.Permission) infraAuth.Subject {
+	return infraAuth.Subject{
+		UserID: user.ID.String(),
+		Role:   user.Role,
+		Scopes: scopes,
+	}
+}
+
+// sessionSubject is subjectOf for tokens issued to a login session, which is named by
+// the family id its refresh tokens share.
+func sessionSubject(user *User, scopes []infraAuth.Permission, sessionID uuid.UUID) infraAuth.Subject {
+	subject := subjectOf(user, scopes)
+	subject.SessionID = sessionID.String()
+	return subject
+}
+
+// grantScopes checks that the role grants every requested scope and returns them sorted
+// and deduplicated. Requesting none leaves the credential unrestricted.
+func grantScopes(role infraAuth.Role, requested []infraAuth.Permission) ([]infraAuth.Permission, error) {
+	if len(requested) == 0 {
+		return nil, nil
+	}
+	for _, scope := range requested {
+		if !role.Can(scope) {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidScope, scope)
+		}
+	}
+	return slices.Compact(slices.Sorted(slices.Values(requested))), nil
+}
+
+// narrowScopes drops the scopes the role no longer grants.
+func narrowScopes(role infraAuth.Role, scopes []infraAuth.Permission) []infraAuth.Permission {
+	narrowed := make([]infraAuth.Permission, 0, len(scopes))
+	for _, scope := range scopes {
+		if role.Can(scope) {
+			narrowed = append(narrowed, scope)
+		}
+	}
+	return narrowed
+}
+
+func truncate(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	return strings.ToValidUTF8(s[:max], "")
 }
 
-func (u *usecase) Logout(ctx context.Context, refreshTokenStr string) error {
-	return u.repo.DeleteByToken(ctx, refreshTokenStr)
+// normalizeIdentifier makes usernames and emails case-insensitive.
+func normalizeIdentifier(s string) string {
+	return strings.ToLower(strings.TrimSpace(s))
 }
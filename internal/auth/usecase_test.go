@@ -0,0 +1,1817 @@
+package auth
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"go-boilerplate/internal/infra/audit"
+	infraAuth "go-boilerplate/internal/infra/auth"
+	"go-boilerplate/internal/infra/mail"
+	"go-boilerplate/internal/infra/oidc"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// MockRepository is a manual mock of the Repository interface.
+type MockRepository struct {
+	mock.Mock
+}
+
+func (m *MockRepository) Create(ctx context.Context, token *RefreshToken) error {
+	args := m.Called(ctx, token)
+	return args.Error(0)
+}
+
+func (m *MockRepository) GetByToken(ctx context.Context, tokenStr string) (*RefreshToken, error) {
+	args := m.Called(ctx, tokenStr)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*RefreshToken), args.Error(1)
+}
+
+func (m *MockRepository) DeleteByToken(ctx context.Context, tokenStr string) error {
+	args := m.Called(ctx, tokenStr)
+	return args.Error(0)
+}
+
+func (m *MockRepository) Revoke(ctx context.Context, id uuid.UUID) (bool, error) {
+	args := m.Called(ctx, id)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRepository) RevokeFamily(ctx context.Context, familyID uuid.UUID) error {
+	args := m.Called(ctx, familyID)
+	return args.Error(0)
+}
+
+func (m *MockRepository) RevokeUserFamily(ctx context.Context, userID, familyID uuid.UUID) (bool, error) {
+	args := m.Called(ctx, userID, familyID)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRepository) ListActiveByUserID(ctx context.Context, userID uuid.UUID) ([]RefreshToken, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).([]RefreshToken), args.Error(1)
+}
+
+func (m *MockRepository) DeleteByUserID(ctx context.Context, userID uuid.UUID) error {
+	args := m.Called(ctx, userID)
+	return args.Error(0)
+}
+
+func (m *MockRepository) PurgeExpired(ctx context.Context, cutoff time.Time, batchSize int) (int64, error) {
+	args := m.Called(ctx, cutoff, batchSize)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+// MockUserRepository is a manual mock of the UserRepository interface.
+type MockUserRepository struct {
+	mock.Mock
+}
+
+func (m *MockUserRepository) Create(ctx context.Context, user *User) error {
+	args := m.Called(ctx, user)
+	return args.Error(0)
+}
+
+func (m *MockUserRepository) GetByID(ctx context.Context, id uuid.UUID) (*User, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*User), args.Error(1)
+}
+
+func (m *MockUserRepository) GetByUsername(ctx context.Context, username string) (*User, error) {
+	args := m.Called(ctx, username)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*User), args.Error(1)
+}
+
+func (m *MockUserRepository) UpdateRole(ctx context.Context, id uuid.UUID, role infraAuth.Role) error {
+	args := m.Called(ctx, id, role)
+	return args.Error(0)
+}
+
+// MockLoginAttemptRepository is a manual mock of the LoginAttemptRepository interface.
+type MockLoginAttemptRepository struct {
+	mock.Mock
+}
+
+func (m *MockLoginAttemptRepository) Find(ctx context.Context, keys []string) ([]LoginAttempt, error) {
+	args := m.Called(ctx, keys)
+	return args.Get(0).([]LoginAttempt), args.Error(1)
+}
+
+func (m *MockLoginAttemptRepository) RecordFailure(ctx context.Context, key string, now, windowStart time.Time) (*LoginAttempt, error) {
+	args := m.Called(ctx, key, now, windowStart)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*LoginAttempt), args.Error(1)
+}
+
+func (m *MockLoginAttemptRepository) Lock(ctx context.Context, key string, until time.Time) error {
+	args := m.Called(ctx, key, until)
+	return args.Error(0)
+}
+
+func (m *MockLoginAttemptRepository) Delete(ctx context.Context, key string) (bool, error) {
+	args := m.Called(ctx, key)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockLoginAttemptRepository) ListActive(ctx context.Context, now, windowStart time.Time) ([]LoginAttempt, error) {
+	args := m.Called(ctx, now, windowStart)
+	return args.Get(0).([]LoginAttempt), args.Error(1)
+}
+
+func (m *MockLoginAttemptRepository) PurgeStale(ctx context.Context, windowStart time.Time) (int64, error) {
+	args := m.Called(ctx, windowStart)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockUserRepository) SetTOTPSecret(ctx context.Context, id uuid.UUID, encryptedSecret string) error {
+	args := m.Called(ctx, id, encryptedSecret)
+	return args.Error(0)
+}
+
+func (m *MockUserRepository) EnableMFA(ctx context.Context, id uuid.UUID, step int64) error {
+	args := m.Called(ctx, id, step)
+	return args.Error(0)
+}
+
+func (m *MockUserRepository) DisableMFA(ctx context.Context, id uuid.UUID) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockUserRepository) AdvanceTOTPStep(ctx context.Context, id uuid.UUID, step int64) (bool, error) {
+	args := m.Called(ctx, id, step)
+	return args.Bool(0), args.Error(1)
+}
+
+// MockRecoveryCodeRepository is a manual mock of the RecoveryCodeRepository interface.
+type MockRecoveryCodeRepository struct {
+	mock.Mock
+}
+
+func (m *MockRecoveryCodeRepository) Replace(ctx context.Context, userID uuid.UUID, codes []RecoveryCode) error {
+	args := m.Called(ctx, userID, codes)
+	return args.Error(0)
+}
+
+func (m *MockRecoveryCodeRepository) Consume(ctx context.Context, userID uuid.UUID, codeHash string) (bool, error) {
+	args := m.Called(ctx, userID, codeHash)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRecoveryCodeRepository) DeleteByUserID(ctx context.Context, userID uuid.UUID) error {
+	args := m.Called(ctx, userID)
+	return args.Error(0)
+}
+
+func (m *MockUserRepository) GetByEmail(ctx context.Context, email string) (*User, error) {
+	args := m.Called(ctx, email)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*User), args.Error(1)
+}
+
+func (m *MockUserRepository) UpdatePassword(ctx context.Context, id uuid.UUID, passwordHash string) error {
+	args := m.Called(ctx, id, passwordHash)
+	return args.Error(0)
+}
+
+func (m *MockUserRepository) MarkEmailVerified(ctx context.Context, id uuid.UUID) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+// MockActionTokenRepository is a manual mock of the ActionTokenRepository interface.
+type MockActionTokenRepository struct {
+	mock.Mock
+}
+
+func (m *MockActionTokenRepository) Create(ctx context.Context, token *ActionToken) error {
+	args := m.Called(ctx, token)
+	return args.Error(0)
+}
+
+func (m *MockActionTokenRepository) Consume(ctx context.Context, tokenHash, purpose string) (*ActionToken, error) {
+	args := m.Called(ctx, tokenHash, purpose)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*ActionToken), args.Error(1)
+}
+
+func (m *MockActionTokenRepository) InvalidateForUser(ctx context.Context, userID uuid.UUID, purpose string) error {
+	args := m.Called(ctx, userID, purpose)
+	return args.Error(0)
+}
+
+func (m *MockActionTokenRepository) PurgeExpired(ctx context.Context, cutoff time.Time) (int64, error) {
+	args := m.Called(ctx, cutoff)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+// MockAPIKeyRepository is a manual mock of the APIKeyRepository interface.
+type MockAPIKeyRepository struct {
+	mock.Mock
+}
+
+func (m *MockAPIKeyRepository) Create(ctx context.Context, key *APIKey) error {
+	args := m.Called(ctx, key)
+	return args.Error(0)
+}
+
+func (m *MockAPIKeyRepository) GetByHash(ctx context.Context, keyHash string) (*APIKey, error) {
+	args := m.Called(ctx, keyHash)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*APIKey), args.Error(1)
+}
+
+func (m *MockAPIKeyRepository) ListByUserID(ctx context.Context, userID uuid.UUID) ([]APIKey, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).([]APIKey), args.Error(1)
+}
+
+func (m *MockAPIKeyRepository) Revoke(ctx context.Context, userID, id uuid.UUID) (bool, error) {
+	args := m.Called(ctx, userID, id)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockAPIKeyRepository) TouchLastUsed(ctx context.Context, id uuid.UUID, at time.Time) error {
+	args := m.Called(ctx, id, at)
+	return args.Error(0)
+}
+
+// MockOIDCStateRepository is a manual mock of the OIDCStateRepository interface.
+type MockOIDCStateRepository struct {
+	mock.Mock
+}
+
+func (m *MockOIDCStateRepository) Create(ctx context.Context, state *OIDCLoginState) error {
+	args := m.Called(ctx, state)
+	return args.Error(0)
+}
+
+func (m *MockOIDCStateRepository) Consume(ctx context.Context, stateHash string) (*OIDCLoginState, error) {
+	args := m.Called(ctx, stateHash)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*OIDCLoginState), args.Error(1)
+}
+
+func (m *MockOIDCStateRepository) PurgeExpired(ctx context.Context, cutoff time.Time) (int64, error) {
+	args := m.Called(ctx, cutoff)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+// MockExternalIdentityRepository is a manual mock of the ExternalIdentityRepository interface.
+type MockExternalIdentityRepository struct {
+	mock.Mock
+}
+
+func (m *MockExternalIdentityRepository) Create(ctx context.Context, identity *ExternalIdentity) error {
+	args := m.Called(ctx, identity)
+	return args.Error(0)
+}
+
+func (m *MockExternalIdentityRepository) GetByProviderSubject(ctx context.Context, provider, subject string) (*ExternalIdentity, error) {
+	args := m.Called(ctx, provider, subject)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*ExternalIdentity), args.Error(1)
+}
+
+func (m *MockExternalIdentityRepository) TouchLastLogin(ctx context.Context, id uuid.UUID, at time.Time) error {
+	args := m.Called(ctx, id, at)
+	return args.Error(0)
+}
+
+// MockOIDCProvider is a manual mock of the oidc.Provider interface.
+type MockOIDCProvider struct {
+	mock.Mock
+}
+
+func (m *MockOIDCProvider) Name() string {
+	return "corp"
+}
+
+func (m *MockOIDCProvider) AuthCodeURL(ctx context.Context, state, nonce, codeChallenge string) (string, error) {
+	args := m.Called(ctx, state, nonce, codeChallenge)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockOIDCProvider) Exchange(ctx context.Context, code, codeVerifier, nonce string) (*oidc.IDTokenClaims, error) {
+	args := m.Called(ctx, code, codeVerifier, nonce)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*oidc.IDTokenClaims), args.Error(1)
+}
+
+var testTokenHasher = infraAuth.NewHMACTokenHasher("test-pepper")
+
+var testLockoutPolicy = LockoutPolicy{
+	MaxAccountFailures: 3,
+	MaxIPFailures:      10,
+	FailureWindow:      15 * time.Minute,
+	BaseLockout:        30 * time.Second,
+	MaxLockout:         time.Hour,
+}
+
+var testSecrets, _ = infraAuth.NewAESGCMSecretBox("test-mfa-key")
+
+// permissiveLockouts returns login throttling that never locks anyone out.
+func permissiveLockouts() *MockLoginAttemptRepository {
+	lockouts := new(MockLoginAttemptRepository)
+	lockouts.On("Find", mock.Anything, mock.Anything).Return([]LoginAttempt{}, nil).Maybe()
+	lockouts.On("RecordFailure", mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return(&LoginAttempt{Failures: 1}, nil).Maybe()
+	lockouts.On("Delete", mock.Anything, mock.Anything).Return(true, nil).Maybe()
+	return lockouts
+}
+
+// testDeps holds the mocks behind a usecase built by newTestDeps.
+type testDeps struct {
+	repo          *MockRepository
+	userRepo      *MockUserRepository
+	lockouts      *MockLoginAttemptRepository
+	recoveryCodes *MockRecoveryCodeRepository
+	actionTokens  *MockActionTokenRepository
+	mailer        *mail.MemoryMailer
+	apiKeys       *MockAPIKeyRepository
+	oidcProvider  *MockOIDCProvider
+	oidcStates    *MockOIDCStateRepository
+	identities    *MockExternalIdentityRepository
+	revocations   infraAuth.RevocationStore
+	audit         *audit.MemoryLogger
+}
+
+func newTestDeps() *testDeps {
+	return &testDeps{
+		repo:          new(MockRepository),
+		userRepo:      new(MockUserRepository),
+		lockouts:      permissiveLockouts(),
+		recoveryCodes: new(MockRecoveryCodeRepository),
+		actionTokens:  new(MockActionTokenRepository),
+		mailer:        mail.NewMemoryMailer(),
+		apiKeys:       new(MockAPIKeyRepository),
+		oidcProvider:  new(MockOIDCProvider),
+		oidcStates:    new(MockOIDCStateRepository),
+		identities:    new(MockExternalIdentityRepository),
+		revocations:   infraAuth.NewMemoryRevocationStore(),
+		audit:         audit.NewMemoryLogger(),
+	}
+}
+
+func (d *testDeps) usecase() Usecase {
+	return NewUsecase(
+		d.repo,
+		d.userRepo,
+		infraAuth.NewJWTService("test-secret", 1),
+		infraAuth.NewBcryptHasher(bcrypt.MinCost),
+		testTokenHasher,
+		d.revocations,
+		d.lockouts,
+		testLockoutPolicy,
+		d.recoveryCodes,
+		testSecrets,
+		"go-boilerplate",
+		d.actionTokens,
+		d.mailer,
+		"https://app.example.com",
+		d.apiKeys,
+		map[string]oidc.Provider{"corp": d.oidcProvider},
+		d.oidcStates,
+		d.identities,
+		d.audit,
+	)
+}
+
+func newTestUsecase(repo *MockRepository, userRepo *MockUserRepository) Usecase {
+	d := newTestDeps()
+	d.repo, d.userRepo = repo, userRepo
+	return d.usecase()
+}
+
+func newTestUsecaseWithLockouts(repo *MockRepository, userRepo *MockUserRepository, lockouts *MockLoginAttemptRepository) Usecase {
+	d := newTestDeps()
+	d.repo, d.userRepo, d.lockouts = repo, userRepo, lockouts
+	return d.usecase()
+}
+
+func TestLogin_Success(t *testing.T) {
+	// Arrange
+	mockRepo := new(MockRepository)
+	mockUserRepo := new(MockUserRepository)
+	u := newTestUsecase(mockRepo, mockUserRepo)
+
+	hash, _ := infraAuth.NewBcryptHasher(bcrypt.MinCost).Hash("password123")
+	user := &User{ID: uuid.New(), Username: "alice", PasswordHash: hash}
+
+	var stored *RefreshToken
+	mockUserRepo.On("GetByUsername", mock.Anything, "alice").Return(user, nil)
+	mockRepo.On("Create", mock.Anything, mock.AnythingOfType("*auth.RefreshToken")).
+		Return(nil).
+		Run(func(args mock.Arguments) {
+			stored = args.Get(1).(*RefreshToken)
+		})
+
+	// Act
+	result, err := u.Login(context.Background(), " Alice ", "password123", nil, ClientInfo{UserAgent: "curl/8.0", IPAddress: "203.0.113.7"})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.NotEmpty(t, result.AccessToken)
+	assert.NotEmpty(t, result.RefreshToken)
+	assert.Empty(t, result.MFAToken)
+	assert.Equal(t, user.ID, stored.UserID)
+	assert.NotEqual(t, uuid.Nil, stored.FamilyID)
+	assert.Equal(t, testTokenHasher.Hash(result.RefreshToken), stored.TokenHash)
+	assert.Equal(t, "curl/8.0", stored.UserAgent)
+	assert.Equal(t, "203.0.113.7", stored.IPAddress)
+	mockRepo.AssertExpectations(t)
+	mockUserRepo.AssertExpectations(t)
+}
+
+func TestLogin_UnknownUser(t *testing.T) {
+	// Arrange
+	mockRepo := new(MockRepository)
+	mockUserRepo := new(MockUserRepository)
+	u := newTestUsecase(mockRepo, mockUserRepo)
+
+	mockUserRepo.On("GetByUsername", mock.Anything, "mallory").Return(nil, ErrUserNotFound)
+
+	// Act
+	_, err := u.Login(context.Background(), "mallory", "password123", nil, ClientInfo{})
+
+	// Assert
+	assert.Equal(t, ErrInvalidCredentials, err)
+	mockRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+}
+
+func TestLogin_LocksAccountAtThreshold(t *testing.T) {
+	// Arrange
+	mockUserRepo := new(MockUserRepository)
+	lockouts := new(MockLoginAttemptRepository)
+	u := newTestUsecaseWithLockouts(new(MockRepository), mockUserRepo, lockouts)
+
+	hash, _ := infraAuth.NewBcryptHasher(bcrypt.MinCost).Hash("password123")
+	user := &User{ID: uuid.New(), Username: "alice", PasswordHash: hash}
+
+	mockUserRepo.On("GetByUsername", mock.Anything, "alice").Return(user, nil)
+	lockouts.On("Find", mock.Anything, []string{"account:alice", "ip:203.0.113.7"}).Return([]LoginAttempt{}, nil)
+	lockouts.On("RecordFailure", mock.Anything, "account:alice", mock.Anything, mock.Anything).
+		Return(&LoginAttempt{Key: "account:alice", Failures: 3}, nil)
+	lockouts.On("RecordFailure", mock.Anything, "ip:203.0.113.7", mock.Anything, mock.Anything).
+		Return(&LoginAttempt{Key: "ip:203.0.113.7", Failures: 3}, nil)
+	lockouts.On("Lock", mock.Anything, "account:alice", mock.AnythingOfType("time.Time")).Return(nil)
+
+	// Act
+	_, err := u.Login(context.Background(), "alice", "wrong-password", nil, ClientInfo{IPAddress: "203.0.113.7"})
+
+	// Assert
+	var locked *LockedError
+	assert.ErrorAs(t, err, &locked)
+	assert.ErrorIs(t, err, ErrTooManyAttempts)
+	assert.Equal(t, testLockoutPolicy.BaseLockout, locked.RetryAfter)
+	lockouts.AssertExpectations(t)
+	lockouts.AssertNotCalled(t, "Lock", mock.Anything, "ip:203.0.113.7", mock.Anything)
+}
+
+func TestLogin_LockedAccountRejectsCorrectPassword(t *testing.T) {
+	// Arrange
+	mockUserRepo := new(MockUserRepository)
+	lockouts := new(MockLoginAttemptRepository)
+	u := newTestUsecaseWithLockouts(new(MockRepository), mockUserRepo, lockouts)
+
+	lockedUntil := time.Now().Add(2 * time.Minute)
+	lockouts.On("Find", mock.Anything, []string{"account:alice"}).
+		Return([]LoginAttempt{{Key: "account:alice", Failures: 4, LockedUntil: &lockedUntil}}, nil)
+
+	// Act
+	_, err := u.Login(context.Background(), "alice", "password123", nil, ClientInfo{})
+
+	// Assert
+	var locked *LockedError
+	assert.ErrorAs(t, err, &locked)
+	assert.InDelta(t, 2*time.Minute, locked.RetryAfter, float64(time.Second))
+	assert.Equal(t, 120, locked.RetryAfterSeconds())
+	mockUserRepo.AssertNotCalled(t, "GetByUsername", mock.Anything, mock.Anything)
+}
+
+func TestLogin_SuccessClearsAccountFailures(t *testing.T) {
+	// Arrange
+	mockRepo := new(MockRepository)
+	mockUserRepo := new(MockUserRepository)
+	lockouts := new(MockLoginAttemptRepository)
+	u := newTestUsecaseWithLockouts(mockRepo, mockUserRepo, lockouts)
+
+	hash, _ := infraAuth.NewBcryptHasher(bcrypt.MinCost).Hash("password123")
+	user := &User{ID: uuid.New(), Username: "alice", PasswordHash: hash}
+
+	mockUserRepo.On("GetByUsername", mock.Anything, "alice").Return(user, nil)
+	mockRepo.On("Create", mock.Anything, mock.Anything).Return(nil)
+	lockouts.On("Find", mock.Anything, mock.Anything).
+		Return([]LoginAttempt{{Key: "account:alice", Failures: 2}}, nil)
+	lockouts.On("Delete", mock.Anything, "account:alice").Return(true, nil)
+
+	// Act
+	_, err := u.Login(context.Background(), "alice", "password123", nil, ClientInfo{IPAddress: "203.0.113.7"})
+
+	// Assert
+	assert.NoError(t, err)
+	lockouts.AssertExpectations(t)
+	lockouts.AssertNotCalled(t, "Delete", mock.Anything, "ip:203.0.113.7")
+}
+
+// newMFAUser returns a user with MFA enabled and the plaintext TOTP secret.
+func newMFAUser(t *testing.T) (*User, string) {
+	t.Helper()
+	secret, err := infraAuth.GenerateTOTPSecret()
+	assert.NoError(t, err)
+	encrypted, err := testSecrets.Seal(secret)
+	assert.NoError(t, err)
+	hash, _ := infraAuth.NewBcryptHasher(bcrypt.MinCost).Hash("password123")
+	enabledAt := time.Now()
+	return &User{
+		ID:           uuid.New(),
+		Username:     "alice",
+		PasswordHash: hash,
+		TOTPSecret:   encrypted,
+		MFAEnabledAt: &enabledAt,
+	}, secret
+}
+
+func TestLogin_MFAEnabledReturnsChallenge(t *testing.T) {
+	// Arrange
+	mockRepo := new(MockRepository)
+	mockUserRepo := new(MockUserRepository)
+	lockouts := new(MockLoginAttemptRepository)
+	u := newTestUsecaseWithLockouts(mockRepo, mockUserRepo, lockouts)
+	user, _ := newMFAUser(t)
+
+	mockUserRepo.On("GetByUsername", mock.Anything, "alice").Return(user, nil)
+	lockouts.On("Find", mock.Anything, mock.Anything).Return([]LoginAttempt{}, nil)
+
+	// Act
+	result, err := u.Login(context.Background(), "alice", "password123", nil, ClientInfo{})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.NotEmpty(t, result.MFAToken)
+	assert.Empty(t, result.AccessToken)
+	assert.Empty(t, result.RefreshToken)
+	mockRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+	lockouts.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything)
+}
+
+func TestVerifyMFA_TOTPIssuesPairOnce(t *testing.T) {
+	// Arrange
+	mockRepo := new(MockRepository)
+	mockUserRepo := new(MockUserRepository)
+	u := newTestUsecase(mockRepo, mockUserRepo)
+	user, secret := newMFAUser(t)
+
+	mockUserRepo.On("GetByUsername", mock.Anything, "alice").Return(user, nil)
+	mockUserRepo.On("GetByID", mock.Anything, user.ID).Return(user, nil)
+	mockUserRepo.On("AdvanceTOTPStep", mock.Anything, user.ID, mock.AnythingOfType("int64")).Return(true, nil)
+	mockRepo.On("Create", mock.Anything, mock.AnythingOfType("*auth.RefreshToken")).Return(nil)
+
+	challenge, err := u.Login(context.Background(), "alice", "password123", nil, ClientInfo{})
+	assert.NoError(t, err)
+	code, err := infraAuth.GenerateTOTPCode(secret, time.Now())
+	assert.NoError(t, err)
+
+	// Act
+	result, err := u.VerifyMFA(context.Background(), challenge.MFAToken, code, ClientInfo{})
+	_, replayErr := u.VerifyMFA(context.Background(), challenge.MFAToken, code, ClientInfo{})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.NotEmpty(t, result.AccessToken)
+	assert.NotEmpty(t, result.RefreshToken)
+	assert.ErrorIs(t, replayErr, ErrInvalidToken)
+	mockUserRepo.AssertExpectations(t)
+}
+
+func TestVerifyMFA_ReplayedTOTPStepRejected(t *testing.T) {
+	// Arrange
+	mockUserRepo := new(MockUserRepository)
+	u := newTestUsecase(new(MockRepository), mockUserRepo)
+	user, secret := newMFAUser(t)
+
+	mockUserRepo.On("GetByUsername", mock.Anything, "alice").Return(user, nil)
+	mockUserRepo.On("GetByID", mock.Anything, user.ID).Return(user, nil)
+	mockUserRepo.On("AdvanceTOTPStep", mock.Anything, user.ID, mock.Anything).Return(false, nil)
+
+	challenge, _ := u.Login(context.Background(), "alice", "password123", nil, ClientInfo{})
+	code, _ := infraAuth.GenerateTOTPCode(secret, time.Now())
+
+	// Act
+	_, err := u.VerifyMFA(context.Background(), challenge.MFAToken, code, ClientInfo{})
+
+	// Assert
+	assert.ErrorIs(t, err, ErrInvalidMFACode)
+}
+
+func TestVerifyMFA_RecoveryCode(t *testing.T) {
+	// Arrange
+	d := newTestDeps()
+	mockRepo, mockUserRepo, recoveryCodes := d.repo, d.userRepo, d.recoveryCodes
+	u := d.usecase()
+	user, _ := newMFAUser(t)
+
+	mockUserRepo.On("GetByUsername", mock.Anything, "alice").Return(user, nil)
+	mockUserRepo.On("GetByID", mock.Anything, user.ID).Return(user, nil)
+	recoveryCodes.On("Consume", mock.Anything, user.ID, testTokenHasher.Hash("abcde12345")).Return(true, nil)
+	mockRepo.On("Create", mock.Anything, mock.Anything).Return(nil)
+
+	challenge, _ := u.Login(context.Background(), "alice", "password123", nil, ClientInfo{})
+
+	// Act
+	result, err := u.VerifyMFA(context.Background(), challenge.MFAToken, "ABCDE-12345", ClientInfo{})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.NotEmpty(t, result.AccessToken)
+	recoveryCodes.AssertExpectations(t)
+}
+
+func TestEnableMFA_ReturnsRecoveryCodes(t *testing.T) {
+	// Arrange
+	d := newTestDeps()
+	mockUserRepo, recoveryCodes := d.userRepo, d.recoveryCodes
+	u := d.usecase()
+	user, secret := newMFAUser(t)
+	user.MFAEnabledAt = nil
+
+	var stored []RecoveryCode
+	mockUserRepo.On("GetByID", mock.Anything, user.ID).Return(user, nil)
+	mockUserRepo.On("EnableMFA", mock.Anything, user.ID, mock.AnythingOfType("int64")).Return(nil)
+	recoveryCodes.On("Replace", mock.Anything, user.ID, mock.Anything).
+		Return(nil).
+		Run(func(args mock.Arguments) {
+			stored = args.Get(2).([]RecoveryCode)
+		})
+	code, _ := infraAuth.GenerateTOTPCode(secret, time.Now())
+
+	// Act
+	codes, err := u.EnableMFA(context.Background(), user.ID, code)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Len(t, codes, recoveryCodeCount)
+	assert.Len(t, stored, recoveryCodeCount)
+	assert.Equal(t, testTokenHasher.Hash(normalizeRecoveryCode(codes[0])), stored[0].CodeHash)
+	mockUserRepo.AssertExpectations(t)
+}
+
+func TestEnableMFA_WrongCode(t *testing.T) {
+	// Arrange
+	mockUserRepo := new(MockUserRepository)
+	u := newTestUsecase(new(MockRepository), mockUserRepo)
+	user, _ := newMFAUser(t)
+	user.MFAEnabledAt = nil
+
+	mockUserRepo.On("GetByID", mock.Anything, user.ID).Return(user, nil)
+
+	// Act
+	_, err := u.EnableMFA(context.Background(), user.ID, "000000")
+
+	// Assert
+	assert.ErrorIs(t, err, ErrInvalidMFACode)
+	mockUserRepo.AssertNotCalled(t, "EnableMFA", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestForgotPassword_EmailsResetLink(t *testing.T) {
+	// Arrange
+	d := newTestDeps()
+	u := d.usecase()
+	user := &User{ID: uuid.New(), Username: "alice", Email: "alice@example.com"}
+
+	var stored *ActionToken
+	d.userRepo.On("GetByEmail", mock.Anything, "alice@example.com").Return(user, nil)
+	d.actionTokens.On("InvalidateForUser", mock.Anything, user.ID, PurposePasswordReset).Return(nil)
+	d.actionTokens.On("Create", mock.Anything, mock.AnythingOfType("*auth.ActionToken")).
+		Return(nil).
+		Run(func(args mock.Arguments) {
+			stored = args.Get(1).(*ActionToken)
+		})
+
+	// Act
+	err := u.ForgotPassword(context.Background(), " Alice@Example.com ")
+
+	// Assert
+	assert.NoError(t, err)
+	sent := d.mailer.Sent()
+	assert.Len(t, sent, 1)
+	assert.Equal(t, "alice@example.com", sent[0].To)
+
+	_, tokenStr, found := strings.Cut(sent[0].Body, "https://app.example.com/reset-password?token=")
+	assert.True(t, found)
+	tokenStr, _, _ = strings.Cut(tokenStr, "\n")
+	tokenStr, _ = url.QueryUnescape(tokenStr)
+	assert.Equal(t, testTokenHasher.Hash(tokenStr), stored.TokenHash)
+	assert.Equal(t, PurposePasswordReset, stored.Purpose)
+	assert.WithinDuration(t, time.Now().Add(passwordResetTTL), stored.ExpiresAt, time.Minute)
+}
+
+func TestForgotPassword_UnknownEmailSendsNothing(t *testing.T) {
+	// Arrange
+	d := newTestDeps()
+	u := d.usecase()
+	d.userRepo.On("GetByEmail", mock.Anything, "nobody@example.com").Return(nil, ErrUserNotFound)
+
+	// Act
+	err := u.ForgotPassword(context.Background(), "nobody@example.com")
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Empty(t, d.mailer.Sent())
+}
+
+func TestResetPassword_RevokesAllSessions(t *testing.T) {
+	// Arrange
+	d := newTestDeps()
+	u := d.usecase()
+	userID := uuid.New()
+
+	d.actionTokens.On("Consume", mock.Anything, testTokenHasher.Hash("reset-token"), PurposePasswordReset).
+		Return(&ActionToken{UserID: userID, Purpose: PurposePasswordReset}, nil)
+	d.userRepo.On("UpdatePassword", mock.Anything, userID, mock.AnythingOfType("string")).Return(nil)
+	d.repo.On("DeleteByUserID", mock.Anything, userID).Return(nil)
+
+	// Act
+	err := u.ResetPassword(context.Background(), "reset-token", "n3w-passw0rd")
+
+	// Assert
+	assert.NoError(t, err)
+	d.userRepo.AssertExpectations(t)
+	d.repo.AssertExpectations(t)
+}
+
+func TestResetPassword_InvalidToken(t *testing.T) {
+	// Arrange
+	d := newTestDeps()
+	u := d.usecase()
+	d.actionTokens.On("Consume", mock.Anything, mock.Anything, PurposePasswordReset).Return(nil, ErrInvalidToken)
+
+	// Act
+	err := u.ResetPassword(context.Background(), "used-token", "n3w-passw0rd")
+
+	// Assert
+	assert.ErrorIs(t, err, ErrInvalidToken)
+	d.userRepo.AssertNotCalled(t, "UpdatePassword", mock.Anything, mock.Anything, mock.Anything)
+	d.repo.AssertNotCalled(t, "DeleteByUserID", mock.Anything, mock.Anything)
+}
+
+func TestVerifyEmail(t *testing.T) {
+	// Arrange
+	d := newTestDeps()
+	u := d.usecase()
+	userID := uuid.New()
+
+	d.actionTokens.On("Consume", mock.Anything, testTokenHasher.Hash("verify-token"), PurposeEmailVerification).
+		Return(&ActionToken{UserID: userID, Purpose: PurposeEmailVerification}, nil)
+	d.userRepo.On("MarkEmailVerified", mock.Anything, userID).Return(nil)
+
+	// Act
+	err := u.VerifyEmail(context.Background(), "verify-token")
+
+	// Assert
+	assert.NoError(t, err)
+	d.userRepo.AssertExpectations(t)
+}
+
+func TestCreateAPIKey_RejectsScopeBeyondRole(t *testing.T) {
+	// Arrange
+	d := newTestDeps()
+	u := d.usecase()
+	user := &User{ID: uuid.New(), Role: infraAuth.RoleReadOnly}
+	d.userRepo.On("GetByID", mock.Anything, user.ID).Return(user, nil)
+
+	// Act
+	_, _, err := u.CreateAPIKey(context.Background(), user.ID, "bot", []infraAuth.Permission{infraAuth.PermPortfoliosWrite}, time.Hour)
+
+	// Assert
+	assert.ErrorIs(t, err, ErrInvalidScope)
+	d.apiKeys.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+}
+
+func TestCreateAPIKey_StoresHashAndDefaultsToRoleScopes(t *testing.T) {
+	// Arrange
+	d := newTestDeps()
+	u := d.usecase()
+	user := &User{ID: uuid.New(), Role: infraAuth.RoleUser}
+	d.userRepo.On("GetByID", mock.Anything, user.ID).Return(user, nil)
+	d.apiKeys.On("Create", mock.Anything, mock.AnythingOfType("*auth.APIKey")).Return(nil)
+
+	// Act
+	key, rawKey, err := u.CreateAPIKey(context.Background(), user.ID, "bot", nil, 24*time.Hour)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(rawKey, key.Prefix+"_"))
+	assert.Equal(t, testTokenHasher.Hash(rawKey), key.KeyHash)
+	assert.ElementsMatch(t, infraAuth.RoleUser.Permissions(), key.Scopes)
+	assert.WithinDuration(t, time.Now().Add(24*time.Hour), key.ExpiresAt, time.Minute)
+}
+
+func TestAuthenticateAPIKey(t *testing.T) {
+	userID := uuid.New()
+	past := time.Now().Add(-time.Hour)
+	tests := []struct {
+		name       string
+		key        *APIKey
+		role       infraAuth.Role
+		wantErr    error
+		wantScopes []infraAuth.Permission
+	}{
+		{
+			name:       "valid key",
+			key:        &APIKey{UserID: userID, Scopes: []infraAuth.Permission{infraAuth.PermPortfoliosRead}, ExpiresAt: time.Now().Add(time.Hour)},
+			role:       infraAuth.RoleUser,
+			wantScopes: []infraAuth.Permission{infraAuth.PermPortfoliosRead},
+		},
+		{
+			name:       "scopes narrowed after role downgrade",
+			key:        &APIKey{UserID: userID, Scopes: []infraAuth.Permission{infraAuth.PermPortfoliosRead, infraAuth.PermPortfoliosWrite}, ExpiresAt: time.Now().Add(time.Hour)},
+			role:       infraAuth.RoleReadOnly,
+			wantScopes: []infraAuth.Permission{infraAuth.PermPortfoliosRead},
+		},
+		{
+			name:    "expired key",
+			key:     &APIKey{UserID: userID, ExpiresAt: past},
+			role:    infraAuth.RoleUser,
+			wantErr: infraAuth.ErrInvalidAPIKey,
+		},
+		{
+			name:    "revoked key",
+			key:     &APIKey{UserID: userID, ExpiresAt: time.Now().Add(time.Hour), RevokedAt: &past},
+			role:    infraAuth.RoleUser,
+			wantErr: infraAuth.ErrInvalidAPIKey,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			d := newTestDeps()
+			u := d.usecase()
+			tt.key.ID = uuid.New()
+			d.apiKeys.On("GetByHash", mock.Anything, testTokenHasher.Hash("gbk_raw")).Return(tt.key, nil)
+			d.apiKeys.On("TouchLastUsed", mock.Anything, tt.key.ID, mock.Anything).Return(nil).Maybe()
+			d.userRepo.On("GetByID", mock.Anything, userID).Return(&User{ID: userID, Role: tt.role}, nil).Maybe()
+
+			// Act
+			principal, err := u.AuthenticateAPIKey(context.Background(), "gbk_raw")
+
+			// Assert
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, userID.String(), principal.UserID)
+			assert.Equal(t, tt.role, principal.Role)
+			assert.Equal(t, tt.wantScopes, principal.Scopes)
+		})
+	}
+}
+
+func TestLockoutPolicy_DoublesUpToMax(t *testing.T) {
+	tests := []struct {
+		name     string
+		failures int
+		want     time.Duration
+	}{
+		{name: "below threshold", failures: 2, want: 0},
+		{name: "at threshold", failures: 3, want: 30 * time.Second},
+		{name: "one over", failures: 4, want: time.Minute},
+		{name: "three over", failures: 6, want: 4 * time.Minute},
+		{name: "capped", failures: 20, want: time.Hour},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, testLockoutPolicy.lockoutFor(tt.failures, testLockoutPolicy.MaxAccountFailures))
+		})
+	}
+}
+
+func TestRefreshToken_RotatesWithinFamily(t *testing.T) {
+	// Arrange
+	mockRepo := new(MockRepository)
+	mockUserRepo := new(MockUserRepository)
+	u := newTestUsecase(mockRepo, mockUserRepo)
+
+	user := &User{ID: uuid.New(), Role: infraAuth.RoleReadOnly}
+	existing := &RefreshToken{
+		ID:               uuid.New(),
+		UserID:           user.ID,
+		FamilyID:         uuid.New(),
+		TokenHash:        testTokenHasher.Hash("old-token"),
+		ExpiresAt:        time.Now().Add(time.Hour),
+		SessionStartedAt: time.Now().Add(-time.Hour),
+	}
+
+	mockRepo.On("GetByToken", mock.Anything, testTokenHasher.Hash("old-token")).Return(existing, nil)
+	mockRepo.On("Revoke", mock.Anything, existing.ID).Return(true, nil)
+	mockUserRepo.On("GetByID", mock.Anything, user.ID).Return(user, nil)
+	mockRepo.On("Create", mock.Anything, mock.AnythingOfType("*auth.RefreshToken")).
+		Return(nil).
+		Run(func(args mock.Arguments) {
+			token := args.Get(1).(*RefreshToken)
+			assert.Equal(t, existing.FamilyID, token.FamilyID)
+			assert.Equal(t, existing.ID, *token.ParentID)
+			assert.Equal(t, existing.SessionStartedAt, token.SessionStartedAt)
+			assert.Equal(t, "curl/8.1", token.UserAgent)
+			assert.NotEqual(t, existing.TokenHash, token.TokenHash)
+		})
+
+	// Act
+	accessToken, refreshToken, err := u.RefreshToken(context.Background(), "old-token", nil, ClientInfo{UserAgent: "curl/8.1"})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.NotEmpty(t, accessToken)
+	assert.NotEqual(t, "old-token", refreshToken)
+	mockRepo.AssertExpectations(t)
+	mockUserRepo.AssertExpectations(t)
+}
+
+func TestRefreshToken_ReuseRevokesFamily(t *testing.T) {
+	// Arrange
+	mockRepo := new(MockRepository)
+	u := newTestUsecase(mockRepo, new(MockUserRepository))
+
+	revokedAt := time.Now().Add(-time.Minute)
+	existing := &RefreshToken{
+		ID:        uuid.New(),
+		UserID:    uuid.New(),
+		FamilyID:  uuid.New(),
+		TokenHash: testTokenHasher.Hash("stolen-token"),
+		ExpiresAt: time.Now().Add(time.Hour),
+		RevokedAt: &revokedAt,
+	}
+
+	mockRepo.On("GetByToken", mock.Anything, testTokenHasher.Hash("stolen-token")).Return(existing, nil)
+	mockRepo.On("RevokeFamily", mock.Anything, existing.FamilyID).Return(nil)
+
+	// Act
+	_, _, err := u.RefreshToken(context.Background(), "stolen-token", nil, ClientInfo{})
+
+	// Assert
+	assert.Equal(t, ErrTokenReused, err)
+	mockRepo.AssertExpectations(t)
+	mockRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+}
+
+func TestUpdateRole_RejectsUnknownRole(t *testing.T) {
+	// Arrange
+	mockUserRepo := new(MockUserRepository)
+	u := newTestUsecase(new(MockRepository), mockUserRepo)
+
+	// Act
+	err := u.UpdateRole(context.Background(), uuid.New(), infraAuth.Role("superuser"))
+
+	// Assert
+	assert.Equal(t, ErrInvalidRole, err)
+	mockUserRepo.AssertNotCalled(t, "UpdateRole", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestUpdateRole_EndsSessionsWhenRoleChanges(t *testing.T) {
+	tests := []struct {
+		name        string
+		from        infraAuth.Role
+		to          infraAuth.Role
+		wantRevoked bool
+	}{
+		{name: "demoted admin", from: infraAuth.RoleAdmin, to: infraAuth.RoleUser, wantRevoked: true},
+		{name: "promoted user", from: infraAuth.RoleUser, to: infraAuth.RoleAdmin, wantRevoked: true},
+		{name: "role unchanged", from: infraAuth.RoleUser, to: infraAuth.RoleUser, wantRevoked: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			d := newTestDeps()
+			u := d.usecase()
+			userID := uuid.New()
+			jwtSvc := infraAuth.NewJWTService("test-secret", 1)
+			accessToken, err := jwtSvc.GenerateToken(infraAuth.Subject{UserID: userID.String(), Role: tt.from})
+			require.NoError(t, err)
+			claims, err := jwtSvc.ValidateToken(accessToken)
+			require.NoError(t, err)
+
+			d.userRepo.On("GetByID", mock.Anything, userID).Return(&User{ID: userID, Role: tt.from}, nil)
+			d.userRepo.On("UpdateRole", mock.Anything, userID, tt.to).Return(nil)
+			if tt.wantRevoked {
+				d.repo.On("DeleteByUserID", mock.Anything, userID).Return(nil)
+			}
+
+			// Act
+			err = u.UpdateRole(context.Background(), userID, tt.to)
+
+			// Assert
+			assert.NoError(t, err)
+			revoked, err := d.revocations.IsRevoked(context.Background(), claims)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantRevoked, revoked)
+			d.repo.AssertExpectations(t)
+			if !tt.wantRevoked {
+				d.repo.AssertNotCalled(t, "DeleteByUserID", mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
+
+func TestUpdateRole_UserNotFound(t *testing.T) {
+	// Arrange
+	d := newTestDeps()
+	u := d.usecase()
+	userID := uuid.New()
+	d.userRepo.On("GetByID", mock.Anything, userID).Return(nil, ErrUserNotFound)
+
+	// Act
+	err := u.UpdateRole(context.Background(), userID, infraAuth.RoleUser)
+
+	// Assert
+	assert.Equal(t, ErrUserNotFound, err)
+	d.userRepo.AssertNotCalled(t, "UpdateRole", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestRevokeAllSessions_RevokesOutstandingAccessTokens(t *testing.T) {
+	// Arrange
+	mockRepo := new(MockRepository)
+	revocations := infraAuth.NewMemoryRevocationStore()
+	jwtSvc := infraAuth.NewJWTService("test-secret", 1)
+	u := NewUsecase(
+		mockRepo,
+		new(MockUserRepository),
+		jwtSvc,
+		infraAuth.NewBcryptHasher(bcrypt.MinCost),
+		testTokenHasher,
+		revocations,
+		new(MockLoginAttemptRepository),
+		testLockoutPolicy,
+		new(MockRecoveryCodeRepository),
+		testSecrets,
+		"go-boilerplate",
+		new(MockActionTokenRepository),
+		mail.NewMemoryMailer(),
+		"https://app.example.com",
+		new(MockAPIKeyRepository),
+		nil,
+		new(MockOIDCStateRepository),
+		new(MockExternalIdentityRepository),
+		audit.NopLogger{},
+	)
+
+	userID := uuid.New()
+	accessToken, err := jwtSvc.GenerateToken(infraAuth.Subject{UserID: userID.String()})
+	assert.NoError(t, err)
+	claims, err := jwtSvc.ValidateToken(accessToken)
+	assert.NoError(t, err)
+
+	mockRepo.On("DeleteByUserID", mock.Anything, userID).Return(nil)
+
+	// Act
+	err = u.RevokeAllSessions(context.Background(), userID)
+
+	// Assert
+	assert.NoError(t, err)
+	revoked, err := revocations.IsRevoked(context.Background(), claims)
+	assert.NoError(t, err)
+	assert.True(t, revoked)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestRevokeSession_NotOwned(t *testing.T) {
+	// Arrange
+	mockRepo := new(MockRepository)
+	u := newTestUsecase(mockRepo, new(MockUserRepository))
+
+	userID := uuid.New()
+	sessionID := uuid.New()
+	mockRepo.On("RevokeUserFamily", mock.Anything, userID, sessionID).Return(false, nil)
+
+	// Act
+	err := u.RevokeSession(context.Background(), userID, sessionID)
+
+	// Assert
+	assert.Equal(t, ErrSessionNotFound, err)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestRevokeSession_RevokesItsAccessTokens(t *testing.T) {
+	// Arrange
+	d := newTestDeps()
+	u := d.usecase()
+	jwtSvc := infraAuth.NewJWTService("test-secret", 1)
+	hash, _ := infraAuth.NewBcryptHasher(bcrypt.MinCost).Hash("password123")
+	user := &User{ID: uuid.New(), Username: "alice", PasswordHash: hash, Role: infraAuth.RoleUser}
+
+	var sessions []*RefreshToken
+	d.userRepo.On("GetByUsername", mock.Anything, "alice").Return(user, nil)
+	d.repo.On("Create", mock.Anything, mock.AnythingOfType("*auth.RefreshToken")).
+		Return(nil).
+		Run(func(args mock.Arguments) {
+			sessions = append(sessions, args.Get(1).(*RefreshToken))
+		})
+	laptop, err := u.Login(context.Background(), "alice", "password123", nil, ClientInfo{})
+	require.NoError(t, err)
+	phone, err := u.Login(context.Background(), "alice", "password123", nil, ClientInfo{})
+	require.NoError(t, err)
+	require.Len(t, sessions, 2)
+	laptopClaims, err := jwtSvc.ValidateToken(laptop.AccessToken)
+	require.NoError(t, err)
+	phoneClaims, err := jwtSvc.ValidateToken(phone.AccessToken)
+	require.NoError(t, err)
+
+	d.repo.On("RevokeUserFamily", mock.Anything, user.ID, sessions[0].FamilyID).Return(true, nil)
+
+	// Act
+	err = u.RevokeSession(context.Background(), user.ID, sessions[0].FamilyID)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, sessions[0].FamilyID.String(), laptopClaims.SessionID)
+	revoked, err := d.revocations.IsRevoked(context.Background(), laptopClaims)
+	assert.NoError(t, err)
+	assert.True(t, revoked, "the revoked session's access token stops working")
+	revoked, err = d.revocations.IsRevoked(context.Background(), phoneClaims)
+	assert.NoError(t, err)
+	assert.False(t, revoked, "other sessions are unaffected")
+}
+
+func TestLogin_PasswordlessUser(t *testing.T) {
+	// Arrange
+	d := newTestDeps()
+	u := d.usecase()
+	user := &User{ID: uuid.New(), Username: "alice", Role: infraAuth.RoleUser}
+	d.userRepo.On("GetByUsername", mock.Anything, "alice").Return(user, nil)
+
+	// Act
+	result, err := u.Login(context.Background(), "alice", "", nil, ClientInfo{})
+
+	// Assert - accounts created through SSO cannot log in with an empty password
+	assert.Nil(t, result)
+	assert.Equal(t, ErrInvalidCredentials, err)
+}
+
+func TestStartOIDCLogin(t *testing.T) {
+	// Arrange
+	d := newTestDeps()
+	u := d.usecase()
+
+	var state, nonce, challenge string
+	d.oidcProvider.On("AuthCodeURL", mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) {
+			state, nonce, challenge = args.String(1), args.String(2), args.String(3)
+		}).
+		Return("https://sso.example.com/authorize?state=x", nil)
+
+	var stored *OIDCLoginState
+	d.oidcStates.On("Create", mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) { stored = args.Get(1).(*OIDCLoginState) }).
+		Return(nil)
+
+	// Act
+	authURL, err := u.StartOIDCLogin(context.Background(), "corp", true)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, "https://sso.example.com/authorize?state=x", authURL)
+	assert.Equal(t, testTokenHasher.Hash(state), stored.StateHash)
+	assert.Equal(t, "corp", stored.Provider)
+	assert.Equal(t, nonce, stored.Nonce)
+	assert.Equal(t, oidc.S256Challenge(stored.CodeVerifier), challenge)
+	assert.True(t, stored.Cookie)
+	assert.WithinDuration(t, time.Now().Add(oidcLoginTTL), stored.ExpiresAt, time.Minute)
+}
+
+func TestStartOIDCLogin_UnknownProvider(t *testing.T) {
+	// Arrange
+	u := newTestDeps().usecase()
+
+	// Act
+	_, err := u.StartOIDCLogin(context.Background(), "unknown", false)
+
+	// Assert
+	assert.Equal(t, ErrUnknownProvider, err)
+}
+
+// arrangeOIDCCallback sets up a pending login for the corp provider that resolves to claims.
+func arrangeOIDCCallback(d *testDeps, claims *oidc.IDTokenClaims) {
+	d.oidcStates.On("Consume", mock.Anything, testTokenHasher.Hash("state-1")).Return(&OIDCLoginState{
+		Provider:     "corp",
+		Nonce:        "nonce-1",
+		CodeVerifier: "verifier-1",
+	}, nil)
+	d.oidcProvider.On("Exchange", mock.Anything, "code-1", "verifier-1", "nonce-1").Return(claims, nil)
+}
+
+func oidcClaims(subject, email string, verified bool) *oidc.IDTokenClaims {
+	claims := &oidc.IDTokenClaims{Email: email, EmailVerified: verified, PreferredUsername: "Alice.Smith"}
+	claims.Subject = subject
+	return claims
+}
+
+func TestCompleteOIDCLogin_LinkedIdentity(t *testing.T) {
+	// Arrange
+	d := newTestDeps()
+	u := d.usecase()
+	user := &User{ID: uuid.New(), Username: "alice", Role: infraAuth.RoleUser}
+	identity := &ExternalIdentity{ID: uuid.New(), UserID: user.ID, Provider: "corp", Subject: "sub-1"}
+
+	arrangeOIDCCallback(d, oidcClaims("sub-1", "alice@example.com", true))
+	d.identities.On("GetByProviderSubject", mock.Anything, "corp", "sub-1").Return(identity, nil)
+	d.identities.On("TouchLastLogin", mock.Anything, identity.ID, mock.Anything).Return(nil)
+	d.userRepo.On("GetByID", mock.Anything, user.ID).Return(user, nil)
+	d.repo.On("Create", mock.Anything, mock.MatchedBy(func(token *RefreshToken) bool {
+		return token.UserID == user.ID
+	})).Return(nil)
+
+	// Act
+	result, err := u.CompleteOIDCLogin(context.Background(), "corp", "state-1", "code-1", ClientInfo{})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.NotEmpty(t, result.AccessToken)
+	assert.NotEmpty(t, result.RefreshToken)
+	d.identities.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+	d.repo.AssertExpectations(t)
+}
+
+func TestCompleteOIDCLogin_CreatesUser(t *testing.T) {
+	// Arrange
+	d := newTestDeps()
+	u := d.usecase()
+
+	arrangeOIDCCallback(d, oidcClaims("sub-1", "Alice@Example.com", true))
+	d.identities.On("GetByProviderSubject", mock.Anything, "corp", "sub-1").Return(nil, ErrIdentityNotFound)
+	d.userRepo.On("GetByEmail", mock.Anything, "alice@example.com").Return(nil, ErrUserNotFound)
+
+	var created *User
+	d.userRepo.On("Create", mock.Anything, mock.MatchedBy(func(user *User) bool {
+		return user.Username == "alicesmith"
+	})).Return(ErrUserAlreadyExists).Once()
+	d.userRepo.On("Create", mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) { created = args.Get(1).(*User) }).
+		Return(nil).Once()
+	d.identities.On("Create", mock.Anything, mock.MatchedBy(func(identity *ExternalIdentity) bool {
+		return identity.UserID == created.ID && identity.Provider == "corp" && identity.Subject == "sub-1"
+	})).Return(nil)
+	d.repo.On("Create", mock.Anything, mock.Anything).Return(nil)
+
+	// Act
+	result, err := u.CompleteOIDCLogin(context.Background(), "corp", "state-1", "code-1", ClientInfo{})
+
+	// Assert - the taken username gets a numeric suffix
+	assert.NoError(t, err)
+	assert.NotEmpty(t, result.AccessToken)
+	assert.Regexp(t, `^alicesmith\d{6}$`, created.Username)
+	assert.Equal(t, "alice@example.com", created.Email)
+	assert.Empty(t, created.PasswordHash)
+	assert.NotNil(t, created.EmailVerifiedAt)
+	d.identities.AssertExpectations(t)
+}
+
+func TestCompleteOIDCLogin_LinksVerifiedAccount(t *testing.T) {
+	// Arrange
+	d := newTestDeps()
+	u := d.usecase()
+	verifiedAt := time.Now().Add(-time.Hour)
+	user := &User{ID: uuid.New(), Username: "alice", Email: "alice@example.com", EmailVerifiedAt: &verifiedAt}
+
+	arrangeOIDCCallback(d, oidcClaims("sub-1", "alice@example.com", true))
+	d.identities.On("GetByProviderSubject", mock.Anything, "corp", "sub-1").Return(nil, ErrIdentityNotFound)
+	d.userRepo.On("GetByEmail", mock.Anything, "alice@example.com").Return(user, nil)
+	d.identities.On("Create", mock.Anything, mock.MatchedBy(func(identity *ExternalIdentity) bool {
+		return identity.UserID == user.ID
+	})).Return(nil)
+	d.repo.On("Create", mock.Anything, mock.Anything).Return(nil)
+
+	// Act
+	result, err := u.CompleteOIDCLogin(context.Background(), "corp", "state-1", "code-1", ClientInfo{})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.NotEmpty(t, result.AccessToken)
+	d.userRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+	d.identities.AssertExpectations(t)
+}
+
+func TestCompleteOIDCLogin_RefusesToLink(t *testing.T) {
+	tests := []struct {
+		name    string
+		claims  *oidc.IDTokenClaims
+		local   *User
+		wantErr error
+	}{
+		{
+			name:    "provider email not verified",
+			claims:  oidcClaims("sub-1", "alice@example.com", false),
+			wantErr: ErrOIDCEmailNotVerified,
+		},
+		{
+			name:    "local email not verified",
+			claims:  oidcClaims("sub-1", "alice@example.com", true),
+			local:   &User{ID: uuid.New(), Username: "alice", Email: "alice@example.com"},
+			wantErr: ErrOIDCAccountConflict,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			d := newTestDeps()
+			u := d.usecase()
+			arrangeOIDCCallback(d, tt.claims)
+			d.identities.On("GetByProviderSubject", mock.Anything, "corp", "sub-1").Return(nil, ErrIdentityNotFound)
+			if tt.local != nil {
+				d.userRepo.On("GetByEmail", mock.Anything, tt.local.Email).Return(tt.local, nil)
+			}
+
+			// Act
+			result, err := u.CompleteOIDCLogin(context.Background(), "corp", "state-1", "code-1", ClientInfo{})
+
+			// Assert
+			assert.Nil(t, result)
+			assert.Equal(t, tt.wantErr, err)
+			d.identities.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+			d.repo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestCompleteOIDCLogin_InvalidState(t *testing.T) {
+	// Arrange
+	d := newTestDeps()
+	u := d.usecase()
+	d.oidcStates.On("Consume", mock.Anything, testTokenHasher.Hash("state-1")).Return(&OIDCLoginState{
+		Provider: "other",
+	}, nil)
+	d.oidcStates.On("Consume", mock.Anything, testTokenHasher.Hash("replayed")).Return(nil, ErrInvalidOIDCState)
+
+	// Act
+	_, wrongProviderErr := u.CompleteOIDCLogin(context.Background(), "corp", "state-1", "code-1", ClientInfo{})
+	_, replayErr := u.CompleteOIDCLogin(context.Background(), "corp", "replayed", "code-1", ClientInfo{})
+
+	// Assert
+	assert.Equal(t, ErrInvalidOIDCState, wrongProviderErr)
+	assert.Equal(t, ErrInvalidOIDCState, replayErr)
+	d.oidcProvider.AssertNotCalled(t, "Exchange", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestIntrospect_AccessToken(t *testing.T) {
+	// Arrange
+	d := newTestDeps()
+	u := d.usecase()
+	user := &User{ID: uuid.New(), Username: "alice", Role: infraAuth.RoleReadOnly}
+	d.userRepo.On("GetByID", mock.Anything, user.ID).Return(user, nil)
+
+	jwtSvc := infraAuth.NewJWTService("test-secret", 1)
+	token, err := jwtSvc.GenerateToken(infraAuth.Subject{UserID: user.ID.String(), Role: user.Role})
+	assert.NoError(t, err)
+
+	// Act
+	result, err := u.Introspect(context.Background(), token)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.True(t, result.Active)
+	assert.Equal(t, user.ID.String(), result.Subject)
+	assert.Equal(t, "alice", result.Username)
+	assert.Equal(t, "portfolios:read", result.Scope)
+	assert.Equal(t, infraAuth.RoleReadOnly, result.Role)
+	assert.NotZero(t, result.ExpiresAt)
+	assert.NotEmpty(t, result.TokenID)
+}
+
+func TestIntrospect_APIKey(t *testing.T) {
+	// Arrange
+	d := newTestDeps()
+	u := d.usecase()
+	user := &User{ID: uuid.New(), Username: "alice", Role: infraAuth.RoleUser}
+	rawKey, _, err := infraAuth.GenerateAPIKey()
+	assert.NoError(t, err)
+	now := time.Now()
+	key := &APIKey{
+		ID:         uuid.New(),
+		UserID:     user.ID,
+		Scopes:     []infraAuth.Permission{infraAuth.PermPortfoliosRead, infraAuth.PermPortfoliosWrite},
+		ExpiresAt:  now.Add(time.Hour),
+		LastUsedAt: &now,
+	}
+	d.apiKeys.On("GetByHash", mock.Anything, testTokenHasher.Hash(rawKey)).Return(key, nil)
+	d.userRepo.On("GetByID", mock.Anything, user.ID).Return(user, nil)
+
+	// Act
+	result, err := u.Introspect(context.Background(), rawKey)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.True(t, result.Active)
+	assert.Equal(t, key.ID.String(), result.ClientID)
+	assert.Equal(t, "portfolios:read portfolios:write", result.Scope)
+	assert.Equal(t, key.ExpiresAt.Unix(), result.ExpiresAt)
+}
+
+func TestIntrospect_Inactive(t *testing.T) {
+	jwtSvc := infraAuth.NewJWTService("test-secret", 1)
+	deletedUserID := uuid.New()
+
+	tests := []struct {
+		name    string
+		arrange func(d *testDeps) string
+	}{
+		{
+			name:    "malformed token",
+			arrange: func(d *testDeps) string { return "not-a-token" },
+		},
+		{
+			name: "signed with another secret",
+			arrange: func(d *testDeps) string {
+				token, _ := infraAuth.NewJWTService("other-secret", 1).GenerateToken(infraAuth.Subject{UserID: uuid.NewString()})
+				return token
+			},
+		},
+		{
+			name: "revoked token",
+			arrange: func(d *testDeps) string {
+				token, _ := jwtSvc.GenerateToken(infraAuth.Subject{UserID: uuid.NewString()})
+				claims, _ := jwtSvc.ValidateToken(token)
+				_ = d.revocations.RevokeToken(context.Background(), claims.ID, claims.ExpiresAt.Time)
+				return token
+			},
+		},
+		{
+			name: "deleted user",
+			arrange: func(d *testDeps) string {
+				d.userRepo.On("GetByID", mock.Anything, deletedUserID).Return(nil, ErrUserNotFound)
+				token, _ := jwtSvc.GenerateToken(infraAuth.Subject{UserID: deletedUserID.String()})
+				return token
+			},
+		},
+		{
+			name: "unknown API key",
+			arrange: func(d *testDeps) string {
+				d.apiKeys.On("GetByHash", mock.Anything, mock.Anything).Return(nil, ErrAPIKeyNotFound)
+				return infraAuth.APIKeyPrefix + "unknown_secret"
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			d := newTestDeps()
+			u := d.usecase()
+			token := tt.arrange(d)
+
+			// Act
+			result, err := u.Introspect(context.Background(), token)
+
+			// Assert - nothing but the active flag is disclosed
+			assert.NoError(t, err)
+			assert.Equal(t, &infraAuth.IntrospectionResponse{}, result)
+		})
+	}
+}
+
+func TestLogin_ScopedTokens(t *testing.T) {
+	// Arrange
+	d := newTestDeps()
+	u := d.usecase()
+	hash, _ := infraAuth.NewBcryptHasher(bcrypt.MinCost).Hash("password123")
+	user := &User{ID: uuid.New(), Username: "alice", PasswordHash: hash, Role: infraAuth.RoleUser}
+	d.userRepo.On("GetByUsername", mock.Anything, "alice").Return(user, nil)
+
+	var stored *RefreshToken
+	d.repo.On("Create", mock.Anything, mock.AnythingOfType("*auth.RefreshToken")).
+		Run(func(args mock.Arguments) { stored = args.Get(1).(*RefreshToken) }).
+		Return(nil)
+
+	scopes := []infraAuth.Permission{infraAuth.PermPortfoliosRead, infraAuth.PermPortfoliosRead}
+
+	// Act
+	result, err := u.Login(context.Background(), "alice", "password123", scopes, ClientInfo{})
+	_, deniedErr := u.Login(context.Background(), "alice", "password123", []infraAuth.Permission{infraAuth.PermUsersManage}, ClientInfo{})
+
+	// Assert
+	assert.NoError(t, err)
+	claims, err := infraAuth.NewJWTService("test-secret", 1).ValidateToken(result.AccessToken)
+	assert.NoError(t, err)
+	assert.Equal(t, "portfolios:read", claims.Scope)
+	assert.Equal(t, []infraAuth.Permission{infraAuth.PermPortfoliosRead}, stored.Scopes)
+	assert.ErrorIs(t, deniedErr, ErrInvalidScope)
+}
+
+func TestRefreshToken_Scopes(t *testing.T) {
+	read := []infraAuth.Permission{infraAuth.PermPortfoliosRead}
+	readWrite := []infraAuth.Permission{infraAuth.PermPortfoliosRead, infraAuth.PermPortfoliosWrite}
+
+	tests := []struct {
+		name          string
+		sessionScopes []infraAuth.Permission
+		requested     []infraAuth.Permission
+		role          infraAuth.Role
+		wantScope     string
+		wantErr       error
+	}{
+		{
+			name:          "keeps session scopes",
+			sessionScopes: read,
+			role:          infraAuth.RoleUser,
+			wantScope:     "portfolios:read",
+		},
+		{
+			name:          "narrows on request",
+			sessionScopes: readWrite,
+			requested:     read,
+			role:          infraAuth.RoleUser,
+			wantScope:     "portfolios:read",
+		},
+		{
+			name:      "narrows an unscoped session",
+			requested: read,
+			role:      infraAuth.RoleUser,
+			wantScope: "portfolios:read",
+		},
+		{
+			name:          "cannot widen",
+			sessionScopes: read,
+			requested:     readWrite,
+			role:          infraAuth.RoleUser,
+			wantErr:       ErrInvalidScope,
+		},
+		{
+			name:      "unscoped session cannot ask for what the role does not grant",
+			requested: []infraAuth.Permission{infraAuth.PermUsersManage},
+			role:      infraAuth.RoleUser,
+			wantErr:   ErrInvalidScope,
+		},
+		{
+			name:      "unknown scopes are rejected rather than dropped",
+			requested: []infraAuth.Permission{infraAuth.PermPortfoliosRead, "portfolios:delete"},
+			role:      infraAuth.RoleUser,
+			wantErr:   ErrInvalidScope,
+		},
+		{
+			name:          "drops scopes the role lost",
+			sessionScopes: readWrite,
+			role:          infraAuth.RoleReadOnly,
+			wantScope:     "portfolios:read",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			d := newTestDeps()
+			u := d.usecase()
+			user := &User{ID: uuid.New(), Username: "alice", Role: tt.role}
+			existing := &RefreshToken{
+				ID:        uuid.New(),
+				UserID:    user.ID,
+				FamilyID:  uuid.New(),
+				TokenHash: testTokenHasher.Hash("old-token"),
+				Scopes:    tt.sessionScopes,
+				ExpiresAt: time.Now().Add(time.Hour),
+			}
+			d.repo.On("GetByToken", mock.Anything, existing.TokenHash).Return(existing, nil)
+			d.repo.On("Revoke", mock.Anything, existing.ID).Return(true, nil).Maybe()
+			d.userRepo.On("GetByID", mock.Anything, user.ID).Return(user, nil).Maybe()
+			d.repo.On("Create", mock.Anything, mock.AnythingOfType("*auth.RefreshToken")).Return(nil).Maybe()
+
+			// Act
+			accessToken, _, err := u.RefreshToken(context.Background(), "old-token", tt.requested, ClientInfo{})
+
+			// Assert
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				d.repo.AssertNotCalled(t, "Revoke", mock.Anything, mock.Anything)
+				return
+			}
+			assert.NoError(t, err)
+			claims, err := infraAuth.NewJWTService("test-secret", 1).ValidateToken(accessToken)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantScope, claims.Scope)
+		})
+	}
+}
+
+func TestVerifyMFA_KeepsRequestedScopes(t *testing.T) {
+	// Arrange
+	d := newTestDeps()
+	u := d.usecase()
+	user, secret := newMFAUser(t)
+	user.Role = infraAuth.RoleUser
+	d.userRepo.On("GetByUsername", mock.Anything, "alice").Return(user, nil)
+	d.userRepo.On("GetByID", mock.Anything, user.ID).Return(user, nil)
+	d.userRepo.On("AdvanceTOTPStep", mock.Anything, user.ID, mock.AnythingOfType("int64")).Return(true, nil)
+	d.repo.On("Create", mock.Anything, mock.AnythingOfType("*auth.RefreshToken")).Return(nil)
+
+	challenge, err := u.Login(context.Background(), "alice", "password123", []infraAuth.Permission{infraAuth.PermPortfoliosRead}, ClientInfo{})
+	assert.NoError(t, err)
+	code, err := infraAuth.GenerateTOTPCode(secret, time.Now())
+	assert.NoError(t, err)
+
+	// Act
+	result, err := u.VerifyMFA(context.Background(), challenge.MFAToken, code, ClientInfo{})
+
+	// Assert
+	assert.NoError(t, err)
+	claims, err := infraAuth.NewJWTService("test-secret", 1).ValidateToken(result.AccessToken)
+	assert.NoError(t, err)
+	assert.Equal(t, "portfolios:read", claims.Scope)
+}
+
+func TestLogin_AuditsOutcome(t *testing.T) {
+	hash, _ := infraAuth.NewBcryptHasher(bcrypt.MinCost).Hash("password123")
+	user := &User{ID: uuid.New(), Username: "alice", PasswordHash: hash}
+
+	tests := []struct {
+		name        string
+		username    string
+		password    string
+		wantOutcome string
+		wantActor   string
+		wantReason  any
+	}{
+		{name: "success", username: "alice", password: "password123", wantOutcome: audit.OutcomeSuccess, wantActor: user.ID.String()},
+		{name: "wrong password", username: "alice", password: "guess", wantOutcome: audit.OutcomeFailure, wantActor: user.ID.String(), wantReason: "wrong_password"},
+		{name: "unknown user", username: "mallory", password: "guess", wantOutcome: audit.OutcomeFailure, wantReason: "unknown_user"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			d := newTestDeps()
+			u := d.usecase()
+			d.userRepo.On("GetByUsername", mock.Anything, "alice").Return(user, nil)
+			d.userRepo.On("GetByUsername", mock.Anything, "mallory").Return(nil, ErrUserNotFound)
+			d.repo.On("Create", mock.Anything, mock.AnythingOfType("*auth.RefreshToken")).Return(nil)
+			ctx := audit.WithRequestInfo(context.Background(), audit.RequestInfo{IPAddress: "203.0.113.7", RequestID: "req-1"})
+
+			// Act
+			_, _ = u.Login(ctx, tt.username, tt.password, nil, ClientInfo{IPAddress: "203.0.113.7"})
+
+			// Assert
+			events := d.audit.Events()
+			if assert.Len(t, events, 1) {
+				assert.Equal(t, ActionLogin, events[0].Action)
+				assert.Equal(t, tt.wantOutcome, events[0].Outcome)
+				assert.Equal(t, tt.wantActor, events[0].ActorID)
+				assert.Equal(t, tt.wantReason, events[0].Metadata["reason"])
+				assert.Equal(t, "203.0.113.7", events[0].IPAddress)
+				assert.Equal(t, "req-1", events[0].RequestID)
+			}
+		})
+	}
+}
+
+func TestRefreshToken_ReuseIsAudited(t *testing.T) {
+	// Arrange
+	d := newTestDeps()
+	u := d.usecase()
+
+	revokedAt := time.Now().Add(-time.Minute)
+	existing := &RefreshToken{
+		ID:        uuid.New(),
+		UserID:    uuid.New(),
+		FamilyID:  uuid.New(),
+		ExpiresAt: time.Now().Add(time.Hour),
+		RevokedAt: &revokedAt,
+	}
+	d.repo.On("GetByToken", mock.Anything, testTokenHasher.Hash("stolen-token")).Return(existing, nil)
+	d.repo.On("RevokeFamily", mock.Anything, existing.FamilyID).Return(nil)
+
+	// Act
+	_, _, err := u.RefreshToken(context.Background(), "stolen-token", nil, ClientInfo{})
+
+	// Assert
+	assert.Equal(t, ErrTokenReused, err)
+	events := d.audit.Events()
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, ActionTokenRefresh, events[0].Action)
+		assert.Equal(t, audit.OutcomeFailure, events[0].Outcome)
+		assert.Equal(t, existing.UserID.String(), events[0].TargetID)
+		assert.Equal(t, "token_reused", events[0].Metadata["reason"])
+	}
+}
+
+func TestUpdateRole_AuditsAdminAsActor(t *testing.T) {
+	// Arrange
+	d := newTestDeps()
+	u := d.usecase()
+	adminID, userID := uuid.New(), uuid.New()
+	d.userRepo.On("GetByID", mock.Anything, userID).Return(&User{ID: userID, Role: infraAuth.RoleAdmin}, nil)
+	d.userRepo.On("UpdateRole", mock.Anything, userID, infraAuth.RoleAdmin).Return(nil)
+	ctx := audit.WithActorID(context.Background(), adminID.String())
+
+	// Act
+	err := u.UpdateRole(ctx, userID, infraAuth.RoleAdmin)
+
+	// Assert
+	assert.NoError(t, err)
+	events := d.audit.Events()
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, ActionRoleChange, events[0].Action)
+		assert.Equal(t, adminID.String(), events[0].ActorID)
+		assert.Equal(t, userID.String(), events[0].TargetID)
+		assert.Equal(t, "admin", events[0].Metadata["role"])
+	}
+}
+
+func TestImpersonate(t *testing.T) {
+	// Arrange
+	d := newTestDeps()
+	u := d.usecase()
+	admin := &User{ID: uuid.New(), Username: "root", Role: infraAuth.RoleAdmin}
+	target := &User{ID: uuid.New(), Username: "alice", Role: infraAuth.RoleUser}
+	d.userRepo.On("GetByID", mock.Anything, admin.ID).Return(admin, nil)
+	d.userRepo.On("GetByID", mock.Anything, target.ID).Return(target, nil)
+	ctx := audit.WithActorID(context.Background(), admin.ID.String())
+
+	// Act
+	result, err := u.Impersonate(ctx, admin.ID, target.ID, true)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.True(t, result.ReadOnly)
+	claims, err := infraAuth.NewJWTService("test-secret", 1).ValidateToken(result.AccessToken)
+	assert.NoError(t, err)
+	assert.Equal(t, target.ID.String(), claims.UserID)
+	assert.Equal(t, infraAuth.RoleUser, claims.Role)
+	if assert.NotNil(t, claims.Act) {
+		assert.Equal(t, admin.ID.String(), claims.Act.Subject)
+	}
+	assert.True(t, claims.ReadOnly)
+	assert.WithinDuration(t, time.Now().Add(impersonationTTL), claims.ExpiresAt.Time, 5*time.Second)
+
+	events := d.audit.Events()
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, ActionImpersonate, events[0].Action)
+		assert.Equal(t, admin.ID.String(), events[0].ActorID)
+		assert.Equal(t, target.ID.String(), events[0].TargetID)
+		assert.Equal(t, audit.OutcomeSuccess, events[0].Outcome)
+	}
+}
+
+func TestImpersonate_Refused(t *testing.T) {
+	admin := &User{ID: uuid.New(), Username: "root", Role: infraAuth.RoleAdmin}
+	otherAdmin := &User{ID: uuid.New(), Username: "ops", Role: infraAuth.RoleAdmin}
+	demoted := &User{ID: uuid.New(), Username: "former", Role: infraAuth.RoleUser}
+	target := &User{ID: uuid.New(), Username: "alice", Role: infraAuth.RoleUser}
+
+	tests := []struct {
+		name     string
+		adminID  uuid.UUID
+		targetID uuid.UUID
+	}{
+		{name: "self", adminID: admin.ID, targetID: admin.ID},
+		{name: "another admin", adminID: admin.ID, targetID: otherAdmin.ID},
+		{name: "caller no longer admin", adminID: demoted.ID, targetID: target.ID},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			d := newTestDeps()
+			u := d.usecase()
+			for _, user := range []*User{admin, otherAdmin, demoted, target} {
+				d.userRepo.On("GetByID", mock.Anything, user.ID).Return(user, nil)
+			}
+
+			// Act
+			result, err := u.Impersonate(context.Background(), tt.adminID, tt.targetID, false)
+
+			// Assert
+			assert.Nil(t, result)
+			assert.ErrorIs(t, err, ErrCannotImpersonate)
+		})
+	}
+}
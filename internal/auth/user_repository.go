@@ -0,0 +1,136 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	infraAuth "go-boilerplate/internal/infra/auth"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type UserRepository interface {
+	Create(ctx context.Context, user *User) error
+	GetByID(ctx context.Context, id uuid.UUID) (*User, error)
+	GetByUsername(ctx context.Context, username string) (*User, error)
+	GetByEmail(ctx context.Context, email string) (*User, error)
+	UpdatePassword(ctx context.Context, id uuid.UUID, passwordHash string) error
+	MarkEmailVerified(ctx context.Context, id uuid.UUID) error
+	UpdateRole(ctx context.Context, id uuid.UUID, role infraAuth.Role) error
+	SetTOTPSecret(ctx context.Context, id uuid.UUID, encryptedSecret string) error
+	EnableMFA(ctx context.Context, id uuid.UUID, step int64) error
+	DisableMFA(ctx context.Context, id uuid.UUID) error
+	AdvanceTOTPStep(ctx context.Context, id uuid.UUID, step int64) (bool, error)
+}
+
+type userRepository struct {
+	db *gorm.DB
+}
+
+func NewUserRepository(db *gorm.DB) UserRepository {
+	return &userRepository{db: db}
+}
+
+func (r *userRepository) Create(ctx context.Context, user *User) error {
+	err := r.db.WithContext(ctx).Create(user).Error
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return ErrUserAlreadyExists
+	}
+	return err
+}
+
+func (r *userRepository) GetByID(ctx context.Context, id uuid.UUID) (*User, error) {
+	return r.first(ctx, "id = ?", id)
+}
+
+func (r *userRepository) GetByUsername(ctx context.Context, username string) (*User, error) {
+	return r.first(ctx, "username = ?", username)
+}
+
+func (r *userRepository) GetByEmail(ctx context.Context, email string) (*User, error) {
+	return r.first(ctx, "email = ?", email)
+}
+
+func (r *userRepository) UpdatePassword(ctx context.Context, id uuid.UUID, passwordHash string) error {
+	return r.update(ctx, id, map[string]interface{}{"password_hash": passwordHash})
+}
+
+func (r *userRepository) MarkEmailVerified(ctx context.Context, id uuid.UUID) error {
+	return r.update(ctx, id, map[string]interface{}{"email_verified_at": time.Now()})
+}
+
+func (r *userRepository) UpdateRole(ctx context.Context, id uuid.UUID, role infraAuth.Role) error {
+	result := r.db.WithContext(ctx).
+		Model(&User{}).
+		Where("id = ?", id).
+		Update("role", role)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+// SetTOTPSecret stores a pending authenticator secret; MFA stays off until EnableMFA.
+func (r *userRepository) SetTOTPSecret(ctx context.Context, id uuid.UUID, encryptedSecret string) error {
+	return r.update(ctx, id, map[string]interface{}{
+		"totp_secret":    encryptedSecret,
+		"totp_last_step": 0,
+		"mfa_enabled_at": nil,
+	})
+}
+
+func (r *userRepository) EnableMFA(ctx context.Context, id uuid.UUID, step int64) error {
+	return r.update(ctx, id, map[string]interface{}{
+		"totp_last_step": step,
+		"mfa_enabled_at": time.Now(),
+	})
+}
+
+func (r *userRepository) DisableMFA(ctx context.Context, id uuid.UUID) error {
+	return r.update(ctx, id, map[string]interface{}{
+		"totp_secret":    "",
+		"totp_last_step": 0,
+		"mfa_enabled_at": nil,
+	})
+}
+
+// AdvanceTOTPStep records step as the last accepted TOTP step. It reports false when a
+// code from that step or a later one was already accepted, which means a replay.
+func (r *userRepository) AdvanceTOTPStep(ctx context.Context, id uuid.UUID, step int64) (bool, error) {
+	result := r.db.WithContext(ctx).
+		Model(&User{}).
+		Where("id = ? AND totp_last_step < ?", id, step).
+		Update("totp_last_step", step)
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *userRepository) update(ctx context.Context, id uuid.UUID, fields map[string]interface{}) error {
+	result := r.db.WithContext(ctx).
+		Model(&User{}).
+		Where("id = ?", id).
+		Updates(fields)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+func (r *userRepository) first(ctx context.Context, query string, args ...interface{}) (*User, error) {
+	var user User
+	err := r.db.WithContext(ctx).Where(query, args...).First(&user).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+	return &user, nil
+}
@@ -1,6 +1,8 @@
 package config
 
 import (
+	"errors"
+
 	"github.com/ilyakaznacheev/cleanenv"
 )
 
@@ -21,8 +23,94 @@ type Config struct {
 
 	MaxRequestPerSecond float64 `env:"MAX_REQUEST_PER_SECOND" env-default:"20"`
 
-	JWTSecret      string `env:"JWT_SECRET" env-required:"true"`
-	JWTExpiryHours int    `env:"JWT_EXPIRY_HOURS" env-default:"24"`
+	// TrustedProxies are the CIDR ranges of reverse proxies whose X-Forwarded-For header is
+	// believed. With none, clients are identified by the address they connect from.
+	TrustedProxies []string `env:"TRUSTED_PROXIES"`
+
+	// JWTSecret signs tokens with HS256 unless JWTSigningKeysDir is set,
+	// in which case tokens are signed with the key named by JWTActiveKeyID.
+	JWTSecret         string `env:"JWT_SECRET"`
+	JWTExpiryHours    int    `env:"JWT_EXPIRY_HOURS" env-default:"24"`
+	JWTSigningKeysDir string `env:"JWT_SIGNING_KEYS_DIR"`
+	JWTActiveKeyID    string `env:"JWT_ACTIVE_KEY_ID"`
+
+	// JWTIssuer and JWTAudience are stamped on issued tokens and required on validated ones.
+	JWTIssuer        string   `env:"JWT_ISSUER" env-default:"go-boilerplate"`
+	JWTAudience      []string `env:"JWT_AUDIENCE" env-default:"go-boilerplate"`
+	JWTLeewaySeconds int      `env:"JWT_LEEWAY_SECONDS" env-default:"30"`
+
+	// TokenRevocationStore selects where revoked access tokens are tracked: "memory" or "postgres".
+	TokenRevocationStore string `env:"TOKEN_REVOCATION_STORE" env-default:"memory"`
+
+	BcryptCost int `env:"BCRYPT_COST" env-default:"12"`
+
+	Housekeeping struct {
+		IntervalMinutes     int `env:"HOUSEKEEPING_INTERVAL_MINUTES" env-default:"60"`
+		JitterSeconds       int `env:"HOUSEKEEPING_JITTER_SECONDS" env-default:"300"`
+		TokenRetentionHours int `env:"REFRESH_TOKEN_RETENTION_HOURS" env-default:"168"`
+		PurgeBatchSize      int `env:"PURGE_BATCH_SIZE" env-default:"1000"`
+	}
+
+	// Lockout throttles failed logins per account and per client address.
+	Lockout struct {
+		MaxAccountFailures   int `env:"LOGIN_MAX_ACCOUNT_FAILURES" env-default:"5"`
+		MaxIPFailures        int `env:"LOGIN_MAX_IP_FAILURES" env-default:"20"`
+		FailureWindowMinutes int `env:"LOGIN_FAILURE_WINDOW_MINUTES" env-default:"15"`
+		BaseLockoutSeconds   int `env:"LOGIN_LOCKOUT_BASE_SECONDS" env-default:"30"`
+		MaxLockoutMinutes    int `env:"LOGIN_LOCKOUT_MAX_MINUTES" env-default:"60"`
+	}
+
+	// AdminUsernames are promoted to the admin role on start-up.
+	AdminUsernames []string `env:"ADMIN_USERNAMES"`
+
+	// IntrospectionClients are "client_id:secret" pairs of services allowed to call /auth/introspect.
+	IntrospectionClients []string `env:"INTROSPECTION_CLIENTS"`
+
+	// TokenPepper keys the HMAC under which refresh tokens and other opaque secrets are stored.
+	TokenPepper string `env:"TOKEN_PEPPER" env-required:"true"`
+
+	// AppBaseURL is the front-end origin that links in emails point to.
+	AppBaseURL string `env:"APP_BASE_URL" env-default:"http://localhost:3000"`
+
+	// Mail selects how emails are delivered: "smtp", or "file" to write them to MailDir.
+	Mail struct {
+		Driver       string `env:"MAIL_DRIVER" env-default:"file"`
+		From         string `env:"MAIL_FROM" env-default:"no-reply@localhost"`
+		Dir          string `env:"MAIL_DIR" env-default:"./tmp/mail"`
+		SMTPHost     string `env:"SMTP_HOST"`
+		SMTPPort     string `env:"SMTP_PORT" env-default:"587"`
+		SMTPUsername string `env:"SMTP_USERNAME"`
+		SMTPPassword string `env:"SMTP_PASSWORD"`
+	}
+
+	// MFAEncryptionKey encrypts TOTP secrets at rest; changing it disables every authenticator.
+	MFAEncryptionKey string `env:"MFA_ENCRYPTION_KEY" env-required:"true"`
+	// MFAIssuer is the account label shown in authenticator apps.
+	MFAIssuer string `env:"MFA_ISSUER" env-default:"go-boilerplate"`
+
+	// Cookies lets browser clients receive their tokens as HttpOnly cookies, guarded by a
+	// double-submit CSRF token, instead of in the response body.
+	Cookies struct {
+		Enabled  bool   `env:"AUTH_COOKIES_ENABLED" env-default:"false"`
+		Domain   string `env:"AUTH_COOKIE_DOMAIN"`
+		Secure   bool   `env:"AUTH_COOKIE_SECURE" env-default:"true"`
+		SameSite string `env:"AUTH_COOKIE_SAMESITE" env-default:"strict"`
+	}
+
+	// OIDCProvidersFile is a JSON file listing single sign-on providers; SSO is off when empty.
+	OIDCProvidersFile string `env:"OIDC_PROVIDERS_FILE"`
+
+	// Pricing refreshes holdings' prices: "file" reads quotes from a CSV file, "http" asks
+	// a JSON price service. Prices are left alone when no provider is set.
+	Pricing struct {
+		Provider               string `env:"PRICE_PROVIDER"`
+		File                   string `env:"PRICE_FILE" env-default:"./prices.csv"`
+		HTTPURL                string `env:"PRICE_HTTP_URL"`
+		HTTPAPIKey             string `env:"PRICE_HTTP_API_KEY"`
+		DefaultCurrency        string `env:"PRICE_DEFAULT_CURRENCY" env-default:"USD"`
+		RefreshIntervalSeconds int    `env:"PRICE_REFRESH_INTERVAL_SECONDS" env-default:"300"`
+		RefreshJitterSeconds   int    `env:"PRICE_REFRESH_JITTER_SECONDS" env-default:"30"`
+	}
 }
 
 func NewConfig() (*Config, error) {
@@ -32,5 +120,10 @@ func NewConfig() (*Config, error) {
 	if err != nil {
 		return nil, err
 	}
+
+	if cfg.JWTSecret == "" && cfg.JWTSigningKeysDir == "" {
+		return nil, errors.New("either JWT_SECRET or JWT_SIGNING_KEYS_DIR must be set")
+	}
+
 	return cfg, nil
 }
@@ -0,0 +1,90 @@
+package portfolio
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go-boilerplate/internal/crypto/pricing"
+)
+
+// dayChange is how much the value of the units held moved over the last day, and what
+// they were worth at its start.
+type dayChange struct {
+	change        float64
+	previousValue float64
+}
+
+func (d dayChange) pct() float64 {
+	if d.previousValue == 0 {
+		return 0
+	}
+	return d.change / d.previousValue * 100
+}
+
+// referenceTime is when the price a day change is measured from was current, for a
+// holding priced at pricedAt. Crypto trades around the clock and is compared with its
+// price 24 hours earlier. Other assets are compared with their previous close: the last
+// price recorded before the UTC day of their current price, so a holding priced over the
+// weekend still shows Friday's move.
+func referenceTime(assetType string, pricedAt time.Time) time.Time {
+	if assetType == "crypto" {
+		return pricedAt.Add(-24 * time.Hour)
+	}
+	year, month, day := pricedAt.UTC().Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+// maxReferenceAge is how much older than its reference time a price may be and still be
+// compared with. Crypto should have been priced shortly before; other assets may last
+// have closed before a long weekend. A holding with only older history has no day change.
+func maxReferenceAge(assetType string) time.Duration {
+	if assetType == "crypto" {
+		return 6 * time.Hour
+	}
+	return 4 * 24 * time.Hour
+}
+
+// measureDayChange compares the current price of each open holding with its price a day
+// earlier in the portfolio's currency, from the price history. The change is that of the
+// units held now. Holdings with no earlier price are taken as unchanged, so they add
+// their market value to the portfolio's previous value but nothing to its change, and so
+// are holdings whose last earlier price is too old to say anything about the last day.
+func (u *usecase) measureDayChange(ctx context.Context, portfolio *Portfolio, now time.Time) (map[assetKey]dayChange, dayChange, error) {
+	byHolding := make(map[assetKey]dayChange, len(portfolio.Holdings))
+	var total dayChange
+
+	for _, holding := range portfolio.Holdings {
+		if holding.Quantity <= 0 {
+			continue
+		}
+		value := holding.Quantity * holding.CurrentPrice
+
+		pricedAt := now
+		if holding.PriceUpdatedAt != nil {
+			pricedAt = *holding.PriceUpdatedAt
+		}
+		series := pricing.Series{Symbol: holding.Symbol, AssetType: holding.AssetType, Currency: portfolio.Currency}
+		at := referenceTime(holding.AssetType, pricedAt)
+		reference, err := u.priceHistory.AsOf(ctx, series, at)
+		if err == nil && reference.Timestamp.Before(at.Add(-maxReferenceAge(holding.AssetType))) {
+			err = pricing.ErrPriceNotFound
+		}
+		if err != nil {
+			if errors.Is(err, pricing.ErrPriceNotFound) {
+				total.previousValue += value
+				continue
+			}
+			return nil, dayChange{}, fmt.Errorf("failed to get previous price of %s: %w", holding.Symbol, err)
+		}
+
+		previousValue := holding.Quantity * reference.Close
+		change := dayChange{change: value - previousValue, previousValue: previousValue}
+		byHolding[holdingKey(holding)] = change
+		total.change += change.change
+		total.previousValue += change.previousValue
+	}
+
+	return byHolding, total, nil
+}
@@ -2,6 +2,7 @@ package portfolio
 
 import (
 	"context"
+	"go-boilerplate/internal/crypto/pricing"
 	"go-boilerplate/internal/dto"
 	"time"
 
@@ -10,42 +11,292 @@ import (
 )
 
 type Portfolio struct {
-	ID          uuid.UUID      `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
-	UserID      uuid.UUID      `json:"user_id" gorm:"type:uuid;not null;index"`
-	Name        string         `json:"name" gorm:"type:varchar(100);not null"`
-	Description *string        `json:"description,omitempty" gorm:"type:text"`
-	TotalValue  float64        `json:"total_value" gorm:"type:decimal(15,2);default:0"`
-	Currency    string         `json:"currency" gorm:"type:varchar(3);default:'USD'"`
-	IsActive    bool           `json:"is_active" gorm:"default:true"`
-	CreatedAt   time.Time      `json:"created_at" gorm:"autoCreateTime"`
-	UpdatedAt   time.Time      `json:"updated_at" gorm:"autoUpdateTime"`
-	DeletedAt   gorm.DeletedAt `json:"-" gorm:"index"`
+	ID          uuid.UUID `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	UserID      uuid.UUID `json:"user_id" gorm:"type:uuid;not null;index"`
+	Name        string    `json:"name" gorm:"type:varchar(100);not null"`
+	Description *string   `json:"description,omitempty" gorm:"type:text"`
+	TotalValue  float64   `json:"total_value" gorm:"type:decimal(15,2);default:0"`
+	Currency    string    `json:"currency" gorm:"type:varchar(3);default:'USD'"`
+	// CostBasisMethod picks the lots that sales and transfers out consume.
+	CostBasisMethod CostBasisMethod `json:"cost_basis_method" gorm:"type:varchar(10);not null;default:'average'"`
+	IsActive        bool            `json:"is_active" gorm:"default:true"`
+	CreatedAt       time.Time       `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt       time.Time       `json:"updated_at" gorm:"autoUpdateTime"`
+	DeletedAt       gorm.DeletedAt  `json:"-" gorm:"index"`
 
 	// Relations
 	Holdings []Holding `json:"holdings,omitempty" gorm:"foreignKey:PortfolioID"`
 }
+
+// Holding is a position derived from the ledger, valued at CurrentPrice. The price
+// starts at the last trade price and is refreshed from a price provider, which records
+// when and where it was quoted.
 type Holding struct {
-	ID           uuid.UUID      `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
-	PortfolioID  uuid.UUID      `json:"portfolio_id" gorm:"type:uuid;not null;index"`
-	Symbol       string         `json:"symbol" gorm:"type:varchar(10);not null"`
-	AssetType    string         `json:"asset_type" gorm:"type:varchar(20);not null"`
-	Quantity     float64        `json:"quantity" gorm:"type:decimal(15,8);not null"`
-	AvgCost      float64        `json:"avg_cost" gorm:"type:decimal(15,2);not null"`
-	CurrentPrice float64        `json:"current_price" gorm:"type:decimal(15,2);default:0"`
-	MarketValue  float64        `json:"market_value" gorm:"type:decimal(15,2);default:0"`
-	CreatedAt    time.Time      `json:"created_at" gorm:"autoCreateTime"`
-	UpdatedAt    time.Time      `json:"updated_at" gorm:"autoUpdateTime"`
-	DeletedAt    gorm.DeletedAt `json:"-" gorm:"index"`
+	ID             uuid.UUID      `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	PortfolioID    uuid.UUID      `json:"portfolio_id" gorm:"type:uuid;not null;index"`
+	Symbol         string         `json:"symbol" gorm:"type:varchar(10);not null"`
+	AssetType      string         `json:"asset_type" gorm:"type:varchar(20);not null"`
+	Quantity       float64        `json:"quantity" gorm:"type:decimal(15,8);not null"`
+	AvgCost        float64        `json:"avg_cost" gorm:"type:decimal(15,2);not null"`
+	CurrentPrice   float64        `json:"current_price" gorm:"type:decimal(15,2);default:0"`
+	MarketValue    float64        `json:"market_value" gorm:"type:decimal(15,2);default:0"`
+	PriceUpdatedAt *time.Time     `json:"price_updated_at,omitempty"`
+	PriceSource    string         `json:"price_source,omitempty" gorm:"type:varchar(100)"`
+	CreatedAt      time.Time      `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt      time.Time      `json:"updated_at" gorm:"autoUpdateTime"`
+	DeletedAt      gorm.DeletedAt `json:"-" gorm:"index"`
+}
+
+// HeldSymbol is a symbol with open holdings in portfolios of one currency.
+type HeldSymbol struct {
+	Symbol    string
+	AssetType string
+	Currency  string
+}
+
+// CostBasisMethod decides which lots a disposal consumes, and so the cost basis and
+// holding period of the units that leave.
+type CostBasisMethod string
+
+const (
+	CostBasisFIFO    CostBasisMethod = "fifo"
+	CostBasisLIFO    CostBasisMethod = "lifo"
+	CostBasisHIFO    CostBasisMethod = "hifo"
+	CostBasisAverage CostBasisMethod = "average"
+	// CostBasisSpecific consumes the lots named on each disposal.
+	CostBasisSpecific CostBasisMethod = "specific"
+)
+
+// TransactionType is what a ledger entry records.
+type TransactionType string
+
+const (
+	TransactionBuy         TransactionType = "buy"
+	TransactionSell        TransactionType = "sell"
+	TransactionTransferIn  TransactionType = "transfer_in"
+	TransactionTransferOut TransactionType = "transfer_out"
+	TransactionFee         TransactionType = "fee"
+	TransactionDeposit     TransactionType = "deposit"
+	TransactionWithdrawal  TransactionType = "withdrawal"
+	// TransactionDividend is cash income paid on a symbol.
+	TransactionDividend TransactionType = "dividend"
+	// TransactionStakingReward is income paid in units of the symbol itself.
+	TransactionStakingReward TransactionType = "staking_reward"
+)
+
+// Transaction is an entry in a portfolio's ledger, from which holdings are derived.
+// Buys, sells, transfers and staking rewards move Quantity units of Symbol at Price
+// each; deposits, withdrawals and dividends move Quantity of cash in Currency, with
+// dividends paid on a Symbol; a fee transaction only carries Fee. Entries are never
+// edited or deleted, only voided.
+type Transaction struct {
+	ID          uuid.UUID       `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	PortfolioID uuid.UUID       `json:"portfolio_id" gorm:"type:uuid;not null;index:idx_transactions_portfolio_symbol"`
+	Type        TransactionType `json:"type" gorm:"type:varchar(20);not null"`
+	Symbol      string          `json:"symbol,omitempty" gorm:"type:varchar(10);index:idx_transactions_portfolio_symbol"`
+	AssetType   string          `json:"asset_type,omitempty" gorm:"type:varchar(20)"`
+	Quantity    float64         `json:"quantity" gorm:"type:decimal(20,8);not null;default:0"`
+	Price       float64         `json:"price" gorm:"type:decimal(20,8);not null;default:0"`
+	Fee         float64         `json:"fee" gorm:"type:decimal(20,8);not null;default:0"`
+	Currency    string          `json:"currency" gorm:"type:varchar(3);not null"`
+	ExecutedAt  time.Time       `json:"executed_at" gorm:"not null;index"`
+	Notes       *string         `json:"notes,omitempty" gorm:"type:text"`
+	// CostBasisMethod is fixed on sales and transfers out when they are posted, so a
+	// later change to the portfolio's method leaves them as they were.
+	CostBasisMethod CostBasisMethod `json:"cost_basis_method,omitempty" gorm:"type:varchar(10)"`
+	// LotSelections names the lots a disposal consumes under specific identification.
+	LotSelections []LotSelection `json:"lots,omitempty" gorm:"type:text;serializer:json"`
+	VoidedAt      *time.Time     `json:"voided_at,omitempty"`
+	VoidReason    *string        `json:"void_reason,omitempty" gorm:"type:text"`
+	CreatedAt     time.Time      `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt     time.Time      `json:"updated_at" gorm:"autoUpdateTime"`
+
+	// Disposals lists the lots a sale or transfer out consumed. It is filled in when
+	// the transaction is recorded and not stored.
+	Disposals []Disposal `json:"disposals,omitempty" gorm:"-"`
+}
+
+// LotSelection is part of a lot, identified by the transaction that acquired it.
+type LotSelection struct {
+	LotID    uuid.UUID `json:"lot_id"`
+	Quantity float64   `json:"quantity"`
+}
+
+// TransactionFilter selects ledger entries. Zero fields match everything.
+type TransactionFilter struct {
+	Symbol        string
+	Type          TransactionType
+	IncludeVoided bool
+	Limit         int
+	Offset        int
+}
+
+// Lot is what remains of the units acquired by one buy or transfer in. Its ID is the
+// acquiring transaction's.
+type Lot struct {
+	ID         uuid.UUID
+	AcquiredAt time.Time
+	Quantity   float64
+	CostBasis  float64
+}
+
+// UnitCost is the lot's cost basis per unit.
+func (l Lot) UnitCost() float64 {
+	if l.Quantity == 0 {
+		return 0
+	}
+	return l.CostBasis / l.Quantity
+}
+
+// HoldingTerm is how long the units of a disposal were held, for tax purposes.
+type HoldingTerm string
+
+const (
+	TermShort HoldingTerm = "short"
+	// TermLong applies to units held for more than a year.
+	TermLong HoldingTerm = "long"
+)
+
+// Disposal is the part of a lot consumed by a sale or transfer out. Only sales realize a
+// gain, so Proceeds, Gain and Term are left empty for transfers.
+type Disposal struct {
+	TransactionID uuid.UUID
+	Type          TransactionType
+	LotID         uuid.UUID
+	AcquiredAt    time.Time
+	DisposedAt    time.Time
+	Quantity      float64
+	CostBasis     float64
+	Proceeds      float64
+	Gain          float64
+	Term          HoldingTerm
+}
+
+// RealizedGains splits the gains of sales by holding term.
+type RealizedGains struct {
+	ShortTerm float64
+	LongTerm  float64
+}
+
+// Position is a holding as derived from its transactions: the lots still held, and every
+// disposal made along the way.
+type Position struct {
+	Lots      []Lot
+	Disposals []Disposal
+}
+
+// Quantity is the number of units held.
+func (p Position) Quantity() float64 {
+	var qty float64
+	for _, lot := range p.Lots {
+		qty += lot.Quantity
+	}
+	return qty
+}
+
+// CostBasis is the cost basis of the units held.
+func (p Position) CostBasis() float64 {
+	var basis float64
+	for _, lot := range p.Lots {
+		basis += lot.CostBasis
+	}
+	return basis
+}
+
+// AvgCost is the cost basis per unit held.
+func (p Position) AvgCost() float64 {
+	qty := p.Quantity()
+	if qty == 0 {
+		return 0
+	}
+	return p.CostBasis() / qty
+}
+
+// DisposalsOf returns the disposals made by one transaction.
+func (p Position) DisposalsOf(transactionID uuid.UUID) []Disposal {
+	var disposals []Disposal
+	for _, d := range p.Disposals {
+		if d.TransactionID == transactionID {
+			disposals = append(disposals, d)
+		}
+	}
+	return disposals
+}
+
+// HoldingLots is a holding broken down into its open lots, with the disposals of its
+// symbol so far.
+type HoldingLots struct {
+	Holding         Holding
+	CostBasisMethod CostBasisMethod
+	Lots            []Lot
+	Disposals       []Disposal
 }
 
+// PositionFunc derives a position from a symbol's transactions, oldest first. It fails
+// when the history is impossible, such as selling more than was held at the time.
+type PositionFunc func(history []Transaction) (Position, error)
+
+// Performance is the profit and loss of a holding, an asset type or a whole portfolio.
+// FeesPaid counts every fee, including those already in a cost basis or taken off sale
+// proceeds; TotalReturn is the realized and unrealized P&L plus income, less only the
+// fees not already counted there.
+type Performance struct {
+	RealizedPnL   float64
+	UnrealizedPnL float64
+	FeesPaid      float64
+	Income        float64
+	TotalReturn   float64
+}
+
+func (p *Performance) add(other Performance) {
+	p.RealizedPnL += other.RealizedPnL
+	p.UnrealizedPnL += other.UnrealizedPnL
+	p.FeesPaid += other.FeesPaid
+	p.Income += other.Income
+	p.TotalReturn += other.TotalReturn
+}
+
+// HoldingPerformance is the performance of every symbol the ledger has touched, closed
+// positions included. DayChange is the move in value of the units held over the last
+// day.
+type HoldingPerformance struct {
+	Symbol       string
+	AssetType    string
+	Quantity     float64
+	CostBasis    float64
+	MarketValue  float64
+	DayChange    float64
+	DayChangePct float64
+	Performance
+}
+
+// AssetTypePerformance adds up the performance of the symbols of one asset type.
+type AssetTypePerformance struct {
+	AssetType   string
+	CostBasis   float64
+	MarketValue float64
+	Performance
+}
+
+// PortfolioSummary reports a portfolio's performance from its whole ledger. Open
+// positions are valued at their holdings' current prices.
 type PortfolioSummary struct {
 	Portfolio
+	// TotalReturnPct is TotalReturn relative to the cost basis of the units held and of
+	// those sold.
 	TotalReturn    float64 `json:"total_return"`
 	TotalReturnPct float64 `json:"total_return_pct"`
 	DayChange      float64 `json:"day_change"`
 	DayChangePct   float64 `json:"day_change_pct"`
-	TotalInvested  float64 `json:"total_invested"`
-	HoldingsCount  int     `json:"holdings_count"`
+	// TotalInvested is the cost basis of the units held.
+	TotalInvested float64                `json:"total_invested"`
+	HoldingsCount int                    `json:"holdings_count"`
+	RealizedPnL   float64                `json:"realized_pnl"`
+	UnrealizedPnL float64                `json:"unrealized_pnl"`
+	FeesPaid      float64                `json:"fees_paid"`
+	Income        float64                `json:"income"`
+	ByHolding     []HoldingPerformance   `json:"by_holding"`
+	ByAssetType   []AssetTypePerformance `json:"by_asset_type"`
 }
 
 func (Portfolio) TableName() string {
@@ -56,6 +307,10 @@ func (Holding) TableName() string {
 	return "holdings"
 }
 
+func (Transaction) TableName() string {
+	return "transactions"
+}
+
 type Usecase interface {
 	CreatePortfolio(ctx context.Context, userID uuid.UUID, req dto.CreatePortfolioRequest) (*Portfolio, error)
 	GetPortfolio(ctx context.Context, userID, portfolioID uuid.UUID) (*Portfolio, error)
@@ -65,6 +320,10 @@ type Usecase interface {
 	AddHolding(ctx context.Context, userID, portfolioID uuid.UUID, req dto.AddHoldingRequest) (*Holding, error)
 	RemoveHolding(ctx context.Context, userID, portfolioID, holdingID uuid.UUID) error
 	GetPortfolioSummary(ctx context.Context, userID, portfolioID uuid.UUID) (*PortfolioSummary, error)
+	RecordTransaction(ctx context.Context, userID, portfolioID uuid.UUID, req dto.CreateTransactionRequest) (*Transaction, error)
+	ListTransactions(ctx context.Context, userID, portfolioID uuid.UUID, filter TransactionFilter) ([]Transaction, int64, error)
+	VoidTransaction(ctx context.Context, userID, portfolioID, transactionID uuid.UUID, reason string) (*Transaction, error)
+	GetHoldingLots(ctx context.Context, userID, portfolioID, holdingID uuid.UUID) (*HoldingLots, error)
 }
 
 type Repository interface {
@@ -73,8 +332,27 @@ type Repository interface {
 	GetByUserID(ctx context.Context, userID uuid.UUID) ([]Portfolio, error)
 	Update(ctx context.Context, portfolio *Portfolio) error
 	Delete(ctx context.Context, id uuid.UUID) error
-	AddHolding(ctx context.Context, holding *Holding) error
-	UpdateHolding(ctx context.Context, holding *Holding) error
-	RemoveHolding(ctx context.Context, portfolioID, holdingID uuid.UUID) error
 	GetHoldingsByPortfolioID(ctx context.Context, portfolioID uuid.UUID) ([]Holding, error)
+
+	// RecordTransaction appends txn to the ledger and, in the same database transaction,
+	// rebuilds the holding for its symbol and asset type with derive and the portfolio's total value.
+	// It returns the rebuilt holding, or nil when the position is closed or txn has no
+	// symbol. Nothing is written if derive fails.
+	RecordTransaction(ctx context.Context, txn *Transaction, derive PositionFunc) (*Holding, error)
+	// VoidTransaction marks a transaction void and rebuilds its holding as above.
+	VoidTransaction(ctx context.Context, portfolioID, transactionID uuid.UUID, reason string, derive PositionFunc) (*Transaction, error)
+	ListTransactions(ctx context.Context, portfolioID uuid.UUID, filter TransactionFilter) ([]Transaction, int64, error)
+	// ListSymbolTransactions returns the history of a symbol and asset type that holdings
+	// are derived from: its transactions that are not void, oldest first.
+	ListSymbolTransactions(ctx context.Context, portfolioID uuid.UUID, symbol, assetType string) ([]Transaction, error)
+	// ListLedger returns every transaction of the portfolio that is not void, oldest first.
+	ListLedger(ctx context.Context, portfolioID uuid.UUID) ([]Transaction, error)
+
+	// ListHeldSymbols returns every symbol with an open holding, once per currency of the
+	// portfolios holding it.
+	ListHeldSymbols(ctx context.Context) ([]HeldSymbol, error)
+	// ApplyQuote values the holdings of the quoted symbol in portfolios of the quote's
+	// currency at its price, and refreshes those portfolios' total value. Holdings priced
+	// by a more recent quote are left alone. It returns how many holdings were updated.
+	ApplyQuote(ctx context.Context, quote pricing.Quote) (int64, error)
 }
@@ -16,4 +16,20 @@ var (
 
 	// ErrInvalidInput is returned when input validation fails.
 	ErrInvalidInput = errors.New("invalid input")
+
+	// ErrTransactionNotFound is returned when a transaction is not in the portfolio's ledger.
+	ErrTransactionNotFound = errors.New("transaction not found")
+
+	// ErrInvalidTransaction is returned when a transaction is missing or has fields its type forbids.
+	ErrInvalidTransaction = errors.New("invalid transaction")
+
+	// ErrInsufficientQuantity is returned when a transaction would leave a negative position.
+	ErrInsufficientQuantity = errors.New("insufficient quantity")
+
+	// ErrTransactionVoided is returned when voiding a transaction that is already void.
+	ErrTransactionVoided = errors.New("transaction already voided")
+
+	// ErrInvalidLotSelection is returned when a disposal names a lot that is not held, or
+	// more of it than is left.
+	ErrInvalidLotSelection = errors.New("invalid lot selection")
 )
@@ -3,6 +3,7 @@ package portfolio
 import (
 	"errors"
 	"go-boilerplate/internal/dto"
+	"go-boilerplate/internal/infra/auth"
 	"go-boilerplate/pkg/response"
 
 	"github.com/google/uuid"
@@ -23,19 +24,31 @@ func NewHandler(
 	}
 
 	v1 := g.Group("/v1")
-	portfolios := v1.Group("/portfolios")
+	portfolios := v1.Group("/portfolios", bearerMiddleware)
 
-	portfolios.POST("", handler.CreatePortfolio, bearerMiddleware)
-	portfolios.GET("", handler.GetPortfolios, bearerMiddleware)
-	portfolios.GET("/:id", handler.GetPortfolio, bearerMiddleware)
-	portfolios.PUT("/:id", handler.UpdatePortfolio, bearerMiddleware)
-	portfolios.DELETE("/:id", handler.DeletePortfolio, bearerMiddleware)
-	portfolios.GET("/:id/summary", handler.GetPortfolioSummary, bearerMiddleware)
+	// Each route declares the scope it needs; scoped access tokens and API keys without
+	// it are rejected even when the user's role would allow the action.
+	read := auth.RequirePermission(auth.PermPortfoliosRead)
+	write := auth.RequirePermission(auth.PermPortfoliosWrite)
+
+	portfolios.POST("", handler.CreatePortfolio, write)
+	portfolios.GET("", handler.GetPortfolios, read)
+	portfolios.GET("/:id", handler.GetPortfolio, read)
+	portfolios.PUT("/:id", handler.UpdatePortfolio, write)
+	portfolios.DELETE("/:id", handler.DeletePortfolio, write)
+	portfolios.GET("/:id/summary", handler.GetPortfolioSummary, read)
 
 	// Holdings endpoints
 	holdings := portfolios.Group("/:id/holdings")
-	holdings.POST("", handler.AddHolding)
-	holdings.DELETE("/:holdingId", handler.RemoveHolding)
+	holdings.POST("", handler.AddHolding, write)
+	holdings.DELETE("/:holdingId", handler.RemoveHolding, write)
+	holdings.GET("/:holdingId/lots", handler.GetHoldingLots, read)
+
+	// Transaction ledger, from which holdings are derived
+	transactions := portfolios.Group("/:id/transactions")
+	transactions.POST("", handler.RecordTransaction, write)
+	transactions.GET("", handler.ListTransactions, read)
+	transactions.POST("/:transactionId/void", handler.VoidTransaction, write)
 }
 
 func (h *Handler) CreatePortfolio(c *echo.Context) error {
@@ -233,7 +246,7 @@ func (h *Handler) GetPortfolioSummary(c *echo.Context) error {
 		}
 	}
 
-	return response.Success(c, "success get portfolio summary", summary)
+	return response.Success(c, "success get portfolio summary", ToPortfolioSummaryResponse(summary))
 }
 
 func (h *Handler) AddHolding(c *echo.Context) error {
@@ -269,13 +282,15 @@ func (h *Handler) AddHolding(c *echo.Context) error {
 			return response.NotFound(c, "Portfolio not found")
 		case errors.Is(err, ErrUnauthorized):
 			return response.Forbidden(c, "Access denied")
+		case errors.Is(err, ErrInvalidTransaction):
+			return response.BadRequest(c, err.Error())
 		default:
 			c.Logger().Error("failed to add holding", "error", err, "portfolio_id", portfolioID)
 			return response.InternalServerError(c, "An error occurred")
 		}
 	}
 
-	return response.Success(c, "success add holding", holding)
+	return response.Success(c, "success add holding", ToHoldingResponse(holding))
 }
 
 func (h *Handler) RemoveHolding(c *echo.Context) error {
@@ -306,6 +321,12 @@ func (h *Handler) RemoveHolding(c *echo.Context) error {
 			return response.NotFound(c, "Portfolio not found")
 		case errors.Is(err, ErrUnauthorized):
 			return response.Forbidden(c, "Access denied")
+		case errors.Is(err, ErrHoldingNotFound):
+			return response.NotFound(c, "Holding not found")
+		case errors.Is(err, ErrInvalidTransaction):
+			return response.BadRequest(c, err.Error())
+		case errors.Is(err, ErrInsufficientQuantity):
+			return response.Conflict(c, err.Error())
 		default:
 			c.Logger().Error("failed to remove holding", "error", err, "portfolio_id", portfolioID, "holding_id", holdingID)
 			return response.InternalServerError(c, "An error occurred")
@@ -314,3 +335,195 @@ func (h *Handler) RemoveHolding(c *echo.Context) error {
 
 	return response.Success(c, "success remove holding", nil)
 }
+
+func (h *Handler) RecordTransaction(c *echo.Context) error {
+	userIDValue := c.Get("user_id")
+	userIDStr, ok := userIDValue.(string)
+	if !ok {
+		return response.InternalServerError(c, "invalid user context")
+	}
+
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		return response.BadRequest(c, "invalid user id format")
+	}
+
+	portfolioID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return response.BadRequest(c, "invalid portfolio id")
+	}
+
+	var req dto.CreateTransactionRequest
+	if err := c.Bind(&req); err != nil {
+		return response.BadRequest(c, "invalid request payload")
+	}
+
+	if err := c.Validate(req); err != nil {
+		return response.BadRequest(c, err.Error())
+	}
+
+	txn, err := h.usecase.RecordTransaction(c.Request().Context(), userID, portfolioID, req)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrNotFound):
+			return response.NotFound(c, "Portfolio not found")
+		case errors.Is(err, ErrUnauthorized):
+			return response.Forbidden(c, "Access denied")
+		case errors.Is(err, ErrInvalidTransaction), errors.Is(err, ErrInvalidLotSelection):
+			return response.BadRequest(c, err.Error())
+		case errors.Is(err, ErrInsufficientQuantity):
+			return response.Conflict(c, err.Error())
+		default:
+			c.Logger().Error("failed to record transaction", "error", err, "portfolio_id", portfolioID)
+			return response.InternalServerError(c, "An error occurred")
+		}
+	}
+
+	return response.Created(c, "transaction recorded", ToTransactionResponse(txn))
+}
+
+func (h *Handler) ListTransactions(c *echo.Context) error {
+	userIDValue := c.Get("user_id")
+	userIDStr, ok := userIDValue.(string)
+	if !ok {
+		return response.InternalServerError(c, "invalid user context")
+	}
+
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		return response.BadRequest(c, "invalid user id format")
+	}
+
+	portfolioID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return response.BadRequest(c, "invalid portfolio id")
+	}
+
+	req := dto.ListTransactionsRequest{Page: 1, PageSize: 50}
+	if err := echo.BindQueryParams(c, &req); err != nil {
+		return response.BadRequest(c, "invalid query parameters")
+	}
+
+	if err := c.Validate(req); err != nil {
+		return response.BadRequest(c, err.Error())
+	}
+
+	transactions, total, err := h.usecase.ListTransactions(c.Request().Context(), userID, portfolioID, TransactionFilter{
+		Symbol:        req.Symbol,
+		Type:          TransactionType(req.Type),
+		IncludeVoided: req.IncludeVoided,
+		Limit:         req.PageSize,
+		Offset:        (req.Page - 1) * req.PageSize,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrNotFound):
+			return response.NotFound(c, "Portfolio not found")
+		case errors.Is(err, ErrUnauthorized):
+			return response.Forbidden(c, "Access denied")
+		default:
+			c.Logger().Error("failed to list transactions", "error", err, "portfolio_id", portfolioID)
+			return response.InternalServerError(c, "An error occurred")
+		}
+	}
+
+	responseData := ToTransactionListResponse(transactions, dto.PaginationResponse{
+		Page:       req.Page,
+		PageSize:   req.PageSize,
+		Total:      total,
+		TotalPages: int((total + int64(req.PageSize) - 1) / int64(req.PageSize)),
+	})
+
+	return response.Success(c, "success get transactions", responseData)
+}
+
+func (h *Handler) VoidTransaction(c *echo.Context) error {
+	userIDValue := c.Get("user_id")
+	userIDStr, ok := userIDValue.(string)
+	if !ok {
+		return response.InternalServerError(c, "invalid user context")
+	}
+
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		return response.BadRequest(c, "invalid user id format")
+	}
+
+	portfolioID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return response.BadRequest(c, "invalid portfolio id")
+	}
+
+	transactionID, err := uuid.Parse(c.Param("transactionId"))
+	if err != nil {
+		return response.BadRequest(c, "invalid transaction id")
+	}
+
+	var req dto.VoidTransactionRequest
+	if err := c.Bind(&req); err != nil {
+		return response.BadRequest(c, "invalid request payload")
+	}
+
+	if err := c.Validate(req); err != nil {
+		return response.BadRequest(c, err.Error())
+	}
+
+	txn, err := h.usecase.VoidTransaction(c.Request().Context(), userID, portfolioID, transactionID, req.Reason)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrNotFound):
+			return response.NotFound(c, "Portfolio not found")
+		case errors.Is(err, ErrUnauthorized):
+			return response.Forbidden(c, "Access denied")
+		case errors.Is(err, ErrTransactionNotFound):
+			return response.NotFound(c, "Transaction not found")
+		case errors.Is(err, ErrTransactionVoided), errors.Is(err, ErrInsufficientQuantity), errors.Is(err, ErrInvalidLotSelection):
+			return response.Conflict(c, err.Error())
+		default:
+			c.Logger().Error("failed to void transaction", "error", err, "portfolio_id", portfolioID, "transaction_id", transactionID)
+			return response.InternalServerError(c, "An error occurred")
+		}
+	}
+
+	return response.Success(c, "transaction voided", ToTransactionResponse(txn))
+}
+
+func (h *Handler) GetHoldingLots(c *echo.Context) error {
+	userIDValue := c.Get("user_id")
+	userIDStr, ok := userIDValue.(string)
+	if !ok {
+		return response.InternalServerError(c, "invalid user context")
+	}
+
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		return response.BadRequest(c, "invalid user id format")
+	}
+
+	portfolioID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return response.BadRequest(c, "invalid portfolio id")
+	}
+
+	holdingID, err := uuid.Parse(c.Param("holdingId"))
+	if err != nil {
+		return response.BadRequest(c, "invalid holding id")
+	}
+
+	lots, err := h.usecase.GetHoldingLots(c.Request().Context(), userID, portfolioID, holdingID)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrNotFound):
+			return response.NotFound(c, "Portfolio not found")
+		case errors.Is(err, ErrUnauthorized):
+			return response.Forbidden(c, "Access denied")
+		case errors.Is(err, ErrHoldingNotFound):
+			return response.NotFound(c, "Holding not found")
+		default:
+			c.Logger().Error("failed to get holding lots", "error", err, "portfolio_id", portfolioID, "holding_id", holdingID)
+			return response.InternalServerError(c, "An error occurred")
+		}
+	}
+
+	return response.Success(c, "success get holding lots", ToHoldingLotsResponse(lots))
+}
@@ -0,0 +1,91 @@
+package portfolio
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"go-boilerplate/internal/crypto/pricing"
+	"go-boilerplate/internal/infra/scheduler"
+)
+
+// NewPriceRefreshJob values every held symbol at its latest quote, updating the current
+// price and market value of its holdings and their portfolios' total value. The quotes
+// are also recorded in the price history, which day changes are measured against.
+func NewPriceRefreshJob(repo Repository, prices pricing.PriceProvider, history pricing.Repository, interval, jitter time.Duration) scheduler.Job {
+	return scheduler.Job{
+		Name:     "refresh-prices",
+		Interval: interval,
+		Jitter:   jitter,
+		Run: func(ctx context.Context) error {
+			return refreshPrices(ctx, repo, prices, history)
+		},
+	}
+}
+
+// refreshPrices quotes each held symbol once. A quote is only applied to portfolios of
+// its currency, however either spells it, since there are no exchange rates to convert with. Symbols that cannot be
+// quoted keep their price; the run only fails when no symbol could be quoted at all.
+func refreshPrices(ctx context.Context, repo Repository, prices pricing.PriceProvider, history pricing.Repository) error {
+	held, err := repo.ListHeldSymbols(ctx)
+	if err != nil {
+		return err
+	}
+
+	type quoteKey struct{ symbol, assetType string }
+	quotes := make(map[quoteKey]*pricing.Quote)
+	var recorded []pricing.Price
+	var updated int64
+	var failed int
+	var lastErr error
+
+	for _, h := range held {
+		key := quoteKey{h.Symbol, h.AssetType}
+		quote, seen := quotes[key]
+		if !seen {
+			q, err := prices.Quote(ctx, h.Symbol, h.AssetType)
+			if err != nil {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				slog.WarnContext(ctx, "failed to get quote", "symbol", h.Symbol, "asset_type", h.AssetType, "error", err)
+				failed++
+				lastErr = err
+			} else {
+				// Apply the quote to the symbol exactly as the holdings spell it.
+				q.Symbol, q.AssetType = h.Symbol, h.AssetType
+				quote = &q
+				recorded = append(recorded, pricing.QuotePrice(q))
+			}
+			quotes[key] = quote
+		}
+		if quote == nil {
+			continue
+		}
+		if !strings.EqualFold(quote.Currency, h.Currency) {
+			slog.DebugContext(ctx, "quote is in another currency",
+				"symbol", h.Symbol, "quote_currency", quote.Currency, "portfolio_currency", h.Currency)
+			continue
+		}
+
+		n, err := repo.ApplyQuote(ctx, *quote)
+		if err != nil {
+			return err
+		}
+		updated += n
+	}
+
+	if len(recorded) > 0 {
+		if _, err := history.Ingest(ctx, recorded); err != nil {
+			return fmt.Errorf("failed to record prices: %w", err)
+		}
+	}
+
+	slog.InfoContext(ctx, "refreshed prices", "symbols", len(quotes), "holdings", updated, "failed", failed)
+	if failed > 0 && failed == len(quotes) {
+		return fmt.Errorf("no symbol could be quoted: %w", lastErr)
+	}
+	return nil
+}
@@ -0,0 +1,185 @@
+package portfolio
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"go-boilerplate/internal/dto"
+
+	"github.com/google/uuid"
+)
+
+// quantityEpsilon absorbs float rounding when a sale closes a position; quantities are
+// stored with 8 decimal places.
+const quantityEpsilon = 1e-9
+
+// futureTolerance allows for clock skew between the client and the server.
+const futureTolerance = 5 * time.Minute
+
+// newTransaction builds and checks a ledger entry for a portfolio. Each type requires
+// the fields that give it meaning and rejects those it has no use for.
+func newTransaction(portfolio *Portfolio, req dto.CreateTransactionRequest, now time.Time) (*Transaction, error) {
+	txn := &Transaction{
+		PortfolioID: portfolio.ID,
+		Type:        TransactionType(req.Type),
+		Symbol:      strings.ToUpper(strings.TrimSpace(req.Symbol)),
+		AssetType:   req.AssetType,
+		Quantity:    req.Quantity,
+		Price:       req.Price,
+		Fee:         req.Fee,
+		Currency:    strings.ToUpper(req.Currency),
+		ExecutedAt:  now,
+		Notes:       req.Notes,
+	}
+	for _, sel := range req.Lots {
+		txn.LotSelections = append(txn.LotSelections, LotSelection{LotID: sel.LotID, Quantity: sel.Quantity})
+	}
+	if txn.Currency == "" {
+		txn.Currency = portfolio.Currency
+	}
+	if req.ExecutedAt != nil {
+		txn.ExecutedAt = *req.ExecutedAt
+	}
+
+	invalid := func(reason string) error {
+		return fmt.Errorf("%w: %s", ErrInvalidTransaction, reason)
+	}
+
+	// Holdings are valued in the portfolio's currency and there are no exchange rates
+	// to convert with.
+	if txn.Currency != portfolio.Currency {
+		return nil, invalid("currency must be the portfolio's currency, " + portfolio.Currency)
+	}
+	if txn.ExecutedAt.After(now.Add(futureTolerance)) {
+		return nil, invalid("executed_at is in the future")
+	}
+
+	switch txn.Type {
+	case TransactionBuy, TransactionSell, TransactionTransferIn, TransactionTransferOut:
+		if txn.Symbol == "" || txn.AssetType == "" {
+			return nil, invalid(string(txn.Type) + " needs a symbol and asset_type")
+		}
+		if txn.Quantity <= 0 {
+			return nil, invalid(string(txn.Type) + " needs a positive quantity")
+		}
+	case TransactionDeposit, TransactionWithdrawal:
+		if txn.Symbol != "" {
+			return nil, invalid(string(txn.Type) + " moves cash and takes no symbol")
+		}
+		if txn.Quantity <= 0 {
+			return nil, invalid(string(txn.Type) + " needs a positive quantity of cash")
+		}
+		if txn.Price != 0 {
+			return nil, invalid(string(txn.Type) + " takes no price")
+		}
+	case TransactionDividend:
+		if txn.Symbol == "" || txn.AssetType == "" {
+			return nil, invalid("dividend needs the symbol and asset_type it was paid on")
+		}
+		if txn.Quantity <= 0 {
+			return nil, invalid("dividend needs a positive quantity of cash")
+		}
+		if txn.Price != 0 {
+			return nil, invalid("dividend takes no price")
+		}
+	case TransactionStakingReward:
+		if txn.Symbol == "" || txn.AssetType == "" {
+			return nil, invalid("staking_reward needs a symbol and asset_type")
+		}
+		if txn.Quantity <= 0 {
+			return nil, invalid("staking_reward needs a positive quantity")
+		}
+	case TransactionFee:
+		if txn.Fee <= 0 {
+			return nil, invalid("fee needs a positive fee")
+		}
+		if txn.Quantity != 0 || txn.Price != 0 {
+			return nil, invalid("fee takes no quantity or price")
+		}
+		if txn.Symbol != "" && txn.AssetType == "" {
+			return nil, invalid("fee charged on a symbol needs its asset_type")
+		}
+	default:
+		return nil, invalid("unknown type " + string(txn.Type))
+	}
+
+	if len(txn.LotSelections) > 0 {
+		if !txn.Type.disposes() {
+			return nil, invalid("only a sell or transfer_out takes lots")
+		}
+		var selected float64
+		seen := make(map[uuid.UUID]bool, len(txn.LotSelections))
+		for _, sel := range txn.LotSelections {
+			if seen[sel.LotID] {
+				return nil, invalid("lot " + sel.LotID.String() + " is selected twice")
+			}
+			seen[sel.LotID] = true
+			selected += sel.Quantity
+		}
+		if math.Abs(selected-txn.Quantity) > quantityEpsilon {
+			return nil, invalid("the quantities of the selected lots must add up to the quantity")
+		}
+	}
+
+	return txn, nil
+}
+
+// disposes reports whether transactions of the type take units out of lots.
+func (t TransactionType) disposes() bool {
+	return t == TransactionSell || t == TransactionTransferOut
+}
+
+// assignCostBasisMethod fixes the method a sale or transfer out is matched to lots with:
+// specific identification when it names lots, otherwise the portfolio's method.
+func assignCostBasisMethod(portfolio *Portfolio, txn *Transaction) error {
+	if !txn.Type.disposes() {
+		return nil
+	}
+
+	switch {
+	case len(txn.LotSelections) > 0:
+		txn.CostBasisMethod = CostBasisSpecific
+	case portfolio.CostBasisMethod == CostBasisSpecific:
+		return fmt.Errorf("%w: the portfolio uses specific identification, so %s needs lots",
+			ErrInvalidTransaction, txn.Type)
+	case portfolio.CostBasisMethod == "":
+		txn.CostBasisMethod = CostBasisAverage
+	default:
+		txn.CostBasisMethod = portfolio.CostBasisMethod
+	}
+	return nil
+}
+
+// derivePosition replays a symbol's transactions into lots. Each buy or transfer in opens
+// a lot costing its quantity at its price plus its fee, and each staking reward one at
+// its price when received, which is the income it represents. Each sale or transfer out
+// consumes lots by the cost basis method fixed on it. Fees on their own, and dividends,
+// do not touch the lots.
+func derivePosition(history []Transaction) (Position, error) {
+	var pos Position
+	for _, txn := range history {
+		switch txn.Type {
+		case TransactionBuy, TransactionTransferIn:
+			pos.Lots = append(pos.Lots, Lot{
+				ID:         txn.ID,
+				AcquiredAt: txn.ExecutedAt,
+				Quantity:   txn.Quantity,
+				CostBasis:  txn.Quantity*txn.Price + txn.Fee,
+			})
+		case TransactionStakingReward:
+			pos.Lots = append(pos.Lots, Lot{
+				ID:         txn.ID,
+				AcquiredAt: txn.ExecutedAt,
+				Quantity:   txn.Quantity,
+				CostBasis:  txn.Quantity * txn.Price,
+			})
+		case TransactionSell, TransactionTransferOut:
+			if err := pos.dispose(txn); err != nil {
+				return Position{}, err
+			}
+		}
+	}
+	return pos, nil
+}
@@ -0,0 +1,182 @@
+package portfolio
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// lotPick is a quantity to take from the lot at index in Position.Lots.
+type lotPick struct {
+	index    int
+	quantity float64
+}
+
+// dispose takes txn's quantity out of the lots picked by its cost basis method and
+// records a disposal for each lot touched. Transactions posted before lots were tracked
+// carry no method and are replayed at average cost, as they were at the time.
+func (p *Position) dispose(txn Transaction) error {
+	if held := p.Quantity(); txn.Quantity > held+quantityEpsilon {
+		return fmt.Errorf("%w: %s of %g %s on %s exceeds the %g held",
+			ErrInsufficientQuantity, txn.Type, txn.Quantity, txn.Symbol,
+			txn.ExecutedAt.Format(time.RFC3339), held)
+	}
+
+	method := txn.CostBasisMethod
+	if method == "" {
+		method = CostBasisAverage
+	}
+	if method == CostBasisAverage {
+		p.poolCost()
+	}
+
+	picks, err := p.pickLots(txn, method)
+	if err != nil {
+		return err
+	}
+
+	// A sale's fee comes off its proceeds, shared across the lots by quantity.
+	var proceeds float64
+	if txn.Type == TransactionSell {
+		proceeds = txn.Quantity*txn.Price - txn.Fee
+	}
+
+	for _, pick := range picks {
+		lot := &p.Lots[pick.index]
+		qty := math.Min(pick.quantity, lot.Quantity)
+		cost := lot.UnitCost() * qty
+		if lot.Quantity-qty < quantityEpsilon {
+			cost = lot.CostBasis
+		}
+		lot.Quantity -= qty
+		lot.CostBasis -= cost
+
+		disposal := Disposal{
+			TransactionID: txn.ID,
+			Type:          txn.Type,
+			LotID:         lot.ID,
+			AcquiredAt:    lot.AcquiredAt,
+			DisposedAt:    txn.ExecutedAt,
+			Quantity:      qty,
+			CostBasis:     cost,
+		}
+		if txn.Type == TransactionSell {
+			disposal.Proceeds = proceeds * qty / txn.Quantity
+			disposal.Gain = disposal.Proceeds - cost
+			disposal.Term = holdingTerm(lot.AcquiredAt, txn.ExecutedAt)
+		}
+		p.Disposals = append(p.Disposals, disposal)
+	}
+
+	open := p.Lots[:0]
+	for _, lot := range p.Lots {
+		if lot.Quantity >= quantityEpsilon {
+			open = append(open, lot)
+		}
+	}
+	p.Lots = open
+	return nil
+}
+
+// pickLots chooses the lots a disposal consumes. FIFO and average cost take the oldest
+// lots first, LIFO the newest and HIFO the dearest, oldest first on a tie.
+func (p *Position) pickLots(txn Transaction, method CostBasisMethod) ([]lotPick, error) {
+	order := make([]int, len(p.Lots))
+	for i := range order {
+		order[i] = i
+	}
+
+	switch method {
+	case CostBasisFIFO, CostBasisAverage:
+	case CostBasisLIFO:
+		sort.SliceStable(order, func(i, j int) bool {
+			return p.Lots[order[i]].AcquiredAt.After(p.Lots[order[j]].AcquiredAt)
+		})
+	case CostBasisHIFO:
+		sort.SliceStable(order, func(i, j int) bool {
+			return p.Lots[order[i]].UnitCost() > p.Lots[order[j]].UnitCost()
+		})
+	case CostBasisSpecific:
+		return p.selectedLots(txn)
+	default:
+		return nil, fmt.Errorf("%w: unknown cost basis method %q", ErrInvalidTransaction, method)
+	}
+
+	var picks []lotPick
+	remaining := txn.Quantity
+	for _, i := range order {
+		if remaining < quantityEpsilon {
+			break
+		}
+		qty := math.Min(remaining, p.Lots[i].Quantity)
+		picks = append(picks, lotPick{index: i, quantity: qty})
+		remaining -= qty
+	}
+	return picks, nil
+}
+
+// selectedLots resolves the lots a disposal names under specific identification.
+func (p *Position) selectedLots(txn Transaction) ([]lotPick, error) {
+	if len(txn.LotSelections) == 0 {
+		return nil, fmt.Errorf("%w: %s on %s names no lots",
+			ErrInvalidLotSelection, txn.Type, txn.ExecutedAt.Format(time.RFC3339))
+	}
+
+	picks := make([]lotPick, 0, len(txn.LotSelections))
+	for _, sel := range txn.LotSelections {
+		index := -1
+		for i := range p.Lots {
+			if p.Lots[i].ID == sel.LotID {
+				index = i
+				break
+			}
+		}
+		if index < 0 {
+			return nil, fmt.Errorf("%w: lot %s is not held on %s",
+				ErrInvalidLotSelection, sel.LotID, txn.ExecutedAt.Format(time.RFC3339))
+		}
+		if sel.Quantity > p.Lots[index].Quantity+quantityEpsilon {
+			return nil, fmt.Errorf("%w: lot %s has %g left, not %g",
+				ErrInvalidLotSelection, sel.LotID, p.Lots[index].Quantity, sel.Quantity)
+		}
+		picks = append(picks, lotPick{index: index, quantity: sel.Quantity})
+	}
+	return picks, nil
+}
+
+// poolCost spreads the cost basis evenly over the units held, so that under average cost
+// every lot has the average unit cost while keeping its own acquisition date.
+func (p *Position) poolCost() {
+	avgCost := p.AvgCost()
+	for i := range p.Lots {
+		p.Lots[i].CostBasis = avgCost * p.Lots[i].Quantity
+	}
+}
+
+// holdingTerm is long for units held more than a year.
+func holdingTerm(acquiredAt, disposedAt time.Time) HoldingTerm {
+	if disposedAt.After(longTermAt(acquiredAt)) {
+		return TermLong
+	}
+	return TermShort
+}
+
+// longTermAt is when units acquired at acquiredAt have been held for a year.
+func longTermAt(acquiredAt time.Time) time.Time {
+	return acquiredAt.AddDate(1, 0, 0)
+}
+
+// realizedGains totals the gains of disposals by holding term.
+func realizedGains(disposals []Disposal) RealizedGains {
+	var gains RealizedGains
+	for _, d := range disposals {
+		switch d.Term {
+		case TermShort:
+			gains.ShortTerm += d.Gain
+		case TermLong:
+			gains.LongTerm += d.Gain
+		}
+	}
+	return gains
+}
@@ -4,32 +4,22 @@ import "go-boilerplate/internal/dto"
 
 func ToPortfolioResponse(p *Portfolio) dto.PortfolioResponse {
 	holdings := make([]dto.HoldingResponse, len(p.Holdings))
-	for i, holding := range p.Holdings {
-		holdings[i] = dto.HoldingResponse{
-			ID:           holding.ID,
-			PortfolioID:  holding.PortfolioID,
-			Symbol:       holding.Symbol,
-			AssetType:    holding.AssetType,
-			Quantity:     holding.Quantity,
-			AvgCost:      holding.AvgCost,
-			CurrentPrice: holding.CurrentPrice,
-			MarketValue:  holding.MarketValue,
-			CreatedAt:    holding.CreatedAt,
-			UpdatedAt:    holding.UpdatedAt,
-		}
+	for i := range p.Holdings {
+		holdings[i] = ToHoldingResponse(&p.Holdings[i])
 	}
 
 	return dto.PortfolioResponse{
-		ID:          p.ID,
-		UserID:      p.UserID,
-		Name:        p.Name,
-		Description: p.Description,
-		TotalValue:  p.TotalValue,
-		Currency:    p.Currency,
-		IsActive:    p.IsActive,
-		CreatedAt:   p.CreatedAt,
-		UpdatedAt:   p.UpdatedAt,
-		Holdings:    holdings,
+		ID:              p.ID,
+		UserID:          p.UserID,
+		Name:            p.Name,
+		Description:     p.Description,
+		TotalValue:      p.TotalValue,
+		Currency:        p.Currency,
+		CostBasisMethod: string(p.CostBasisMethod),
+		IsActive:        p.IsActive,
+		CreatedAt:       p.CreatedAt,
+		UpdatedAt:       p.UpdatedAt,
+		Holdings:        holdings,
 	}
 }
 
@@ -43,3 +33,168 @@ func ToPortfolioListResponse(p []Portfolio) dto.PortfolioListResponse {
 		Portfolios: portfolios,
 	}
 }
+
+func ToPortfolioSummaryResponse(s *PortfolioSummary) dto.PortfolioSummaryResponse {
+	byHolding := make([]dto.HoldingPerformanceResponse, len(s.ByHolding))
+	for i, h := range s.ByHolding {
+		byHolding[i] = dto.HoldingPerformanceResponse{
+			Symbol:              h.Symbol,
+			AssetType:           h.AssetType,
+			Quantity:            h.Quantity,
+			CostBasis:           h.CostBasis,
+			MarketValue:         h.MarketValue,
+			DayChange:           h.DayChange,
+			DayChangePct:        h.DayChangePct,
+			PerformanceResponse: toPerformanceResponse(h.Performance),
+		}
+	}
+
+	byAssetType := make([]dto.AssetTypePerformanceResponse, len(s.ByAssetType))
+	for i, a := range s.ByAssetType {
+		byAssetType[i] = dto.AssetTypePerformanceResponse{
+			AssetType:           a.AssetType,
+			CostBasis:           a.CostBasis,
+			MarketValue:         a.MarketValue,
+			PerformanceResponse: toPerformanceResponse(a.Performance),
+		}
+	}
+
+	return dto.PortfolioSummaryResponse{
+		PortfolioResponse: ToPortfolioResponse(&s.Portfolio),
+		TotalReturn:       s.TotalReturn,
+		TotalReturnPct:    s.TotalReturnPct,
+		DayChange:         s.DayChange,
+		DayChangePct:      s.DayChangePct,
+		TotalInvested:     s.TotalInvested,
+		HoldingsCount:     s.HoldingsCount,
+		RealizedPnL:       s.RealizedPnL,
+		UnrealizedPnL:     s.UnrealizedPnL,
+		FeesPaid:          s.FeesPaid,
+		Income:            s.Income,
+		ByHolding:         byHolding,
+		ByAssetType:       byAssetType,
+	}
+}
+
+func toPerformanceResponse(p Performance) dto.PerformanceResponse {
+	return dto.PerformanceResponse{
+		RealizedPnL:   p.RealizedPnL,
+		UnrealizedPnL: p.UnrealizedPnL,
+		FeesPaid:      p.FeesPaid,
+		Income:        p.Income,
+		TotalReturn:   p.TotalReturn,
+	}
+}
+
+func ToHoldingResponse(holding *Holding) dto.HoldingResponse {
+	return dto.HoldingResponse{
+		ID:             holding.ID,
+		PortfolioID:    holding.PortfolioID,
+		Symbol:         holding.Symbol,
+		AssetType:      holding.AssetType,
+		Quantity:       holding.Quantity,
+		AvgCost:        holding.AvgCost,
+		CurrentPrice:   holding.CurrentPrice,
+		MarketValue:    holding.MarketValue,
+		PriceUpdatedAt: holding.PriceUpdatedAt,
+		PriceSource:    holding.PriceSource,
+		CreatedAt:      holding.CreatedAt,
+		UpdatedAt:      holding.UpdatedAt,
+	}
+}
+
+func ToTransactionResponse(txn *Transaction) dto.TransactionResponse {
+	var lots []dto.LotSelection
+	for _, sel := range txn.LotSelections {
+		lots = append(lots, dto.LotSelection{LotID: sel.LotID, Quantity: sel.Quantity})
+	}
+
+	resp := dto.TransactionResponse{
+		ID:              txn.ID,
+		PortfolioID:     txn.PortfolioID,
+		Type:            string(txn.Type),
+		Symbol:          txn.Symbol,
+		AssetType:       txn.AssetType,
+		Quantity:        txn.Quantity,
+		Price:           txn.Price,
+		Fee:             txn.Fee,
+		Currency:        txn.Currency,
+		ExecutedAt:      txn.ExecutedAt,
+		Notes:           txn.Notes,
+		CostBasisMethod: string(txn.CostBasisMethod),
+		Lots:            lots,
+		VoidedAt:        txn.VoidedAt,
+		VoidReason:      txn.VoidReason,
+		CreatedAt:       txn.CreatedAt,
+	}
+	if len(txn.Disposals) > 0 {
+		resp.Disposals = toDisposalResponses(txn.Disposals)
+		if txn.Type == TransactionSell {
+			gains := toRealizedGainsResponse(realizedGains(txn.Disposals))
+			resp.RealizedGains = &gains
+		}
+	}
+
+	return resp
+}
+
+func ToTransactionListResponse(t []Transaction, pagination dto.PaginationResponse) dto.TransactionListResponse {
+	transactions := make([]dto.TransactionResponse, len(t))
+	for i := range t {
+		transactions[i] = ToTransactionResponse(&t[i])
+	}
+
+	return dto.TransactionListResponse{
+		Transactions: transactions,
+		Pagination:   pagination,
+	}
+}
+
+func ToHoldingLotsResponse(h *HoldingLots) dto.HoldingLotsResponse {
+	lots := make([]dto.LotResponse, len(h.Lots))
+	for i, lot := range h.Lots {
+		lots[i] = dto.LotResponse{
+			LotID:      lot.ID,
+			AcquiredAt: lot.AcquiredAt,
+			Quantity:   lot.Quantity,
+			UnitCost:   lot.UnitCost(),
+			CostBasis:  lot.CostBasis,
+			LongTermAt: longTermAt(lot.AcquiredAt),
+		}
+	}
+
+	return dto.HoldingLotsResponse{
+		Holding:         ToHoldingResponse(&h.Holding),
+		CostBasisMethod: string(h.CostBasisMethod),
+		Lots:            lots,
+		Disposals:       toDisposalResponses(h.Disposals),
+		RealizedGains:   toRealizedGainsResponse(realizedGains(h.Disposals)),
+	}
+}
+
+func toDisposalResponses(d []Disposal) []dto.DisposalResponse {
+	disposals := make([]dto.DisposalResponse, len(d))
+	for i, disposal := range d {
+		disposals[i] = dto.DisposalResponse{
+			TransactionID: disposal.TransactionID,
+			Type:          string(disposal.Type),
+			LotID:         disposal.LotID,
+			AcquiredAt:    disposal.AcquiredAt,
+			DisposedAt:    disposal.DisposedAt,
+			Quantity:      disposal.Quantity,
+			CostBasis:     disposal.CostBasis,
+			Proceeds:      disposal.Proceeds,
+			Gain:          disposal.Gain,
+			Term:          string(disposal.Term),
+		}
+	}
+	return disposals
+}
+
+func toRealizedGainsResponse(g RealizedGains) dto.RealizedGainsResponse {
+	return dto.RealizedGainsResponse{
+		ShortTerm: g.ShortTerm,
+		LongTerm:  g.LongTerm,
+		Total:     g.ShortTerm + g.LongTerm,
+	}
+}
@@ -0,0 +1,75 @@
+package portfolio
+
+import (
+	"fmt"
+
+	"go-boilerplate/internal/database"
+
+	"gorm.io/gorm"
+)
+
+// Migrate creates or updates the portfolio tables.
+func Migrate(db *gorm.DB) error {
+	if err := db.AutoMigrate(&Portfolio{}, &Holding{}, &Transaction{}); err != nil {
+		return err
+	}
+	if err := database.EnsureForeignKey(db, "portfolios", "user_id", "users", "id"); err != nil {
+		return err
+	}
+	if err := database.EnsureForeignKey(db, "transactions", "portfolio_id", "portfolios", "id"); err != nil {
+		return err
+	}
+
+	if err := uppercaseSymbols(db); err != nil {
+		return err
+	}
+	if err := uppercaseCurrencies(db); err != nil {
+		return err
+	}
+	return seedOpeningTransactions(db)
+}
+
+// uppercaseSymbols spells symbols stored before the ledger the way new transactions do,
+// so a legacy "btc" holding and its ledger history are found when a "BTC" transaction
+// is posted. Rows already in upper case are left alone.
+func uppercaseSymbols(db *gorm.DB) error {
+	for _, table := range []string{"holdings", "transactions"} {
+		err := db.Exec(fmt.Sprintf("UPDATE %q SET symbol = UPPER(symbol) WHERE symbol <> UPPER(symbol)", table)).Error
+		if err != nil {
+			return fmt.Errorf("failed to uppercase %s symbols: %w", table, err)
+		}
+	}
+	return nil
+}
+
+// uppercaseCurrencies spells the currencies of portfolios created before they were
+// normalised the way transactions and price quotes do, so both match them.
+func uppercaseCurrencies(db *gorm.DB) error {
+	err := db.Exec("UPDATE portfolios SET currency = UPPER(currency) WHERE currency <> UPPER(currency)").Error
+	if err != nil {
+		return fmt.Errorf("failed to uppercase portfolio currencies: %w", err)
+	}
+	return nil
+}
+
+// seedOpeningTransactions gives holdings created before the ledger existed a transfer in
+// of their quantity at their average cost, so rebuilding them from the ledger leaves them
+// as they were. Holdings whose symbol and asset type already have transactions are
+// skipped, making this safe to run on every start-up.
+func seedOpeningTransactions(db *gorm.DB) error {
+	err := db.Exec(`
+		INSERT INTO transactions (portfolio_id, type, symbol, asset_type, quantity, price, fee, currency, executed_at, notes, created_at, updated_at)
+		SELECT h.portfolio_id, ?, UPPER(h.symbol), h.asset_type, h.quantity, h.avg_cost, 0, p.currency, h.created_at, ?, NOW(), NOW()
+		FROM holdings h
+		JOIN portfolios p ON p.id = h.portfolio_id
+		WHERE h.deleted_at IS NULL AND h.quantity > 0
+		  AND NOT EXISTS (
+			SELECT 1 FROM transactions t WHERE t.portfolio_id = h.portfolio_id AND t.symbol = UPPER(h.symbol) AND t.asset_type = h.asset_type
+		  )`,
+		TransactionTransferIn, "Opening balance carried over from before the ledger",
+	).Error
+	if err != nil {
+		return fmt.Errorf("failed to seed opening transactions: %w", err)
+	}
+	return nil
+}
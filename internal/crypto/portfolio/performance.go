@@ -0,0 +1,122 @@
+package portfolio
+
+import "sort"
+
+// ledgerPerformance is the outcome of replaying a portfolio's whole ledger.
+type ledgerPerformance struct {
+	total       Performance
+	byHolding   []HoldingPerformance
+	byAssetType []AssetTypePerformance
+	// invested is the cost basis of the units held and costOfSales that of the units sold.
+	invested    float64
+	costOfSales float64
+}
+
+// assetKey identifies a position. A ticker alone does not, since a stock and a token may
+// share one.
+type assetKey struct {
+	symbol    string
+	assetType string
+}
+
+func holdingKey(holding Holding) assetKey {
+	return assetKey{symbol: holding.Symbol, assetType: holding.AssetType}
+}
+
+// measurePerformance replays a portfolio's ledger, oldest first, asset by asset. Open
+// positions are valued at the current price of their holding. Fees charged on the
+// portfolio rather than a symbol only count towards the total.
+func measurePerformance(ledger []Transaction, holdings []Holding) (ledgerPerformance, error) {
+	prices := make(map[assetKey]float64, len(holdings))
+	for _, holding := range holdings {
+		prices[holdingKey(holding)] = holding.CurrentPrice
+	}
+
+	var result ledgerPerformance
+	var keys []assetKey
+	histories := make(map[assetKey][]Transaction)
+	for _, txn := range ledger {
+		if txn.Symbol == "" {
+			if txn.Type == TransactionFee {
+				result.total.FeesPaid += txn.Fee
+				result.total.TotalReturn -= txn.Fee
+			}
+			continue
+		}
+		key := assetKey{symbol: txn.Symbol, assetType: txn.AssetType}
+		if _, seen := histories[key]; !seen {
+			keys = append(keys, key)
+		}
+		histories[key] = append(histories[key], txn)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].symbol != keys[j].symbol {
+			return keys[i].symbol < keys[j].symbol
+		}
+		return keys[i].assetType < keys[j].assetType
+	})
+
+	byAssetType := make(map[string]*AssetTypePerformance)
+	for _, key := range keys {
+		history := histories[key]
+		pos, err := derivePosition(history)
+		if err != nil {
+			return ledgerPerformance{}, err
+		}
+
+		holding := HoldingPerformance{
+			Symbol:    key.symbol,
+			AssetType: key.assetType,
+			Quantity:  pos.Quantity(),
+			CostBasis: pos.CostBasis(),
+		}
+		holding.MarketValue = holding.Quantity * prices[key]
+		holding.UnrealizedPnL = holding.MarketValue - holding.CostBasis
+
+		// Fees on buys and transfers in are part of the cost basis, and those on sales come
+		// off the proceeds, so only the rest are charged against the return here.
+		var expensed float64
+		for _, txn := range history {
+			holding.FeesPaid += txn.Fee
+			switch txn.Type {
+			case TransactionDividend:
+				holding.Income += txn.Quantity
+				expensed += txn.Fee
+			case TransactionStakingReward:
+				holding.Income += txn.Quantity * txn.Price
+				expensed += txn.Fee
+			case TransactionFee, TransactionTransferOut:
+				expensed += txn.Fee
+			}
+		}
+		for _, d := range pos.Disposals {
+			if d.Type == TransactionSell {
+				holding.RealizedPnL += d.Gain
+				result.costOfSales += d.CostBasis
+			}
+		}
+		holding.TotalReturn = holding.RealizedPnL + holding.UnrealizedPnL + holding.Income - expensed
+
+		result.byHolding = append(result.byHolding, holding)
+		result.total.add(holding.Performance)
+		result.invested += holding.CostBasis
+
+		assetType, ok := byAssetType[holding.AssetType]
+		if !ok {
+			assetType = &AssetTypePerformance{AssetType: holding.AssetType}
+			byAssetType[holding.AssetType] = assetType
+		}
+		assetType.CostBasis += holding.CostBasis
+		assetType.MarketValue += holding.MarketValue
+		assetType.add(holding.Performance)
+	}
+
+	for _, assetType := range byAssetType {
+		result.byAssetType = append(result.byAssetType, *assetType)
+	}
+	sort.Slice(result.byAssetType, func(i, j int) bool {
+		return result.byAssetType[i].AssetType < result.byAssetType[j].AssetType
+	})
+
+	return result, nil
+}
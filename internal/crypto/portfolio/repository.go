@@ -2,10 +2,15 @@ package portfolio
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"time"
+
+	"go-boilerplate/internal/crypto/pricing"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type repository struct {
@@ -82,47 +87,297 @@ func (r *repository) Delete(ctx context.Context, id uuid.UUID) error {
 	return nil
 }
 
-func (r *repository) AddHolding(ctx context.Context, holding *Holding) error {
-	if err := r.db.WithContext(ctx).Create(holding).Error; err != nil {
-		return fmt.Errorf("failed to add holding: %w", err)
+func (r *repository) GetHoldingsByPortfolioID(ctx context.Context, portfolioID uuid.UUID) ([]Holding, error) {
+	var holdings []Holding
+
+	err := r.db.WithContext(ctx).
+		Where("portfolio_id = ?", portfolioID).
+		Find(&holdings).Error
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get holdings: %w", err)
 	}
-	return nil
+
+	return holdings, nil
 }
 
-func (r *repository) UpdateHolding(ctx context.Context, holding *Holding) error {
-	err := r.db.WithContext(ctx).
-		Model(holding).
-		Updates(holding).Error
+func (r *repository) RecordTransaction(ctx context.Context, txn *Transaction, derive PositionFunc) (*Holding, error) {
+	var holding *Holding
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := lockPortfolio(tx, txn.PortfolioID); err != nil {
+			return err
+		}
+		if err := tx.Create(txn).Error; err != nil {
+			return fmt.Errorf("failed to record transaction: %w", err)
+		}
 
+		var err error
+		holding, err = rebuildHolding(tx, txn.PortfolioID, txn.Symbol, txn.AssetType, derive)
+		return err
+	})
 	if err != nil {
-		return fmt.Errorf("failed to update holding: %w", err)
+		return nil, err
 	}
+	return holding, nil
+}
 
-	return nil
+func (r *repository) VoidTransaction(ctx context.Context, portfolioID, transactionID uuid.UUID, reason string, derive PositionFunc) (*Transaction, error) {
+	var txn Transaction
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := lockPortfolio(tx, portfolioID); err != nil {
+			return err
+		}
+
+		err := tx.Where("id = ? AND portfolio_id = ?", transactionID, portfolioID).First(&txn).Error
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrTransactionNotFound
+			}
+			return fmt.Errorf("failed to get transaction: %w", err)
+		}
+		if txn.VoidedAt != nil {
+			return ErrTransactionVoided
+		}
+
+		now := time.Now()
+		txn.VoidedAt = &now
+		if reason != "" {
+			txn.VoidReason = &reason
+		}
+		err = tx.Model(&txn).Updates(map[string]any{"voided_at": txn.VoidedAt, "void_reason": txn.VoidReason}).Error
+		if err != nil {
+			return fmt.Errorf("failed to void transaction: %w", err)
+		}
+
+		_, err = rebuildHolding(tx, portfolioID, txn.Symbol, txn.AssetType, derive)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &txn, nil
+}
+
+// ListTransactions returns the matching ledger entries, newest first, and how many match
+// in total.
+func (r *repository) ListTransactions(ctx context.Context, portfolioID uuid.UUID, filter TransactionFilter) ([]Transaction, int64, error) {
+	query := r.db.WithContext(ctx).Model(&Transaction{}).Where("portfolio_id = ?", portfolioID)
+	if filter.Symbol != "" {
+		query = query.Where("symbol = ?", filter.Symbol)
+	}
+	if filter.Type != "" {
+		query = query.Where("type = ?", filter.Type)
+	}
+	if !filter.IncludeVoided {
+		query = query.Where("voided_at IS NULL")
+	}
+
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count transactions: %w", err)
+	}
+
+	var transactions []Transaction
+	err := query.
+		Order("executed_at DESC, created_at DESC").
+		Limit(filter.Limit).
+		Offset(filter.Offset).
+		Find(&transactions).Error
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list transactions: %w", err)
+	}
+
+	return transactions, total, nil
+}
+
+func (r *repository) ListSymbolTransactions(ctx context.Context, portfolioID uuid.UUID, symbol, assetType string) ([]Transaction, error) {
+	return symbolHistory(r.db.WithContext(ctx), portfolioID, symbol, assetType)
+}
+
+func (r *repository) ListLedger(ctx context.Context, portfolioID uuid.UUID) ([]Transaction, error) {
+	var ledger []Transaction
+	err := r.db.WithContext(ctx).
+		Where("portfolio_id = ? AND voided_at IS NULL", portfolioID).
+		Order("executed_at, created_at").
+		Find(&ledger).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to load transactions: %w", err)
+	}
+	return ledger, nil
 }
 
-func (r *repository) RemoveHolding(ctx context.Context, portfolioID, holdingID uuid.UUID) error {
+func (r *repository) ListHeldSymbols(ctx context.Context) ([]HeldSymbol, error) {
+	var held []HeldSymbol
 	err := r.db.WithContext(ctx).
-		Where("id = ? AND portfolio_id = ?", holdingID, portfolioID).
-		Delete(&Holding{}).Error
+		Table("holdings h").
+		Select("DISTINCT h.symbol, h.asset_type, UPPER(p.currency) AS currency").
+		Joins("JOIN portfolios p ON p.id = h.portfolio_id AND p.deleted_at IS NULL").
+		Where("h.deleted_at IS NULL AND h.quantity > 0").
+		Order("h.symbol, h.asset_type, currency").
+		Scan(&held).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list held symbols: %w", err)
+	}
+	return held, nil
+}
 
+func (r *repository) ApplyQuote(ctx context.Context, quote pricing.Quote) (int64, error) {
+	var updated int64
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// The portfolios are locked as ledger writes lock them, so a holding being rebuilt
+		// cannot save its old price over this one.
+		holders := tx.Model(&Holding{}).
+			Select("portfolio_id").
+			Where("symbol = ? AND asset_type = ?", quote.Symbol, quote.AssetType)
+		var portfolioIDs []uuid.UUID
+		err := tx.Model(&Portfolio{}).
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("UPPER(currency) = UPPER(?) AND id IN (?)", quote.Currency, holders).
+			Order("id").
+			Pluck("id", &portfolioIDs).Error
+		if err != nil {
+			return fmt.Errorf("failed to lock portfolios: %w", err)
+		}
+		if len(portfolioIDs) == 0 {
+			return nil
+		}
+
+		result := tx.Model(&Holding{}).
+			Where("portfolio_id IN ? AND symbol = ? AND asset_type = ?", portfolioIDs, quote.Symbol, quote.AssetType).
+			Where("price_updated_at IS NULL OR price_updated_at < ?", quote.Timestamp).
+			Updates(map[string]any{
+				"current_price":    quote.Price,
+				"market_value":     gorm.Expr("quantity * ?", quote.Price),
+				"price_updated_at": quote.Timestamp,
+				"price_source":     quote.Source,
+			})
+		if result.Error != nil {
+			return fmt.Errorf("failed to update holding prices: %w", result.Error)
+		}
+		updated = result.RowsAffected
+
+		for _, portfolioID := range portfolioIDs {
+			if err := updateTotalValue(tx, portfolioID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 	if err != nil {
-		return fmt.Errorf("failed to remove holding: %w", err)
+		return 0, err
 	}
+	return updated, nil
+}
 
+// lockPortfolio serializes ledger writes to a portfolio, so concurrent sales cannot each
+// see the same position and together oversell it.
+func lockPortfolio(tx *gorm.DB, portfolioID uuid.UUID) error {
+	var portfolio Portfolio
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Select("id").
+		Where("id = ?", portfolioID).
+		First(&portfolio).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("failed to lock portfolio: %w", err)
+	}
 	return nil
 }
 
-func (r *repository) GetHoldingsByPortfolioID(ctx context.Context, portfolioID uuid.UUID) ([]Holding, error) {
-	var holdings []Holding
+// rebuildHolding replaces the holding for symbol and assetType with the position derived
+// from their ledger, then refreshes the portfolio's total value. Holdings keep their
+// current price; a new one starts at the last trade price until a price refresh.
+func rebuildHolding(tx *gorm.DB, portfolioID uuid.UUID, symbol, assetType string, derive PositionFunc) (*Holding, error) {
+	if symbol == "" {
+		return nil, nil
+	}
 
-	err := r.db.WithContext(ctx).
-		Where("portfolio_id = ?", portfolioID).
-		Find(&holdings).Error
+	history, err := symbolHistory(tx, portfolioID, symbol, assetType)
+	if err != nil {
+		return nil, err
+	}
 
+	pos, err := derive(history)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get holdings: %w", err)
+		return nil, err
 	}
 
-	return holdings, nil
+	var existing []Holding
+	err = tx.Where("portfolio_id = ? AND symbol = ? AND asset_type = ?", portfolioID, symbol, assetType).
+		Order("created_at").
+		Find(&existing).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to load holding: %w", err)
+	}
+
+	var holding *Holding
+	if qty := pos.Quantity(); qty > 0 {
+		holding = &Holding{PortfolioID: portfolioID, Symbol: symbol, AssetType: assetType}
+		if len(existing) > 0 {
+			holding = &existing[0]
+			existing = existing[1:]
+		}
+		if holding.CurrentPrice == 0 {
+			holding.CurrentPrice = lastTradePrice(history, pos)
+		}
+		holding.Quantity = qty
+		holding.AvgCost = pos.AvgCost()
+		holding.MarketValue = qty * holding.CurrentPrice
+		if err := tx.Save(holding).Error; err != nil {
+			return nil, fmt.Errorf("failed to save holding: %w", err)
+		}
+	}
+
+	// Closed positions, and duplicates left from before the ledger, are removed.
+	for _, stale := range existing {
+		if err := tx.Delete(&stale).Error; err != nil {
+			return nil, fmt.Errorf("failed to remove holding: %w", err)
+		}
+	}
+
+	if err := updateTotalValue(tx, portfolioID); err != nil {
+		return nil, err
+	}
+
+	return holding, nil
+}
+
+// symbolHistory loads the transactions of a symbol and asset type that are not void,
+// oldest first.
+func symbolHistory(db *gorm.DB, portfolioID uuid.UUID, symbol, assetType string) ([]Transaction, error) {
+	var history []Transaction
+	err := db.Where("portfolio_id = ? AND symbol = ? AND asset_type = ? AND voided_at IS NULL", portfolioID, symbol, assetType).
+		Order("executed_at, created_at").
+		Find(&history).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to load transactions: %w", err)
+	}
+	return history, nil
+}
+
+// updateTotalValue sets the portfolio's total value to the sum of its holdings' market values.
+func updateTotalValue(tx *gorm.DB, portfolioID uuid.UUID) error {
+	totalValue := tx.Model(&Holding{}).
+		Select("COALESCE(SUM(market_value), 0)").
+		Where("portfolio_id = ? AND deleted_at IS NULL", portfolioID)
+
+	err := tx.Model(&Portfolio{}).
+		Where("id = ?", portfolioID).
+		Update("total_value", totalValue).Error
+	if err != nil {
+		return fmt.Errorf("failed to update portfolio value: %w", err)
+	}
+	return nil
+}
+
+// lastTradePrice is the price of the latest priced transaction, or the average cost.
+func lastTradePrice(history []Transaction, pos Position) float64 {
+	for i := len(history) - 1; i >= 0; i-- {
+		if history[i].Price > 0 {
+			return history[i].Price
+		}
+	}
+	return pos.AvgCost()
 }
@@ -3,40 +3,68 @@ package portfolio
 import (
 	"context"
 	"fmt"
+	"go-boilerplate/internal/crypto/pricing"
 	"go-boilerplate/internal/dto"
+	"go-boilerplate/internal/infra/audit"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+// Audit actions recorded by the portfolio usecase.
+const (
+	ActionPortfolioCreate = "portfolio.create"
+	ActionPortfolioDelete = "portfolio.delete"
+	// ActionPortfolioAccess is recorded with OutcomeDenied when a user reaches for a
+	// portfolio they do not own.
+	ActionPortfolioAccess = "portfolio.access"
+	// ActionTransactionVoid is recorded when a ledger entry is voided, since it rewrites
+	// how the holdings came about.
+	ActionTransactionVoid = "portfolio.transaction_void"
+)
+
+const auditTargetPortfolio = "portfolio"
+
 type usecase struct {
-	repo Repository
+	repo         Repository
+	priceHistory pricing.Repository
+	auditLogger  audit.AuditLogger
 }
 
-func NewUsecase(repo Repository) Usecase {
+func NewUsecase(repo Repository, priceHistory pricing.Repository, auditLogger audit.AuditLogger) Usecase {
 	return &usecase{
-		repo: repo,
+		repo:         repo,
+		priceHistory: priceHistory,
+		auditLogger:  auditLogger,
 	}
 }
 
 func (u *usecase) CreatePortfolio(ctx context.Context, userID uuid.UUID, req dto.CreatePortfolioRequest) (*Portfolio, error) {
 	portfolio := &Portfolio{
-		UserID:      userID,
-		Name:        req.Name,
-		Description: req.Description,
-		Currency:    "USD", // default
-		IsActive:    true,
-		TotalValue:  0,
+		UserID:          userID,
+		Name:            req.Name,
+		Description:     req.Description,
+		Currency:        "USD", // default
+		CostBasisMethod: CostBasisAverage,
+		IsActive:        true,
+		TotalValue:      0,
 	}
 
 	if req.Currency != "" {
-		portfolio.Currency = req.Currency
+		// Transactions and quotes spell currencies in upper case.
+		portfolio.Currency = strings.ToUpper(req.Currency)
+	}
+	if req.CostBasisMethod != "" {
+		portfolio.CostBasisMethod = CostBasisMethod(req.CostBasisMethod)
 	}
 
 	if err := u.repo.Create(ctx, portfolio); err != nil {
 		return nil, fmt.Errorf("failed to create portfolio: %w", err)
 	}
 
+	u.audit(ctx, ActionPortfolioCreate, audit.OutcomeSuccess, userID, portfolio.ID)
+
 	return portfolio, nil
 }
 
@@ -47,6 +75,7 @@ func (u *usecase) GetPortfolio(ctx context.Context, userID, portfolioID uuid.UUI
 	}
 
 	if portfolio.UserID != userID {
+		u.audit(ctx, ActionPortfolioAccess, audit.OutcomeDenied, userID, portfolioID)
 		return nil, ErrUnauthorized
 	}
 
@@ -78,6 +107,9 @@ func (u *usecase) UpdatePortfolio(ctx context.Context, userID, portfolioID uuid.
 	if req.IsActive != nil {
 		portfolio.IsActive = *req.IsActive
 	}
+	if req.CostBasisMethod != nil {
+		portfolio.CostBasisMethod = CostBasisMethod(*req.CostBasisMethod)
+	}
 
 	portfolio.UpdatedAt = time.Now()
 
@@ -99,107 +131,238 @@ func (u *usecase) DeletePortfolio(ctx context.Context, userID, portfolioID uuid.
 		return fmt.Errorf("failed to delete portfolio: %w", err)
 	}
 
+	u.audit(ctx, ActionPortfolioDelete, audit.OutcomeSuccess, userID, portfolioID)
+
 	return nil
 }
 
+// AddHolding records a purchase of the holding at its average cost. The holding itself
+// is derived from the ledger.
 func (u *usecase) AddHolding(ctx context.Context, userID, portfolioID uuid.UUID, req dto.AddHoldingRequest) (*Holding, error) {
-	// Verify portfolio ownership
-	_, err := u.GetPortfolio(ctx, userID, portfolioID)
+	portfolio, err := u.GetPortfolio(ctx, userID, portfolioID)
 	if err != nil {
 		return nil, err
 	}
 
-	holding := &Holding{
-		PortfolioID:  portfolioID,
-		Symbol:       req.Symbol,
-		AssetType:    req.AssetType,
-		Quantity:     req.Quantity,
-		AvgCost:      req.AvgCost,
-		CurrentPrice: req.AvgCost, // Initially set to avg cost
-		MarketValue:  req.Quantity * req.AvgCost,
-	}
-
-	if err := u.repo.AddHolding(ctx, holding); err != nil {
-		return nil, fmt.Errorf("failed to add holding: %w", err)
+	txn, err := newTransaction(portfolio, dto.CreateTransactionRequest{
+		Type:      string(TransactionBuy),
+		Symbol:    req.Symbol,
+		AssetType: req.AssetType,
+		Quantity:  req.Quantity,
+		Price:     req.AvgCost,
+	}, time.Now())
+	if err != nil {
+		return nil, err
 	}
 
-	// Update portfolio total value
-	if err := u.recalculatePortfolioValue(ctx, portfolioID); err != nil {
-		// Log error but don't fail the operation
-		// In production, consider using background job for this
-		fmt.Printf("Warning: failed to recalculate portfolio value: %v\n", err)
+	holding, err := u.repo.RecordTransaction(ctx, txn, derivePosition)
+	if err != nil {
+		return nil, err
 	}
 
 	return holding, nil
 }
 
+// RemoveHolding records the whole holding as transferred out, closing the position
+// while keeping its history. Every lot is consumed whatever the portfolio's cost basis
+// method, so the transfer is simply matched first in, first out.
 func (u *usecase) RemoveHolding(ctx context.Context, userID, portfolioID, holdingID uuid.UUID) error {
-	// Verify portfolio ownership
-	_, err := u.GetPortfolio(ctx, userID, portfolioID)
+	portfolio, err := u.GetPortfolio(ctx, userID, portfolioID)
 	if err != nil {
 		return err
 	}
 
-	if err := u.repo.RemoveHolding(ctx, portfolioID, holdingID); err != nil {
-		return fmt.Errorf("failed to remove holding: %w", err)
+	var holding *Holding
+	for i := range portfolio.Holdings {
+		if portfolio.Holdings[i].ID == holdingID {
+			holding = &portfolio.Holdings[i]
+		}
+	}
+	if holding == nil {
+		return ErrHoldingNotFound
+	}
+
+	txn, err := newTransaction(portfolio, dto.CreateTransactionRequest{
+		Type:      string(TransactionTransferOut),
+		Symbol:    holding.Symbol,
+		AssetType: holding.AssetType,
+		Quantity:  holding.Quantity,
+	}, time.Now())
+	if err != nil {
+		return err
 	}
+	txn.CostBasisMethod = CostBasisFIFO
 
-	// Update portfolio total value
-	if err := u.recalculatePortfolioValue(ctx, portfolioID); err != nil {
-		fmt.Printf("Warning: failed to recalculate portfolio value: %v\n", err)
+	if _, err := u.repo.RecordTransaction(ctx, txn, derivePosition); err != nil {
+		return err
 	}
 
 	return nil
 }
 
+// GetPortfolioSummary measures the portfolio's performance from its whole ledger, so
+// positions already sold still count towards its return, and its move over the last day
+// from the price history.
 func (u *usecase) GetPortfolioSummary(ctx context.Context, userID, portfolioID uuid.UUID) (*PortfolioSummary, error) {
 	portfolio, err := u.GetPortfolio(ctx, userID, portfolioID)
 	if err != nil {
 		return nil, err
 	}
 
-	// Calculate metrics
-	var totalInvested, totalReturn float64
+	ledger, err := u.repo.ListLedger(ctx, portfolioID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list transactions: %w", err)
+	}
+	perf, err := measurePerformance(ledger, portfolio.Holdings)
+	if err != nil {
+		return nil, err
+	}
 
-	for _, holding := range portfolio.Holdings {
-		totalInvested += holding.AvgCost * holding.Quantity
-		totalReturn += (holding.CurrentPrice - holding.AvgCost) * holding.Quantity
+	dayChanges, dayTotal, err := u.measureDayChange(ctx, portfolio, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	for i := range perf.byHolding {
+		key := assetKey{symbol: perf.byHolding[i].Symbol, assetType: perf.byHolding[i].AssetType}
+		if change, ok := dayChanges[key]; ok {
+			perf.byHolding[i].DayChange = change.change
+			perf.byHolding[i].DayChangePct = change.pct()
+		}
 	}
 
 	var totalReturnPct float64
-	if totalInvested > 0 {
-		totalReturnPct = (totalReturn / totalInvested) * 100
+	if costBasis := perf.invested + perf.costOfSales; costBasis > 0 {
+		totalReturnPct = (perf.total.TotalReturn / costBasis) * 100
 	}
 
 	summary := &PortfolioSummary{
 		Portfolio:      *portfolio,
-		TotalReturn:    totalReturn,
+		TotalReturn:    perf.total.TotalReturn,
 		TotalReturnPct: totalReturnPct,
-		TotalInvested:  totalInvested,
+		TotalInvested:  perf.invested,
 		HoldingsCount:  len(portfolio.Holdings),
-		DayChange:      0, // Would need historical data
-		DayChangePct:   0, // Would need historical data
+		DayChange:      dayTotal.change,
+		DayChangePct:   dayTotal.pct(),
+		RealizedPnL:    perf.total.RealizedPnL,
+		UnrealizedPnL:  perf.total.UnrealizedPnL,
+		FeesPaid:       perf.total.FeesPaid,
+		Income:         perf.total.Income,
+		ByHolding:      perf.byHolding,
+		ByAssetType:    perf.byAssetType,
 	}
 
 	return summary, nil
 }
 
-func (u *usecase) recalculatePortfolioValue(ctx context.Context, portfolioID uuid.UUID) error {
-	holdings, err := u.repo.GetHoldingsByPortfolioID(ctx, portfolioID)
+func (u *usecase) RecordTransaction(ctx context.Context, userID, portfolioID uuid.UUID, req dto.CreateTransactionRequest) (*Transaction, error) {
+	portfolio, err := u.GetPortfolio(ctx, userID, portfolioID)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	txn, err := newTransaction(portfolio, req, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if err := assignCostBasisMethod(portfolio, txn); err != nil {
+		return nil, err
 	}
 
-	var totalValue float64
-	for _, holding := range holdings {
-		totalValue += holding.MarketValue
+	// The position is kept to report the lots a disposal consumed.
+	var pos Position
+	derive := func(history []Transaction) (Position, error) {
+		var err error
+		pos, err = derivePosition(history)
+		return pos, err
 	}
+	if _, err := u.repo.RecordTransaction(ctx, txn, derive); err != nil {
+		return nil, err
+	}
+	txn.Disposals = pos.DisposalsOf(txn.ID)
 
-	portfolio := &Portfolio{
-		ID:         portfolioID,
-		TotalValue: totalValue,
-		UpdatedAt:  time.Now(),
+	return txn, nil
+}
+
+func (u *usecase) ListTransactions(ctx context.Context, userID, portfolioID uuid.UUID, filter TransactionFilter) ([]Transaction, int64, error) {
+	if _, err := u.GetPortfolio(ctx, userID, portfolioID); err != nil {
+		return nil, 0, err
+	}
+
+	filter.Symbol = strings.ToUpper(filter.Symbol)
+	transactions, total, err := u.repo.ListTransactions(ctx, portfolioID, filter)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list transactions: %w", err)
+	}
+
+	return transactions, total, nil
+}
+
+// VoidTransaction cancels a ledger entry, such as one posted by mistake. It is refused
+// if the holding's remaining history would no longer add up, for example when voiding a
+// buy that a later sale depends on.
+func (u *usecase) VoidTransaction(ctx context.Context, userID, portfolioID, transactionID uuid.UUID, reason string) (*Transaction, error) {
+	if _, err := u.GetPortfolio(ctx, userID, portfolioID); err != nil {
+		return nil, err
+	}
+
+	txn, err := u.repo.VoidTransaction(ctx, portfolioID, transactionID, reason, derivePosition)
+	if err != nil {
+		return nil, err
 	}
 
-	return u.repo.Update(ctx, portfolio)
+	u.auditLogger.Log(ctx, audit.Event{
+		ActorID:    userID.String(),
+		Action:     ActionTransactionVoid,
+		TargetType: auditTargetPortfolio,
+		TargetID:   portfolioID.String(),
+		Outcome:    audit.OutcomeSuccess,
+		Metadata:   map[string]any{"transaction_id": transactionID.String(), "reason": reason},
+	})
+
+	return txn, nil
+}
+
+// GetHoldingLots breaks a holding down into the lots it is made of, replaying the history
+// of its symbol and asset type.
+func (u *usecase) GetHoldingLots(ctx context.Context, userID, portfolioID, holdingID uuid.UUID) (*HoldingLots, error) {
+	portfolio, err := u.GetPortfolio(ctx, userID, portfolioID)
+	if err != nil {
+		return nil, err
+	}
+
+	var holding *Holding
+	for i := range portfolio.Holdings {
+		if portfolio.Holdings[i].ID == holdingID {
+			holding = &portfolio.Holdings[i]
+		}
+	}
+	if holding == nil {
+		return nil, ErrHoldingNotFound
+	}
+
+	history, err := u.repo.ListSymbolTransactions(ctx, portfolioID, holding.Symbol, holding.AssetType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list transactions: %w", err)
+	}
+	pos, err := derivePosition(history)
+	if err != nil {
+		return nil, err
+	}
+
+	return &HoldingLots{
+		Holding:         *holding,
+		CostBasisMethod: portfolio.CostBasisMethod,
+		Lots:            pos.Lots,
+		Disposals:       pos.Disposals,
+	}, nil
+}
+
+func (u *usecase) audit(ctx context.Context, action, outcome string, userID, portfolioID uuid.UUID) {
+	u.auditLogger.Log(ctx, audit.Event{
+		ActorID:    userID.String(),
+		Action:     action,
+		TargetType: auditTargetPortfolio,
+		TargetID:   portfolioID.String(),
+		Outcome:    outcome,
+	})
 }
@@ -2,13 +2,20 @@ package portfolio
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"strings"
 	"testing"
+	"time"
 
+	"go-boilerplate/internal/crypto/pricing"
 	"go-boilerplate/internal/dto"
+	"go-boilerplate/internal/infra/audit"
 
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 // MockRepository is a manual mock of the Repository interface.
@@ -44,30 +51,87 @@ func (m *MockRepository) Delete(ctx context.Context, id uuid.UUID) error {
 	return args.Error(0)
 }
 
-func (m *MockRepository) AddHolding(ctx context.Context, h *Holding) error {
-	args := m.Called(ctx, h)
-	return args.Error(0)
+func (m *MockRepository) GetHoldingsByPortfolioID(ctx context.Context, pID uuid.UUID) ([]Holding, error) {
+	args := m.Called(ctx, pID)
+	return args.Get(0).([]Holding), args.Error(1)
 }
 
-func (m *MockRepository) UpdateHolding(ctx context.Context, h *Holding) error {
-	args := m.Called(ctx, h)
-	return args.Error(0)
+func (m *MockRepository) RecordTransaction(ctx context.Context, txn *Transaction, derive PositionFunc) (*Holding, error) {
+	args := m.Called(ctx, txn, derive)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*Holding), args.Error(1)
 }
 
-func (m *MockRepository) RemoveHolding(ctx context.Context, pID, hID uuid.UUID) error {
-	args := m.Called(ctx, pID, hID)
-	return args.Error(0)
+func (m *MockRepository) VoidTransaction(ctx context.Context, portfolioID, transactionID uuid.UUID, reason string, derive PositionFunc) (*Transaction, error) {
+	args := m.Called(ctx, portfolioID, transactionID, reason, derive)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*Transaction), args.Error(1)
 }
 
-func (m *MockRepository) GetHoldingsByPortfolioID(ctx context.Context, pID uuid.UUID) ([]Holding, error) {
-	args := m.Called(ctx, pID)
-	return args.Get(0).([]Holding), args.Error(1)
+func (m *MockRepository) ListTransactions(ctx context.Context, portfolioID uuid.UUID, filter TransactionFilter) ([]Transaction, int64, error) {
+	args := m.Called(ctx, portfolioID, filter)
+	return args.Get(0).([]Transaction), args.Get(1).(int64), args.Error(2)
+}
+
+func (m *MockRepository) ListSymbolTransactions(ctx context.Context, portfolioID uuid.UUID, symbol, assetType string) ([]Transaction, error) {
+	args := m.Called(ctx, portfolioID, symbol, assetType)
+	return args.Get(0).([]Transaction), args.Error(1)
+}
+
+func (m *MockRepository) ListLedger(ctx context.Context, portfolioID uuid.UUID) ([]Transaction, error) {
+	args := m.Called(ctx, portfolioID)
+	return args.Get(0).([]Transaction), args.Error(1)
+}
+
+func (m *MockRepository) ListHeldSymbols(ctx context.Context) ([]HeldSymbol, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]HeldSymbol), args.Error(1)
+}
+
+func (m *MockRepository) ApplyQuote(ctx context.Context, quote pricing.Quote) (int64, error) {
+	args := m.Called(ctx, quote)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+// MockPriceRepository is a manual mock of the pricing.Repository interface.
+type MockPriceRepository struct {
+	mock.Mock
+}
+
+func (m *MockPriceRepository) Ingest(ctx context.Context, prices []pricing.Price) (int64, error) {
+	args := m.Called(ctx, prices)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockPriceRepository) Range(ctx context.Context, series pricing.Series, from, to time.Time, limit int) ([]pricing.Price, error) {
+	args := m.Called(ctx, series, from, to, limit)
+	return args.Get(0).([]pricing.Price), args.Error(1)
+}
+
+func (m *MockPriceRepository) Latest(ctx context.Context, series pricing.Series) (*pricing.Price, error) {
+	args := m.Called(ctx, series)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*pricing.Price), args.Error(1)
+}
+
+func (m *MockPriceRepository) AsOf(ctx context.Context, series pricing.Series, at time.Time) (*pricing.Price, error) {
+	args := m.Called(ctx, series, at)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*pricing.Price), args.Error(1)
 }
 
 func TestCreatePortfolio(t *testing.T) {
 	// Arrange
 	mockRepo := new(MockRepository)
-	u := NewUsecase(mockRepo)
+	u := NewUsecase(mockRepo, new(MockPriceRepository), audit.NopLogger{})
 
 	userID := uuid.New()
 	name := "My Crypto Portfolio"
@@ -86,6 +150,7 @@ func TestCreatePortfolio(t *testing.T) {
 			assert.Equal(t, userID, p.UserID)
 			assert.Equal(t, name, p.Name)
 			assert.Equal(t, "USD", p.Currency)
+			assert.Equal(t, CostBasisAverage, p.CostBasisMethod)
 		})
 
 	// Act
@@ -99,10 +164,30 @@ func TestCreatePortfolio(t *testing.T) {
 	mockRepo.AssertExpectations(t)
 }
 
+func TestCreatePortfolio_UppercasesCurrency(t *testing.T) {
+	// Arrange
+	mockRepo := new(MockRepository)
+	u := NewUsecase(mockRepo, new(MockPriceRepository), audit.NopLogger{})
+	mockRepo.On("Create", mock.Anything, mock.AnythingOfType("*portfolio.Portfolio")).Return(nil)
+
+	// Act
+	portfolio, err := u.CreatePortfolio(context.Background(), uuid.New(), dto.CreatePortfolioRequest{Name: "Savings", Currency: "eur"})
+	require.NoError(t, err)
+	txn, txnErr := newTransaction(portfolio, dto.CreateTransactionRequest{
+		Type: string(TransactionDeposit), Quantity: 100, Currency: "eur",
+	}, time.Now())
+
+	// Assert
+	assert.Equal(t, "EUR", portfolio.Currency)
+	assert.NoError(t, txnErr, "a transaction in the portfolio's currency is accepted however it is spelt")
+	assert.Equal(t, "EUR", txn.Currency)
+}
+
 func TestGetPortfolio_Unauthorized(t *testing.T) {
 	// Arrange
 	mockRepo := new(MockRepository)
-	u := NewUsecase(mockRepo)
+	auditLogger := audit.NewMemoryLogger()
+	u := NewUsecase(mockRepo, new(MockPriceRepository), auditLogger)
 
 	userID := uuid.New()
 	otherUserID := uuid.New()
@@ -124,4 +209,1200 @@ func TestGetPortfolio_Unauthorized(t *testing.T) {
 	assert.Nil(t, result)
 	assert.True(t, assert.IsType(t, ErrUnauthorized, err) || err == ErrUnauthorized)
 	mockRepo.AssertExpectations(t)
+
+	events := auditLogger.Events()
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, ActionPortfolioAccess, events[0].Action)
+		assert.Equal(t, audit.OutcomeDenied, events[0].Outcome)
+		assert.Equal(t, userID.String(), events[0].ActorID)
+		assert.Equal(t, portfolioID.String(), events[0].TargetID)
+	}
+}
+
+func ownedPortfolio(userID uuid.UUID) *Portfolio {
+	return &Portfolio{ID: uuid.New(), UserID: userID, Name: "Main", Currency: "USD"}
+}
+
+func TestRecordTransaction_Validation(t *testing.T) {
+	yesterday := time.Now().Add(-24 * time.Hour)
+	nextWeek := time.Now().Add(7 * 24 * time.Hour)
+
+	tests := []struct {
+		name    string
+		req     dto.CreateTransactionRequest
+		wantErr error
+	}{
+		{
+			name: "buy",
+			req:  dto.CreateTransactionRequest{Type: "buy", Symbol: "btc", AssetType: "crypto", Quantity: 1, Price: 30000, Fee: 10, ExecutedAt: &yesterday},
+		},
+		{
+			name: "deposit",
+			req:  dto.CreateTransactionRequest{Type: "deposit", Quantity: 1000},
+		},
+		{
+			name: "fee on a symbol",
+			req:  dto.CreateTransactionRequest{Type: "fee", Symbol: "BTC", AssetType: "crypto", Fee: 2.5},
+		},
+		{
+			name: "dividend",
+			req:  dto.CreateTransactionRequest{Type: "dividend", Symbol: "AAPL", AssetType: "stock", Quantity: 24.5},
+		},
+		{
+			name: "staking reward",
+			req:  dto.CreateTransactionRequest{Type: "staking_reward", Symbol: "ETH", AssetType: "crypto", Quantity: 0.01, Price: 3000},
+		},
+		{
+			name:    "dividend without symbol",
+			req:     dto.CreateTransactionRequest{Type: "dividend", Quantity: 24.5},
+			wantErr: ErrInvalidTransaction,
+		},
+		{
+			name:    "dividend with price",
+			req:     dto.CreateTransactionRequest{Type: "dividend", Symbol: "AAPL", AssetType: "stock", Quantity: 24.5, Price: 1},
+			wantErr: ErrInvalidTransaction,
+		},
+		{
+			name:    "staking reward without quantity",
+			req:     dto.CreateTransactionRequest{Type: "staking_reward", Symbol: "ETH", AssetType: "crypto", Price: 3000},
+			wantErr: ErrInvalidTransaction,
+		},
+		{
+			name:    "sell without symbol",
+			req:     dto.CreateTransactionRequest{Type: "sell", Quantity: 1, Price: 100},
+			wantErr: ErrInvalidTransaction,
+		},
+		{
+			name:    "buy without quantity",
+			req:     dto.CreateTransactionRequest{Type: "buy", Symbol: "AAPL", AssetType: "stock", Price: 100},
+			wantErr: ErrInvalidTransaction,
+		},
+		{
+			name:    "deposit with symbol",
+			req:     dto.CreateTransactionRequest{Type: "deposit", Symbol: "AAPL", Quantity: 100},
+			wantErr: ErrInvalidTransaction,
+		},
+		{
+			name:    "fee with quantity",
+			req:     dto.CreateTransactionRequest{Type: "fee", Quantity: 1, Fee: 5},
+			wantErr: ErrInvalidTransaction,
+		},
+		{
+			name:    "other currency",
+			req:     dto.CreateTransactionRequest{Type: "deposit", Quantity: 100, Currency: "EUR"},
+			wantErr: ErrInvalidTransaction,
+		},
+		{
+			name:    "executed in the future",
+			req:     dto.CreateTransactionRequest{Type: "deposit", Quantity: 100, ExecutedAt: &nextWeek},
+			wantErr: ErrInvalidTransaction,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			mockRepo := new(MockRepository)
+			u := NewUsecase(mockRepo, new(MockPriceRepository), audit.NopLogger{})
+			userID := uuid.New()
+			portfolio := ownedPortfolio(userID)
+
+			mockRepo.On("GetByID", mock.Anything, portfolio.ID).Return(portfolio, nil)
+			mockRepo.On("RecordTransaction", mock.Anything, mock.AnythingOfType("*portfolio.Transaction"), mock.Anything).
+				Return(nil, nil)
+
+			// Act
+			txn, err := u.RecordTransaction(context.Background(), userID, portfolio.ID, tt.req)
+
+			// Assert
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				mockRepo.AssertNotCalled(t, "RecordTransaction", mock.Anything, mock.Anything, mock.Anything)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, portfolio.ID, txn.PortfolioID)
+			assert.Equal(t, "USD", txn.Currency)
+			assert.Equal(t, strings.ToUpper(tt.req.Symbol), txn.Symbol)
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestDerivePosition(t *testing.T) {
+	day := func(n int) time.Time { return time.Date(2025, 1, n, 0, 0, 0, 0, time.UTC) }
+	buy := func(n int, qty, price, fee float64) Transaction {
+		return Transaction{Type: TransactionBuy, Symbol: "BTC", Quantity: qty, Price: price, Fee: fee, ExecutedAt: day(n)}
+	}
+	sell := func(n int, qty, price float64) Transaction {
+		return Transaction{Type: TransactionSell, Symbol: "BTC", Quantity: qty, Price: price, ExecutedAt: day(n)}
+	}
+
+	tests := []struct {
+		name        string
+		history     []Transaction
+		wantQty     float64
+		wantAvgCost float64
+		wantErr     error
+	}{
+		{
+			name:        "buys average their cost including fees",
+			history:     []Transaction{buy(1, 1, 100, 0), buy(2, 1, 200, 10)},
+			wantQty:     2,
+			wantAvgCost: 155,
+		},
+		{
+			name:        "sale keeps the average cost",
+			history:     []Transaction{buy(1, 1, 100, 0), buy(2, 1, 200, 0), sell(3, 1, 500)},
+			wantQty:     1,
+			wantAvgCost: 150,
+		},
+		{
+			name:    "selling everything closes the position",
+			history: []Transaction{buy(1, 0.3, 100, 0), buy(2, 0.7, 100, 0), sell(3, 1, 120)},
+		},
+		{
+			name: "transfers move units like trades",
+			history: []Transaction{
+				{Type: TransactionTransferIn, Quantity: 2, Price: 50, ExecutedAt: day(1)},
+				{Type: TransactionTransferOut, Quantity: 1, ExecutedAt: day(2)},
+			},
+			wantQty:     1,
+			wantAvgCost: 50,
+		},
+		{
+			name:        "fee transactions are expenses",
+			history:     []Transaction{buy(1, 1, 100, 0), {Type: TransactionFee, Fee: 5, ExecutedAt: day(2)}},
+			wantQty:     1,
+			wantAvgCost: 100,
+		},
+		{
+			name:    "overselling is refused",
+			history: []Transaction{buy(1, 1, 100, 0), sell(2, 2, 100)},
+			wantErr: ErrInsufficientQuantity,
+		},
+		{
+			name:    "selling before buying is refused",
+			history: []Transaction{sell(1, 1, 100), buy(2, 1, 100, 0)},
+			wantErr: ErrInsufficientQuantity,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			pos, err := derivePosition(tt.history)
+
+			// Assert
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.InDelta(t, tt.wantQty, pos.Quantity(), 1e-9)
+			assert.InDelta(t, tt.wantAvgCost, pos.AvgCost(), 1e-9)
+		})
+	}
+}
+
+func TestAddHolding_RecordsBuy(t *testing.T) {
+	// Arrange
+	mockRepo := new(MockRepository)
+	u := NewUsecase(mockRepo, new(MockPriceRepository), audit.NopLogger{})
+	userID := uuid.New()
+	portfolio := ownedPortfolio(userID)
+	holding := &Holding{ID: uuid.New(), PortfolioID: portfolio.ID, Symbol: "ETH", Quantity: 2, AvgCost: 1500}
+
+	mockRepo.On("GetByID", mock.Anything, portfolio.ID).Return(portfolio, nil)
+	mockRepo.On("RecordTransaction", mock.Anything, mock.AnythingOfType("*portfolio.Transaction"), mock.Anything).
+		Return(holding, nil).
+		Run(func(args mock.Arguments) {
+			txn := args.Get(1).(*Transaction)
+			assert.Equal(t, TransactionBuy, txn.Type)
+			assert.Equal(t, "ETH", txn.Symbol)
+			assert.Equal(t, 2.0, txn.Quantity)
+			assert.Equal(t, 1500.0, txn.Price)
+		})
+
+	// Act
+	result, err := u.AddHolding(context.Background(), userID, portfolio.ID, dto.AddHoldingRequest{
+		Symbol:    "eth",
+		AssetType: "crypto",
+		Quantity:  2,
+		AvgCost:   1500,
+	})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, holding, result)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestRemoveHolding_TransfersOutWholePosition(t *testing.T) {
+	// Arrange
+	mockRepo := new(MockRepository)
+	u := NewUsecase(mockRepo, new(MockPriceRepository), audit.NopLogger{})
+	userID := uuid.New()
+	portfolio := ownedPortfolio(userID)
+	holding := Holding{ID: uuid.New(), PortfolioID: portfolio.ID, Symbol: "AAPL", AssetType: "stock", Quantity: 7}
+	portfolio.Holdings = []Holding{holding}
+
+	mockRepo.On("GetByID", mock.Anything, portfolio.ID).Return(portfolio, nil)
+	mockRepo.On("RecordTransaction", mock.Anything, mock.AnythingOfType("*portfolio.Transaction"), mock.Anything).
+		Return(nil, nil).
+		Run(func(args mock.Arguments) {
+			txn := args.Get(1).(*Transaction)
+			assert.Equal(t, TransactionTransferOut, txn.Type)
+			assert.Equal(t, "AAPL", txn.Symbol)
+			assert.Equal(t, 7.0, txn.Quantity)
+		})
+
+	// Act
+	err := u.RemoveHolding(context.Background(), userID, portfolio.ID, holding.ID)
+	missingErr := u.RemoveHolding(context.Background(), userID, portfolio.ID, uuid.New())
+
+	// Assert
+	assert.NoError(t, err)
+	assert.ErrorIs(t, missingErr, ErrHoldingNotFound)
+	mockRepo.AssertNumberOfCalls(t, "RecordTransaction", 1)
+}
+
+func TestVoidTransaction_IsAudited(t *testing.T) {
+	// Arrange
+	mockRepo := new(MockRepository)
+	auditLogger := audit.NewMemoryLogger()
+	u := NewUsecase(mockRepo, new(MockPriceRepository), auditLogger)
+	userID := uuid.New()
+	portfolio := ownedPortfolio(userID)
+	transactionID := uuid.New()
+	voidedAt := time.Now()
+
+	mockRepo.On("GetByID", mock.Anything, portfolio.ID).Return(portfolio, nil)
+	mockRepo.On("VoidTransaction", mock.Anything, portfolio.ID, transactionID, "duplicate", mock.Anything).
+		Return(&Transaction{ID: transactionID, PortfolioID: portfolio.ID, VoidedAt: &voidedAt}, nil)
+
+	// Act
+	txn, err := u.VoidTransaction(context.Background(), userID, portfolio.ID, transactionID, "duplicate")
+
+	// Assert
+	assert.NoError(t, err)
+	assert.NotNil(t, txn.VoidedAt)
+	events := auditLogger.Events()
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, ActionTransactionVoid, events[0].Action)
+		assert.Equal(t, transactionID.String(), events[0].Metadata["transaction_id"])
+	}
+	mockRepo.AssertExpectations(t)
+}
+
+// lotHistory is three BTC lots: one held for well over a year by the time of the sale,
+// and two bought within the year at different prices.
+type lotHistory struct {
+	a, b, c Transaction
+	saleAt  time.Time
+}
+
+func newLotHistory() lotHistory {
+	at := func(y int, m time.Month, d int) time.Time { return time.Date(y, m, d, 0, 0, 0, 0, time.UTC) }
+	lot := func(executedAt time.Time, price float64) Transaction {
+		return Transaction{ID: uuid.New(), Type: TransactionBuy, Symbol: "BTC", Quantity: 1, Price: price, ExecutedAt: executedAt}
+	}
+	return lotHistory{
+		a:      lot(at(2023, time.January, 10), 100),
+		b:      lot(at(2024, time.March, 1), 300),
+		c:      lot(at(2024, time.June, 1), 200),
+		saleAt: at(2024, time.September, 1),
+	}
+}
+
+func (h lotHistory) with(sale Transaction) []Transaction {
+	return []Transaction{h.a, h.b, h.c, sale}
+}
+
+func TestDerivePosition_CostBasisMethods(t *testing.T) {
+	h := newLotHistory()
+
+	type consumed struct {
+		lotID    uuid.UUID
+		quantity float64
+		basis    float64
+		gain     float64
+		term     HoldingTerm
+	}
+
+	tests := []struct {
+		name          string
+		method        CostBasisMethod
+		lots          []LotSelection
+		fee           float64
+		wantConsumed  []consumed
+		wantShortTerm float64
+		wantLongTerm  float64
+		wantRemaining float64
+	}{
+		{
+			name:   "fifo takes the oldest lots",
+			method: CostBasisFIFO,
+			wantConsumed: []consumed{
+				{lotID: h.a.ID, quantity: 1, basis: 100, gain: 300, term: TermLong},
+				{lotID: h.b.ID, quantity: 0.5, basis: 150, gain: 50, term: TermShort},
+			},
+			wantShortTerm: 50,
+			wantLongTerm:  300,
+			wantRemaining: 350,
+		},
+		{
+			name:   "lifo takes the newest lots",
+			method: CostBasisLIFO,
+			wantConsumed: []consumed{
+				{lotID: h.c.ID, quantity: 1, basis: 200, gain: 200, term: TermShort},
+				{lotID: h.b.ID, quantity: 0.5, basis: 150, gain: 50, term: TermShort},
+			},
+			wantShortTerm: 250,
+			wantRemaining: 250,
+		},
+		{
+			name:   "hifo takes the dearest lots",
+			method: CostBasisHIFO,
+			wantConsumed: []consumed{
+				{lotID: h.b.ID, quantity: 1, basis: 300, gain: 100, term: TermShort},
+				{lotID: h.c.ID, quantity: 0.5, basis: 100, gain: 100, term: TermShort},
+			},
+			wantShortTerm: 200,
+			wantRemaining: 200,
+		},
+		{
+			name:   "average cost keeps each lot's holding period",
+			method: CostBasisAverage,
+			wantConsumed: []consumed{
+				{lotID: h.a.ID, quantity: 1, basis: 200, gain: 200, term: TermLong},
+				{lotID: h.b.ID, quantity: 0.5, basis: 100, gain: 100, term: TermShort},
+			},
+			wantShortTerm: 100,
+			wantLongTerm:  200,
+			wantRemaining: 300,
+		},
+		{
+			name:   "transactions without a method replay at average cost",
+			method: "",
+			wantConsumed: []consumed{
+				{lotID: h.a.ID, quantity: 1, basis: 200, gain: 200, term: TermLong},
+				{lotID: h.b.ID, quantity: 0.5, basis: 100, gain: 100, term: TermShort},
+			},
+			wantShortTerm: 100,
+			wantLongTerm:  200,
+			wantRemaining: 300,
+		},
+		{
+			name:   "specific identification takes the named lots",
+			method: CostBasisSpecific,
+			lots:   []LotSelection{{LotID: h.c.ID, Quantity: 0.5}, {LotID: h.a.ID, Quantity: 1}},
+			wantConsumed: []consumed{
+				{lotID: h.c.ID, quantity: 0.5, basis: 100, gain: 100, term: TermShort},
+				{lotID: h.a.ID, quantity: 1, basis: 100, gain: 300, term: TermLong},
+			},
+			wantShortTerm: 100,
+			wantLongTerm:  300,
+			wantRemaining: 400,
+		},
+		{
+			name:   "the sale's fee is shared across the lots",
+			method: CostBasisFIFO,
+			fee:    15,
+			wantConsumed: []consumed{
+				{lotID: h.a.ID, quantity: 1, basis: 100, gain: 290, term: TermLong},
+				{lotID: h.b.ID, quantity: 0.5, basis: 150, gain: 45, term: TermShort},
+			},
+			wantShortTerm: 45,
+			wantLongTerm:  290,
+			wantRemaining: 350,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			sale := Transaction{
+				ID: uuid.New(), Type: TransactionSell, Symbol: "BTC", Quantity: 1.5, Price: 400, Fee: tt.fee,
+				ExecutedAt: h.saleAt, CostBasisMethod: tt.method, LotSelections: tt.lots,
+			}
+
+			// Act
+			pos, err := derivePosition(h.with(sale))
+
+			// Assert
+			assert.NoError(t, err)
+			disposals := pos.DisposalsOf(sale.ID)
+			if assert.Len(t, disposals, len(tt.wantConsumed)) {
+				for i, want := range tt.wantConsumed {
+					assert.Equal(t, want.lotID, disposals[i].LotID)
+					assert.InDelta(t, want.quantity, disposals[i].Quantity, 1e-9)
+					assert.InDelta(t, want.basis, disposals[i].CostBasis, 1e-9)
+					assert.InDelta(t, want.gain, disposals[i].Gain, 1e-9)
+					assert.Equal(t, want.term, disposals[i].Term)
+				}
+			}
+			gains := realizedGains(disposals)
+			assert.InDelta(t, tt.wantShortTerm, gains.ShortTerm, 1e-9)
+			assert.InDelta(t, tt.wantLongTerm, gains.LongTerm, 1e-9)
+			assert.InDelta(t, 1.5, pos.Quantity(), 1e-9)
+			assert.InDelta(t, tt.wantRemaining, pos.CostBasis(), 1e-9)
+		})
+	}
+}
+
+func TestDerivePosition_LotSelection(t *testing.T) {
+	h := newLotHistory()
+	specific := func(qty float64, lots ...LotSelection) Transaction {
+		return Transaction{
+			ID: uuid.New(), Type: TransactionSell, Symbol: "BTC", Quantity: qty, Price: 400,
+			ExecutedAt: h.saleAt, CostBasisMethod: CostBasisSpecific, LotSelections: lots,
+		}
+	}
+	firstSale := specific(1, LotSelection{LotID: h.a.ID, Quantity: 1})
+	firstSale.ExecutedAt = h.saleAt.Add(-time.Hour)
+
+	tests := []struct {
+		name    string
+		history []Transaction
+		wantErr error
+	}{
+		{
+			name:    "lot that was never held",
+			history: h.with(specific(1, LotSelection{LotID: uuid.New(), Quantity: 1})),
+			wantErr: ErrInvalidLotSelection,
+		},
+		{
+			name:    "more than is left of a lot",
+			history: h.with(specific(1.5, LotSelection{LotID: h.b.ID, Quantity: 1.5})),
+			wantErr: ErrInvalidLotSelection,
+		},
+		{
+			name:    "lot already sold",
+			history: []Transaction{h.a, h.b, h.c, firstSale, specific(1, LotSelection{LotID: h.a.ID, Quantity: 1})},
+			wantErr: ErrInvalidLotSelection,
+		},
+		{
+			name:    "lot bought after the sale",
+			history: []Transaction{h.a, specific(1, LotSelection{LotID: h.b.ID, Quantity: 1}), h.b},
+			wantErr: ErrInvalidLotSelection,
+		},
+		{
+			name:    "no lots named",
+			history: h.with(specific(1)),
+			wantErr: ErrInvalidLotSelection,
+		},
+		{
+			name:    "overselling is checked first",
+			history: h.with(specific(4, LotSelection{LotID: h.a.ID, Quantity: 4})),
+			wantErr: ErrInsufficientQuantity,
+		},
+		{
+			name: "transfers consume lots without realizing a gain",
+			history: h.with(Transaction{
+				ID: uuid.New(), Type: TransactionTransferOut, Symbol: "BTC", Quantity: 1,
+				ExecutedAt: h.saleAt, CostBasisMethod: CostBasisSpecific,
+				LotSelections: []LotSelection{{LotID: h.b.ID, Quantity: 1}},
+			}),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			pos, err := derivePosition(tt.history)
+
+			// Assert
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			if assert.Len(t, pos.Disposals, 1) {
+				assert.Equal(t, h.b.ID, pos.Disposals[0].LotID)
+				assert.Zero(t, pos.Disposals[0].Gain)
+				assert.Empty(t, pos.Disposals[0].Term)
+			}
+			assert.Len(t, pos.Lots, 2)
+		})
+	}
+}
+
+func TestHoldingTerm(t *testing.T) {
+	acquired := time.Date(2024, time.February, 29, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		disposed time.Time
+		want     HoldingTerm
+	}{
+		{name: "same day", disposed: acquired, want: TermShort},
+		{name: "exactly a year", disposed: acquired.AddDate(1, 0, 0), want: TermShort},
+		{name: "a year and a second", disposed: acquired.AddDate(1, 0, 0).Add(time.Second), want: TermLong},
+		{name: "years later", disposed: acquired.AddDate(3, 0, 0), want: TermLong},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, holdingTerm(acquired, tt.disposed))
+		})
+	}
+}
+
+func TestRecordTransaction_CostBasisMethod(t *testing.T) {
+	lotID := uuid.New()
+	sell := func(qty float64, lots ...dto.LotSelection) dto.CreateTransactionRequest {
+		return dto.CreateTransactionRequest{Type: "sell", Symbol: "BTC", AssetType: "crypto", Quantity: qty, Price: 400, Lots: lots}
+	}
+
+	tests := []struct {
+		name            string
+		portfolioMethod CostBasisMethod
+		req             dto.CreateTransactionRequest
+		wantMethod      CostBasisMethod
+		wantErr         error
+	}{
+		{
+			name:            "sale takes the portfolio's method",
+			portfolioMethod: CostBasisHIFO,
+			req:             sell(1),
+			wantMethod:      CostBasisHIFO,
+		},
+		{
+			name:            "portfolio without a method sells at average cost",
+			portfolioMethod: "",
+			req:             sell(1),
+			wantMethod:      CostBasisAverage,
+		},
+		{
+			name:            "naming lots overrides the portfolio's method",
+			portfolioMethod: CostBasisFIFO,
+			req:             sell(1, dto.LotSelection{LotID: lotID, Quantity: 1}),
+			wantMethod:      CostBasisSpecific,
+		},
+		{
+			name:            "buys carry no method",
+			portfolioMethod: CostBasisLIFO,
+			req:             dto.CreateTransactionRequest{Type: "buy", Symbol: "BTC", AssetType: "crypto", Quantity: 1, Price: 400},
+		},
+		{
+			name:            "specific identification needs lots",
+			portfolioMethod: CostBasisSpecific,
+			req:             sell(1),
+			wantErr:         ErrInvalidTransaction,
+		},
+		{
+			name:            "lots on a buy",
+			portfolioMethod: CostBasisFIFO,
+			req: dto.CreateTransactionRequest{
+				Type: "buy", Symbol: "BTC", AssetType: "crypto", Quantity: 1, Price: 400,
+				Lots: []dto.LotSelection{{LotID: lotID, Quantity: 1}},
+			},
+			wantErr: ErrInvalidTransaction,
+		},
+		{
+			name:            "lots that do not add up to the quantity",
+			portfolioMethod: CostBasisFIFO,
+			req:             sell(2, dto.LotSelection{LotID: lotID, Quantity: 1}),
+			wantErr:         ErrInvalidTransaction,
+		},
+		{
+			name:            "lot named twice",
+			portfolioMethod: CostBasisFIFO,
+			req:             sell(2, dto.LotSelection{LotID: lotID, Quantity: 1}, dto.LotSelection{LotID: lotID, Quantity: 1}),
+			wantErr:         ErrInvalidTransaction,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			mockRepo := new(MockRepository)
+			u := NewUsecase(mockRepo, new(MockPriceRepository), audit.NopLogger{})
+			userID := uuid.New()
+			portfolio := ownedPortfolio(userID)
+			portfolio.CostBasisMethod = tt.portfolioMethod
+
+			mockRepo.On("GetByID", mock.Anything, portfolio.ID).Return(portfolio, nil)
+			mockRepo.On("RecordTransaction", mock.Anything, mock.AnythingOfType("*portfolio.Transaction"), mock.Anything).
+				Return(nil, nil)
+
+			// Act
+			txn, err := u.RecordTransaction(context.Background(), userID, portfolio.ID, tt.req)
+
+			// Assert
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				mockRepo.AssertNotCalled(t, "RecordTransaction", mock.Anything, mock.Anything, mock.Anything)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantMethod, txn.CostBasisMethod)
+			assert.Len(t, txn.LotSelections, len(tt.req.Lots))
+		})
+	}
+}
+
+func TestRecordTransaction_ReportsDisposals(t *testing.T) {
+	// Arrange
+	mockRepo := new(MockRepository)
+	u := NewUsecase(mockRepo, new(MockPriceRepository), audit.NopLogger{})
+	userID := uuid.New()
+	portfolio := ownedPortfolio(userID)
+	portfolio.CostBasisMethod = CostBasisFIFO
+	h := newLotHistory()
+	saleAt := h.saleAt
+
+	mockRepo.On("GetByID", mock.Anything, portfolio.ID).Return(portfolio, nil)
+	mockRepo.On("RecordTransaction", mock.Anything, mock.AnythingOfType("*portfolio.Transaction"), mock.Anything).
+		Return(nil, nil).
+		Run(func(args mock.Arguments) {
+			// Stand in for the database: assign an ID and derive from the full history.
+			txn := args.Get(1).(*Transaction)
+			txn.ID = uuid.New()
+			derive := args.Get(2).(PositionFunc)
+			_, err := derive(h.with(*txn))
+			assert.NoError(t, err)
+		})
+
+	// Act
+	txn, err := u.RecordTransaction(context.Background(), userID, portfolio.ID, dto.CreateTransactionRequest{
+		Type: "sell", Symbol: "BTC", AssetType: "crypto", Quantity: 1.5, Price: 400, ExecutedAt: &saleAt,
+	})
+
+	// Assert
+	assert.NoError(t, err)
+	if assert.Len(t, txn.Disposals, 2) {
+		assert.Equal(t, h.a.ID, txn.Disposals[0].LotID)
+		assert.Equal(t, TermLong, txn.Disposals[0].Term)
+		assert.Equal(t, h.b.ID, txn.Disposals[1].LotID)
+		assert.Equal(t, TermShort, txn.Disposals[1].Term)
+	}
+	resp := ToTransactionResponse(txn)
+	if assert.NotNil(t, resp.RealizedGains) {
+		assert.InDelta(t, 50, resp.RealizedGains.ShortTerm, 1e-9)
+		assert.InDelta(t, 300, resp.RealizedGains.LongTerm, 1e-9)
+		assert.InDelta(t, 350, resp.RealizedGains.Total, 1e-9)
+	}
+}
+
+func TestGetHoldingLots(t *testing.T) {
+	// Arrange
+	mockRepo := new(MockRepository)
+	u := NewUsecase(mockRepo, new(MockPriceRepository), audit.NopLogger{})
+	userID := uuid.New()
+	portfolio := ownedPortfolio(userID)
+	portfolio.CostBasisMethod = CostBasisLIFO
+	holding := Holding{ID: uuid.New(), PortfolioID: portfolio.ID, Symbol: "BTC", AssetType: "crypto", Quantity: 1.5}
+	portfolio.Holdings = []Holding{holding}
+	h := newLotHistory()
+	sale := Transaction{
+		ID: uuid.New(), Type: TransactionSell, Symbol: "BTC", Quantity: 1.5, Price: 400,
+		ExecutedAt: h.saleAt, CostBasisMethod: CostBasisLIFO,
+	}
+
+	mockRepo.On("GetByID", mock.Anything, portfolio.ID).Return(portfolio, nil)
+	mockRepo.On("ListSymbolTransactions", mock.Anything, portfolio.ID, "BTC", "crypto").Return(h.with(sale), nil)
+
+	// Act
+	lots, err := u.GetHoldingLots(context.Background(), userID, portfolio.ID, holding.ID)
+	_, missingErr := u.GetHoldingLots(context.Background(), userID, portfolio.ID, uuid.New())
+
+	// Assert
+	assert.NoError(t, err)
+	assert.ErrorIs(t, missingErr, ErrHoldingNotFound)
+	assert.Equal(t, CostBasisLIFO, lots.CostBasisMethod)
+	if assert.Len(t, lots.Lots, 2) {
+		assert.Equal(t, h.a.ID, lots.Lots[0].ID)
+		assert.InDelta(t, 1, lots.Lots[0].Quantity, 1e-9)
+		assert.Equal(t, h.b.ID, lots.Lots[1].ID)
+		assert.InDelta(t, 0.5, lots.Lots[1].Quantity, 1e-9)
+		assert.InDelta(t, 300, lots.Lots[1].UnitCost(), 1e-9)
+	}
+	assert.Len(t, lots.Disposals, 2)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestGetPortfolioSummary(t *testing.T) {
+	// Arrange
+	mockRepo := new(MockRepository)
+	priceHistory := new(MockPriceRepository)
+	u := NewUsecase(mockRepo, priceHistory, audit.NopLogger{})
+	userID := uuid.New()
+	portfolio := ownedPortfolio(userID)
+	pricedAt := time.Date(2025, 1, 10, 15, 0, 0, 0, time.UTC)
+	portfolio.Holdings = []Holding{
+		{ID: uuid.New(), PortfolioID: portfolio.ID, Symbol: "BTC", AssetType: "crypto", Quantity: 1, CurrentPrice: 250, PriceUpdatedAt: &pricedAt},
+		{ID: uuid.New(), PortfolioID: portfolio.ID, Symbol: "AAPL", AssetType: "stock", Quantity: 10, CurrentPrice: 160, PriceUpdatedAt: &pricedAt},
+	}
+
+	day := func(n int) time.Time { return time.Date(2025, 1, n, 0, 0, 0, 0, time.UTC) }
+	txn := func(n int, typ TransactionType, symbol, assetType string, qty, price, fee float64) Transaction {
+		return Transaction{
+			ID: uuid.New(), PortfolioID: portfolio.ID, Type: typ, Symbol: symbol, AssetType: assetType,
+			Quantity: qty, Price: price, Fee: fee, ExecutedAt: day(n), CostBasisMethod: CostBasisFIFO,
+		}
+	}
+	ledger := []Transaction{
+		txn(1, TransactionDeposit, "", "", 5000, 0, 0),
+		txn(1, TransactionBuy, "BTC", "crypto", 2, 100, 10),
+		txn(1, TransactionBuy, "ETH", "crypto", 1, 1000, 0),
+		txn(1, TransactionBuy, "AAPL", "stock", 10, 150, 0),
+		txn(2, TransactionSell, "BTC", "crypto", 1, 300, 5),
+		txn(3, TransactionStakingReward, "ETH", "crypto", 0.1, 1200, 1),
+		txn(4, TransactionSell, "ETH", "crypto", 1.1, 1300, 0),
+		txn(5, TransactionDividend, "AAPL", "stock", 25, 0, 0),
+		txn(5, TransactionFee, "AAPL", "stock", 0, 0, 2),
+		txn(6, TransactionFee, "", "", 0, 0, 3),
+	}
+
+	mockRepo.On("GetByID", mock.Anything, portfolio.ID).Return(portfolio, nil)
+	mockRepo.On("ListLedger", mock.Anything, portfolio.ID).Return(ledger, nil)
+	// BTC is compared with its price 24 hours earlier, AAPL with the previous day's close.
+	priceHistory.On("AsOf", mock.Anything, pricing.Series{Symbol: "BTC", AssetType: "crypto", Currency: "USD"}, pricedAt.Add(-24*time.Hour)).
+		Return(&pricing.Price{Timestamp: pricedAt.Add(-25 * time.Hour), Close: 200}, nil)
+	priceHistory.On("AsOf", mock.Anything, pricing.Series{Symbol: "AAPL", AssetType: "stock", Currency: "USD"}, day(10)).
+		Return(&pricing.Price{Timestamp: day(9).Add(21 * time.Hour), Close: 150}, nil)
+
+	// Act
+	summary, err := u.GetPortfolioSummary(context.Background(), userID, portfolio.ID)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.InDelta(t, 150, summary.DayChange, 1e-9)
+	assert.InDelta(t, 150.0/1700*100, summary.DayChangePct, 1e-9)
+	assert.InDelta(t, 500, summary.RealizedPnL, 1e-9)
+	assert.InDelta(t, 245, summary.UnrealizedPnL, 1e-9)
+	assert.InDelta(t, 145, summary.Income, 1e-9)
+	assert.InDelta(t, 21, summary.FeesPaid, 1e-9)
+	assert.InDelta(t, 884, summary.TotalReturn, 1e-9)
+	assert.InDelta(t, 1605, summary.TotalInvested, 1e-9)
+	assert.InDelta(t, 884.0/2830*100, summary.TotalReturnPct, 1e-9)
+	assert.Equal(t, 2, summary.HoldingsCount)
+
+	wantHoldings := []HoldingPerformance{
+		{Symbol: "AAPL", AssetType: "stock", Quantity: 10, CostBasis: 1500, MarketValue: 1600, DayChange: 100, DayChangePct: 100.0 / 15,
+			Performance: Performance{UnrealizedPnL: 100, FeesPaid: 2, Income: 25, TotalReturn: 123}},
+		{Symbol: "BTC", AssetType: "crypto", Quantity: 1, CostBasis: 105, MarketValue: 250, DayChange: 50, DayChangePct: 25,
+			Performance: Performance{RealizedPnL: 190, UnrealizedPnL: 145, FeesPaid: 15, TotalReturn: 335}},
+		{Symbol: "ETH", AssetType: "crypto",
+			Performance: Performance{RealizedPnL: 310, FeesPaid: 1, Income: 120, TotalReturn: 429}},
+	}
+	if assert.Len(t, summary.ByHolding, len(wantHoldings)) {
+		for i, want := range wantHoldings {
+			got := summary.ByHolding[i]
+			assert.Equal(t, want.Symbol, got.Symbol)
+			assert.Equal(t, want.AssetType, got.AssetType)
+			assert.InDelta(t, want.Quantity, got.Quantity, 1e-9, want.Symbol)
+			assert.InDelta(t, want.CostBasis, got.CostBasis, 1e-9, want.Symbol)
+			assert.InDelta(t, want.MarketValue, got.MarketValue, 1e-9, want.Symbol)
+			assert.InDelta(t, want.DayChange, got.DayChange, 1e-9, want.Symbol)
+			assert.InDelta(t, want.DayChangePct, got.DayChangePct, 1e-9, want.Symbol)
+			assertPerformance(t, want.Performance, got.Performance, want.Symbol)
+		}
+	}
+
+	wantAssetTypes := []AssetTypePerformance{
+		{AssetType: "crypto", CostBasis: 105, MarketValue: 250,
+			Performance: Performance{RealizedPnL: 500, UnrealizedPnL: 145, FeesPaid: 16, Income: 120, TotalReturn: 764}},
+		{AssetType: "stock", CostBasis: 1500, MarketValue: 1600,
+			Performance: Performance{UnrealizedPnL: 100, FeesPaid: 2, Income: 25, TotalReturn: 123}},
+	}
+	if assert.Len(t, summary.ByAssetType, len(wantAssetTypes)) {
+		for i, want := range wantAssetTypes {
+			got := summary.ByAssetType[i]
+			assert.Equal(t, want.AssetType, got.AssetType)
+			assert.InDelta(t, want.CostBasis, got.CostBasis, 1e-9, want.AssetType)
+			assert.InDelta(t, want.MarketValue, got.MarketValue, 1e-9, want.AssetType)
+			assertPerformance(t, want.Performance, got.Performance, want.AssetType)
+		}
+	}
+	mockRepo.AssertExpectations(t)
+	priceHistory.AssertExpectations(t)
+}
+
+func assertPerformance(t *testing.T, want, got Performance, label string) {
+	t.Helper()
+	assert.InDelta(t, want.RealizedPnL, got.RealizedPnL, 1e-9, label+" realized")
+	assert.InDelta(t, want.UnrealizedPnL, got.UnrealizedPnL, 1e-9, label+" unrealized")
+	assert.InDelta(t, want.FeesPaid, got.FeesPaid, 1e-9, label+" fees")
+	assert.InDelta(t, want.Income, got.Income, 1e-9, label+" income")
+	assert.InDelta(t, want.TotalReturn, got.TotalReturn, 1e-9, label+" total")
+}
+
+func TestMeasurePerformance_Fees(t *testing.T) {
+	day := func(n int) time.Time { return time.Date(2025, 1, n, 0, 0, 0, 0, time.UTC) }
+	buy := Transaction{ID: uuid.New(), Type: TransactionBuy, Symbol: "SOL", AssetType: "crypto", Quantity: 10, Price: 20, Fee: 4, ExecutedAt: day(1)}
+
+	tests := []struct {
+		name        string
+		next        Transaction
+		wantFees    float64
+		wantRealize float64
+		wantTotal   float64
+	}{
+		{
+			name:      "buy fee is in the cost basis",
+			next:      Transaction{Type: TransactionDeposit, Quantity: 100, ExecutedAt: day(2)},
+			wantFees:  4,
+			wantTotal: -4,
+		},
+		{
+			name:        "sale fee comes off the proceeds",
+			next:        Transaction{Type: TransactionSell, Symbol: "SOL", AssetType: "crypto", Quantity: 10, Price: 30, Fee: 6, ExecutedAt: day(2)},
+			wantFees:    10,
+			wantRealize: 90,
+			wantTotal:   90,
+		},
+		{
+			name:      "transfer out fee is an expense",
+			next:      Transaction{Type: TransactionTransferOut, Symbol: "SOL", AssetType: "crypto", Quantity: 10, Fee: 1, ExecutedAt: day(2)},
+			wantFees:  5,
+			wantTotal: -1,
+		},
+		{
+			name:      "fee on the portfolio counts towards the total only",
+			next:      Transaction{Type: TransactionFee, Fee: 7, ExecutedAt: day(2)},
+			wantFees:  11,
+			wantTotal: -11,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			holdings := []Holding{{Symbol: "SOL", AssetType: "crypto", CurrentPrice: 20}}
+
+			// Act
+			perf, err := measurePerformance([]Transaction{buy, tt.next}, holdings)
+
+			// Assert
+			assert.NoError(t, err)
+			assert.InDelta(t, tt.wantFees, perf.total.FeesPaid, 1e-9)
+			assert.InDelta(t, tt.wantRealize, perf.total.RealizedPnL, 1e-9)
+			assert.InDelta(t, tt.wantTotal, perf.total.TotalReturn, 1e-9)
+		})
+	}
+}
+
+func TestGetPortfolioSummary_SameTickerTwoAssetTypes(t *testing.T) {
+	// Arrange
+	mockRepo := new(MockRepository)
+	priceHistory := new(MockPriceRepository)
+	u := NewUsecase(mockRepo, priceHistory, audit.NopLogger{})
+	userID := uuid.New()
+	portfolio := ownedPortfolio(userID)
+	pricedAt := time.Date(2025, 1, 10, 15, 0, 0, 0, time.UTC)
+	portfolio.Holdings = []Holding{
+		{ID: uuid.New(), PortfolioID: portfolio.ID, Symbol: "ABC", AssetType: "stock", Quantity: 10, CurrentPrice: 60, PriceUpdatedAt: &pricedAt},
+		{ID: uuid.New(), PortfolioID: portfolio.ID, Symbol: "ABC", AssetType: "crypto", Quantity: 100, CurrentPrice: 2, PriceUpdatedAt: &pricedAt},
+	}
+	executedAt := time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC)
+	ledger := []Transaction{
+		{ID: uuid.New(), Type: TransactionBuy, Symbol: "ABC", AssetType: "stock", Quantity: 10, Price: 50, ExecutedAt: executedAt},
+		{ID: uuid.New(), Type: TransactionBuy, Symbol: "ABC", AssetType: "crypto", Quantity: 100, Price: 1, ExecutedAt: executedAt},
+	}
+
+	mockRepo.On("GetByID", mock.Anything, portfolio.ID).Return(portfolio, nil)
+	mockRepo.On("ListLedger", mock.Anything, portfolio.ID).Return(ledger, nil)
+	priceHistory.On("AsOf", mock.Anything, pricing.Series{Symbol: "ABC", AssetType: "stock", Currency: "USD"}, mock.AnythingOfType("time.Time")).
+		Return(&pricing.Price{Timestamp: pricedAt.Add(-18 * time.Hour), Close: 55}, nil)
+	priceHistory.On("AsOf", mock.Anything, pricing.Series{Symbol: "ABC", AssetType: "crypto", Currency: "USD"}, mock.AnythingOfType("time.Time")).
+		Return(&pricing.Price{Timestamp: pricedAt.Add(-25 * time.Hour), Close: 1.5}, nil)
+
+	// Act
+	summary, err := u.GetPortfolioSummary(context.Background(), userID, portfolio.ID)
+
+	// Assert
+	assert.NoError(t, err)
+	wantHoldings := []HoldingPerformance{
+		{Symbol: "ABC", AssetType: "crypto", Quantity: 100, CostBasis: 100, MarketValue: 200, DayChange: 50, DayChangePct: 100.0 / 3,
+			Performance: Performance{UnrealizedPnL: 100, TotalReturn: 100}},
+		{Symbol: "ABC", AssetType: "stock", Quantity: 10, CostBasis: 500, MarketValue: 600, DayChange: 50, DayChangePct: 100.0 / 11,
+			Performance: Performance{UnrealizedPnL: 100, TotalReturn: 100}},
+	}
+	if assert.Len(t, summary.ByHolding, len(wantHoldings)) {
+		for i, want := range wantHoldings {
+			got := summary.ByHolding[i]
+			assert.Equal(t, want.AssetType, got.AssetType)
+			assert.InDelta(t, want.Quantity, got.Quantity, 1e-9, want.AssetType)
+			assert.InDelta(t, want.CostBasis, got.CostBasis, 1e-9, want.AssetType)
+			assert.InDelta(t, want.MarketValue, got.MarketValue, 1e-9, want.AssetType)
+			assert.InDelta(t, want.DayChange, got.DayChange, 1e-9, want.AssetType)
+			assert.InDelta(t, want.DayChangePct, got.DayChangePct, 1e-9, want.AssetType)
+			assert.InDelta(t, want.TotalReturn, got.TotalReturn, 1e-9, want.AssetType)
+		}
+	}
+	assert.Equal(t, 2, summary.HoldingsCount)
+	assert.InDelta(t, 100, summary.DayChange, 1e-9)
+}
+
+func TestGetPortfolioSummary_DayChangeWithoutHistory(t *testing.T) {
+	// Arrange
+	mockRepo := new(MockRepository)
+	priceHistory := new(MockPriceRepository)
+	u := NewUsecase(mockRepo, priceHistory, audit.NopLogger{})
+	userID := uuid.New()
+	portfolio := ownedPortfolio(userID)
+	portfolio.Holdings = []Holding{
+		{ID: uuid.New(), PortfolioID: portfolio.ID, Symbol: "BTC", AssetType: "crypto", Quantity: 2, CurrentPrice: 110},
+		{ID: uuid.New(), PortfolioID: portfolio.ID, Symbol: "NEW", AssetType: "stock", Quantity: 1, CurrentPrice: 80},
+	}
+
+	mockRepo.On("GetByID", mock.Anything, portfolio.ID).Return(portfolio, nil)
+	mockRepo.On("ListLedger", mock.Anything, portfolio.ID).Return([]Transaction{}, nil)
+	priceHistory.On("AsOf", mock.Anything, pricing.Series{Symbol: "BTC", AssetType: "crypto", Currency: "USD"}, mock.AnythingOfType("time.Time")).
+		Return(&pricing.Price{Timestamp: time.Now().Add(-25 * time.Hour), Close: 100}, nil)
+	priceHistory.On("AsOf", mock.Anything, pricing.Series{Symbol: "NEW", AssetType: "stock", Currency: "USD"}, mock.AnythingOfType("time.Time")).
+		Return(nil, pricing.ErrPriceNotFound)
+
+	// Act
+	summary, err := u.GetPortfolioSummary(context.Background(), userID, portfolio.ID)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.InDelta(t, 20, summary.DayChange, 1e-9)
+	assert.InDelta(t, 20.0/280*100, summary.DayChangePct, 1e-9, "a holding without history counts as unchanged")
+	priceHistory.AssertExpectations(t)
+}
+
+func TestGetPortfolioSummary_StaleReferencePrice(t *testing.T) {
+	monday := time.Date(2025, 1, 13, 15, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name          string
+		assetType     string
+		referenceAt   time.Time
+		wantDayChange float64
+	}{
+		{name: "crypto priced shortly before a day ago", assetType: "crypto", referenceAt: monday.Add(-25 * time.Hour), wantDayChange: 10},
+		{name: "crypto last priced days ago", assetType: "crypto", referenceAt: monday.Add(-72 * time.Hour), wantDayChange: 0},
+		{name: "stock closed on Friday", assetType: "stock", referenceAt: monday.Add(-66 * time.Hour), wantDayChange: 10},
+		{name: "stock last priced weeks ago", assetType: "stock", referenceAt: monday.Add(-21 * 24 * time.Hour), wantDayChange: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			mockRepo := new(MockRepository)
+			priceHistory := new(MockPriceRepository)
+			u := NewUsecase(mockRepo, priceHistory, audit.NopLogger{})
+			userID := uuid.New()
+			portfolio := ownedPortfolio(userID)
+			portfolio.Holdings = []Holding{
+				{ID: uuid.New(), PortfolioID: portfolio.ID, Symbol: "ABC", AssetType: tt.assetType, Quantity: 1, CurrentPrice: 110, PriceUpdatedAt: &monday},
+			}
+
+			mockRepo.On("GetByID", mock.Anything, portfolio.ID).Return(portfolio, nil)
+			mockRepo.On("ListLedger", mock.Anything, portfolio.ID).Return([]Transaction{}, nil)
+			priceHistory.On("AsOf", mock.Anything, mock.Anything, mock.Anything).
+				Return(&pricing.Price{Timestamp: tt.referenceAt, Close: 100}, nil)
+
+			// Act
+			summary, err := u.GetPortfolioSummary(context.Background(), userID, portfolio.ID)
+
+			// Assert
+			assert.NoError(t, err)
+			assert.InDelta(t, tt.wantDayChange, summary.DayChange, 1e-9)
+		})
+	}
+}
+
+func TestGetPortfolioSummary_PriceHistoryError(t *testing.T) {
+	// Arrange
+	mockRepo := new(MockRepository)
+	priceHistory := new(MockPriceRepository)
+	u := NewUsecase(mockRepo, priceHistory, audit.NopLogger{})
+	userID := uuid.New()
+	portfolio := ownedPortfolio(userID)
+	portfolio.Holdings = []Holding{
+		{ID: uuid.New(), PortfolioID: portfolio.ID, Symbol: "BTC", AssetType: "crypto", Quantity: 1, CurrentPrice: 100},
+	}
+	dbErr := errors.New("database is down")
+
+	mockRepo.On("GetByID", mock.Anything, portfolio.ID).Return(portfolio, nil)
+	mockRepo.On("ListLedger", mock.Anything, portfolio.ID).Return([]Transaction{}, nil)
+	priceHistory.On("AsOf", mock.Anything, mock.Anything, mock.Anything).Return(nil, dbErr)
+
+	// Act
+	summary, err := u.GetPortfolioSummary(context.Background(), userID, portfolio.ID)
+
+	// Assert
+	assert.ErrorIs(t, err, dbErr)
+	assert.Nil(t, summary)
+}
+
+func TestReferenceTime(t *testing.T) {
+	tests := []struct {
+		name      string
+		assetType string
+		pricedAt  time.Time
+		want      time.Time
+	}{
+		{
+			name:      "crypto looks back 24 hours",
+			assetType: "crypto",
+			pricedAt:  time.Date(2025, 3, 3, 9, 30, 0, 0, time.UTC),
+			want:      time.Date(2025, 3, 2, 9, 30, 0, 0, time.UTC),
+		},
+		{
+			name:      "stocks look back to the previous close",
+			assetType: "stock",
+			pricedAt:  time.Date(2025, 3, 3, 20, 0, 0, 0, time.UTC),
+			want:      time.Date(2025, 3, 3, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:      "days are UTC days",
+			assetType: "etf",
+			pricedAt:  time.Date(2025, 3, 3, 22, 0, 0, 0, time.FixedZone("EST", -5*60*60)),
+			want:      time.Date(2025, 3, 4, 0, 0, 0, 0, time.UTC),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			got := referenceTime(tt.assetType, tt.pricedAt)
+
+			// Assert
+			assert.True(t, tt.want.Equal(got), "got %s", got)
+		})
+	}
+}
+
+// stubPrices is a PriceProvider answering from a map keyed by symbol, counting lookups.
+type stubPrices struct {
+	quotes map[string]pricing.Quote
+	calls  map[string]int
+}
+
+func (s *stubPrices) Quote(ctx context.Context, symbol, assetType string) (pricing.Quote, error) {
+	s.calls[symbol]++
+	quote, ok := s.quotes[symbol]
+	if !ok {
+		return pricing.Quote{}, fmt.Errorf("%w: %s", pricing.ErrNoQuote, symbol)
+	}
+	return quote, nil
+}
+
+func TestRefreshPrices(t *testing.T) {
+	now := time.Now()
+	btc := pricing.Quote{Symbol: "btc", AssetType: "CRYPTO", Price: 64000, Currency: "USD", Timestamp: now, Source: "stub"}
+	aapl := pricing.Quote{Symbol: "AAPL", AssetType: "stock", Price: 190, Currency: "USD", Timestamp: now, Source: "stub"}
+
+	tests := []struct {
+		name        string
+		held        []HeldSymbol
+		quotes      map[string]pricing.Quote
+		wantApplied []pricing.Quote
+		wantErr     bool
+	}{
+		{
+			name: "quotes are applied as the holdings spell the symbol",
+			held: []HeldSymbol{
+				{Symbol: "AAPL", AssetType: "stock", Currency: "USD"},
+				{Symbol: "BTC", AssetType: "crypto", Currency: "USD"},
+			},
+			quotes: map[string]pricing.Quote{"AAPL": aapl, "BTC": btc},
+			wantApplied: []pricing.Quote{
+				aapl,
+				{Symbol: "BTC", AssetType: "crypto", Price: 64000, Currency: "USD", Timestamp: now, Source: "stub"},
+			},
+		},
+		{
+			name: "portfolios in another currency keep their price",
+			held: []HeldSymbol{
+				{Symbol: "AAPL", AssetType: "stock", Currency: "EUR"},
+				{Symbol: "AAPL", AssetType: "stock", Currency: "USD"},
+			},
+			quotes:      map[string]pricing.Quote{"AAPL": aapl},
+			wantApplied: []pricing.Quote{aapl},
+		},
+		{
+			name: "portfolio currencies are matched in any case",
+			held: []HeldSymbol{
+				{Symbol: "AAPL", AssetType: "stock", Currency: "usd"},
+			},
+			quotes:      map[string]pricing.Quote{"AAPL": aapl},
+			wantApplied: []pricing.Quote{aapl},
+		},
+		{
+			name: "a symbol without a quote does not stop the others",
+			held: []HeldSymbol{
+				{Symbol: "AAPL", AssetType: "stock", Currency: "USD"},
+				{Symbol: "XYZ", AssetType: "stock", Currency: "USD"},
+			},
+			quotes:      map[string]pricing.Quote{"AAPL": aapl},
+			wantApplied: []pricing.Quote{aapl},
+		},
+		{
+			name: "nothing quoted at all fails the run",
+			held: []HeldSymbol{
+				{Symbol: "XYZ", AssetType: "stock", Currency: "USD"},
+				{Symbol: "XYZ", AssetType: "stock", Currency: "EUR"},
+			},
+			quotes:  map[string]pricing.Quote{},
+			wantErr: true,
+		},
+		{
+			name: "nothing held",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			mockRepo := new(MockRepository)
+			history := new(MockPriceRepository)
+			prices := &stubPrices{quotes: tt.quotes, calls: map[string]int{}}
+			var applied []pricing.Quote
+			var recorded []pricing.Price
+
+			mockRepo.On("ListHeldSymbols", mock.Anything).Return(tt.held, nil)
+			mockRepo.On("ApplyQuote", mock.Anything, mock.AnythingOfType("pricing.Quote")).
+				Return(int64(1), nil).
+				Run(func(args mock.Arguments) {
+					applied = append(applied, args.Get(1).(pricing.Quote))
+				})
+			history.On("Ingest", mock.Anything, mock.AnythingOfType("[]pricing.Price")).
+				Return(int64(1), nil).
+				Run(func(args mock.Arguments) {
+					recorded = append(recorded, args.Get(1).([]pricing.Price)...)
+				})
+
+			// Act
+			err := NewPriceRefreshJob(mockRepo, prices, history, time.Minute, 0).Run(context.Background())
+
+			// Assert
+			if tt.wantErr {
+				assert.ErrorIs(t, err, pricing.ErrNoQuote)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.wantApplied, applied)
+			// Every quote is recorded in the price history once.
+			var wantRecorded []pricing.Price
+			for _, quote := range tt.wantApplied {
+				wantRecorded = append(wantRecorded, pricing.QuotePrice(quote))
+			}
+			assert.Equal(t, wantRecorded, recorded)
+			for symbol, calls := range prices.calls {
+				assert.Equal(t, 1, calls, "%s is quoted once per run", symbol)
+			}
+		})
+	}
+}
+
+func TestRefreshPrices_RepositoryError(t *testing.T) {
+	// Arrange
+	mockRepo := new(MockRepository)
+	prices := &stubPrices{quotes: map[string]pricing.Quote{"AAPL": {Price: 190, Currency: "USD"}}, calls: map[string]int{}}
+	mockRepo.On("ListHeldSymbols", mock.Anything).Return([]HeldSymbol{{Symbol: "AAPL", AssetType: "stock", Currency: "USD"}}, nil)
+	mockRepo.On("ApplyQuote", mock.Anything, mock.Anything).Return(int64(0), errors.New("database is down"))
+
+	// Act
+	err := refreshPrices(context.Background(), mockRepo, prices, new(MockPriceRepository))
+
+	// Assert
+	assert.EqualError(t, err, "database is down")
 }
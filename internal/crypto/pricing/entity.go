@@ -0,0 +1,78 @@
+package pricing
+
+import (
+	"context"
+	"go-boilerplate/internal/dto"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Price is a symbol's price in Currency for the period starting at Timestamp. Bars carry
+// their open, high and low; a single quote only has a close.
+type Price struct {
+	ID        uuid.UUID `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	AssetType string    `json:"asset_type" gorm:"type:varchar(20);not null;uniqueIndex:idx_prices_series_time,priority:1"`
+	Symbol    string    `json:"symbol" gorm:"type:varchar(10);not null;uniqueIndex:idx_prices_series_time,priority:2"`
+	Currency  string    `json:"currency" gorm:"type:varchar(3);not null;uniqueIndex:idx_prices_series_time,priority:3"`
+	Timestamp time.Time `json:"timestamp" gorm:"not null;uniqueIndex:idx_prices_series_time,priority:4"`
+	Open      *float64  `json:"open,omitempty" gorm:"type:decimal(24,8)"`
+	High      *float64  `json:"high,omitempty" gorm:"type:decimal(24,8)"`
+	Low       *float64  `json:"low,omitempty" gorm:"type:decimal(24,8)"`
+	Close     float64   `json:"close" gorm:"type:decimal(24,8);not null"`
+	Source    string    `json:"source" gorm:"type:varchar(100)"`
+	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+func (Price) TableName() string {
+	return "prices"
+}
+
+// Series names the prices of one symbol in one currency.
+type Series struct {
+	Symbol    string
+	AssetType string
+	Currency  string
+}
+
+// normalize spells the series as it is stored, so "btc" and "BTC" are the same symbol.
+func (s Series) normalize() Series {
+	return Series{
+		Symbol:    strings.ToUpper(s.Symbol),
+		AssetType: strings.ToLower(s.AssetType),
+		Currency:  strings.ToUpper(s.Currency),
+	}
+}
+
+// QuotePrice records a quote as a price with only a close.
+func QuotePrice(q Quote) Price {
+	return Price{
+		Symbol:    q.Symbol,
+		AssetType: q.AssetType,
+		Currency:  q.Currency,
+		Timestamp: q.Timestamp,
+		Close:     q.Price,
+		Source:    q.Source,
+	}
+}
+
+type Repository interface {
+	// Ingest records prices, replacing any already recorded for the same series and time.
+	Ingest(ctx context.Context, prices []Price) (int64, error)
+	// Range returns up to limit prices of the series from from until to, oldest first.
+	Range(ctx context.Context, series Series, from, to time.Time, limit int) ([]Price, error)
+	// Latest returns the most recent price of the series.
+	Latest(ctx context.Context, series Series) (*Price, error)
+	// AsOf returns the last price of the series recorded before at.
+	AsOf(ctx context.Context, series Series, at time.Time) (*Price, error)
+}
+
+type Usecase interface {
+	IngestPrices(ctx context.Context, req dto.IngestPricesRequest) (int64, error)
+	GetPrices(ctx context.Context, series Series, from, to time.Time, limit int) ([]Price, error)
+	// GetPrice returns the latest price of the series, or the last one before before
+	// when it is set.
+	GetPrice(ctx context.Context, series Series, before *time.Time) (*Price, error)
+}
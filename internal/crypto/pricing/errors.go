@@ -0,0 +1,13 @@
+package pricing
+
+import "errors"
+
+// Sentinel errors for the price history.
+var (
+	// ErrPriceNotFound is returned when no price of a series is recorded for the time asked.
+	ErrPriceNotFound = errors.New("price not found")
+
+	// ErrInvalidPrice is returned when an ingested price is inconsistent, such as a close
+	// above its high.
+	ErrInvalidPrice = errors.New("invalid price")
+)
@@ -0,0 +1,151 @@
+package pricing
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// fileColumns are the columns a price file must have, in any order. currency and
+// timestamp may be left empty.
+var fileColumns = []string{"symbol", "asset_type", "price", "currency", "timestamp"}
+
+type fileProvider struct {
+	path            string
+	defaultCurrency string
+
+	mu      sync.Mutex
+	modTime time.Time
+	quotes  map[string]Quote
+}
+
+// NewFileProvider returns a PriceProvider that reads quotes from a CSV file with a header
+// row naming the symbol, asset_type, price, currency and timestamp columns. The file is
+// read again whenever it changes, so another process can keep it up to date. Rows
+// without a currency are in defaultCurrency; rows without an RFC 3339 timestamp are as
+// of the file's modification time.
+func NewFileProvider(path, defaultCurrency string) PriceProvider {
+	return &fileProvider{path: path, defaultCurrency: strings.ToUpper(defaultCurrency)}
+}
+
+func (p *fileProvider) Quote(ctx context.Context, symbol, assetType string) (Quote, error) {
+	if err := ctx.Err(); err != nil {
+		return Quote{}, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err := p.reload(); err != nil {
+		return Quote{}, err
+	}
+
+	quote, ok := p.quotes[quoteKey(symbol, assetType)]
+	if !ok {
+		return Quote{}, fmt.Errorf("%w: %s %s", ErrNoQuote, assetType, symbol)
+	}
+	return quote, nil
+}
+
+// reload parses the file if it changed since it was last read. The previous quotes are
+// kept when the new contents cannot be parsed.
+func (p *fileProvider) reload() error {
+	info, err := os.Stat(p.path)
+	if err != nil {
+		return fmt.Errorf("failed to read price file: %w", err)
+	}
+	if p.quotes != nil && info.ModTime().Equal(p.modTime) {
+		return nil
+	}
+
+	f, err := os.Open(p.path)
+	if err != nil {
+		return fmt.Errorf("failed to read price file: %w", err)
+	}
+	defer f.Close()
+
+	quotes, err := parsePriceFile(f, p.defaultCurrency, info.ModTime())
+	if err != nil {
+		return fmt.Errorf("invalid price file %s: %w", p.path, err)
+	}
+
+	p.quotes = quotes
+	p.modTime = info.ModTime()
+	return nil
+}
+
+func parsePriceFile(r io.Reader, defaultCurrency string, modTime time.Time) (map[string]Quote, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	reader.Comment = '#'
+
+	header, err := reader.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("missing header row")
+		}
+		return nil, err
+	}
+	index := make(map[string]int, len(header))
+	for i, name := range header {
+		index[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range fileColumns {
+		if _, ok := index[name]; !ok {
+			return nil, fmt.Errorf("missing %s column", name)
+		}
+	}
+
+	quotes := make(map[string]Quote)
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := reader.FieldPos(0)
+		field := func(name string) string {
+			return strings.TrimSpace(record[index[name]])
+		}
+
+		quote := Quote{
+			Symbol:    strings.ToUpper(field("symbol")),
+			AssetType: strings.ToLower(field("asset_type")),
+			Currency:  strings.ToUpper(field("currency")),
+			Timestamp: modTime,
+			Source:    "file",
+		}
+		if quote.Symbol == "" || quote.AssetType == "" {
+			return nil, fmt.Errorf("line %d: symbol and asset_type are required", line)
+		}
+		quote.Price, err = strconv.ParseFloat(field("price"), 64)
+		if err != nil || quote.Price <= 0 {
+			return nil, fmt.Errorf("line %d: price must be a positive number", line)
+		}
+		if quote.Currency == "" {
+			quote.Currency = defaultCurrency
+		}
+		if ts := field("timestamp"); ts != "" {
+			quote.Timestamp, err = time.Parse(time.RFC3339, ts)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: timestamp must be RFC 3339", line)
+			}
+		}
+
+		quotes[quoteKey(quote.Symbol, quote.AssetType)] = quote
+	}
+	return quotes, nil
+}
+
+func quoteKey(symbol, assetType string) string {
+	return strings.ToLower(assetType) + ":" + strings.ToUpper(symbol)
+}
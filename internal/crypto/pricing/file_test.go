@@ -0,0 +1,136 @@
+package pricing
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePriceFile(t *testing.T) {
+	modTime := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		csv     string
+		want    map[string]Quote
+		wantErr string
+	}{
+		{
+			name: "columns in any order with defaults",
+			csv: "price,symbol,asset_type,timestamp,currency\n" +
+				"# comments are skipped\n" +
+				"64250.5, btc, Crypto, 2025-03-01T14:30:00Z, usd\n" +
+				"190,AAPL,stock,,\n",
+			want: map[string]Quote{
+				"crypto:BTC": {Symbol: "BTC", AssetType: "crypto", Price: 64250.5, Currency: "USD",
+					Timestamp: time.Date(2025, 3, 1, 14, 30, 0, 0, time.UTC), Source: "file"},
+				"stock:AAPL": {Symbol: "AAPL", AssetType: "stock", Price: 190, Currency: "EUR",
+					Timestamp: modTime, Source: "file"},
+			},
+		},
+		{
+			name:    "empty file",
+			csv:     "",
+			wantErr: "missing header row",
+		},
+		{
+			name:    "missing column",
+			csv:     "symbol,asset_type,price,currency\nBTC,crypto,1,USD\n",
+			wantErr: "missing timestamp column",
+		},
+		{
+			name:    "price that is not a number",
+			csv:     "symbol,asset_type,price,currency,timestamp\nBTC,crypto,lots,USD,\n",
+			wantErr: "line 2: price must be a positive number",
+		},
+		{
+			name:    "zero price",
+			csv:     "symbol,asset_type,price,currency,timestamp\nBTC,crypto,0,USD,\n",
+			wantErr: "line 2: price must be a positive number",
+		},
+		{
+			name:    "timestamp that is not RFC 3339",
+			csv:     "symbol,asset_type,price,currency,timestamp\nBTC,crypto,1,USD,01/03/2025\n",
+			wantErr: "line 2: timestamp must be RFC 3339",
+		},
+		{
+			name:    "missing symbol",
+			csv:     "symbol,asset_type,price,currency,timestamp\n,crypto,1,USD,\n",
+			wantErr: "line 2: symbol and asset_type are required",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			quotes, err := parsePriceFile(strings.NewReader(tt.csv), "EUR", modTime)
+
+			// Assert
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, quotes)
+		})
+	}
+}
+
+func TestFileProvider_Quote(t *testing.T) {
+	// Arrange
+	path := filepath.Join(t.TempDir(), "prices.csv")
+	write := func(contents string, modTime time.Time) {
+		require.NoError(t, os.WriteFile(path, []byte(contents), 0o600))
+		require.NoError(t, os.Chtimes(path, modTime, modTime))
+	}
+	header := "symbol,asset_type,price,currency,timestamp\n"
+	start := time.Now().Add(-time.Hour)
+	write(header+"BTC,crypto,60000,USD,\n", start)
+	provider := NewFileProvider(path, "USD")
+	ctx := context.Background()
+
+	// Act
+	first, firstErr := provider.Quote(ctx, "btc", "crypto")
+	_, missingErr := provider.Quote(ctx, "ETH", "crypto")
+	_, wrongTypeErr := provider.Quote(ctx, "BTC", "stock")
+
+	write(header+"BTC,crypto,65000,USD,\n", start.Add(time.Minute))
+	updated, updatedErr := provider.Quote(ctx, "BTC", "crypto")
+
+	write("not a price file\n", start.Add(2*time.Minute))
+	kept, keptErr := provider.Quote(ctx, "BTC", "crypto")
+
+	// Assert
+	assert.NoError(t, firstErr)
+	assert.Equal(t, 60000.0, first.Price)
+	assert.Equal(t, "file", first.Source)
+	assert.ErrorIs(t, missingErr, ErrNoQuote)
+	assert.ErrorIs(t, wrongTypeErr, ErrNoQuote)
+	assert.NoError(t, updatedErr)
+	assert.Equal(t, 65000.0, updated.Price, "a changed file is read again")
+	assert.Error(t, keptErr, "an invalid file is reported")
+	assert.Zero(t, kept)
+
+	write(header+"BTC,crypto,66000,USD,\n", start.Add(3*time.Minute))
+	recovered, err := provider.Quote(ctx, "BTC", "crypto")
+	assert.NoError(t, err)
+	assert.Equal(t, 66000.0, recovered.Price)
+}
+
+func TestFileProvider_MissingFile(t *testing.T) {
+	// Arrange
+	provider := NewFileProvider(filepath.Join(t.TempDir(), "missing.csv"), "USD")
+
+	// Act
+	_, err := provider.Quote(context.Background(), "BTC", "crypto")
+
+	// Assert
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, ErrNoQuote)
+}
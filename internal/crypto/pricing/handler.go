@@ -0,0 +1,113 @@
+package pricing
+
+import (
+	"errors"
+	"go-boilerplate/internal/dto"
+	"go-boilerplate/internal/infra/auth"
+	"go-boilerplate/pkg/response"
+	"time"
+
+	"github.com/labstack/echo/v5"
+)
+
+type Handler struct {
+	usecase Usecase
+}
+
+func NewHandler(
+	g *echo.Group,
+	usecase Usecase,
+	bearerMiddleware echo.MiddlewareFunc,
+) {
+	handler := &Handler{
+		usecase: usecase,
+	}
+
+	v1 := g.Group("/v1")
+	prices := v1.Group("/prices", bearerMiddleware)
+
+	// Market prices are not anyone's data, so reading them only takes the scope every
+	// role has. Recording them changes how every holding is valued and is left to admins.
+	read := auth.RequirePermission(auth.PermPortfoliosRead)
+	write := auth.RequirePermission(auth.PermPricesWrite)
+
+	prices.POST("", handler.IngestPrices, write)
+	prices.GET("", handler.ListPrices, read)
+	prices.GET("/latest", handler.GetPrice, read)
+}
+
+func (h *Handler) IngestPrices(c *echo.Context) error {
+	var req dto.IngestPricesRequest
+
+	if err := c.Bind(&req); err != nil {
+		return response.BadRequest(c, "invalid request payload")
+	}
+
+	if err := c.Validate(req); err != nil {
+		return response.BadRequest(c, err.Error())
+	}
+
+	ingested, err := h.usecase.IngestPrices(c.Request().Context(), req)
+	if err != nil {
+		if errors.Is(err, ErrInvalidPrice) {
+			return response.BadRequest(c, err.Error())
+		}
+		c.Logger().Error("failed to ingest prices", "error", err, "count", len(req.Prices))
+		return response.InternalServerError(c, "An error occurred")
+	}
+
+	return response.Success(c, "prices ingested successfully", dto.IngestPricesResponse{Ingested: ingested})
+}
+
+func (h *Handler) ListPrices(c *echo.Context) error {
+	req := dto.ListPricesRequest{Limit: 500}
+	if err := echo.BindQueryParams(c, &req); err != nil {
+		return response.BadRequest(c, "invalid query parameters")
+	}
+
+	if err := c.Validate(req); err != nil {
+		return response.BadRequest(c, err.Error())
+	}
+
+	if req.To.IsZero() {
+		req.To = time.Now()
+	}
+	if req.From.IsZero() {
+		req.From = req.To.Add(-24 * time.Hour)
+	}
+	if !req.From.Before(req.To) {
+		return response.BadRequest(c, "from must be before to")
+	}
+
+	series := Series{Symbol: req.Symbol, AssetType: req.AssetType, Currency: req.Currency}
+	prices, err := h.usecase.GetPrices(c.Request().Context(), series, req.From, req.To, req.Limit)
+	if err != nil {
+		c.Logger().Error("failed to list prices", "error", err, "symbol", req.Symbol)
+		return response.InternalServerError(c, "An error occurred")
+	}
+
+	return response.Success(c, "success get prices", ToPriceListResponse(prices))
+}
+
+func (h *Handler) GetPrice(c *echo.Context) error {
+	var req dto.GetPriceRequest
+	if err := echo.BindQueryParams(c, &req); err != nil {
+		return response.BadRequest(c, "invalid query parameters")
+	}
+
+	if err := c.Validate(req); err != nil {
+		return response.BadRequest(c, err.Error())
+	}
+
+	series := Series{Symbol: req.Symbol, AssetType: req.AssetType, Currency: req.Currency}
+	price, err := h.usecase.GetPrice(c.Request().Context(), series, req.Before)
+	if err != nil {
+		if errors.Is(err, ErrPriceNotFound) {
+			return response.NotFound(c, "Price not found")
+		}
+		c.Logger().Error("failed to get price", "error", err, "symbol", req.Symbol)
+		return response.InternalServerError(c, "An error occurred")
+	}
+
+	return response.Success(c, "success get price", ToPriceResponse(price))
+}
@@ -0,0 +1,110 @@
+package pricing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+type httpProvider struct {
+	baseURL         string
+	apiKey          string
+	defaultCurrency string
+	client          *http.Client
+}
+
+// NewHTTPProvider returns a PriceProvider that asks a JSON price service for quotes with
+// GET {baseURL}/quotes?symbol=BTC&asset_type=crypto, sending apiKey as a bearer token
+// when set. The service answers 404 when it has no price, or 200 with:
+//
+//	{"symbol": "BTC", "price": 64250.5, "currency": "USD", "timestamp": "2025-03-01T14:30:00Z", "source": "exchange"}
+//
+// currency, timestamp and source are optional and default to defaultCurrency, the time
+// of the response and the service's host.
+func NewHTTPProvider(baseURL, apiKey, defaultCurrency string, client *http.Client) PriceProvider {
+	return &httpProvider{
+		baseURL:         strings.TrimRight(baseURL, "/"),
+		apiKey:          apiKey,
+		defaultCurrency: strings.ToUpper(defaultCurrency),
+		client:          client,
+	}
+}
+
+type httpQuote struct {
+	Symbol    string    `json:"symbol"`
+	Price     float64   `json:"price"`
+	Currency  string    `json:"currency"`
+	Timestamp time.Time `json:"timestamp"`
+	Source    string    `json:"source"`
+}
+
+func (p *httpProvider) Quote(ctx context.Context, symbol, assetType string) (Quote, error) {
+	query := url.Values{}
+	query.Set("symbol", symbol)
+	query.Set("asset_type", assetType)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.baseURL+"/quotes?"+query.Encode(), nil)
+	if err != nil {
+		return Quote{}, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if p.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+p.apiKey)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return Quote{}, fmt.Errorf("failed to fetch quote: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return Quote{}, fmt.Errorf("failed to fetch quote: %w", err)
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return Quote{}, fmt.Errorf("%w: %s %s", ErrNoQuote, assetType, symbol)
+	default:
+		return Quote{}, fmt.Errorf("failed to fetch quote: unexpected status %d: %s",
+			resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var payload httpQuote
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return Quote{}, fmt.Errorf("failed to decode quote: %w", err)
+	}
+	if payload.Symbol != "" && !strings.EqualFold(payload.Symbol, symbol) {
+		return Quote{}, fmt.Errorf("quote is for %s, not %s", payload.Symbol, symbol)
+	}
+	if payload.Price <= 0 {
+		return Quote{}, errors.New("quote has no positive price")
+	}
+
+	quote := Quote{
+		Symbol:    strings.ToUpper(symbol),
+		AssetType: assetType,
+		Price:     payload.Price,
+		Currency:  strings.ToUpper(payload.Currency),
+		Timestamp: payload.Timestamp,
+		Source:    payload.Source,
+	}
+	if quote.Currency == "" {
+		quote.Currency = p.defaultCurrency
+	}
+	if quote.Timestamp.IsZero() {
+		quote.Timestamp = time.Now()
+	}
+	if quote.Source == "" {
+		quote.Source = req.URL.Host
+	}
+	return quote, nil
+}
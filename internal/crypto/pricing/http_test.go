@@ -0,0 +1,134 @@
+package pricing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHTTPProvider_Quote(t *testing.T) {
+	quotedAt := time.Date(2025, 3, 1, 14, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		want    Quote
+		wantErr error
+		anyErr  bool
+	}{
+		{
+			name:   "full quote",
+			status: http.StatusOK,
+			body:   `{"symbol": "BTC", "price": 64250.5, "currency": "usd", "timestamp": "2025-03-01T14:30:00Z", "source": "exchange"}`,
+			want:   Quote{Symbol: "BTC", AssetType: "crypto", Price: 64250.5, Currency: "USD", Timestamp: quotedAt, Source: "exchange"},
+		},
+		{
+			name:   "defaults for optional fields",
+			status: http.StatusOK,
+			body:   `{"price": 64250.5}`,
+			want:   Quote{Symbol: "BTC", AssetType: "crypto", Price: 64250.5, Currency: "EUR"},
+		},
+		{
+			name:    "unknown symbol",
+			status:  http.StatusNotFound,
+			body:    `{"error": "not found"}`,
+			wantErr: ErrNoQuote,
+		},
+		{
+			name:   "server error",
+			status: http.StatusBadGateway,
+			body:   "upstream unavailable",
+			anyErr: true,
+		},
+		{
+			name:   "malformed body",
+			status: http.StatusOK,
+			body:   `{"price": "soon"`,
+			anyErr: true,
+		},
+		{
+			name:   "quote for another symbol",
+			status: http.StatusOK,
+			body:   `{"symbol": "ETH", "price": 3000}`,
+			anyErr: true,
+		},
+		{
+			name:   "no price",
+			status: http.StatusOK,
+			body:   `{"symbol": "BTC"}`,
+			anyErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			var gotQuery url.Values
+			var gotAuth string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/v1/quotes" {
+					http.NotFound(w, r)
+					return
+				}
+				gotQuery = r.URL.Query()
+				gotAuth = r.Header.Get("Authorization")
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(tt.status)
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			t.Cleanup(server.Close)
+
+			provider := NewHTTPProvider(server.URL+"/v1/", "secret-key", "eur", server.Client())
+			before := time.Now()
+
+			// Act
+			quote, err := provider.Quote(context.Background(), "BTC", "crypto")
+
+			// Assert
+			assert.Equal(t, "BTC", gotQuery.Get("symbol"))
+			assert.Equal(t, "crypto", gotQuery.Get("asset_type"))
+			assert.Equal(t, "Bearer secret-key", gotAuth)
+			switch {
+			case tt.wantErr != nil:
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			case tt.anyErr:
+				assert.Error(t, err)
+				assert.NotErrorIs(t, err, ErrNoQuote)
+				return
+			}
+			assert.NoError(t, err)
+			if tt.want.Timestamp.IsZero() {
+				assert.False(t, quote.Timestamp.Before(before), "the response time stands in for a missing timestamp")
+				tt.want.Timestamp = quote.Timestamp
+			}
+			if tt.want.Source == "" {
+				tt.want.Source = server.Listener.Addr().String()
+			}
+			assert.Equal(t, tt.want, quote)
+		})
+	}
+}
+
+func TestHTTPProvider_ContextCancelled(t *testing.T) {
+	// Arrange
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"price": 1}`))
+	}))
+	t.Cleanup(server.Close)
+	provider := NewHTTPProvider(server.URL, "", "USD", server.Client())
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// Act
+	_, err := provider.Quote(ctx, "BTC", "crypto")
+
+	// Assert
+	assert.ErrorIs(t, err, context.Canceled)
+}
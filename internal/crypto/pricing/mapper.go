@@ -0,0 +1,29 @@
+package pricing
+
+import "go-boilerplate/internal/dto"
+
+func ToPriceResponse(p *Price) dto.PriceResponse {
+	return dto.PriceResponse{
+		ID:        p.ID,
+		Symbol:    p.Symbol,
+		AssetType: p.AssetType,
+		Currency:  p.Currency,
+		Timestamp: p.Timestamp,
+		Open:      p.Open,
+		High:      p.High,
+		Low:       p.Low,
+		Close:     p.Close,
+		Source:    p.Source,
+	}
+}
+
+func ToPriceListResponse(prices []Price) dto.PriceListResponse {
+	responses := make([]dto.PriceResponse, len(prices))
+	for i := range prices {
+		responses[i] = ToPriceResponse(&prices[i])
+	}
+
+	return dto.PriceListResponse{
+		Prices: responses,
+	}
+}
@@ -0,0 +1,8 @@
+package pricing
+
+import "gorm.io/gorm"
+
+// Migrate creates or updates the price history table.
+func Migrate(db *gorm.DB) error {
+	return db.AutoMigrate(&Price{})
+}
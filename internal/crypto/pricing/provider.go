@@ -0,0 +1,26 @@
+package pricing
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrNoQuote is returned when a provider has no price for a symbol.
+var ErrNoQuote = errors.New("no quote for symbol")
+
+// Quote is a symbol's market price in Currency as of Timestamp, and where it came from.
+type Quote struct {
+	Symbol    string
+	AssetType string
+	Price     float64
+	Currency  string
+	Timestamp time.Time
+	Source    string
+}
+
+// PriceProvider looks up the latest market price of a symbol of the given asset type,
+// such as "crypto" or "stock".
+type PriceProvider interface {
+	Quote(ctx context.Context, symbol, assetType string) (Quote, error)
+}
@@ -0,0 +1,109 @@
+package pricing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ingestBatchSize keeps each insert well under Postgres' limit on bind parameters.
+const ingestBatchSize = 1000
+
+type repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) Repository {
+	return &repository{
+		db: db,
+	}
+}
+
+// Ingest upserts the prices in batches. Prices for the same series and time replace one
+// another, the last one winning, as a single statement cannot update a row twice.
+func (r *repository) Ingest(ctx context.Context, prices []Price) (int64, error) {
+	if len(prices) == 0 {
+		return 0, nil
+	}
+
+	type key struct {
+		series Series
+		at     time.Time
+	}
+	index := make(map[key]int, len(prices))
+	rows := make([]Price, 0, len(prices))
+	for _, price := range prices {
+		series := Series{Symbol: price.Symbol, AssetType: price.AssetType, Currency: price.Currency}.normalize()
+		price.Symbol, price.AssetType, price.Currency = series.Symbol, series.AssetType, series.Currency
+		// Postgres keeps microseconds, so times that only differ below that are the same row.
+		price.Timestamp = price.Timestamp.UTC().Truncate(time.Microsecond)
+
+		k := key{series, price.Timestamp}
+		if i, seen := index[k]; seen {
+			rows[i] = price
+			continue
+		}
+		index[k] = len(rows)
+		rows = append(rows, price)
+	}
+
+	result := r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "asset_type"}, {Name: "symbol"}, {Name: "currency"}, {Name: "timestamp"}},
+			DoUpdates: clause.AssignmentColumns([]string{
+				"open", "high", "low", "close", "source", "updated_at",
+			}),
+		}).
+		CreateInBatches(rows, ingestBatchSize)
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to ingest prices: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+func (r *repository) Range(ctx context.Context, series Series, from, to time.Time, limit int) ([]Price, error) {
+	var prices []Price
+
+	err := r.inSeries(ctx, series).
+		Where("timestamp >= ? AND timestamp < ?", from, to).
+		Order("timestamp ASC").
+		Limit(limit).
+		Find(&prices).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list prices: %w", err)
+	}
+
+	return prices, nil
+}
+
+func (r *repository) Latest(ctx context.Context, series Series) (*Price, error) {
+	return r.last(r.inSeries(ctx, series))
+}
+
+func (r *repository) AsOf(ctx context.Context, series Series, at time.Time) (*Price, error) {
+	return r.last(r.inSeries(ctx, series).Where("timestamp < ?", at))
+}
+
+func (r *repository) inSeries(ctx context.Context, series Series) *gorm.DB {
+	series = series.normalize()
+	return r.db.WithContext(ctx).
+		Where("asset_type = ? AND symbol = ? AND currency = ?", series.AssetType, series.Symbol, series.Currency)
+}
+
+func (r *repository) last(query *gorm.DB) (*Price, error) {
+	var price Price
+
+	err := query.Order("timestamp DESC").First(&price).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrPriceNotFound
+		}
+		return nil, fmt.Errorf("failed to get price: %w", err)
+	}
+
+	return &price, nil
+}
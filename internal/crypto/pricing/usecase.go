@@ -0,0 +1,95 @@
+package pricing
+
+import (
+	"context"
+	"fmt"
+	"go-boilerplate/internal/dto"
+	"time"
+)
+
+// maxClockSkew is how far in the future an ingested price may be, to allow for clocks
+// that run a little fast. Later prices would pass for the current one.
+const maxClockSkew = 5 * time.Minute
+
+// defaultSource is recorded for ingested prices that do not say where they came from.
+const defaultSource = "import"
+
+type usecase struct {
+	repo Repository
+}
+
+func NewUsecase(repo Repository) Usecase {
+	return &usecase{
+		repo: repo,
+	}
+}
+
+// IngestPrices checks the whole batch before recording any of it, so a rejected batch
+// can be fixed and sent again as it is.
+func (u *usecase) IngestPrices(ctx context.Context, req dto.IngestPricesRequest) (int64, error) {
+	now := time.Now()
+	prices := make([]Price, len(req.Prices))
+	for i, bar := range req.Prices {
+		price, err := newPrice(bar, now)
+		if err != nil {
+			return 0, fmt.Errorf("prices[%d]: %w", i, err)
+		}
+		prices[i] = price
+	}
+
+	return u.repo.Ingest(ctx, prices)
+}
+
+func (u *usecase) GetPrices(ctx context.Context, series Series, from, to time.Time, limit int) ([]Price, error) {
+	return u.repo.Range(ctx, series, from, to, limit)
+}
+
+func (u *usecase) GetPrice(ctx context.Context, series Series, before *time.Time) (*Price, error) {
+	if before != nil {
+		return u.repo.AsOf(ctx, series, *before)
+	}
+	return u.repo.Latest(ctx, series)
+}
+
+// newPrice builds a price from an ingested bar, checking that its open and close lie
+// between its low and high where those are given.
+func newPrice(bar dto.PriceBar, now time.Time) (Price, error) {
+	if bar.Timestamp.After(now.Add(maxClockSkew)) {
+		return Price{}, fmt.Errorf("%w: timestamp is in the future", ErrInvalidPrice)
+	}
+
+	if bar.Low != nil && bar.High != nil && *bar.Low > *bar.High {
+		return Price{}, fmt.Errorf("%w: low is above high", ErrInvalidPrice)
+	}
+	bounded := []struct {
+		name  string
+		value *float64
+	}{{"open", bar.Open}, {"close", &bar.Close}}
+	for _, b := range bounded {
+		if b.value == nil {
+			continue
+		}
+		if bar.Low != nil && *b.value < *bar.Low {
+			return Price{}, fmt.Errorf("%w: %s is below low", ErrInvalidPrice, b.name)
+		}
+		if bar.High != nil && *b.value > *bar.High {
+			return Price{}, fmt.Errorf("%w: %s is above high", ErrInvalidPrice, b.name)
+		}
+	}
+
+	price := Price{
+		Symbol:    bar.Symbol,
+		AssetType: bar.AssetType,
+		Currency:  bar.Currency,
+		Timestamp: bar.Timestamp,
+		Open:      bar.Open,
+		High:      bar.High,
+		Low:       bar.Low,
+		Close:     bar.Close,
+		Source:    bar.Source,
+	}
+	if price.Source == "" {
+		price.Source = defaultSource
+	}
+	return price, nil
+}
@@ -0,0 +1,169 @@
+package pricing
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go-boilerplate/internal/dto"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockRepository is a manual mock of the Repository interface.
+type MockRepository struct {
+	mock.Mock
+}
+
+func (m *MockRepository) Ingest(ctx context.Context, prices []Price) (int64, error) {
+	args := m.Called(ctx, prices)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockRepository) Range(ctx context.Context, series Series, from, to time.Time, limit int) ([]Price, error) {
+	args := m.Called(ctx, series, from, to, limit)
+	return args.Get(0).([]Price), args.Error(1)
+}
+
+func (m *MockRepository) Latest(ctx context.Context, series Series) (*Price, error) {
+	args := m.Called(ctx, series)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*Price), args.Error(1)
+}
+
+func (m *MockRepository) AsOf(ctx context.Context, series Series, at time.Time) (*Price, error) {
+	args := m.Called(ctx, series, at)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*Price), args.Error(1)
+}
+
+func ptr(v float64) *float64 {
+	return &v
+}
+
+func TestIngestPrices_Validation(t *testing.T) {
+	at := time.Date(2025, 3, 3, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		bar     dto.PriceBar
+		wantErr string
+	}{
+		{
+			name: "full bar",
+			bar:  dto.PriceBar{Timestamp: at, Open: ptr(100), High: ptr(110), Low: ptr(95), Close: 105},
+		},
+		{
+			name: "close only",
+			bar:  dto.PriceBar{Timestamp: at, Close: 105},
+		},
+		{
+			name: "close equal to the high",
+			bar:  dto.PriceBar{Timestamp: at, High: ptr(105), Close: 105},
+		},
+		{
+			name:    "low above high",
+			bar:     dto.PriceBar{Timestamp: at, High: ptr(100), Low: ptr(101), Close: 100},
+			wantErr: "prices[1]: invalid price: low is above high",
+		},
+		{
+			name:    "close above high",
+			bar:     dto.PriceBar{Timestamp: at, High: ptr(110), Close: 111},
+			wantErr: "prices[1]: invalid price: close is above high",
+		},
+		{
+			name:    "open below low",
+			bar:     dto.PriceBar{Timestamp: at, Open: ptr(90), Low: ptr(95), Close: 100},
+			wantErr: "prices[1]: invalid price: open is below low",
+		},
+		{
+			name:    "timestamp in the future",
+			bar:     dto.PriceBar{Timestamp: time.Now().Add(time.Hour), Close: 100},
+			wantErr: "prices[1]: invalid price: timestamp is in the future",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			mockRepo := new(MockRepository)
+			u := NewUsecase(mockRepo)
+			valid := dto.PriceBar{Symbol: "BTC", AssetType: "crypto", Currency: "USD", Timestamp: at, Close: 100}
+			tt.bar.Symbol, tt.bar.AssetType, tt.bar.Currency = "ETH", "crypto", "USD"
+			req := dto.IngestPricesRequest{Prices: []dto.PriceBar{valid, tt.bar}}
+			if tt.wantErr == "" {
+				mockRepo.On("Ingest", mock.Anything, mock.AnythingOfType("[]pricing.Price")).Return(int64(2), nil)
+			}
+
+			// Act
+			ingested, err := u.IngestPrices(context.Background(), req)
+
+			// Assert
+			if tt.wantErr != "" {
+				assert.ErrorIs(t, err, ErrInvalidPrice)
+				assert.EqualError(t, err, tt.wantErr)
+				mockRepo.AssertNotCalled(t, "Ingest", mock.Anything, mock.Anything)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, int64(2), ingested)
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestIngestPrices_DefaultSource(t *testing.T) {
+	// Arrange
+	mockRepo := new(MockRepository)
+	u := NewUsecase(mockRepo)
+	at := time.Date(2025, 3, 3, 0, 0, 0, 0, time.UTC)
+	req := dto.IngestPricesRequest{Prices: []dto.PriceBar{
+		{Symbol: "AAPL", AssetType: "stock", Currency: "USD", Timestamp: at, Close: 190},
+		{Symbol: "AAPL", AssetType: "stock", Currency: "USD", Timestamp: at.Add(24 * time.Hour), Close: 192, Source: "exchange"},
+	}}
+	var recorded []Price
+	mockRepo.On("Ingest", mock.Anything, mock.AnythingOfType("[]pricing.Price")).
+		Return(int64(2), nil).
+		Run(func(args mock.Arguments) {
+			recorded = args.Get(1).([]Price)
+		})
+
+	// Act
+	_, err := u.IngestPrices(context.Background(), req)
+
+	// Assert
+	assert.NoError(t, err)
+	if assert.Len(t, recorded, 2) {
+		assert.Equal(t, "import", recorded[0].Source)
+		assert.Equal(t, "exchange", recorded[1].Source)
+		assert.Equal(t, 192.0, recorded[1].Close)
+	}
+}
+
+func TestGetPrice(t *testing.T) {
+	// Arrange
+	mockRepo := new(MockRepository)
+	u := NewUsecase(mockRepo)
+	series := Series{Symbol: "BTC", AssetType: "crypto", Currency: "USD"}
+	before := time.Date(2025, 3, 3, 0, 0, 0, 0, time.UTC)
+	latest := &Price{Close: 65000}
+	earlier := &Price{Close: 62000}
+	mockRepo.On("Latest", mock.Anything, series).Return(latest, nil)
+	mockRepo.On("AsOf", mock.Anything, series, before).Return(earlier, nil)
+
+	// Act
+	gotLatest, latestErr := u.GetPrice(context.Background(), series, nil)
+	gotEarlier, earlierErr := u.GetPrice(context.Background(), series, &before)
+
+	// Assert
+	assert.NoError(t, latestErr)
+	assert.Same(t, latest, gotLatest)
+	assert.NoError(t, earlierErr)
+	assert.Same(t, earlier, gotEarlier)
+	mockRepo.AssertExpectations(t)
+}
@@ -1,8 +1,12 @@
 package crypto
 
 import (
+	"time"
+
 	"go-boilerplate/internal/crypto/portfolio"
-	"go-boilerplate/internal/infra/auth"
+	"go-boilerplate/internal/crypto/pricing"
+	"go-boilerplate/internal/infra/audit"
+	"go-boilerplate/internal/infra/scheduler"
 
 	"github.com/labstack/echo/v5"
 	"github.com/samber/do"
@@ -11,15 +15,18 @@ import (
 
 // NewInjector creates and configures a new dependency injection container.
 // Returns the injector instead of storing it globally for better testability.
-func NewInjector(db *gorm.DB) *do.Injector {
+func NewInjector(db *gorm.DB, auditLogger audit.AuditLogger) *do.Injector {
 	injector := do.New()
 
+	do.ProvideValue(injector, auditLogger)
+
 	if db != nil {
 		do.Provide[*gorm.DB](injector, func(i *do.Injector) (*gorm.DB, error) {
 			return db, nil
 		})
 	}
 
+	newPricing(injector)
 	newPortfolio(injector)
 	return injector
 }
@@ -29,15 +36,31 @@ func NewInjector(db *gorm.DB) *do.Injector {
 func NewHTTPHandlers(
 	g *echo.Group,
 	injector *do.Injector,
-	jwtSvc auth.JWTService,
+	bearerMiddleware echo.MiddlewareFunc,
 ) {
-	bearerMiddleware := auth.BearerAuth(jwtSvc)
-
 	portfolio.NewHandler(
 		g,
 		do.MustInvoke[portfolio.Usecase](injector),
 		bearerMiddleware,
 	)
+	pricing.NewHandler(
+		g,
+		do.MustInvoke[pricing.Usecase](injector),
+		bearerMiddleware,
+	)
+}
+
+// RegisterJobs adds the crypto domain's background jobs to the scheduler: refreshing
+// holdings' prices from prices and recording them in the price history.
+func RegisterJobs(
+	sched *scheduler.Scheduler,
+	injector *do.Injector,
+	prices pricing.PriceProvider,
+	interval, jitter time.Duration,
+) {
+	repo := do.MustInvoke[portfolio.Repository](injector)
+	history := do.MustInvoke[pricing.Repository](injector)
+	sched.Register(portfolio.NewPriceRefreshJob(repo, prices, history, interval, jitter))
 }
 
 // newPortfolio registers portfolio-related dependencies in the injector.
@@ -51,6 +74,23 @@ func newPortfolio(injector *do.Injector) {
 	do.Provide[portfolio.Usecase](injector, func(i *do.Injector) (portfolio.Usecase, error) {
 		return portfolio.NewUsecase(
 			do.MustInvoke[portfolio.Repository](i),
+			do.MustInvoke[pricing.Repository](i),
+			do.MustInvoke[audit.AuditLogger](i),
+		), nil
+	})
+}
+
+// newPricing registers price history dependencies in the injector.
+func newPricing(injector *do.Injector) {
+	do.Provide[pricing.Repository](injector, func(i *do.Injector) (pricing.Repository, error) {
+		return pricing.NewRepository(
+			do.MustInvoke[*gorm.DB](i),
+		), nil
+	})
+
+	do.Provide[pricing.Usecase](injector, func(i *do.Injector) (pricing.Usecase, error) {
+		return pricing.NewUsecase(
+			do.MustInvoke[pricing.Repository](i),
 		), nil
 	})
 }
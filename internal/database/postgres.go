@@ -19,7 +19,7 @@ func NewPostgres(cfg *config.Config) (*gorm.DB, error) {
 		cfg.Database.Password,
 	)
 
-	db, err := gorm.Open(postgres.Open(dsn), nil)
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{TranslateError: true})
 	if err != nil {
 		return nil, err
 	}
@@ -43,3 +43,24 @@ func Close(db *gorm.DB) error {
 	}
 	return sqlDB.Close()
 }
+
+// EnsureForeignKey adds an ON DELETE CASCADE foreign key from table.column to refTable.refColumn
+// unless a constraint with the same name already exists. The constraint is created NOT VALID,
+// so it is enforced for new rows without failing on legacy rows that predate it.
+func EnsureForeignKey(db *gorm.DB, table, column, refTable, refColumn string) error {
+	name := fmt.Sprintf("fk_%s_%s", table, column)
+
+	var count int64
+	err := db.Raw("SELECT COUNT(*) FROM pg_constraint WHERE conname = ?", name).Scan(&count).Error
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+
+	return db.Exec(fmt.Sprintf(
+		"ALTER TABLE %q ADD CONSTRAINT %q FOREIGN KEY (%q) REFERENCES %q (%q) ON DELETE CASCADE NOT VALID",
+		table, name, column, refTable, refColumn,
+	)).Error
+}
@@ -11,12 +11,17 @@ type CreatePortfolioRequest struct {
 	Name        string  `json:"name" validate:"required,min=1,max=100"`
 	Description *string `json:"description,omitempty" validate:"omitempty,max=500"`
 	Currency    string  `json:"currency,omitempty" validate:"omitempty,len=3"`
+	// CostBasisMethod defaults to average.
+	CostBasisMethod string `json:"cost_basis_method,omitempty" validate:"omitempty,oneof=fifo lifo hifo average specific"`
 }
 
+// UpdatePortfolioRequest changes the given fields. A new cost basis method applies to
+// disposals posted from then on.
 type UpdatePortfolioRequest struct {
-	Name        *string `json:"name,omitempty" validate:"omitempty,min=1,max=100"`
-	Description *string `json:"description,omitempty" validate:"omitempty,max=500"`
-	IsActive    *bool   `json:"is_active,omitempty"`
+	Name            *string `json:"name,omitempty" validate:"omitempty,min=1,max=100"`
+	Description     *string `json:"description,omitempty" validate:"omitempty,max=500"`
+	IsActive        *bool   `json:"is_active,omitempty"`
+	CostBasisMethod *string `json:"cost_basis_method,omitempty" validate:"omitempty,oneof=fifo lifo hifo average specific"`
 }
 
 type AddHoldingRequest struct {
@@ -26,41 +31,174 @@ type AddHoldingRequest struct {
 	AvgCost   float64 `json:"avg_cost" validate:"required,gt=0"`
 }
 
+// CreateTransactionRequest posts a ledger entry. Currency defaults to the portfolio's
+// and ExecutedAt to now. Lots names the lots a sale or transfer out consumes, which
+// overrides the portfolio's cost basis method.
+type CreateTransactionRequest struct {
+	Type       string         `json:"type" validate:"required,oneof=buy sell transfer_in transfer_out fee deposit withdrawal dividend staking_reward"`
+	Symbol     string         `json:"symbol,omitempty" validate:"omitempty,min=1,max=10"`
+	AssetType  string         `json:"asset_type,omitempty" validate:"omitempty,oneof=stock crypto bond etf"`
+	Quantity   float64        `json:"quantity" validate:"gte=0"`
+	Price      float64        `json:"price" validate:"gte=0"`
+	Fee        float64        `json:"fee" validate:"gte=0"`
+	Currency   string         `json:"currency,omitempty" validate:"omitempty,len=3"`
+	ExecutedAt *time.Time     `json:"executed_at,omitempty"`
+	Notes      *string        `json:"notes,omitempty" validate:"omitempty,max=500"`
+	Lots       []LotSelection `json:"lots,omitempty" validate:"omitempty,max=100,dive"`
+}
+
+// LotSelection is part of a lot, identified by the transaction that acquired it.
+type LotSelection struct {
+	LotID    uuid.UUID `json:"lot_id" validate:"required"`
+	Quantity float64   `json:"quantity" validate:"gt=0"`
+}
+
+type ListTransactionsRequest struct {
+	Page          int    `query:"page" validate:"min=1"`
+	PageSize      int    `query:"page_size" validate:"min=1,max=100"`
+	Symbol        string `query:"symbol" validate:"omitempty,max=10"`
+	Type          string `query:"type" validate:"omitempty,oneof=buy sell transfer_in transfer_out fee deposit withdrawal dividend staking_reward"`
+	IncludeVoided bool   `query:"include_voided"`
+}
+
+type VoidTransactionRequest struct {
+	Reason string `json:"reason" validate:"max=500"`
+}
+
 // Portfolio Response DTOs
 type PortfolioResponse struct {
-	ID          uuid.UUID         `json:"id"`
-	UserID      uuid.UUID         `json:"user_id"`
-	Name        string            `json:"name"`
-	Description *string           `json:"description,omitempty"`
-	TotalValue  float64           `json:"total_value"`
-	Currency    string            `json:"currency"`
-	IsActive    bool              `json:"is_active"`
-	CreatedAt   time.Time         `json:"created_at"`
-	UpdatedAt   time.Time         `json:"updated_at"`
-	Holdings    []HoldingResponse `json:"holdings,omitempty"`
+	ID              uuid.UUID         `json:"id"`
+	UserID          uuid.UUID         `json:"user_id"`
+	Name            string            `json:"name"`
+	Description     *string           `json:"description,omitempty"`
+	TotalValue      float64           `json:"total_value"`
+	Currency        string            `json:"currency"`
+	CostBasisMethod string            `json:"cost_basis_method"`
+	IsActive        bool              `json:"is_active"`
+	CreatedAt       time.Time         `json:"created_at"`
+	UpdatedAt       time.Time         `json:"updated_at"`
+	Holdings        []HoldingResponse `json:"holdings,omitempty"`
 }
 
 type HoldingResponse struct {
-	ID           uuid.UUID `json:"id"`
-	PortfolioID  uuid.UUID `json:"portfolio_id"`
-	Symbol       string    `json:"symbol"`
-	AssetType    string    `json:"asset_type"`
-	Quantity     float64   `json:"quantity"`
-	AvgCost      float64   `json:"avg_cost"`
-	CurrentPrice float64   `json:"current_price"`
-	MarketValue  float64   `json:"market_value"`
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
+	ID             uuid.UUID  `json:"id"`
+	PortfolioID    uuid.UUID  `json:"portfolio_id"`
+	Symbol         string     `json:"symbol"`
+	AssetType      string     `json:"asset_type"`
+	Quantity       float64    `json:"quantity"`
+	AvgCost        float64    `json:"avg_cost"`
+	CurrentPrice   float64    `json:"current_price"`
+	MarketValue    float64    `json:"market_value"`
+	PriceUpdatedAt *time.Time `json:"price_updated_at,omitempty"`
+	PriceSource    string     `json:"price_source,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+type TransactionResponse struct {
+	ID              uuid.UUID      `json:"id"`
+	PortfolioID     uuid.UUID      `json:"portfolio_id"`
+	Type            string         `json:"type"`
+	Symbol          string         `json:"symbol,omitempty"`
+	AssetType       string         `json:"asset_type,omitempty"`
+	Quantity        float64        `json:"quantity"`
+	Price           float64        `json:"price"`
+	Fee             float64        `json:"fee"`
+	Currency        string         `json:"currency"`
+	ExecutedAt      time.Time      `json:"executed_at"`
+	Notes           *string        `json:"notes,omitempty"`
+	CostBasisMethod string         `json:"cost_basis_method,omitempty"`
+	Lots            []LotSelection `json:"lots,omitempty"`
+	VoidedAt        *time.Time     `json:"voided_at,omitempty"`
+	VoidReason      *string        `json:"void_reason,omitempty"`
+	CreatedAt       time.Time      `json:"created_at"`
+	// Disposals and RealizedGains are only reported when a sale or transfer out is recorded.
+	Disposals     []DisposalResponse     `json:"disposals,omitempty"`
+	RealizedGains *RealizedGainsResponse `json:"realized_gains,omitempty"`
+}
+
+type LotResponse struct {
+	LotID      uuid.UUID `json:"lot_id"`
+	AcquiredAt time.Time `json:"acquired_at"`
+	Quantity   float64   `json:"quantity"`
+	UnitCost   float64   `json:"unit_cost"`
+	CostBasis  float64   `json:"cost_basis"`
+	// LongTermAt is when the lot starts to qualify for long-term treatment.
+	LongTermAt time.Time `json:"long_term_at"`
+}
+
+type DisposalResponse struct {
+	TransactionID uuid.UUID `json:"transaction_id"`
+	Type          string    `json:"type"`
+	LotID         uuid.UUID `json:"lot_id"`
+	AcquiredAt    time.Time `json:"acquired_at"`
+	DisposedAt    time.Time `json:"disposed_at"`
+	Quantity      float64   `json:"quantity"`
+	CostBasis     float64   `json:"cost_basis"`
+	Proceeds      float64   `json:"proceeds"`
+	Gain          float64   `json:"gain"`
+	Term          string    `json:"term,omitempty"`
+}
+
+type RealizedGainsResponse struct {
+	ShortTerm float64 `json:"short_term"`
+	LongTerm  float64 `json:"long_term"`
+	Total     float64 `json:"total"`
+}
+
+type HoldingLotsResponse struct {
+	Holding         HoldingResponse       `json:"holding"`
+	CostBasisMethod string                `json:"cost_basis_method"`
+	Lots            []LotResponse         `json:"lots"`
+	Disposals       []DisposalResponse    `json:"disposals"`
+	RealizedGains   RealizedGainsResponse `json:"realized_gains"`
+}
+
+type TransactionListResponse struct {
+	Transactions []TransactionResponse `json:"transactions"`
+	Pagination   PaginationResponse    `json:"pagination"`
 }
 
 type PortfolioSummaryResponse struct {
 	PortfolioResponse
-	TotalReturn    float64 `json:"total_return"`
-	TotalReturnPct float64 `json:"total_return_pct"`
-	DayChange      float64 `json:"day_change"`
-	DayChangePct   float64 `json:"day_change_pct"`
-	TotalInvested  float64 `json:"total_invested"`
-	HoldingsCount  int     `json:"holdings_count"`
+	TotalReturn    float64                        `json:"total_return"`
+	TotalReturnPct float64                        `json:"total_return_pct"`
+	DayChange      float64                        `json:"day_change"`
+	DayChangePct   float64                        `json:"day_change_pct"`
+	TotalInvested  float64                        `json:"total_invested"`
+	HoldingsCount  int                            `json:"holdings_count"`
+	RealizedPnL    float64                        `json:"realized_pnl"`
+	UnrealizedPnL  float64                        `json:"unrealized_pnl"`
+	FeesPaid       float64                        `json:"fees_paid"`
+	Income         float64                        `json:"income"`
+	ByHolding      []HoldingPerformanceResponse   `json:"by_holding"`
+	ByAssetType    []AssetTypePerformanceResponse `json:"by_asset_type"`
+}
+
+type PerformanceResponse struct {
+	RealizedPnL   float64 `json:"realized_pnl"`
+	UnrealizedPnL float64 `json:"unrealized_pnl"`
+	FeesPaid      float64 `json:"fees_paid"`
+	Income        float64 `json:"income"`
+	TotalReturn   float64 `json:"total_return"`
+}
+
+type HoldingPerformanceResponse struct {
+	Symbol       string  `json:"symbol"`
+	AssetType    string  `json:"asset_type"`
+	Quantity     float64 `json:"quantity"`
+	CostBasis    float64 `json:"cost_basis"`
+	MarketValue  float64 `json:"market_value"`
+	DayChange    float64 `json:"day_change"`
+	DayChangePct float64 `json:"day_change_pct"`
+	PerformanceResponse
+}
+
+type AssetTypePerformanceResponse struct {
+	AssetType   string  `json:"asset_type"`
+	CostBasis   float64 `json:"cost_basis"`
+	MarketValue float64 `json:"market_value"`
+	PerformanceResponse
 }
 
 type PortfolioListResponse struct {
@@ -0,0 +1,71 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Price Request DTOs
+
+// IngestPricesRequest records a batch of prices, replacing those already recorded for
+// the same symbol, currency and time.
+type IngestPricesRequest struct {
+	Prices []PriceBar `json:"prices" validate:"required,min=1,max=5000,dive"`
+}
+
+// PriceBar is a symbol's price for the period starting at Timestamp. Open, high and low
+// are optional, so single quotes can be recorded with only a close. Source defaults to
+// "import".
+type PriceBar struct {
+	Symbol    string    `json:"symbol" validate:"required,min=1,max=10"`
+	AssetType string    `json:"asset_type" validate:"required,oneof=stock crypto bond etf"`
+	Currency  string    `json:"currency" validate:"required,len=3"`
+	Timestamp time.Time `json:"timestamp" validate:"required"`
+	Open      *float64  `json:"open,omitempty" validate:"omitempty,gt=0"`
+	High      *float64  `json:"high,omitempty" validate:"omitempty,gt=0"`
+	Low       *float64  `json:"low,omitempty" validate:"omitempty,gt=0"`
+	Close     float64   `json:"close" validate:"required,gt=0"`
+	Source    string    `json:"source,omitempty" validate:"max=100"`
+}
+
+// ListPricesRequest asks for the prices of a symbol from From until To, which default
+// to the last 24 hours.
+type ListPricesRequest struct {
+	Symbol    string    `query:"symbol" validate:"required,min=1,max=10"`
+	AssetType string    `query:"asset_type" validate:"required,oneof=stock crypto bond etf"`
+	Currency  string    `query:"currency" validate:"required,len=3"`
+	From      time.Time `query:"from"`
+	To        time.Time `query:"to"`
+	Limit     int       `query:"limit" validate:"min=1,max=5000"`
+}
+
+// GetPriceRequest asks for the latest price of a symbol, or the last one before Before.
+type GetPriceRequest struct {
+	Symbol    string     `query:"symbol" validate:"required,min=1,max=10"`
+	AssetType string     `query:"asset_type" validate:"required,oneof=stock crypto bond etf"`
+	Currency  string     `query:"currency" validate:"required,len=3"`
+	Before    *time.Time `query:"before"`
+}
+
+// Price Response DTOs
+type IngestPricesResponse struct {
+	Ingested int64 `json:"ingested"`
+}
+
+type PriceResponse struct {
+	ID        uuid.UUID `json:"id"`
+	Symbol    string    `json:"symbol"`
+	AssetType string    `json:"asset_type"`
+	Currency  string    `json:"currency"`
+	Timestamp time.Time `json:"timestamp"`
+	Open      *float64  `json:"open,omitempty"`
+	High      *float64  `json:"high,omitempty"`
+	Low       *float64  `json:"low,omitempty"`
+	Close     float64   `json:"close"`
+	Source    string    `json:"source"`
+}
+
+type PriceListResponse struct {
+	Prices []PriceResponse `json:"prices"`
+}
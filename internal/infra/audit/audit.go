@@ -0,0 +1,86 @@
+package audit
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Outcomes of an audited action.
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+	// OutcomeDenied marks an authenticated request refused for lack of rights.
+	OutcomeDenied = "denied"
+)
+
+// Event records who did what to which resource, whether it worked, and where the request
+// came from. ActorID is empty when the caller could not be identified, for example a
+// failed login for an unknown username. ImpersonatorID is the admin behind the actor when
+// they were impersonating them; Metadata holds action specific details.
+type Event struct {
+	ID             uuid.UUID      `gorm:"type:uuid;primaryKey" json:"id"`
+	OccurredAt     time.Time      `gorm:"index;not null" json:"occurred_at"`
+	ActorID        string         `gorm:"type:varchar(64);index" json:"actor_id,omitempty"`
+	ImpersonatorID string         `gorm:"type:varchar(64);index" json:"impersonator_id,omitempty"`
+	Action         string         `gorm:"type:varchar(64);index;not null" json:"action"`
+	TargetType     string         `gorm:"type:varchar(32)" json:"target_type,omitempty"`
+	TargetID       string         `gorm:"type:varchar(128);index" json:"target_id,omitempty"`
+	Outcome        string         `gorm:"type:varchar(16);index;not null" json:"outcome"`
+	IPAddress      string         `gorm:"type:varchar(45)" json:"ip_address,omitempty"`
+	UserAgent      string         `gorm:"type:varchar(512)" json:"user_agent,omitempty"`
+	RequestID      string         `gorm:"type:varchar(64)" json:"request_id,omitempty"`
+	Metadata       map[string]any `gorm:"type:text;serializer:json" json:"metadata,omitempty"`
+}
+
+func (Event) TableName() string {
+	return "audit_events"
+}
+
+// AuditLogger appends events to the audit trail. Logging never fails the audited
+// operation; implementations report their own errors.
+type AuditLogger interface {
+	Log(ctx context.Context, event Event)
+}
+
+// NopLogger discards every event.
+type NopLogger struct{}
+
+func (NopLogger) Log(context.Context, Event) {}
+
+// maxUserAgentLength matches the width of the user_agent column.
+const maxUserAgentLength = 512
+
+// complete fills in the id, time, actor and request details the caller left out.
+func complete(ctx context.Context, event Event) Event {
+	if event.ID == uuid.Nil {
+		event.ID = uuid.New()
+	}
+	if event.OccurredAt.IsZero() {
+		event.OccurredAt = time.Now()
+	}
+
+	if event.ActorID == "" {
+		event.ActorID = ActorIDFromContext(ctx)
+	}
+	if event.ImpersonatorID == "" {
+		event.ImpersonatorID = ImpersonatorIDFromContext(ctx)
+	}
+
+	info := RequestInfoFromContext(ctx)
+	if event.IPAddress == "" {
+		event.IPAddress = info.IPAddress
+	}
+	if event.UserAgent == "" {
+		event.UserAgent = info.UserAgent
+	}
+	if event.RequestID == "" {
+		event.RequestID = info.RequestID
+	}
+	if len(event.UserAgent) > maxUserAgentLength {
+		event.UserAgent = strings.ToValidUTF8(event.UserAgent[:maxUserAgentLength], "")
+	}
+	return event
+}
@@ -0,0 +1,68 @@
+package audit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v5"
+	"github.com/labstack/echo/v5/middleware"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestInfoMiddleware(t *testing.T) {
+	// Arrange
+	e := echo.New()
+	logger := NewMemoryLogger()
+	e.Use(middleware.RequestID())
+	e.Use(RequestInfoMiddleware())
+	e.GET("/", func(c *echo.Context) error {
+		logger.Log(c.Request().Context(), Event{Action: "test.action", Outcome: OutcomeSuccess})
+		return c.NoContent(http.StatusNoContent)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("User-Agent", "curl/8.0")
+	req.RemoteAddr = "203.0.113.7:4321"
+	rec := httptest.NewRecorder()
+
+	// Act
+	e.ServeHTTP(rec, req)
+
+	// Assert
+	require.Equal(t, http.StatusNoContent, rec.Code)
+	events := logger.Events()
+	require.Len(t, events, 1)
+	assert.Equal(t, "203.0.113.7", events[0].IPAddress)
+	assert.Equal(t, "curl/8.0", events[0].UserAgent)
+	assert.NotEmpty(t, events[0].RequestID)
+	assert.Equal(t, rec.Header().Get(echo.HeaderXRequestID), events[0].RequestID)
+}
+
+func TestComplete(t *testing.T) {
+	// Arrange
+	ctx := WithRequestInfo(context.Background(), RequestInfo{
+		IPAddress: "203.0.113.7",
+		UserAgent: strings.Repeat("a", 600),
+		RequestID: "req-1",
+	})
+	ctx = WithActorID(ctx, "user-1")
+
+	// Act
+	event := complete(ctx, Event{Action: "test.action", Outcome: OutcomeSuccess})
+	explicit := complete(ctx, Event{ActorID: "user-2", IPAddress: "198.51.100.1"})
+
+	// Assert
+	assert.NotEqual(t, uuid.Nil, event.ID)
+	assert.False(t, event.OccurredAt.IsZero())
+	assert.Equal(t, "user-1", event.ActorID)
+	assert.Equal(t, "203.0.113.7", event.IPAddress)
+	assert.Equal(t, "req-1", event.RequestID)
+	assert.Len(t, event.UserAgent, maxUserAgentLength)
+	assert.Equal(t, "user-2", explicit.ActorID)
+	assert.Equal(t, "198.51.100.1", explicit.IPAddress)
+}
@@ -0,0 +1,94 @@
+package audit
+
+import (
+	"time"
+
+	"go-boilerplate/internal/dto"
+	"go-boilerplate/pkg/response"
+
+	"github.com/labstack/echo/v5"
+)
+
+// defaultPageSize applies when a query does not ask for a page size.
+const defaultPageSize = 50
+
+type Handler struct {
+	store Store
+}
+
+func NewHandler(store Store) *Handler {
+	return &Handler{store: store}
+}
+
+type ListEventsRequest struct {
+	Page           int    `query:"page" validate:"min=1"`
+	PageSize       int    `query:"page_size" validate:"min=1,max=100"`
+	ActorID        string `query:"actor_id"`
+	ImpersonatorID string `query:"impersonator_id"`
+	Action         string `query:"action"`
+	TargetType     string `query:"target_type"`
+	TargetID       string `query:"target_id"`
+	Outcome        string `query:"outcome" validate:"omitempty,oneof=success failure denied"`
+	// From and To bound occurred_at as RFC 3339 timestamps; To is exclusive.
+	From string `query:"from" validate:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`
+	To   string `query:"to" validate:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`
+}
+
+type ListEventsResponse struct {
+	Events     []Event                `json:"events"`
+	Pagination dto.PaginationResponse `json:"pagination"`
+}
+
+// List returns audit events matching the query parameters, newest first.
+func (h *Handler) List(c *echo.Context) error {
+	req := ListEventsRequest{Page: 1, PageSize: defaultPageSize}
+	if err := echo.BindQueryParams(c, &req); err != nil {
+		return response.BadRequest(c, "invalid query parameters")
+	}
+
+	if err := c.Validate(&req); err != nil {
+		return response.BadRequest(c, err.Error())
+	}
+
+	// Already validated as RFC 3339
+	from, _ := parseTime(req.From)
+	to, _ := parseTime(req.To)
+
+	events, total, err := h.store.Query(c.Request().Context(), Filter{
+		ActorID:        req.ActorID,
+		ImpersonatorID: req.ImpersonatorID,
+		Action:         req.Action,
+		TargetType:     req.TargetType,
+		TargetID:       req.TargetID,
+		Outcome:        req.Outcome,
+		From:           from,
+		To:             to,
+		Limit:          req.PageSize,
+		Offset:         (req.Page - 1) * req.PageSize,
+	})
+	if err != nil {
+		c.Logger().Error("failed to query audit events", "error", err)
+		return response.InternalServerError(c, "failed to query audit events")
+	}
+
+	if events == nil {
+		events = []Event{}
+	}
+
+	return response.Success(c, "success get audit events", ListEventsResponse{
+		Events: events,
+		Pagination: dto.PaginationResponse{
+			Page:       req.Page,
+			PageSize:   req.PageSize,
+			Total:      total,
+			TotalPages: int((total + int64(req.PageSize) - 1) / int64(req.PageSize)),
+		},
+	})
+}
+
+func parseTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
@@ -0,0 +1,30 @@
+package audit
+
+import (
+	"context"
+	"slices"
+	"sync"
+)
+
+// MemoryLogger keeps events in memory. It is meant for tests.
+type MemoryLogger struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+func NewMemoryLogger() *MemoryLogger {
+	return &MemoryLogger{}
+}
+
+func (l *MemoryLogger) Log(ctx context.Context, event Event) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.events = append(l.events, complete(ctx, event))
+}
+
+// Events returns the events logged so far, oldest first.
+func (l *MemoryLogger) Events() []Event {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return slices.Clone(l.events)
+}
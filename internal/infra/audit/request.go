@@ -0,0 +1,73 @@
+package audit
+
+import (
+	"context"
+
+	"github.com/labstack/echo/v5"
+)
+
+// RequestInfo describes the HTTP request an audited action was made in.
+type RequestInfo struct {
+	IPAddress string
+	UserAgent string
+	RequestID string
+}
+
+type (
+	requestInfoKey  struct{}
+	actorKey        struct{}
+	impersonatorKey struct{}
+)
+
+// WithRequestInfo returns a context whose audit events are attributed to info.
+func WithRequestInfo(ctx context.Context, info RequestInfo) context.Context {
+	return context.WithValue(ctx, requestInfoKey{}, info)
+}
+
+// RequestInfoFromContext returns the request details stored by WithRequestInfo.
+func RequestInfoFromContext(ctx context.Context) RequestInfo {
+	info, _ := ctx.Value(requestInfoKey{}).(RequestInfo)
+	return info
+}
+
+// WithActorID returns a context whose audit events are attributed to the authenticated
+// user actorID unless the event names its own actor.
+func WithActorID(ctx context.Context, actorID string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actorID)
+}
+
+// ActorIDFromContext returns the actor stored by WithActorID.
+func ActorIDFromContext(ctx context.Context) string {
+	actorID, _ := ctx.Value(actorKey{}).(string)
+	return actorID
+}
+
+// WithImpersonatorID returns a context whose audit events record that the admin
+// impersonatorID was acting as the user.
+func WithImpersonatorID(ctx context.Context, impersonatorID string) context.Context {
+	return context.WithValue(ctx, impersonatorKey{}, impersonatorID)
+}
+
+// ImpersonatorIDFromContext returns the admin stored by WithImpersonatorID.
+func ImpersonatorIDFromContext(ctx context.Context) string {
+	impersonatorID, _ := ctx.Value(impersonatorKey{}).(string)
+	return impersonatorID
+}
+
+// RequestInfoMiddleware makes the client address, user agent and request id available to
+// audit loggers further down the stack through the request context. It must run after
+// the RequestID middleware.
+func RequestInfoMiddleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c *echo.Context) error {
+			req := c.Request()
+			ctx := WithRequestInfo(req.Context(), RequestInfo{
+				IPAddress: c.RealIP(),
+				UserAgent: req.UserAgent(),
+				RequestID: c.Response().Header().Get(echo.HeaderXRequestID),
+			})
+			c.SetRequest(req.WithContext(ctx))
+			return next(c)
+		}
+	}
+}
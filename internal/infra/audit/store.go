@@ -0,0 +1,102 @@
+package audit
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Filter selects audit events. Zero fields match everything.
+type Filter struct {
+	ActorID        string
+	ImpersonatorID string
+	Action         string
+	TargetType     string
+	TargetID       string
+	Outcome        string
+	From           time.Time
+	To             time.Time
+	Limit          int
+	Offset         int
+}
+
+// Store is an append-only audit trail that can also be searched. It has no way to change
+// or remove an event once written.
+type Store interface {
+	AuditLogger
+	Query(ctx context.Context, filter Filter) ([]Event, int64, error)
+}
+
+type gormStore struct {
+	db *gorm.DB
+}
+
+func NewGormStore(db *gorm.DB) Store {
+	return &gormStore{db: db}
+}
+
+// Migrate creates or updates the audit_events table.
+func Migrate(db *gorm.DB) error {
+	return db.AutoMigrate(&Event{})
+}
+
+// Log writes the event even if the request that caused it has been cancelled, so an
+// aborted request still leaves a trace.
+func (s *gormStore) Log(ctx context.Context, event Event) {
+	event = complete(ctx, event)
+	if err := s.db.WithContext(context.WithoutCancel(ctx)).Create(&event).Error; err != nil {
+		slog.ErrorContext(ctx, "failed to write audit event",
+			"error", err,
+			"action", event.Action,
+			"actor_id", event.ActorID,
+			"outcome", event.Outcome,
+		)
+	}
+}
+
+// Query returns the matching events, newest first, and how many match in total.
+func (s *gormStore) Query(ctx context.Context, filter Filter) ([]Event, int64, error) {
+	query := s.db.WithContext(ctx).Model(&Event{})
+	if filter.ActorID != "" {
+		query = query.Where("actor_id = ?", filter.ActorID)
+	}
+	if filter.ImpersonatorID != "" {
+		query = query.Where("impersonator_id = ?", filter.ImpersonatorID)
+	}
+	if filter.Action != "" {
+		query = query.Where("action = ?", filter.Action)
+	}
+	if filter.TargetType != "" {
+		query = query.Where("target_type = ?", filter.TargetType)
+	}
+	if filter.TargetID != "" {
+		query = query.Where("target_id = ?", filter.TargetID)
+	}
+	if filter.Outcome != "" {
+		query = query.Where("outcome = ?", filter.Outcome)
+	}
+	if !filter.From.IsZero() {
+		query = query.Where("occurred_at >= ?", filter.From)
+	}
+	if !filter.To.IsZero() {
+		query = query.Where("occurred_at < ?", filter.To)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var events []Event
+	err := query.
+		Order("occurred_at DESC").
+		Limit(filter.Limit).
+		Offset(filter.Offset).
+		Find(&events).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return events, total, nil
+}
@@ -0,0 +1,56 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"time"
+)
+
+// APIKeyPrefix marks a bearer credential as an API key rather than a JWT.
+const APIKeyPrefix = "gbk_"
+
+// apiKeyIDBytes sizes the public part of a key that is stored in clear and shown in
+// listings so users can tell their keys apart.
+const apiKeyIDBytes = 6
+
+var ErrInvalidAPIKey = errors.New("invalid or expired API key")
+
+// APIKeyPrincipal is who an API key authenticates as and what it may do.
+type APIKeyPrincipal struct {
+	KeyID     string
+	UserID    string
+	Role      Role
+	Scopes    []Permission
+	ExpiresAt time.Time
+}
+
+// APIKeyAuthenticator resolves a raw API key to its principal, returning ErrInvalidAPIKey
+// for unknown, revoked or expired keys.
+type APIKeyAuthenticator interface {
+	AuthenticateAPIKey(ctx context.Context, key string) (*APIKeyPrincipal, error)
+}
+
+// GenerateAPIKey returns a new key of the form "gbk_<id>_<secret>" together with its
+// "gbk_<id>" prefix. Only the prefix may be stored in clear.
+func GenerateAPIKey() (key, prefix string, err error) {
+	id := make([]byte, apiKeyIDBytes)
+	if _, err := rand.Read(id); err != nil {
+		return "", "", err
+	}
+
+	secret, err := GenerateOpaqueToken()
+	if err != nil {
+		return "", "", err
+	}
+
+	prefix = APIKeyPrefix + base64.RawURLEncoding.EncodeToString(id)
+	return prefix + "_" + secret, prefix, nil
+}
+
+// IsAPIKey reports whether a bearer credential looks like an API key.
+func IsAPIKey(token string) bool {
+	return strings.HasPrefix(token, APIKeyPrefix)
+}
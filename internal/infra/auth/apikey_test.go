@@ -0,0 +1,96 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+type stubAPIKeyAuthenticator map[string]*APIKeyPrincipal
+
+func (s stubAPIKeyAuthenticator) AuthenticateAPIKey(_ context.Context, key string) (*APIKeyPrincipal, error) {
+	principal, ok := s[key]
+	if !ok {
+		return nil, ErrInvalidAPIKey
+	}
+	return principal, nil
+}
+
+func TestGenerateAPIKey(t *testing.T) {
+	// Act
+	key, prefix, err := GenerateAPIKey()
+
+	// Assert
+	assert.NoError(t, err)
+	assert.True(t, IsAPIKey(key))
+	assert.True(t, strings.HasPrefix(key, prefix+"_"))
+	assert.Len(t, prefix, len(APIKeyPrefix)+8)
+}
+
+func TestBearerAuth_APIKey(t *testing.T) {
+	// Arrange
+	key, _, _ := GenerateAPIKey()
+	authenticator := stubAPIKeyAuthenticator{
+		key: {KeyID: "key-1", UserID: "user-123", Role: RoleUser, Scopes: []Permission{PermPortfoliosRead}},
+	}
+	middleware := BearerAuth(NewJWTService("secret", 1), WithAPIKeyAuthenticator(authenticator))
+
+	serve := func(token string, perm Permission) (int, *echo.Context) {
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		handler := middleware(RequirePermission(perm)(func(c *echo.Context) error {
+			return c.NoContent(http.StatusNoContent)
+		}))
+		_ = handler(c)
+		return rec.Code, c
+	}
+
+	// Act
+	readCode, c := serve(key, PermPortfoliosRead)
+	writeCode, _ := serve(key, PermPortfoliosWrite)
+	unknownCode, _ := serve(APIKeyPrefix+"unknown_secret", PermPortfoliosRead)
+
+	// Assert
+	assert.Equal(t, http.StatusNoContent, readCode)
+	assert.Equal(t, "user-123", c.Get("user_id"))
+	assert.Equal(t, AuthMethodAPIKey, AuthMethodFromContext(c))
+	assert.Equal(t, http.StatusForbidden, writeCode, "role allows writes but the key is read-only")
+	assert.Equal(t, http.StatusUnauthorized, unknownCode)
+}
+
+func TestBearerAuth_ScopedAccessToken(t *testing.T) {
+	// Arrange
+	jwtSvc := NewJWTService("secret", 1)
+	token, err := jwtSvc.GenerateToken(Subject{UserID: "user-123", Role: RoleUser, Scopes: []Permission{PermPortfoliosRead}})
+	assert.NoError(t, err)
+	middleware := BearerAuth(jwtSvc)
+
+	serve := func(perm Permission) int {
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		handler := middleware(RequirePermission(perm)(func(c *echo.Context) error {
+			return c.NoContent(http.StatusNoContent)
+		}))
+		_ = handler(c)
+		return rec.Code
+	}
+
+	// Act
+	readCode := serve(PermPortfoliosRead)
+	writeCode := serve(PermPortfoliosWrite)
+
+	// Assert - the role allows writing but the token was issued for reading only
+	assert.Equal(t, http.StatusNoContent, readCode)
+	assert.Equal(t, http.StatusForbidden, writeCode)
+}
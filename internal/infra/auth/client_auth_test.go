@@ -0,0 +1,92 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseClientCredentials(t *testing.T) {
+	// Act
+	clients, err := ParseClientCredentials([]string{"billing:s3cret", " reports:with:colon "})
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, ClientCredentials{"billing": "s3cret", "reports": "with:colon"}, clients)
+
+	_, err = ParseClientCredentials([]string{"missing-secret"})
+	assert.Error(t, err)
+}
+
+func TestClientAuth(t *testing.T) {
+	clients := ClientCredentials{"billing": "s3cret"}
+
+	tests := []struct {
+		name     string
+		arrange  func(req *http.Request)
+		form     url.Values
+		wantCode int
+	}{
+		{
+			name:     "basic credentials",
+			arrange:  func(req *http.Request) { req.SetBasicAuth("billing", "s3cret") },
+			wantCode: http.StatusNoContent,
+		},
+		{
+			name:     "form credentials",
+			form:     url.Values{"client_id": {"billing"}, "client_secret": {"s3cret"}},
+			wantCode: http.StatusNoContent,
+		},
+		{
+			name:     "wrong secret",
+			arrange:  func(req *http.Request) { req.SetBasicAuth("billing", "guess") },
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name:     "unknown client",
+			arrange:  func(req *http.Request) { req.SetBasicAuth("other", "") },
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name:     "no credentials",
+			wantCode: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodPost, "/auth/introspect", strings.NewReader(tt.form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			if tt.arrange != nil {
+				tt.arrange(req)
+			}
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+
+			var clientID string
+			handler := ClientAuth(clients)(func(c *echo.Context) error {
+				clientID = ClientIDFromContext(c)
+				return c.NoContent(http.StatusNoContent)
+			})
+
+			// Act
+			_ = handler(c)
+
+			// Assert
+			assert.Equal(t, tt.wantCode, rec.Code)
+			if tt.wantCode == http.StatusNoContent {
+				assert.Equal(t, "billing", clientID)
+			} else {
+				assert.NotEmpty(t, rec.Header().Get("WWW-Authenticate"))
+			}
+		})
+	}
+}
@@ -0,0 +1,150 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"go-boilerplate/pkg/response"
+
+	"github.com/labstack/echo/v5"
+)
+
+// Cookies set in cookie mode. The CSRF token cookie is readable by scripts, so the
+// front-end can echo it back in the CSRFTokenHeader.
+const (
+	AccessTokenCookie  = "access_token"
+	RefreshTokenCookie = "refresh_token"
+	CSRFTokenCookie    = "csrf_token"
+	CSRFTokenHeader    = "X-CSRF-Token"
+
+	// RefreshCookiePath keeps the refresh token cookie off every request but those to
+	// the auth endpoints, which are the only ones that read it.
+	RefreshCookiePath = "/auth"
+)
+
+// SessionCookies hands tokens to browser clients as cookies instead of in the response
+// body, so scripts on the page never see them. Requests authenticated this way must be
+// guarded by the CSRF middleware.
+type SessionCookies struct {
+	Domain     string
+	Secure     bool
+	SameSite   http.SameSite
+	AccessTTL  time.Duration
+	RefreshTTL time.Duration
+}
+
+// NewSessionCookies returns the cookie settings for the given SameSite mode: "strict",
+// "lax" or "none". Browsers only accept SameSite=None on secure cookies.
+func NewSessionCookies(domain string, secure bool, sameSite string, accessTTL, refreshTTL time.Duration) (*SessionCookies, error) {
+	var mode http.SameSite
+	switch strings.ToLower(sameSite) {
+	case "strict":
+		mode = http.SameSiteStrictMode
+	case "lax":
+		mode = http.SameSiteLaxMode
+	case "none":
+		if !secure {
+			return nil, errors.New("SameSite=None cookies must be secure")
+		}
+		mode = http.SameSiteNoneMode
+	default:
+		return nil, fmt.Errorf("unknown SameSite mode %q", sameSite)
+	}
+
+	return &SessionCookies{
+		Domain:     domain,
+		Secure:     secure,
+		SameSite:   mode,
+		AccessTTL:  accessTTL,
+		RefreshTTL: refreshTTL,
+	}, nil
+}
+
+// Set stores a freshly issued token pair in cookies along with a new CSRF token, which
+// is returned so it can also be handed over in the response body.
+func (s *SessionCookies) Set(c *echo.Context, accessToken, refreshToken string) (string, error) {
+	csrfToken, err := GenerateOpaqueToken()
+	if err != nil {
+		return "", fmt.Errorf("failed to generate CSRF token: %w", err)
+	}
+
+	c.SetCookie(s.cookie(AccessTokenCookie, accessToken, "/", s.AccessTTL, true))
+	c.SetCookie(s.cookie(RefreshTokenCookie, refreshToken, RefreshCookiePath, s.RefreshTTL, true))
+	c.SetCookie(s.cookie(CSRFTokenCookie, csrfToken, "/", s.RefreshTTL, false))
+	return csrfToken, nil
+}
+
+// Clear tells the browser to drop the session cookies.
+func (s *SessionCookies) Clear(c *echo.Context) {
+	c.SetCookie(s.cookie(AccessTokenCookie, "", "/", -1, true))
+	c.SetCookie(s.cookie(RefreshTokenCookie, "", RefreshCookiePath, -1, true))
+	c.SetCookie(s.cookie(CSRFTokenCookie, "", "/", -1, false))
+}
+
+func (s *SessionCookies) cookie(name, value, path string, ttl time.Duration, httpOnly bool) *http.Cookie {
+	maxAge := int(ttl / time.Second)
+	if ttl < 0 {
+		maxAge = -1
+	}
+	return &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     path,
+		Domain:   s.Domain,
+		MaxAge:   maxAge,
+		Secure:   s.Secure,
+		HttpOnly: httpOnly,
+		SameSite: s.SameSite,
+	}
+}
+
+// AccessTokenFromCookie returns the access token a cookie-mode client sent.
+func AccessTokenFromCookie(r *http.Request) (string, bool) {
+	return cookieValue(r, AccessTokenCookie)
+}
+
+// RefreshTokenFromCookie returns the refresh token a cookie-mode client sent.
+func RefreshTokenFromCookie(r *http.Request) (string, bool) {
+	return cookieValue(r, RefreshTokenCookie)
+}
+
+func cookieValue(r *http.Request, name string) (string, bool) {
+	cookie, err := r.Cookie(name)
+	if err != nil || cookie.Value == "" {
+		return "", false
+	}
+	return cookie.Value, true
+}
+
+// CSRF protects cookie-mode clients with the double-submit pattern: a state-changing
+// request carrying a session cookie must repeat the CSRF token cookie in the
+// X-CSRF-Token header. Another site can make the browser send the cookies but cannot
+// read them to fill in the header. Requests without session cookies, such as those
+// from clients sending an Authorization header, pass through untouched.
+func CSRF() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c *echo.Context) error {
+			req := c.Request()
+			if isSafeMethod(req.Method) || !hasSessionCookie(req) {
+				return next(c)
+			}
+
+			expected, ok := cookieValue(req, CSRFTokenCookie)
+			actual := req.Header.Get(CSRFTokenHeader)
+			if !ok || actual == "" || subtle.ConstantTimeCompare([]byte(expected), []byte(actual)) != 1 {
+				return response.Forbidden(c, "missing or invalid CSRF token")
+			}
+			return next(c)
+		}
+	}
+}
+
+func hasSessionCookie(r *http.Request) bool {
+	_, access := AccessTokenFromCookie(r)
+	_, refresh := RefreshTokenFromCookie(r)
+	return access || refresh
+}
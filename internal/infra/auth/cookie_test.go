@@ -0,0 +1,157 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewSessionCookies(t *testing.T) {
+	tests := []struct {
+		name     string
+		secure   bool
+		sameSite string
+		want     http.SameSite
+		wantErr  bool
+	}{
+		{name: "strict", secure: true, sameSite: "strict", want: http.SameSiteStrictMode},
+		{name: "lax is case-insensitive", secure: false, sameSite: "Lax", want: http.SameSiteLaxMode},
+		{name: "none when secure", secure: true, sameSite: "none", want: http.SameSiteNoneMode},
+		{name: "none needs secure", secure: false, sameSite: "none", wantErr: true},
+		{name: "unknown mode", secure: true, sameSite: "sometimes", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			cookies, err := NewSessionCookies("", tt.secure, tt.sameSite, time.Hour, 24*time.Hour)
+
+			// Assert
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, cookies.SameSite)
+		})
+	}
+}
+
+func TestSessionCookies_Set(t *testing.T) {
+	// Arrange
+	cookies, err := NewSessionCookies("example.com", true, "strict", time.Hour, 24*time.Hour)
+	assert.NoError(t, err)
+
+	e := echo.New()
+	rec := httptest.NewRecorder()
+	c := e.NewContext(httptest.NewRequest(http.MethodPost, "/auth/login", nil), rec)
+
+	// Act
+	csrfToken, err := cookies.Set(c, "access", "refresh")
+
+	// Assert
+	assert.NoError(t, err)
+	assert.NotEmpty(t, csrfToken)
+
+	set := map[string]*http.Cookie{}
+	for _, cookie := range rec.Result().Cookies() {
+		set[cookie.Name] = cookie
+	}
+	if access := set[AccessTokenCookie]; assert.NotNil(t, access) {
+		assert.Equal(t, "access", access.Value)
+		assert.Equal(t, "/", access.Path)
+		assert.Equal(t, 3600, access.MaxAge)
+		assert.True(t, access.HttpOnly)
+		assert.True(t, access.Secure)
+		assert.Equal(t, http.SameSiteStrictMode, access.SameSite)
+		assert.Equal(t, "example.com", access.Domain)
+	}
+	if refresh := set[RefreshTokenCookie]; assert.NotNil(t, refresh) {
+		assert.Equal(t, "refresh", refresh.Value)
+		assert.Equal(t, RefreshCookiePath, refresh.Path)
+		assert.True(t, refresh.HttpOnly)
+	}
+	if csrf := set[CSRFTokenCookie]; assert.NotNil(t, csrf) {
+		assert.Equal(t, csrfToken, csrf.Value)
+		assert.False(t, csrf.HttpOnly, "the front-end must be able to read the CSRF token")
+	}
+}
+
+func TestCSRF(t *testing.T) {
+	serve := func(method string, cookies map[string]string, header string) int {
+		e := echo.New()
+		req := httptest.NewRequest(method, "/", nil)
+		for name, value := range cookies {
+			req.AddCookie(&http.Cookie{Name: name, Value: value})
+		}
+		if header != "" {
+			req.Header.Set(CSRFTokenHeader, header)
+		}
+		rec := httptest.NewRecorder()
+		handler := CSRF()(func(c *echo.Context) error {
+			return c.NoContent(http.StatusNoContent)
+		})
+		_ = handler(e.NewContext(req, rec))
+		return rec.Code
+	}
+	session := map[string]string{AccessTokenCookie: "access", CSRFTokenCookie: "csrf-123"}
+	refreshOnly := map[string]string{RefreshTokenCookie: "refresh", CSRFTokenCookie: "csrf-123"}
+
+	tests := []struct {
+		name    string
+		method  string
+		cookies map[string]string
+		header  string
+		want    int
+	}{
+		{name: "safe method", method: http.MethodGet, cookies: session, want: http.StatusNoContent},
+		{name: "no session cookie", method: http.MethodPost, want: http.StatusNoContent},
+		{name: "matching token", method: http.MethodPost, cookies: session, header: "csrf-123", want: http.StatusNoContent},
+		{name: "refresh cookie alone", method: http.MethodPost, cookies: refreshOnly, want: http.StatusForbidden},
+		{name: "missing header", method: http.MethodDelete, cookies: session, want: http.StatusForbidden},
+		{name: "mismatched token", method: http.MethodPut, cookies: session, header: "csrf-456", want: http.StatusForbidden},
+		{name: "missing cookie", method: http.MethodPost, cookies: map[string]string{AccessTokenCookie: "access"}, header: "csrf-123", want: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, serve(tt.method, tt.cookies, tt.header))
+		})
+	}
+}
+
+func TestBearerAuth_AccessTokenCookie(t *testing.T) {
+	// Arrange
+	svc := NewJWTService("secret", 1)
+	token, _ := svc.GenerateToken(Subject{UserID: "user-123", Role: RoleUser})
+
+	serve := func(withCookieAuth bool) (int, *echo.Context) {
+		var opts []MiddlewareOption
+		if withCookieAuth {
+			opts = append(opts, WithAccessTokenCookie())
+		}
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.AddCookie(&http.Cookie{Name: AccessTokenCookie, Value: token})
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		handler := BearerAuth(svc, opts...)(func(c *echo.Context) error {
+			return c.NoContent(http.StatusNoContent)
+		})
+		_ = handler(c)
+		return rec.Code, c
+	}
+
+	// Act
+	enabledCode, c := serve(true)
+	disabledCode, _ := serve(false)
+
+	// Assert
+	assert.Equal(t, http.StatusNoContent, enabledCode)
+	assert.Equal(t, "user-123", c.Get("user_id"))
+	assert.Equal(t, http.StatusUnauthorized, disabledCode, "cookies are ignored unless enabled")
+}
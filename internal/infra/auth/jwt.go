@@ -12,71 +12,254 @@ var (
 	ErrInvalidToken = errors.New("invalid or expired token")
 )
 
+// PurposeMFA marks a token that only proves the password step of an MFA login.
+const PurposeMFA = "mfa"
+
+// mfaChallengeTTL bounds how long a user has to enter their second factor.
+const mfaChallengeTTL = 5 * time.Minute
+
+// Claims are the access token claims. The registered `sub` claim carries the same value as
+// UserID so standard verifiers can read it; UserID is kept for existing consumers.
+// Purpose is empty on access tokens and set on single-purpose tokens such as MFA challenges,
+// which ValidateToken rejects. Scope, when present, is the space-separated list of
+// permissions the token is restricted to. Act is set on impersonation tokens and names the
+// admin acting as the subject, as in RFC 8693; ReadOnly tokens may not change anything.
+// SessionID names the login session a token was issued to, so ending the session can
+// revoke it.
 type Claims struct {
-	UserID string `json:"user_id"`
+	UserID    string `json:"user_id"`
+	Role      Role   `json:"role"`
+	SessionID string `json:"sid,omitempty"`
+	Scope     string `json:"scope,omitempty"`
+	Purpose   string `json:"purpose,omitempty"`
+	Act       *Actor `json:"act,omitempty"`
+	ReadOnly  bool   `json:"read_only,omitempty"`
 	jwt.RegisteredClaims
 }
 
+// Actor is the party acting on behalf of a token's subject.
+type Actor struct {
+	Subject string `json:"sub"`
+}
+
+// Scopes returns the permissions the token is restricted to. ok is false for tokens that
+// carry every permission of their role.
+func (c *Claims) Scopes() (scopes []Permission, ok bool) {
+	if c.Scope == "" {
+		return nil, false
+	}
+	return ParseScope(c.Scope), true
+}
+
+// Subject identifies who an access token is issued to. Scopes restrict the token to
+// part of the role's permissions; an empty list leaves it unrestricted. SessionID is
+// set for tokens issued to a login session.
+type Subject struct {
+	UserID    string
+	Role      Role
+	Scopes    []Permission
+	SessionID string
+}
+
 type JWTService interface {
-	GenerateToken(userID string) (string, error)
+	GenerateToken(subject Subject) (string, error)
 	ValidateToken(tokenString string) (*Claims, error)
-	GeneratePair(userID string) (string, string, error)
+	GeneratePair(subject Subject) (string, string, error)
+	GenerateImpersonationToken(subject Subject, actorID string, readOnly bool, ttl time.Duration) (string, error)
+	GenerateMFAChallenge(userID string, scopes []Permission) (string, error)
+	ValidateMFAChallenge(tokenString string) (*Claims, error)
+	AccessTokenTTL() time.Duration
+	JWKS() JWKS
+}
+
+// Option configures optional behaviour of the JWT service.
+type Option func(*jwtService)
+
+// WithKeyring switches the service from HS256 with a shared secret to asymmetric
+// signing with the keyring's active key. Tokens carry a kid header and are verified
+// with whichever key in the keyring it names.
+func WithKeyring(keyring *Keyring) Option {
+	return func(s *jwtService) {
+		s.keyring = keyring
+	}
+}
+
+// WithIssuer sets the `iss` claim on issued tokens and rejects tokens from any other issuer.
+func WithIssuer(issuer string) Option {
+	return func(s *jwtService) {
+		s.issuer = issuer
+	}
+}
+
+// WithAudience sets the `aud` claim on issued tokens and rejects tokens that were not
+// minted for at least one of the given audiences.
+func WithAudience(audience ...string) Option {
+	return func(s *jwtService) {
+		s.audience = audience
+	}
+}
+
+// WithLeeway tolerates the given clock skew when checking `exp`, `nbf` and `iat`.
+func WithLeeway(leeway time.Duration) Option {
+	return func(s *jwtService) {
+		s.leeway = leeway
+	}
 }
 
 type jwtService struct {
 	secret        []byte
+	keyring       *Keyring
+	issuer        string
+	audience      []string
+	leeway        time.Duration
 	accessExpiry  time.Duration
 	refreshExpiry time.Duration
+	parser        *jwt.Parser
 }
 
-func NewJWTService(secret string, accessExpiryHours int) JWTService {
-	return &jwtService{
+func NewJWTService(secret string, accessExpiryHours int, opts ...Option) JWTService {
+	s := &jwtService{
 		secret:        []byte(secret),
 		accessExpiry:  time.Duration(accessExpiryHours) * time.Hour,
 		refreshExpiry: 7 * 24 * time.Hour, // Default 7 days
 	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	parserOpts := []jwt.ParserOption{
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(s.leeway),
+	}
+	if s.issuer != "" {
+		parserOpts = append(parserOpts, jwt.WithIssuer(s.issuer))
+	}
+	if len(s.audience) > 0 {
+		parserOpts = append(parserOpts, jwt.WithAudience(s.audience...))
+	}
+	s.parser = jwt.NewParser(parserOpts...)
+
+	return s
 }
 
-func (s *jwtService) GeneratePair(userID string) (string, string, error) {
-	accessToken, err := s.GenerateToken(userID)
+func (s *jwtService) GeneratePair(subject Subject) (string, string, error) {
+	accessToken, err := s.GenerateToken(subject)
 	if err != nil {
 		return "", "", err
 	}
 
-	// For simple implementation, refresh token is a random string/UUID
-	refreshToken := uuid.New().String()
+	// Refresh tokens are opaque random strings; only their hash is persisted
+	refreshToken, err := GenerateOpaqueToken()
+	if err != nil {
+		return "", "", err
+	}
 
 	return accessToken, refreshToken, nil
 }
 
-func (s *jwtService) GenerateToken(userID string) (string, error) {
-	claims := &Claims{
-		UserID: userID,
+func (s *jwtService) GenerateToken(subject Subject) (string, error) {
+	return s.sign(s.newClaims(subject, "", s.accessExpiry))
+}
+
+func (s *jwtService) ValidateToken(tokenString string) (*Claims, error) {
+	return s.validate(tokenString, "")
+}
+
+// GenerateImpersonationToken issues an access token for subject that records actorID as
+// the one actually using it. It expires after ttl and, when readOnly is set, BearerAuth
+// only accepts it for safe requests.
+func (s *jwtService) GenerateImpersonationToken(subject Subject, actorID string, readOnly bool, ttl time.Duration) (string, error) {
+	claims := s.newClaims(subject, "", ttl)
+	claims.Act = &Actor{Subject: actorID}
+	claims.ReadOnly = readOnly
+	return s.sign(claims)
+}
+
+// GenerateMFAChallenge issues a short-lived token proving userID passed the password
+// check. It carries the scopes requested at login through to the second step, and
+// cannot be used as an access token.
+func (s *jwtService) GenerateMFAChallenge(userID string, scopes []Permission) (string, error) {
+	return s.sign(s.newClaims(Subject{UserID: userID, Scopes: scopes}, PurposeMFA, mfaChallengeTTL))
+}
+
+func (s *jwtService) ValidateMFAChallenge(tokenString string) (*Claims, error) {
+	return s.validate(tokenString, PurposeMFA)
+}
+
+func (s *jwtService) newClaims(subject Subject, purpose string, ttl time.Duration) *Claims {
+	now := time.Now()
+	return &Claims{
+		UserID:    subject.UserID,
+		Role:      subject.Role,
+		SessionID: subject.SessionID,
+		Scope:     FormatScope(subject.Scopes),
+		Purpose:   purpose,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(s.accessExpiry)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ID:        uuid.NewString(),
+			Issuer:    s.issuer,
+			Subject:   subject.UserID,
+			Audience:  s.audience,
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+			NotBefore: jwt.NewNumericDate(now),
+			IssuedAt:  jwt.NewNumericDate(now),
 		},
 	}
-
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(s.secret)
 }
 
-func (s *jwtService) ValidateToken(tokenString string) (*Claims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, ErrInvalidToken
-		}
-		return s.secret, nil
-	})
+// validate parses the token and requires its purpose to match, so a token minted for
+// one step of a flow cannot stand in for another.
+func (s *jwtService) validate(tokenString, purpose string) (*Claims, error) {
+	token, err := s.parser.ParseWithClaims(tokenString, &Claims{}, s.verificationKey)
 
 	if err != nil {
 		return nil, ErrInvalidToken
 	}
 
-	if claims, ok := token.Claims.(*Claims); ok && token.Valid {
+	if claims, ok := token.Claims.(*Claims); ok && token.Valid && claims.Purpose == purpose {
 		return claims, nil
 	}
 
 	return nil, ErrInvalidToken
 }
+
+func (s *jwtService) AccessTokenTTL() time.Duration {
+	return s.accessExpiry
+}
+
+func (s *jwtService) JWKS() JWKS {
+	if s.keyring == nil {
+		return JWKS{Keys: []JWK{}}
+	}
+	return s.keyring.JWKS()
+}
+
+func (s *jwtService) sign(claims jwt.Claims) (string, error) {
+	if s.keyring == nil {
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+		return token.SignedString(s.secret)
+	}
+
+	key := s.keyring.Active()
+	token := jwt.NewWithClaims(key.Method, claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.Private)
+}
+
+// verificationKey resolves the key for an incoming token. The algorithm is pinned to
+// the one the key was issued for, so a token cannot pick a weaker or confused algorithm.
+func (s *jwtService) verificationKey(token *jwt.Token) (interface{}, error) {
+	if s.keyring == nil {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, ErrInvalidToken
+		}
+		return s.secret, nil
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	key, ok := s.keyring.Lookup(kid)
+	if !ok || token.Method.Alg() != key.Method.Alg() {
+		return nil, ErrInvalidToken
+	}
+	return key.Public, nil
+}
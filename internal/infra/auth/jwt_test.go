@@ -15,7 +15,7 @@ func TestJWTService_TokenLifecycle(t *testing.T) {
 	userID := "user-123"
 
 	// Act - Generate
-	token, err := svc.GenerateToken(userID)
+	token, err := svc.GenerateToken(Subject{UserID: userID, Role: RoleAdmin})
 	assert.NoError(t, err)
 	assert.NotEmpty(t, token)
 
@@ -23,6 +23,10 @@ func TestJWTService_TokenLifecycle(t *testing.T) {
 	claims, err := svc.ValidateToken(token)
 	assert.NoError(t, err)
 	assert.Equal(t, userID, claims.UserID)
+	assert.Equal(t, userID, claims.Subject)
+	assert.Equal(t, RoleAdmin, claims.Role)
+	assert.NotEmpty(t, claims.ID)
+	assert.NotNil(t, claims.NotBefore)
 }
 
 func TestJWTService_InvalidToken(t *testing.T) {
@@ -41,12 +45,10 @@ func TestJWTService_InvalidToken(t *testing.T) {
 func TestJWTService_ExpiredToken(t *testing.T) {
 	// Arrange
 	secret := "secret"
-	svc := &jwtService{
-		secret:       []byte(secret),
-		accessExpiry: -1 * time.Hour, // Expired
-	}
+	svc := NewJWTService(secret, 1).(*jwtService)
+	svc.accessExpiry = -1 * time.Hour // Expired
 
-	token, _ := svc.GenerateToken("user-123")
+	token, _ := svc.GenerateToken(Subject{UserID: "user-123"})
 
 	// Act
 	claims, err := svc.ValidateToken(token)
@@ -56,3 +58,150 @@ func TestJWTService_ExpiredToken(t *testing.T) {
 	assert.Nil(t, claims)
 	assert.Equal(t, ErrInvalidToken, err)
 }
+
+func TestJWTService_IssuerAndAudience(t *testing.T) {
+	// Arrange
+	svc := NewJWTService("secret", 1, WithIssuer("auth.example.com"), WithAudience("portfolio-api"))
+
+	token, err := svc.GenerateToken(Subject{UserID: "user-123"})
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		svc     JWTService
+		wantErr bool
+	}{
+		{
+			name: "matching issuer and audience",
+			svc:  svc,
+		},
+		{
+			name:    "different audience",
+			svc:     NewJWTService("secret", 1, WithIssuer("auth.example.com"), WithAudience("billing-api")),
+			wantErr: true,
+		},
+		{
+			name:    "different issuer",
+			svc:     NewJWTService("secret", 1, WithIssuer("evil.example.com"), WithAudience("portfolio-api")),
+			wantErr: true,
+		},
+		{
+			name: "one of several accepted audiences",
+			svc:  NewJWTService("secret", 1, WithIssuer("auth.example.com"), WithAudience("billing-api", "portfolio-api")),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			claims, err := tt.svc.ValidateToken(token)
+
+			// Assert
+			if tt.wantErr {
+				assert.Equal(t, ErrInvalidToken, err)
+				assert.Nil(t, claims)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, "auth.example.com", claims.Issuer)
+			assert.Contains(t, claims.Audience, "portfolio-api")
+		})
+	}
+}
+
+func TestJWTService_Leeway(t *testing.T) {
+	// Arrange
+	issuer := NewJWTService("secret", 1).(*jwtService)
+	issuer.accessExpiry = -10 * time.Second // Expired moments ago
+
+	token, err := issuer.GenerateToken(Subject{UserID: "user-123"})
+	assert.NoError(t, err)
+
+	// Act
+	_, strictErr := NewJWTService("secret", 1).ValidateToken(token)
+	claims, lenientErr := NewJWTService("secret", 1, WithLeeway(time.Minute)).ValidateToken(token)
+
+	// Assert
+	assert.Equal(t, ErrInvalidToken, strictErr)
+	assert.NoError(t, lenientErr)
+	assert.Equal(t, "user-123", claims.UserID)
+}
+
+func TestJWTService_MFAChallengeIsNotAnAccessToken(t *testing.T) {
+	// Arrange
+	svc := NewJWTService("secret", 1)
+	challenge, err := svc.GenerateMFAChallenge("user-123", nil)
+	assert.NoError(t, err)
+	accessToken, err := svc.GenerateToken(Subject{UserID: "user-123", Role: RoleUser})
+	assert.NoError(t, err)
+
+	// Act
+	claims, challengeErr := svc.ValidateMFAChallenge(challenge)
+	_, asAccessErr := svc.ValidateToken(challenge)
+	_, asChallengeErr := svc.ValidateMFAChallenge(accessToken)
+
+	// Assert
+	assert.NoError(t, challengeErr)
+	assert.Equal(t, "user-123", claims.UserID)
+	assert.Equal(t, PurposeMFA, claims.Purpose)
+	assert.ErrorIs(t, asAccessErr, ErrInvalidToken)
+	assert.ErrorIs(t, asChallengeErr, ErrInvalidToken)
+}
+
+func TestJWTService_ScopedToken(t *testing.T) {
+	// Arrange
+	svc := NewJWTService("secret", 1)
+
+	// Act
+	scopedToken, err := svc.GenerateToken(Subject{UserID: "user-123", Role: RoleUser, Scopes: []Permission{PermPortfoliosRead}})
+	assert.NoError(t, err)
+	fullToken, err := svc.GenerateToken(Subject{UserID: "user-123", Role: RoleUser})
+	assert.NoError(t, err)
+
+	// Assert
+	scopedClaims, err := svc.ValidateToken(scopedToken)
+	assert.NoError(t, err)
+	assert.Equal(t, "portfolios:read", scopedClaims.Scope)
+	scopes, ok := scopedClaims.Scopes()
+	assert.True(t, ok)
+	assert.Equal(t, []Permission{PermPortfoliosRead}, scopes)
+
+	fullClaims, err := svc.ValidateToken(fullToken)
+	assert.NoError(t, err)
+	_, ok = fullClaims.Scopes()
+	assert.False(t, ok)
+}
+
+func TestJWTService_SessionID(t *testing.T) {
+	// Arrange
+	svc := NewJWTService("secret", 1)
+
+	// Act
+	token, err := svc.GenerateToken(Subject{UserID: "user-123", Role: RoleUser, SessionID: "session-1"})
+	assert.NoError(t, err)
+
+	// Assert
+	claims, err := svc.ValidateToken(token)
+	assert.NoError(t, err)
+	assert.Equal(t, "session-1", claims.SessionID)
+}
+
+func TestJWTService_ImpersonationToken(t *testing.T) {
+	// Arrange
+	svc := NewJWTService("secret", 1)
+
+	// Act
+	token, err := svc.GenerateImpersonationToken(Subject{UserID: "user-123", Role: RoleUser}, "admin-1", true, 15*time.Minute)
+	assert.NoError(t, err)
+	claims, err := svc.ValidateToken(token)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, "user-123", claims.Subject)
+	assert.Equal(t, RoleUser, claims.Role)
+	if assert.NotNil(t, claims.Act) {
+		assert.Equal(t, "admin-1", claims.Act.Subject)
+	}
+	assert.True(t, claims.ReadOnly)
+	assert.WithinDuration(t, time.Now().Add(15*time.Minute), claims.ExpiresAt.Time, 5*time.Second)
+}
@@ -0,0 +1,169 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	ErrUnsupportedKey = errors.New("unsupported signing key type")
+	ErrNoSigningKey   = errors.New("active signing key not found in keyring")
+)
+
+// SigningKey is a single asymmetric key in a Keyring.
+// Private is nil for keys that are only kept around to verify tokens they signed earlier.
+type SigningKey struct {
+	ID      string
+	Method  jwt.SigningMethod
+	Private crypto.Signer
+	Public  crypto.PublicKey
+}
+
+// Keyring holds the key used to sign new tokens plus any retiring keys that are still
+// accepted for verification, so keys can be rotated without invalidating issued tokens.
+type Keyring struct {
+	active *SigningKey
+	keys   map[string]*SigningKey
+}
+
+// NewKeyring builds a keyring that signs with the key identified by activeKID.
+func NewKeyring(activeKID string, keys ...*SigningKey) (*Keyring, error) {
+	kr := &Keyring{keys: make(map[string]*SigningKey, len(keys))}
+	for _, key := range keys {
+		if _, exists := kr.keys[key.ID]; exists {
+			return nil, fmt.Errorf("duplicate key id %q", key.ID)
+		}
+		kr.keys[key.ID] = key
+	}
+
+	active, ok := kr.keys[activeKID]
+	if !ok || active.Private == nil {
+		return nil, fmt.Errorf("%w: %q", ErrNoSigningKey, activeKID)
+	}
+	kr.active = active
+
+	return kr, nil
+}
+
+// LoadKeyringFromDir reads every *.pem file in dir, using the file name without its
+// extension as the key id. Private keys may sign; public keys are verification-only.
+func LoadKeyringFromDir(dir, activeKID string) (*Keyring, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	keys := make([]*SigningKey, 0, len(paths))
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read key %s: %w", path, err)
+		}
+
+		kid := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		key, err := ParseSigningKeyPEM(kid, data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse key %s: %w", path, err)
+		}
+		keys = append(keys, key)
+	}
+
+	return NewKeyring(activeKID, keys...)
+}
+
+// ParseSigningKeyPEM parses an RSA, ECDSA or Ed25519 key in PKCS#1, SEC 1, PKCS#8 or PKIX form.
+func ParseSigningKeyPEM(kid string, data []byte) (*SigningKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	var parsed interface{}
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		parsed, err = x509.ParseECPrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("%w: PEM type %q", ErrUnsupportedKey, block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return NewSigningKey(kid, parsed)
+}
+
+// NewSigningKey wraps a parsed private or public key and picks the matching JWS algorithm.
+func NewSigningKey(kid string, key interface{}) (*SigningKey, error) {
+	sk := &SigningKey{ID: kid}
+
+	if signer, ok := key.(crypto.Signer); ok {
+		sk.Private = signer
+		sk.Public = signer.Public()
+	} else {
+		sk.Public = key
+	}
+
+	switch pub := sk.Public.(type) {
+	case *rsa.PublicKey:
+		sk.Method = jwt.SigningMethodRS256
+	case *ecdsa.PublicKey:
+		switch pub.Curve {
+		case elliptic.P256():
+			sk.Method = jwt.SigningMethodES256
+		case elliptic.P384():
+			sk.Method = jwt.SigningMethodES384
+		case elliptic.P521():
+			sk.Method = jwt.SigningMethodES512
+		default:
+			return nil, fmt.Errorf("%w: curve %s", ErrUnsupportedKey, pub.Curve.Params().Name)
+		}
+	case ed25519.PublicKey:
+		sk.Method = jwt.SigningMethodEdDSA
+	default:
+		return nil, fmt.Errorf("%w: %T", ErrUnsupportedKey, key)
+	}
+
+	return sk, nil
+}
+
+// Active returns the key new tokens are signed with.
+func (k *Keyring) Active() *SigningKey {
+	return k.active
+}
+
+// Lookup returns the key with the given id, whether active or retiring.
+func (k *Keyring) Lookup(kid string) (*SigningKey, bool) {
+	key, ok := k.keys[kid]
+	return key, ok
+}
+
+// Keys returns every key in the keyring ordered by key id.
+func (k *Keyring) Keys() []*SigningKey {
+	keys := make([]*SigningKey, 0, len(k.keys))
+	for _, key := range k.keys {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].ID < keys[j].ID })
+	return keys
+}
@@ -0,0 +1,146 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writePEM(t *testing.T, dir, name, blockType string, der []byte) {
+	t.Helper()
+	data := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	require.NoError(t, os.WriteFile(filepath.Join(dir, name), data, 0o600))
+}
+
+func writeKeys(t *testing.T, dir string) {
+	t.Helper()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	writePEM(t, dir, "rsa-2024.pem", "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rsaKey))
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	ecDER, err := x509.MarshalECPrivateKey(ecKey)
+	require.NoError(t, err)
+	writePEM(t, dir, "ec-2025.pem", "EC PRIVATE KEY", ecDER)
+
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	edDER, err := x509.MarshalPKCS8PrivateKey(edKey)
+	require.NoError(t, err)
+	writePEM(t, dir, "ed-2026.pem", "PRIVATE KEY", edDER)
+}
+
+func TestLoadKeyringFromDir(t *testing.T) {
+	// Arrange
+	dir := t.TempDir()
+	writeKeys(t, dir)
+
+	// Act
+	keyring, err := LoadKeyringFromDir(dir, "ec-2025")
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, "ec-2025", keyring.Active().ID)
+	assert.Equal(t, jwt.SigningMethodES256, keyring.Active().Method)
+
+	rsaKey, ok := keyring.Lookup("rsa-2024")
+	assert.True(t, ok)
+	assert.Equal(t, jwt.SigningMethodRS256, rsaKey.Method)
+
+	edKey, ok := keyring.Lookup("ed-2026")
+	assert.True(t, ok)
+	assert.Equal(t, jwt.SigningMethodEdDSA, edKey.Method)
+
+	jwks := keyring.JWKS()
+	assert.Len(t, jwks.Keys, 3)
+	for _, key := range jwks.Keys {
+		assert.Equal(t, "sig", key.Use)
+		assert.NotEmpty(t, key.KeyType)
+
+		// Published keys decode back to the keyring's public keys.
+		pub, err := key.PublicKey()
+		assert.NoError(t, err)
+		signingKey, _ := keyring.Lookup(key.KeyID)
+		assert.Equal(t, signingKey.Public, pub)
+	}
+}
+
+func TestLoadKeyringFromDir_UnknownActiveKey(t *testing.T) {
+	// Arrange
+	dir := t.TempDir()
+	writeKeys(t, dir)
+
+	// Act
+	_, err := LoadKeyringFromDir(dir, "missing")
+
+	// Assert
+	assert.ErrorIs(t, err, ErrNoSigningKey)
+}
+
+func TestJWTService_KeyRotation(t *testing.T) {
+	// Arrange
+	dir := t.TempDir()
+	writeKeys(t, dir)
+
+	oldKeyring, err := LoadKeyringFromDir(dir, "rsa-2024")
+	require.NoError(t, err)
+	newKeyring, err := LoadKeyringFromDir(dir, "ed-2026")
+	require.NoError(t, err)
+
+	oldSvc := NewJWTService("", 1, WithKeyring(oldKeyring))
+	newSvc := NewJWTService("", 1, WithKeyring(newKeyring))
+
+	oldToken, err := oldSvc.GenerateToken(Subject{UserID: "user-123"})
+	require.NoError(t, err)
+
+	// Act
+	claims, err := newSvc.ValidateToken(oldToken)
+
+	// Assert - tokens signed by a retiring key stay valid after rotation
+	assert.NoError(t, err)
+	assert.Equal(t, "user-123", claims.UserID)
+
+	newToken, err := newSvc.GenerateToken(Subject{UserID: "user-123"})
+	require.NoError(t, err)
+	parsed, _, err := jwt.NewParser().ParseUnverified(newToken, &Claims{})
+	require.NoError(t, err)
+	assert.Equal(t, "ed-2026", parsed.Header["kid"])
+	assert.Equal(t, "EdDSA", parsed.Method.Alg())
+}
+
+func TestJWTService_RejectsAlgorithmConfusion(t *testing.T) {
+	// Arrange
+	dir := t.TempDir()
+	writeKeys(t, dir)
+
+	keyring, err := LoadKeyringFromDir(dir, "rsa-2024")
+	require.NoError(t, err)
+	svc := NewJWTService("", 1, WithKeyring(keyring))
+
+	// An HS256 token keyed with the RSA modulus and claiming the RSA key id
+	rsaKey, _ := keyring.Lookup("rsa-2024")
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, &Claims{UserID: "attacker"})
+	forged.Header["kid"] = "rsa-2024"
+	forgedToken, err := forged.SignedString(rsaKey.Public.(*rsa.PublicKey).N.Bytes())
+	require.NoError(t, err)
+
+	// Act
+	claims, err := svc.ValidateToken(forgedToken)
+
+	// Assert
+	assert.Nil(t, claims)
+	assert.Equal(t, ErrInvalidToken, err)
+}
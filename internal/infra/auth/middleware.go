@@ -1,35 +1,230 @@
 package auth
 
 import (
+	"errors"
+	"go-boilerplate/internal/infra/audit"
 	"go-boilerplate/pkg/response"
+	"net/http"
 	"strings"
 
+	"github.com/google/uuid"
 	"github.com/labstack/echo/v5"
 )
 
-func BearerAuth(jwtSvc JWTService) echo.MiddlewareFunc {
+// MiddlewareOption configures optional checks performed by BearerAuth.
+type MiddlewareOption func(*middlewareConfig)
+
+type middlewareConfig struct {
+	revocations RevocationStore
+	apiKeys     APIKeyAuthenticator
+	audit       audit.AuditLogger
+	cookies     bool
+}
+
+// reject answers 401 and, when an audit logger is configured, records why. actorID is
+// the user the credential claimed to belong to, if it could be read.
+func (cfg *middlewareConfig) reject(c *echo.Context, actorID, reason string) error {
+	if cfg.audit != nil {
+		cfg.audit.Log(c.Request().Context(), audit.Event{
+			ActorID:  actorID,
+			Action:   ActionTokenRejected,
+			Outcome:  audit.OutcomeFailure,
+			Metadata: map[string]any{"reason": reason, "method": c.Request().Method, "path": c.Request().URL.Path},
+		})
+	}
+	return response.Unauthorized(c, reason)
+}
+
+// Audit actions recorded by BearerAuth.
+const (
+	// ActionTokenRejected is recorded for requests BearerAuth turns away.
+	ActionTokenRejected = "auth.token_rejected"
+	// ActionImpersonatedRequest is recorded for every request made with an
+	// impersonation token, whether or not it is let through.
+	ActionImpersonatedRequest = "auth.impersonated_request"
+)
+
+// Authentication methods recorded in the "auth_method" context value.
+const (
+	AuthMethodJWT    = "jwt"
+	AuthMethodAPIKey = "api_key"
+)
+
+// WithRevocationStore rejects tokens that were revoked before they expired.
+func WithRevocationStore(store RevocationStore) MiddlewareOption {
+	return func(cfg *middlewareConfig) {
+		cfg.revocations = store
+	}
+}
+
+// WithAPIKeyAuthenticator also accepts API keys as bearer credentials. Requests made with
+// a key get the same user_id and role as a JWT would, plus the key's scopes.
+func WithAPIKeyAuthenticator(authenticator APIKeyAuthenticator) MiddlewareOption {
+	return func(cfg *middlewareConfig) {
+		cfg.apiKeys = authenticator
+	}
+}
+
+// WithAuditLogger records every rejected credential in the audit log.
+func WithAuditLogger(logger audit.AuditLogger) MiddlewareOption {
+	return func(cfg *middlewareConfig) {
+		cfg.audit = logger
+	}
+}
+
+// WithAccessTokenCookie also accepts the access token from the cookie SessionCookies
+// sets, for requests without an Authorization header. Routes must then be guarded by
+// the CSRF middleware.
+func WithAccessTokenCookie() MiddlewareOption {
+	return func(cfg *middlewareConfig) {
+		cfg.cookies = true
+	}
+}
+
+// ExtractBearerToken returns the token from an "Authorization: Bearer <token>" header.
+func ExtractBearerToken(r *http.Request) (string, bool) {
+	parts := strings.Split(r.Header.Get("Authorization"), " ")
+	if len(parts) != 2 || parts[0] != "Bearer" || parts[1] == "" {
+		return "", false
+	}
+	return parts[1], true
+}
+
+func BearerAuth(jwtSvc JWTService, opts ...MiddlewareOption) echo.MiddlewareFunc {
+	cfg := &middlewareConfig{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c *echo.Context) error {
-			authHeader := c.Request().Header.Get("Authorization")
-			if authHeader == "" {
-				return response.Unauthorized(c, "missing authorization header")
+			var token string
+			if c.Request().Header.Get("Authorization") != "" {
+				var ok bool
+				if token, ok = ExtractBearerToken(c.Request()); !ok {
+					return cfg.reject(c, "", "invalid token format")
+				}
+			} else if cfg.cookies {
+				token, _ = AccessTokenFromCookie(c.Request())
 			}
-
-			parts := strings.Split(authHeader, " ")
-			if len(parts) != 2 || parts[0] != "Bearer" {
-				return response.Unauthorized(c, "invalid token format")
+			if token == "" {
+				return cfg.reject(c, "", "missing authorization header")
 			}
 
-			token := parts[1]
+			if cfg.apiKeys != nil && IsAPIKey(token) {
+				return authenticateAPIKey(c, next, cfg, token)
+			}
 
 			claims, err := jwtSvc.ValidateToken(token)
 			if err != nil {
-				return response.Unauthorized(c, "invalid or expired token")
+				return cfg.reject(c, "", "invalid or expired token")
 			}
 
+			if cfg.revocations != nil {
+				revoked, err := cfg.revocations.IsRevoked(c.Request().Context(), claims)
+				if err != nil {
+					c.Logger().Error("failed to check token revocation", "error", err)
+					return response.InternalServerError(c, "failed to verify token")
+				}
+				if revoked {
+					return cfg.reject(c, claims.UserID, "token has been revoked")
+				}
+			}
+
+			setActor(c, claims.UserID)
 			c.Set("user_id", claims.UserID)
+			c.Set("role", string(claims.Role))
+			if scopes, ok := claims.Scopes(); ok {
+				c.Set("scopes", scopes)
+			}
+			c.Set("auth_method", AuthMethodJWT)
+
+			if claims.Act != nil {
+				return impersonate(c, next, cfg, claims)
+			}
 
 			return next(c)
 		}
 	}
 }
+
+func authenticateAPIKey(c *echo.Context, next echo.HandlerFunc, cfg *middlewareConfig, key string) error {
+	principal, err := cfg.apiKeys.AuthenticateAPIKey(c.Request().Context(), key)
+	if err != nil {
+		if errors.Is(err, ErrInvalidAPIKey) {
+			return cfg.reject(c, "", err.Error())
+		}
+		c.Logger().Error("failed to authenticate API key", "error", err)
+		return response.InternalServerError(c, "failed to verify API key")
+	}
+
+	setActor(c, principal.UserID)
+	c.Set("user_id", principal.UserID)
+	c.Set("role", string(principal.Role))
+	c.Set("scopes", principal.Scopes)
+	c.Set("auth_method", AuthMethodAPIKey)
+
+	return next(c)
+}
+
+// impersonate lets a request made with an impersonation token through as the subject,
+// recording the admin behind it on the context and in the audit log. Read-only tokens
+// are refused anything but safe methods.
+func impersonate(c *echo.Context, next echo.HandlerFunc, cfg *middlewareConfig, claims *Claims) error {
+	req := c.Request()
+	c.SetRequest(req.WithContext(audit.WithImpersonatorID(req.Context(), claims.Act.Subject)))
+	c.Set("impersonator_id", claims.Act.Subject)
+	c.Set("read_only", claims.ReadOnly)
+
+	allowed := !claims.ReadOnly || isSafeMethod(req.Method)
+	if cfg.audit != nil {
+		outcome := audit.OutcomeSuccess
+		if !allowed {
+			outcome = audit.OutcomeDenied
+		}
+		cfg.audit.Log(c.Request().Context(), audit.Event{
+			Action:     ActionImpersonatedRequest,
+			TargetType: "user",
+			TargetID:   claims.UserID,
+			Outcome:    outcome,
+			Metadata:   map[string]any{"method": req.Method, "path": req.URL.Path, "read_only": claims.ReadOnly},
+		})
+	}
+
+	if !allowed {
+		return response.Forbidden(c, "impersonation session is read-only")
+	}
+	return next(c)
+}
+
+func isSafeMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+// setActor attributes audit events logged while handling the request to userID.
+func setActor(c *echo.Context, userID string) {
+	req := c.Request()
+	c.SetRequest(req.WithContext(audit.WithActorID(req.Context(), userID)))
+}
+
+// ImpersonatorIDFromContext returns the admin behind an impersonated request. ok is false
+// when the user is acting for themselves.
+func ImpersonatorIDFromContext(c *echo.Context) (impersonatorID string, ok bool) {
+	impersonatorID, ok = c.Get("impersonator_id").(string)
+	return impersonatorID, ok
+}
+
+// UserIDFromContext returns the id of the user BearerAuth authenticated.
+func UserIDFromContext(c *echo.Context) (uuid.UUID, error) {
+	userID, ok := c.Get("user_id").(string)
+	if !ok {
+		return uuid.Nil, errors.New("missing user id in context")
+	}
+	return uuid.Parse(userID)
+}
+
+// AuthMethodFromContext returns how BearerAuth authenticated the request.
+func AuthMethodFromContext(c *echo.Context) string {
+	method, _ := c.Get("auth_method").(string)
+	return method
+}
@@ -0,0 +1,43 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+)
+
+// opaqueTokenBytes is the amount of randomness in refresh tokens and other bearer secrets (256 bits).
+const opaqueTokenBytes = 32
+
+// GenerateOpaqueToken returns a URL-safe random token carrying opaqueTokenBytes of entropy.
+func GenerateOpaqueToken() (string, error) {
+	b := make([]byte, opaqueTokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// TokenHasher derives the value under which an opaque token is stored,
+// so a database dump does not contain usable credentials.
+type TokenHasher interface {
+	Hash(token string) string
+}
+
+type hmacTokenHasher struct {
+	pepper []byte
+}
+
+// NewHMACTokenHasher returns a TokenHasher computing hex-encoded HMAC-SHA256
+// keyed with a server-side pepper that never touches the database.
+func NewHMACTokenHasher(pepper string) TokenHasher {
+	return &hmacTokenHasher{pepper: []byte(pepper)}
+}
+
+func (h *hmacTokenHasher) Hash(token string) string {
+	mac := hmac.New(sha256.New, h.pepper)
+	mac.Write([]byte(token))
+	return hex.EncodeToString(mac.Sum(nil))
+}
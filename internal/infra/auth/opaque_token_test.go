@@ -0,0 +1,36 @@
+package auth
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerateOpaqueToken(t *testing.T) {
+	// Act
+	first, err := GenerateOpaqueToken()
+	assert.NoError(t, err)
+	second, err := GenerateOpaqueToken()
+	assert.NoError(t, err)
+
+	// Assert
+	decoded, err := base64.RawURLEncoding.DecodeString(first)
+	assert.NoError(t, err)
+	assert.Len(t, decoded, opaqueTokenBytes)
+	assert.NotEqual(t, first, second)
+}
+
+func TestHMACTokenHasher(t *testing.T) {
+	// Arrange
+	hasher := NewHMACTokenHasher("pepper")
+
+	// Act
+	hash := hasher.Hash("token")
+
+	// Assert
+	assert.Len(t, hash, 64)
+	assert.Equal(t, hash, hasher.Hash("token"))
+	assert.NotEqual(t, hash, hasher.Hash("other-token"))
+	assert.NotEqual(t, hash, NewHMACTokenHasher("other-pepper").Hash("token"))
+}
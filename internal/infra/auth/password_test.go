@@ -0,0 +1,37 @@
+package auth
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestBcryptHasher_HashAndCompare(t *testing.T) {
+	// Arrange
+	hasher := NewBcryptHasher(bcrypt.MinCost)
+
+	// Act
+	hash, err := hasher.Hash("correct horse battery staple")
+
+	// Assert
+	assert.NoError(t, err)
+	assert.NotEqual(t, "correct horse battery staple", hash)
+	assert.NoError(t, hasher.Compare(hash, "correct horse battery staple"))
+	assert.Equal(t, ErrPasswordMismatch, hasher.Compare(hash, "wrong password"))
+}
+
+func TestBcryptHasher_InvalidCostFallsBackToDefault(t *testing.T) {
+	// Arrange
+	hasher := NewBcryptHasher(100)
+
+	// Act
+	hash, err := hasher.Hash("secret")
+	assert.NoError(t, err)
+
+	cost, err := bcrypt.Cost([]byte(hash))
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, bcrypt.DefaultCost, cost)
+}
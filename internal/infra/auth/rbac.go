@@ -0,0 +1,143 @@
+package auth
+
+import (
+	"go-boilerplate/pkg/response"
+	"slices"
+	"strings"
+
+	"github.com/labstack/echo/v5"
+)
+
+// Role is the coarse-grained access level assigned to a user.
+type Role string
+
+const (
+	RoleAdmin    Role = "admin"
+	RoleUser     Role = "user"
+	RoleReadOnly Role = "readonly"
+)
+
+// Permission is a single action a role may perform, named resource:action.
+type Permission string
+
+const (
+	PermPortfoliosRead  Permission = "portfolios:read"
+	PermPortfoliosWrite Permission = "portfolios:write"
+	PermUsersManage     Permission = "users:manage"
+	// PermPricesWrite records market prices, which value every user's holdings.
+	PermPricesWrite Permission = "prices:write"
+)
+
+var rolePermissions = map[Role][]Permission{
+	RoleAdmin:    {PermPortfoliosRead, PermPortfoliosWrite, PermUsersManage, PermPricesWrite},
+	RoleUser:     {PermPortfoliosRead, PermPortfoliosWrite},
+	RoleReadOnly: {PermPortfoliosRead},
+}
+
+// Valid reports whether r is one of the known roles.
+func (r Role) Valid() bool {
+	_, ok := rolePermissions[r]
+	return ok
+}
+
+// Valid reports whether p is granted by any role, and so may be asked for as a scope.
+func (p Permission) Valid() bool {
+	for _, perms := range rolePermissions {
+		if slices.Contains(perms, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// Can reports whether the role grants the permission.
+func (r Role) Can(p Permission) bool {
+	return slices.Contains(rolePermissions[r], p)
+}
+
+// Permissions returns the permissions granted to the role.
+func (r Role) Permissions() []Permission {
+	return slices.Clone(rolePermissions[r])
+}
+
+// FormatScope joins permissions into an OAuth style space-separated scope string.
+func FormatScope(scopes []Permission) string {
+	names := make([]string, len(scopes))
+	for i, scope := range scopes {
+		names[i] = string(scope)
+	}
+	return strings.Join(names, " ")
+}
+
+// ParseScope splits a space-separated scope string into permissions.
+func ParseScope(scope string) []Permission {
+	fields := strings.Fields(scope)
+	scopes := make([]Permission, len(fields))
+	for i, field := range fields {
+		scopes[i] = Permission(field)
+	}
+	return scopes
+}
+
+// RoleFromContext returns the role BearerAuth stored for the authenticated user.
+func RoleFromContext(c *echo.Context) Role {
+	role, _ := c.Get("role").(string)
+	return Role(role)
+}
+
+// ScopesFromContext returns the scopes the credential was restricted to. ok is false when
+// the credential is not scoped and carries every permission of the user's role.
+func ScopesFromContext(c *echo.Context) (scopes []Permission, ok bool) {
+	scopes, ok = c.Get("scopes").([]Permission)
+	return scopes, ok
+}
+
+// RequireRole allows the request through only if the user has one of the given roles.
+// It must run after BearerAuth.
+func RequireRole(roles ...Role) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c *echo.Context) error {
+			if !slices.Contains(roles, RoleFromContext(c)) {
+				return response.Forbidden(c, "insufficient role")
+			}
+			return next(c)
+		}
+	}
+}
+
+// RequirePermission allows the request through only if the user's role grants every
+// given permission and, for scoped credentials such as API keys and scoped access
+// tokens, the scopes include it. It must run after BearerAuth.
+func RequirePermission(perms ...Permission) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c *echo.Context) error {
+			role := RoleFromContext(c)
+			scopes, scoped := ScopesFromContext(c)
+			for _, perm := range perms {
+				if !role.Can(perm) {
+					return response.Forbidden(c, "missing permission: "+string(perm))
+				}
+				if scoped && !slices.Contains(scopes, perm) {
+					return response.Forbidden(c, "missing scope: "+string(perm))
+				}
+			}
+			return next(c)
+		}
+	}
+}
+
+// RequireFullAccess only lets through requests authenticated by an unscoped login,
+// rejecting API keys, access tokens issued with reduced scopes and impersonation tokens.
+// It guards endpoints that manage credentials or the account itself, so a leaked or
+// borrowed credential cannot entrench itself. It must run after BearerAuth.
+func RequireFullAccess(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c *echo.Context) error {
+		if _, scoped := ScopesFromContext(c); scoped {
+			return response.Forbidden(c, "this endpoint requires a login without reduced scopes")
+		}
+		if _, impersonated := ImpersonatorIDFromContext(c); impersonated {
+			return response.Forbidden(c, "this endpoint is not available while impersonating")
+		}
+		return next(c)
+	}
+}
@@ -0,0 +1,79 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func serveWithRole(role Role, middleware echo.MiddlewareFunc) int {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	if role != "" {
+		c.Set("role", string(role))
+	}
+
+	handler := middleware(func(c *echo.Context) error {
+		return c.NoContent(http.StatusNoContent)
+	})
+	_ = handler(c)
+
+	return rec.Code
+}
+
+func TestRequireRole(t *testing.T) {
+	tests := []struct {
+		name     string
+		role     Role
+		allowed  []Role
+		wantCode int
+	}{
+		{"admin allowed", RoleAdmin, []Role{RoleAdmin}, http.StatusNoContent},
+		{"user denied", RoleUser, []Role{RoleAdmin}, http.StatusForbidden},
+		{"one of several", RoleUser, []Role{RoleAdmin, RoleUser}, http.StatusNoContent},
+		{"missing role denied", "", []Role{RoleUser}, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.wantCode, serveWithRole(tt.role, RequireRole(tt.allowed...)))
+		})
+	}
+}
+
+func TestPermission_Valid(t *testing.T) {
+	for _, role := range []Role{RoleAdmin, RoleUser, RoleReadOnly} {
+		for _, perm := range role.Permissions() {
+			assert.True(t, perm.Valid(), perm)
+		}
+	}
+	assert.False(t, Permission("portfolios:delete").Valid())
+	assert.False(t, Permission("").Valid())
+}
+
+func TestRequirePermission(t *testing.T) {
+	tests := []struct {
+		name     string
+		role     Role
+		perms    []Permission
+		wantCode int
+	}{
+		{"read-only can read", RoleReadOnly, []Permission{PermPortfoliosRead}, http.StatusNoContent},
+		{"read-only cannot write", RoleReadOnly, []Permission{PermPortfoliosWrite}, http.StatusForbidden},
+		{"user can write", RoleUser, []Permission{PermPortfoliosWrite}, http.StatusNoContent},
+		{"user cannot manage users", RoleUser, []Permission{PermUsersManage}, http.StatusForbidden},
+		{"admin has all", RoleAdmin, []Permission{PermPortfoliosWrite, PermUsersManage}, http.StatusNoContent},
+		{"unknown role denied", Role("guest"), []Permission{PermPortfoliosRead}, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.wantCode, serveWithRole(tt.role, RequirePermission(tt.perms...)))
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package auth
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// RevocationStore records access tokens that must be rejected before they expire.
+// Individual tokens are revoked by jti and those of one login session by its sid;
+// revoking a user invalidates every token issued to them up to the moment of revocation.
+type RevocationStore interface {
+	RevokeToken(ctx context.Context, jti string, expiresAt time.Time) error
+	RevokeSession(ctx context.Context, sessionID string, expiresAt time.Time) error
+	RevokeUser(ctx context.Context, userID string, issuedBefore, expiresAt time.Time) error
+	IsRevoked(ctx context.Context, claims *Claims) (bool, error)
+	PurgeExpired(ctx context.Context) error
+}
+
+// isRevokedBy reports whether a token issued at issuedAt falls under a user-wide revocation.
+// Tokens carry second precision, so a token from the same second as the cut-off is revoked.
+func isRevokedBy(issuedAt, revokedBefore time.Time) bool {
+	return !issuedAt.After(revokedBefore.Truncate(time.Second))
+}
+
+type userRevocation struct {
+	issuedBefore time.Time
+	expiresAt    time.Time
+}
+
+type memoryRevocationStore struct {
+	mu       sync.RWMutex
+	tokens   map[string]time.Time
+	sessions map[string]time.Time
+	users    map[string]userRevocation
+	now      func() time.Time
+}
+
+// NewMemoryRevocationStore returns a process-local RevocationStore. Entries are dropped once
+// the tokens they cover have expired. It is only suitable for single-instance deployments.
+func NewMemoryRevocationStore() RevocationStore {
+	return &memoryRevocationStore{
+		tokens:   make(map[string]time.Time),
+		sessions: make(map[string]time.Time),
+		users:    make(map[string]userRevocation),
+		now:      time.Now,
+	}
+}
+
+func (s *memoryRevocationStore) RevokeToken(_ context.Context, jti string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tokens[jti] = expiresAt
+	return nil
+}
+
+func (s *memoryRevocationStore) RevokeSession(_ context.Context, sessionID string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if existing, ok := s.sessions[sessionID]; !ok || expiresAt.After(existing) {
+		s.sessions[sessionID] = expiresAt
+	}
+	return nil
+}
+
+func (s *memoryRevocationStore) RevokeUser(_ context.Context, userID string, issuedBefore, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if existing, ok := s.users[userID]; ok && existing.issuedBefore.After(issuedBefore) {
+		issuedBefore = existing.issuedBefore
+	}
+	s.users[userID] = userRevocation{issuedBefore: issuedBefore, expiresAt: expiresAt}
+	return nil
+}
+
+func (s *memoryRevocationStore) IsRevoked(_ context.Context, claims *Claims) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	now := s.now()
+
+	if expiresAt, ok := s.tokens[claims.ID]; ok && now.Before(expiresAt) {
+		return true, nil
+	}
+	if expiresAt, ok := s.sessions[claims.SessionID]; ok && claims.SessionID != "" && now.Before(expiresAt) {
+		return true, nil
+	}
+
+	if revocation, ok := s.users[claims.UserID]; ok && now.Before(revocation.expiresAt) && claims.IssuedAt != nil {
+		return isRevokedBy(claims.IssuedAt.Time, revocation.issuedBefore), nil
+	}
+
+	return false, nil
+}
+
+func (s *memoryRevocationStore) PurgeExpired(_ context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	for jti, expiresAt := range s.tokens {
+		if !now.Before(expiresAt) {
+			delete(s.tokens, jti)
+		}
+	}
+	for sessionID, expiresAt := range s.sessions {
+		if !now.Before(expiresAt) {
+			delete(s.sessions, sessionID)
+		}
+	}
+	for userID, revocation := range s.users {
+		if !now.Before(revocation.expiresAt) {
+			delete(s.users, userID)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RevokedToken is a single revoked access token, kept until the token would have expired.
+type RevokedToken struct {
+	JTI       string    `gorm:"primaryKey;type:varchar(64)"`
+	ExpiresAt time.Time `gorm:"index;not null"`
+	CreatedAt time.Time
+}
+
+func (RevokedToken) TableName() string {
+	return "revoked_tokens"
+}
+
+// RevokedSession invalidates every access token issued to a login session, kept until
+// the last of them would have expired.
+type RevokedSession struct {
+	SessionID string    `gorm:"primaryKey;type:varchar(64)"`
+	ExpiresAt time.Time `gorm:"index;not null"`
+	CreatedAt time.Time
+}
+
+func (RevokedSession) TableName() string {
+	return "revoked_sessions"
+}
+
+// RevokedUser invalidates every access token issued to a user before IssuedBefore.
+type RevokedUser struct {
+	UserID       string    `gorm:"primaryKey;type:varchar(64)"`
+	IssuedBefore time.Time `gorm:"not null"`
+	ExpiresAt    time.Time `gorm:"index;not null"`
+	UpdatedAt    time.Time
+}
+
+func (RevokedUser) TableName() string {
+	return "revoked_users"
+}
+
+type postgresRevocationStore struct {
+	db *gorm.DB
+}
+
+// NewPostgresRevocationStore returns a RevocationStore shared by every instance using the database.
+// Its tables are created by MigrateRevocationStore.
+func NewPostgresRevocationStore(db *gorm.DB) RevocationStore {
+	return &postgresRevocationStore{db: db}
+}
+
+// MigrateRevocationStore creates the tables used by the Postgres revocation store.
+func MigrateRevocationStore(db *gorm.DB) error {
+	return db.AutoMigrate(&RevokedToken{}, &RevokedSession{}, &RevokedUser{})
+}
+
+func (s *postgresRevocationStore) RevokeToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	return s.db.WithContext(ctx).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&RevokedToken{JTI: jti, ExpiresAt: expiresAt}).Error
+}
+
+func (s *postgresRevocationStore) RevokeSession(ctx context.Context, sessionID string, expiresAt time.Time) error {
+	return s.db.WithContext(ctx).
+		Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "session_id"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{
+				"expires_at": gorm.Expr("GREATEST(revoked_sessions.expires_at, EXCLUDED.expires_at)"),
+			}),
+		}).
+		Create(&RevokedSession{SessionID: sessionID, ExpiresAt: expiresAt}).Error
+}
+
+func (s *postgresRevocationStore) RevokeUser(ctx context.Context, userID string, issuedBefore, expiresAt time.Time) error {
+	return s.db.WithContext(ctx).
+		Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "user_id"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{
+				"issued_before": gorm.Expr("GREATEST(revoked_users.issued_before, EXCLUDED.issued_before)"),
+				"expires_at":    gorm.Expr("GREATEST(revoked_users.expires_at, EXCLUDED.expires_at)"),
+				"updated_at":    time.Now(),
+			}),
+		}).
+		Create(&RevokedUser{UserID: userID, IssuedBefore: issuedBefore, ExpiresAt: expiresAt}).Error
+}
+
+func (s *postgresRevocationStore) IsRevoked(ctx context.Context, claims *Claims) (bool, error) {
+	now := time.Now()
+
+	var count int64
+	err := s.db.WithContext(ctx).
+		Model(&RevokedToken{}).
+		Where("jti = ? AND expires_at > ?", claims.ID, now).
+		Count(&count).Error
+	if err != nil {
+		return false, err
+	}
+	if count > 0 {
+		return true, nil
+	}
+
+	if claims.SessionID != "" {
+		err := s.db.WithContext(ctx).
+			Model(&RevokedSession{}).
+			Where("session_id = ? AND expires_at > ?", claims.SessionID, now).
+			Count(&count).Error
+		if err != nil {
+			return false, err
+		}
+		if count > 0 {
+			return true, nil
+		}
+	}
+
+	if claims.IssuedAt == nil {
+		return false, nil
+	}
+
+	var revocation RevokedUser
+	err = s.db.WithContext(ctx).
+		Where("user_id = ? AND expires_at > ?", claims.UserID, now).
+		First(&revocation).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return isRevokedBy(claims.IssuedAt.Time, revocation.IssuedBefore), nil
+}
+
+func (s *postgresRevocationStore) PurgeExpired(ctx context.Context) error {
+	now := time.Now()
+	if err := s.db.WithContext(ctx).Where("expires_at <= ?", now).Delete(&RevokedToken{}).Error; err != nil {
+		return err
+	}
+	if err := s.db.WithContext(ctx).Where("expires_at <= ?", now).Delete(&RevokedSession{}).Error; err != nil {
+		return err
+	}
+	return s.db.WithContext(ctx).Where("expires_at <= ?", now).Delete(&RevokedUser{}).Error
+}
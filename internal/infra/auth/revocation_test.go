@@ -0,0 +1,242 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go-boilerplate/internal/infra/audit"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryRevocationStore_RevokeToken(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	store := NewMemoryRevocationStore().(*memoryRevocationStore)
+	now := time.Now()
+	store.now = func() time.Time { return now }
+
+	claims := &Claims{RegisteredClaims: jwt.RegisteredClaims{ID: "jti-1"}}
+
+	// Act
+	require.NoError(t, store.RevokeToken(ctx, "jti-1", now.Add(time.Minute)))
+
+	// Assert
+	revoked, err := store.IsRevoked(ctx, claims)
+	assert.NoError(t, err)
+	assert.True(t, revoked)
+
+	// Entries are evicted once the token has expired
+	now = now.Add(2 * time.Minute)
+	require.NoError(t, store.PurgeExpired(ctx))
+	assert.Empty(t, store.tokens)
+}
+
+func TestMemoryRevocationStore_RevokeUser(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	store := NewMemoryRevocationStore()
+	cutoff := time.Now()
+
+	before := &Claims{UserID: "user-1", RegisteredClaims: jwt.RegisteredClaims{
+		IssuedAt: jwt.NewNumericDate(cutoff.Add(-time.Minute)),
+	}}
+	after := &Claims{UserID: "user-1", RegisteredClaims: jwt.RegisteredClaims{
+		IssuedAt: jwt.NewNumericDate(cutoff.Add(time.Minute)),
+	}}
+	otherUser := &Claims{UserID: "user-2", RegisteredClaims: jwt.RegisteredClaims{
+		IssuedAt: jwt.NewNumericDate(cutoff.Add(-time.Minute)),
+	}}
+
+	// Act
+	require.NoError(t, store.RevokeUser(ctx, "user-1", cutoff, cutoff.Add(time.Hour)))
+
+	// Assert
+	revoked, _ := store.IsRevoked(ctx, before)
+	assert.True(t, revoked)
+	revoked, _ = store.IsRevoked(ctx, after)
+	assert.False(t, revoked)
+	revoked, _ = store.IsRevoked(ctx, otherUser)
+	assert.False(t, revoked)
+}
+
+func TestMemoryRevocationStore_RevokeSession(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	store := NewMemoryRevocationStore().(*memoryRevocationStore)
+	now := time.Now()
+	store.now = func() time.Time { return now }
+
+	session := &Claims{SessionID: "session-1", RegisteredClaims: jwt.RegisteredClaims{ID: "jti-1"}}
+	otherSession := &Claims{SessionID: "session-2", RegisteredClaims: jwt.RegisteredClaims{ID: "jti-2"}}
+	noSession := &Claims{RegisteredClaims: jwt.RegisteredClaims{ID: "jti-3"}}
+
+	// Act
+	require.NoError(t, store.RevokeSession(ctx, "session-1", now.Add(time.Minute)))
+
+	// Assert
+	revoked, _ := store.IsRevoked(ctx, session)
+	assert.True(t, revoked)
+	revoked, _ = store.IsRevoked(ctx, otherSession)
+	assert.False(t, revoked)
+	revoked, _ = store.IsRevoked(ctx, noSession)
+	assert.False(t, revoked)
+
+	now = now.Add(2 * time.Minute)
+	require.NoError(t, store.PurgeExpired(ctx))
+	assert.Empty(t, store.sessions)
+}
+
+func TestBearerAuth_RejectsRevokedToken(t *testing.T) {
+	// Arrange
+	svc := NewJWTService("secret", 1)
+	store := NewMemoryRevocationStore()
+	token, err := svc.GenerateToken(Subject{UserID: "user-1", Role: RoleUser})
+	require.NoError(t, err)
+	claims, err := svc.ValidateToken(token)
+	require.NoError(t, err)
+
+	serve := func() int {
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		handler := BearerAuth(svc, WithRevocationStore(store))(func(c *echo.Context) error {
+			return c.NoContent(http.StatusNoContent)
+		})
+		_ = handler(e.NewContext(req, rec))
+		return rec.Code
+	}
+
+	// Act & Assert
+	assert.Equal(t, http.StatusNoContent, serve())
+
+	require.NoError(t, store.RevokeToken(context.Background(), claims.ID, claims.ExpiresAt.Time))
+	assert.Equal(t, http.StatusUnauthorized, serve())
+}
+
+func TestBearerAuth_AuditsRejections(t *testing.T) {
+	// Arrange
+	svc := NewJWTService("secret", 1)
+	store := NewMemoryRevocationStore()
+	logger := audit.NewMemoryLogger()
+	token, err := svc.GenerateToken(Subject{UserID: "user-1", Role: RoleUser})
+	require.NoError(t, err)
+	claims, err := svc.ValidateToken(token)
+	require.NoError(t, err)
+	require.NoError(t, store.RevokeToken(context.Background(), claims.ID, claims.ExpiresAt.Time))
+
+	serve := func(authorization string) int {
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodGet, "/portfolios", nil)
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		rec := httptest.NewRecorder()
+		handler := BearerAuth(svc, WithRevocationStore(store), WithAuditLogger(logger))(func(c *echo.Context) error {
+			return c.NoContent(http.StatusNoContent)
+		})
+		_ = handler(e.NewContext(req, rec))
+		return rec.Code
+	}
+
+	// Act
+	missingCode := serve("")
+	revokedCode := serve("Bearer " + token)
+
+	// Assert
+	assert.Equal(t, http.StatusUnauthorized, missingCode)
+	assert.Equal(t, http.StatusUnauthorized, revokedCode)
+	events := logger.Events()
+	require.Len(t, events, 2)
+	assert.Equal(t, ActionTokenRejected, events[0].Action)
+	assert.Equal(t, audit.OutcomeFailure, events[0].Outcome)
+	assert.Equal(t, "missing authorization header", events[0].Metadata["reason"])
+	assert.Empty(t, events[0].ActorID)
+	assert.Equal(t, "user-1", events[1].ActorID)
+	assert.Equal(t, "token has been revoked", events[1].Metadata["reason"])
+	assert.Equal(t, "/portfolios", events[1].Metadata["path"])
+}
+
+func TestBearerAuth_AttributesAuditEventsToUser(t *testing.T) {
+	// Arrange
+	svc := NewJWTService("secret", 1)
+	token, err := svc.GenerateToken(Subject{UserID: "user-1", Role: RoleUser})
+	require.NoError(t, err)
+
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+
+	var actorID string
+	handler := BearerAuth(svc)(func(c *echo.Context) error {
+		actorID = audit.ActorIDFromContext(c.Request().Context())
+		return c.NoContent(http.StatusNoContent)
+	})
+
+	// Act
+	_ = handler(e.NewContext(req, rec))
+
+	// Assert
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Equal(t, "user-1", actorID)
+}
+
+func TestBearerAuth_ImpersonationToken(t *testing.T) {
+	// Arrange
+	svc := NewJWTService("secret", 1)
+	logger := audit.NewMemoryLogger()
+	subject := Subject{UserID: "user-1", Role: RoleUser}
+	readOnlyToken, err := svc.GenerateImpersonationToken(subject, "admin-1", true, time.Minute)
+	require.NoError(t, err)
+	writableToken, err := svc.GenerateImpersonationToken(subject, "admin-1", false, time.Minute)
+	require.NoError(t, err)
+
+	serve := func(method, token string) (int, *echo.Context, string) {
+		e := echo.New()
+		req := httptest.NewRequest(method, "/crypto-api/portfolios", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		var auditImpersonator string
+		handler := BearerAuth(svc, WithAuditLogger(logger))(func(c *echo.Context) error {
+			auditImpersonator = audit.ImpersonatorIDFromContext(c.Request().Context())
+			return c.NoContent(http.StatusNoContent)
+		})
+		_ = handler(c)
+		return rec.Code, c, auditImpersonator
+	}
+
+	// Act
+	readCode, c, auditImpersonator := serve(http.MethodGet, readOnlyToken)
+	blockedCode, _, _ := serve(http.MethodPost, readOnlyToken)
+	writeCode, _, _ := serve(http.MethodPost, writableToken)
+
+	// Assert
+	assert.Equal(t, http.StatusNoContent, readCode)
+	assert.Equal(t, "user-1", c.Get("user_id"))
+	impersonatorID, ok := ImpersonatorIDFromContext(c)
+	assert.True(t, ok)
+	assert.Equal(t, "admin-1", impersonatorID)
+	assert.Equal(t, "admin-1", auditImpersonator)
+	assert.Equal(t, http.StatusForbidden, blockedCode)
+	assert.Equal(t, http.StatusNoContent, writeCode)
+
+	events := logger.Events()
+	require.Len(t, events, 3)
+	for _, event := range events {
+		assert.Equal(t, ActionImpersonatedRequest, event.Action)
+		assert.Equal(t, "user-1", event.ActorID)
+		assert.Equal(t, "admin-1", event.ImpersonatorID)
+	}
+	assert.Equal(t, audit.OutcomeSuccess, events[0].Outcome)
+	assert.Equal(t, audit.OutcomeDenied, events[1].Outcome)
+	assert.Equal(t, audit.OutcomeSuccess, events[2].Outcome)
+}
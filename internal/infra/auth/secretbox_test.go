@@ -0,0 +1,36 @@
+package auth
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAESGCMSecretBox_RoundTrip(t *testing.T) {
+	// Arrange
+	box, err := NewAESGCMSecretBox("key-material")
+	assert.NoError(t, err)
+
+	// Act
+	sealed, err := box.Seal("JBSWY3DPEHPK3PXP")
+	assert.NoError(t, err)
+	opened, err := box.Open(sealed)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, "JBSWY3DPEHPK3PXP", opened)
+	assert.NotContains(t, sealed, "JBSWY3DPEHPK3PXP")
+}
+
+func TestAESGCMSecretBox_WrongKey(t *testing.T) {
+	// Arrange
+	box, _ := NewAESGCMSecretBox("key-material")
+	other, _ := NewAESGCMSecretBox("other-key-material")
+	sealed, _ := box.Seal("secret")
+
+	// Act
+	_, err := other.Open(sealed)
+
+	// Assert
+	assert.ErrorIs(t, err, ErrDecryptionFailed)
+}
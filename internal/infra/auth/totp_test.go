@@ -0,0 +1,65 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHOTP_RFC6238Vectors(t *testing.T) {
+	// SHA1 test vectors from RFC 6238 Appendix B.
+	key := []byte("12345678901234567890")
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{unix: 59, want: "94287082"},
+		{unix: 1111111109, want: "07081804"},
+		{unix: 1111111111, want: "14050471"},
+		{unix: 1234567890, want: "89005924"},
+		{unix: 2000000000, want: "69279037"},
+		{unix: 20000000000, want: "65353130"},
+	}
+
+	for _, tt := range tests {
+		step := TOTPStep(time.Unix(tt.unix, 0))
+		assert.Equal(t, tt.want, hotp(key, uint64(step), 8), "time %d", tt.unix)
+	}
+}
+
+func TestValidateTOTPCode(t *testing.T) {
+	// Arrange
+	secret, err := GenerateTOTPSecret()
+	assert.NoError(t, err)
+	now := time.Unix(1700000000, 0)
+	previous, err := GenerateTOTPCode(secret, now.Add(-totpPeriod))
+	assert.NoError(t, err)
+	stale, err := GenerateTOTPCode(secret, now.Add(-3*totpPeriod))
+	assert.NoError(t, err)
+
+	// Act
+	step, ok, err := ValidateTOTPCode(secret, previous, now, 1)
+	_, staleOK, _ := ValidateTOTPCode(secret, stale, now, 1)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, TOTPStep(now)-1, step)
+	assert.False(t, staleOK)
+}
+
+func TestValidateTOTPCode_InvalidSecret(t *testing.T) {
+	_, _, err := ValidateTOTPCode("not base32!", "123456", time.Now(), 1)
+	assert.ErrorIs(t, err, ErrInvalidTOTPSecret)
+}
+
+func TestTOTPProvisioningURI(t *testing.T) {
+	uri := TOTPProvisioningURI("Go Boilerplate", "alice", "JBSWY3DPEHPK3PXP")
+
+	assert.True(t, strings.HasPrefix(uri, "otpauth://totp/Go%20Boilerplate:alice?"))
+	assert.Contains(t, uri, "secret=JBSWY3DPEHPK3PXP")
+	assert.Contains(t, uri, "issuer=Go+Boilerplate")
+	assert.Contains(t, uri, "digits=6")
+}
@@ -0,0 +1,63 @@
+package mail
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryMailer(t *testing.T) {
+	// Arrange
+	mailer := NewMemoryMailer()
+	msg := Message{To: "alice@example.com", Subject: "Hello", Body: "Hi Alice"}
+
+	// Act
+	err := mailer.Send(context.Background(), msg)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, []Message{msg}, mailer.Sent())
+}
+
+func TestFileMailer(t *testing.T) {
+	// Arrange
+	dir := t.TempDir()
+	mailer := NewFileMailer(dir, "noreply@example.com")
+
+	// Act
+	err := mailer.Send(context.Background(), Message{
+		To:      "alice@example.com\r\nBcc: mallory@example.com",
+		Subject: "Reset your password",
+		Body:    "line one\nline two",
+	})
+
+	// Assert
+	assert.NoError(t, err)
+	files, _ := filepath.Glob(filepath.Join(dir, "*.eml"))
+	assert.Len(t, files, 1)
+	content, _ := os.ReadFile(files[0])
+	assert.Contains(t, string(content), "From: noreply@example.com\r\n")
+	assert.Contains(t, string(content), "To: alice@example.comBcc: mallory@example.com\r\n")
+	assert.NotContains(t, string(content), "\r\nBcc:")
+	assert.True(t, strings.HasSuffix(string(content), "line one\r\nline two"))
+}
+
+func TestRender(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	out := string(render("from@example.com", Message{To: "to@example.com", Subject: "Hi", Body: "Body"}, now))
+
+	assert.Equal(t, "From: from@example.com\r\n"+
+		"To: to@example.com\r\n"+
+		"Subject: Hi\r\n"+
+		"Date: Fri, 02 Jan 2026 03:04:05 +0000\r\n"+
+		"MIME-Version: 1.0\r\n"+
+		"Content-Type: text/plain; charset=UTF-8\r\n"+
+		"\r\n"+
+		"Body", out)
+}
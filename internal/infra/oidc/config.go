@@ -0,0 +1,44 @@
+package oidc
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// ProviderConfig describes an OpenID Connect provider the API accepts logins from.
+type ProviderConfig struct {
+	// Name identifies the provider in URLs, e.g. /auth/oidc/<name>/login.
+	Name         string   `json:"name"`
+	IssuerURL    string   `json:"issuer"`
+	ClientID     string   `json:"client_id"`
+	ClientSecret string   `json:"client_secret"`
+	RedirectURL  string   `json:"redirect_url"`
+	Scopes       []string `json:"scopes"`
+}
+
+// LoadProviderConfigs reads a JSON array of provider configurations.
+func LoadProviderConfigs(path string) ([]ProviderConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var configs []ProviderConfig
+	if err := json.Unmarshal(data, &configs); err != nil {
+		return nil, fmt.Errorf("failed to parse OIDC providers: %w", err)
+	}
+
+	seen := make(map[string]bool, len(configs))
+	for _, cfg := range configs {
+		if cfg.Name == "" || cfg.IssuerURL == "" || cfg.ClientID == "" || cfg.RedirectURL == "" {
+			return nil, fmt.Errorf("OIDC provider %q: name, issuer, client_id and redirect_url are required", cfg.Name)
+		}
+		if seen[cfg.Name] {
+			return nil, fmt.Errorf("OIDC provider %q is configured twice", cfg.Name)
+		}
+		seen[cfg.Name] = true
+	}
+
+	return configs, nil
+}
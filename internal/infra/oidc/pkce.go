@@ -0,0 +1,23 @@
+package oidc
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+
+	infraAuth "go-boilerplate/internal/infra/auth"
+)
+
+// GeneratePKCE returns a code verifier and its S256 code challenge (RFC 7636).
+func GeneratePKCE() (verifier, challenge string, err error) {
+	verifier, err = infraAuth.GenerateOpaqueToken()
+	if err != nil {
+		return "", "", err
+	}
+	return verifier, S256Challenge(verifier), nil
+}
+
+// S256Challenge derives the code challenge sent with the authorization request.
+func S256Challenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
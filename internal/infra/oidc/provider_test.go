@@ -0,0 +1,252 @@
+package oidc
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	infraAuth "go-boilerplate/internal/infra/auth"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubProvider is a minimal OpenID provider: it issues one authorization code bound to
+// the PKCE challenge and nonce it was given and returns an ID token for it.
+type stubProvider struct {
+	server    *httptest.Server
+	keyring   *infraAuth.Keyring
+	signer    *infraAuth.SigningKey
+	challenge string
+	nonce     string
+	audience  string
+	subject   string
+}
+
+func newStubProvider(t *testing.T) *stubProvider {
+	t.Helper()
+
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	key, err := infraAuth.NewSigningKey("stub-1", private)
+	require.NoError(t, err)
+	keyring, err := infraAuth.NewKeyring("stub-1", key)
+	require.NoError(t, err)
+
+	stub := &stubProvider{keyring: keyring, signer: key, audience: "client-123", subject: "external-42"}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 stub.server.URL,
+			"authorization_endpoint": stub.server.URL + "/authorize",
+			"token_endpoint":         stub.server.URL + "/token",
+			"jwks_uri":               stub.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(stub.keyring.JWKS())
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		if r.PostForm.Get("code") != "auth-code" || S256Challenge(r.PostForm.Get("code_verifier")) != stub.challenge {
+			http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]string{"id_token": stub.idToken(t)})
+	})
+	stub.server = httptest.NewServer(mux)
+	t.Cleanup(stub.server.Close)
+
+	return stub
+}
+
+func (s *stubProvider) idToken(t *testing.T) string {
+	t.Helper()
+
+	now := time.Now()
+	claims := &IDTokenClaims{
+		Nonce:         s.nonce,
+		Email:         "alice@example.com",
+		EmailVerified: true,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    s.server.URL,
+			Subject:   s.subject,
+			Audience:  jwt.ClaimStrings{s.audience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Minute)),
+		},
+	}
+
+	token := jwt.NewWithClaims(s.signer.Method, claims)
+	token.Header["kid"] = s.signer.ID
+	signed, err := token.SignedString(s.signer.Private)
+	require.NoError(t, err)
+	return signed
+}
+
+func (s *stubProvider) config() ProviderConfig {
+	return ProviderConfig{
+		Name:        "stub",
+		IssuerURL:   s.server.URL,
+		ClientID:    "client-123",
+		RedirectURL: "http://localhost:4001/auth/oidc/stub/callback",
+	}
+}
+
+// authorize mimics the user logging in at the provider.
+func (s *stubProvider) authorize(t *testing.T, authURL string) {
+	t.Helper()
+
+	parsed, err := url.Parse(authURL)
+	require.NoError(t, err)
+	s.challenge = parsed.Query().Get("code_challenge")
+	s.nonce = parsed.Query().Get("nonce")
+}
+
+func TestProvider_AuthorizationCodeFlow(t *testing.T) {
+	// Arrange
+	stub := newStubProvider(t)
+	provider := NewProvider(stub.config(), stub.server.Client())
+	verifier, challenge, err := GeneratePKCE()
+	require.NoError(t, err)
+
+	// Act
+	authURL, err := provider.AuthCodeURL(context.Background(), "state-1", "nonce-1", challenge)
+	require.NoError(t, err)
+	stub.authorize(t, authURL)
+	claims, err := provider.Exchange(context.Background(), "auth-code", verifier, "nonce-1")
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, "external-42", claims.Subject)
+	assert.Equal(t, "alice@example.com", claims.Email)
+	assert.True(t, claims.EmailVerified)
+
+	parsed, err := url.Parse(authURL)
+	require.NoError(t, err)
+	assert.Equal(t, stub.server.URL+"/authorize", parsed.Scheme+"://"+parsed.Host+parsed.Path)
+	assert.Equal(t, "state-1", parsed.Query().Get("state"))
+	assert.Equal(t, "S256", parsed.Query().Get("code_challenge_method"))
+	assert.Equal(t, "openid email profile", parsed.Query().Get("scope"))
+}
+
+func TestProvider_Exchange_WrongCodeVerifier(t *testing.T) {
+	// Arrange
+	stub := newStubProvider(t)
+	provider := NewProvider(stub.config(), stub.server.Client())
+	_, challenge, err := GeneratePKCE()
+	require.NoError(t, err)
+	authURL, err := provider.AuthCodeURL(context.Background(), "state-1", "nonce-1", challenge)
+	require.NoError(t, err)
+	stub.authorize(t, authURL)
+
+	// Act
+	claims, err := provider.Exchange(context.Background(), "auth-code", "another-verifier", "nonce-1")
+
+	// Assert
+	assert.Nil(t, claims)
+	assert.ErrorIs(t, err, ErrExchangeFailed)
+}
+
+func TestProvider_Exchange_RejectsInvalidIDTokens(t *testing.T) {
+	tests := []struct {
+		name    string
+		arrange func(stub *stubProvider)
+		nonce   string
+	}{
+		{
+			name:  "nonce mismatch",
+			nonce: "some-other-nonce",
+		},
+		{
+			name:    "wrong audience",
+			arrange: func(stub *stubProvider) { stub.audience = "another-client" },
+			nonce:   "nonce-1",
+		},
+		{
+			name: "signed by an unknown key",
+			arrange: func(stub *stubProvider) {
+				// Same kid, but not the key the JWKS publishes.
+				_, private, _ := ed25519.GenerateKey(rand.Reader)
+				stub.signer, _ = infraAuth.NewSigningKey("stub-1", private)
+			},
+			nonce: "nonce-1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			stub := newStubProvider(t)
+			provider := NewProvider(stub.config(), stub.server.Client())
+			verifier, challenge, err := GeneratePKCE()
+			require.NoError(t, err)
+			authURL, err := provider.AuthCodeURL(context.Background(), "state-1", "nonce-1", challenge)
+			require.NoError(t, err)
+			stub.authorize(t, authURL)
+			if tt.arrange != nil {
+				tt.arrange(stub)
+			}
+
+			// Act
+			claims, err := provider.Exchange(context.Background(), "auth-code", verifier, tt.nonce)
+
+			// Assert
+			assert.Nil(t, claims)
+			assert.ErrorIs(t, err, ErrInvalidIDToken)
+		})
+	}
+}
+
+func TestProvider_DiscoveryIssuerMismatch(t *testing.T) {
+	// Arrange
+	stub := newStubProvider(t)
+	cfg := stub.config()
+	cfg.IssuerURL = stub.server.URL + "/"
+	provider := NewProvider(cfg, stub.server.Client())
+
+	// Act
+	_, err := provider.AuthCodeURL(context.Background(), "state-1", "nonce-1", "challenge")
+
+	// Assert
+	assert.ErrorIs(t, err, ErrDiscoveryFailed)
+}
+
+func TestLoadProviderConfigs(t *testing.T) {
+	// Arrange
+	path := filepath.Join(t.TempDir(), "providers.json")
+	require.NoError(t, os.WriteFile(path, []byte(`[
+		{"name": "corp", "issuer": "https://sso.example.com", "client_id": "api", "redirect_url": "https://api.example.com/auth/oidc/corp/callback"}
+	]`), 0o600))
+
+	// Act
+	configs, err := LoadProviderConfigs(path)
+
+	// Assert
+	require.NoError(t, err)
+	require.Len(t, configs, 1)
+	assert.Equal(t, "corp", configs[0].Name)
+	assert.Equal(t, "https://sso.example.com", configs[0].IssuerURL)
+}
+
+func TestLoadProviderConfigs_MissingFields(t *testing.T) {
+	// Arrange
+	path := filepath.Join(t.TempDir(), "providers.json")
+	require.NoError(t, os.WriteFile(path, []byte(`[{"name": "corp"}]`), 0o600))
+
+	// Act
+	_, err := LoadProviderConfigs(path)
+
+	// Assert
+	assert.Error(t, err)
+}
@@ -0,0 +1,114 @@
+package scheduler
+
+import (
+	"context"
+	"log/slog"
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+// Job is a unit of periodic background work.
+type Job struct {
+	Name string
+	// Interval is the base delay between the end of one run and the start of the next.
+	Interval time.Duration
+	// Jitter adds a random delay of up to this duration before every run, so several
+	// instances started together do not hit the database at the same moment.
+	Jitter time.Duration
+	Run    func(ctx context.Context) error
+}
+
+// Scheduler runs registered jobs on their intervals until it is stopped.
+type Scheduler struct {
+	jobs   []Job
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func New() *Scheduler {
+	return &Scheduler{}
+}
+
+// Register adds a job. Jobs must be registered before Start is called.
+func (s *Scheduler) Register(job Job) {
+	s.jobs = append(s.jobs, job)
+}
+
+// Start launches one goroutine per job. The first run of each job happens after its jitter.
+func (s *Scheduler) Start(ctx context.Context) {
+	ctx, s.cancel = context.WithCancel(ctx)
+
+	for _, job := range s.jobs {
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.loop(ctx, job)
+		}()
+	}
+
+	slog.Info("scheduler started", "jobs", len(s.jobs))
+}
+
+// Stop cancels running jobs and waits for them to return, or for ctx to expire.
+func (s *Scheduler) Stop(ctx context.Context) error {
+	if s.cancel != nil {
+		s.cancel()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		slog.Info("scheduler stopped")
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *Scheduler) loop(ctx context.Context, job Job) {
+	timer := time.NewTimer(jitter(job.Jitter))
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+
+		s.run(ctx, job)
+		timer.Reset(job.Interval + jitter(job.Jitter))
+	}
+}
+
+func (s *Scheduler) run(ctx context.Context, job Job) {
+	start := time.Now()
+	defer func() {
+		if r := recover(); r != nil {
+			slog.Error("scheduled job panicked", "job", job.Name, "panic", r)
+		}
+	}()
+
+	if err := job.Run(ctx); err != nil {
+		if ctx.Err() != nil {
+			return
+		}
+		slog.Error("scheduled job failed", "job", job.Name, "error", err, "duration", time.Since(start))
+		return
+	}
+
+	slog.Debug("scheduled job finished", "job", job.Name, "duration", time.Since(start))
+}
+
+func jitter(max time.Duration) time.Duration {
+	if max <= 0 {
+		return 0
+	}
+	return rand.N(max)
+}
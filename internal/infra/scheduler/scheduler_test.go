@@ -0,0 +1,77 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScheduler_RunsJobsUntilStopped(t *testing.T) {
+	// Arrange
+	var runs, failures atomic.Int32
+	s := New()
+	s.Register(Job{
+		Name:     "counter",
+		Interval: 5 * time.Millisecond,
+		Run: func(ctx context.Context) error {
+			runs.Add(1)
+			return nil
+		},
+	})
+	s.Register(Job{
+		Name:     "failing",
+		Interval: 5 * time.Millisecond,
+		Jitter:   time.Millisecond,
+		Run: func(ctx context.Context) error {
+			failures.Add(1)
+			return errors.New("boom")
+		},
+	})
+
+	// Act
+	s.Start(context.Background())
+	assert.Eventually(t, func() bool {
+		return runs.Load() >= 3 && failures.Load() >= 3
+	}, time.Second, time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	err := s.Stop(ctx)
+
+	// Assert - a failing job keeps being scheduled and nothing runs after Stop
+	assert.NoError(t, err)
+	stoppedAt := runs.Load()
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, stoppedAt, runs.Load())
+}
+
+func TestScheduler_StopWaitsForRunningJob(t *testing.T) {
+	// Arrange
+	var finished atomic.Bool
+	started := make(chan struct{})
+	s := New()
+	s.Register(Job{
+		Name:     "slow",
+		Interval: time.Hour,
+		Run: func(ctx context.Context) error {
+			close(started)
+			<-ctx.Done()
+			time.Sleep(10 * time.Millisecond)
+			finished.Store(true)
+			return ctx.Err()
+		},
+	})
+
+	// Act
+	s.Start(context.Background())
+	<-started
+	err := s.Stop(context.Background())
+
+	// Assert
+	assert.NoError(t, err)
+	assert.True(t, finished.Load())
+}
@@ -0,0 +1,89 @@
+package privacy
+
+import (
+	"context"
+	"time"
+
+	"go-boilerplate/internal/auth"
+	"go-boilerplate/internal/crypto/portfolio"
+	"go-boilerplate/internal/dto"
+	"go-boilerplate/internal/infra/audit"
+	infraAuth "go-boilerplate/internal/infra/auth"
+
+	"github.com/google/uuid"
+)
+
+// Export is everything stored about one user, as handed to them by GET /me/export.
+// Secrets such as password and token hashes are left out; they identify nobody and
+// would only help an attacker who got hold of the archive.
+type Export struct {
+	ExportedAt   time.Time                 `json:"exported_at"`
+	Profile      Profile                   `json:"profile"`
+	Sessions     []Session                 `json:"sessions"`
+	APIKeys      []APIKey                  `json:"api_keys"`
+	Identities   []ExternalIdentity        `json:"external_identities"`
+	Portfolios   []dto.PortfolioResponse   `json:"portfolios"`
+	Transactions []dto.TransactionResponse `json:"transactions"`
+	AuditEvents  []audit.Event             `json:"audit_events"`
+}
+
+type Profile struct {
+	ID              uuid.UUID      `json:"id"`
+	Username        string         `json:"username"`
+	Email           string         `json:"email"`
+	EmailVerifiedAt *time.Time     `json:"email_verified_at,omitempty"`
+	Role            infraAuth.Role `json:"role"`
+	MFAEnabledAt    *time.Time     `json:"mfa_enabled_at,omitempty"`
+	CreatedAt       time.Time      `json:"created_at"`
+	UpdatedAt       time.Time      `json:"updated_at"`
+}
+
+// Session is one stored refresh token, including rotated and revoked ones, since each
+// records the client it was used from.
+type Session struct {
+	ID               uuid.UUID  `json:"id"`
+	SessionID        uuid.UUID  `json:"session_id"`
+	UserAgent        string     `json:"user_agent"`
+	IPAddress        string     `json:"ip_address"`
+	SessionStartedAt time.Time  `json:"session_started_at"`
+	LastUsedAt       time.Time  `json:"last_used_at"`
+	ExpiresAt        time.Time  `json:"expires_at"`
+	RevokedAt        *time.Time `json:"revoked_at,omitempty"`
+}
+
+type APIKey struct {
+	ID         uuid.UUID              `json:"id"`
+	Name       string                 `json:"name"`
+	Prefix     string                 `json:"prefix"`
+	Scopes     []infraAuth.Permission `json:"scopes"`
+	ExpiresAt  time.Time              `json:"expires_at"`
+	LastUsedAt *time.Time             `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time             `json:"revoked_at,omitempty"`
+	CreatedAt  time.Time              `json:"created_at"`
+}
+
+type ExternalIdentity struct {
+	Provider    string    `json:"provider"`
+	Subject     string    `json:"subject"`
+	Email       string    `json:"email,omitempty"`
+	LastLoginAt time.Time `json:"last_login_at"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type Usecase interface {
+	Export(ctx context.Context, userID uuid.UUID) (*Export, error)
+	DeleteAccount(ctx context.Context, userID uuid.UUID, password string) error
+}
+
+// Repository reads and erases a user's data across every domain's tables. Reads include
+// soft-deleted rows: they are still data we hold.
+type Repository interface {
+	GetUser(ctx context.Context, userID uuid.UUID) (*auth.User, error)
+	ListRefreshTokens(ctx context.Context, userID uuid.UUID) ([]auth.RefreshToken, error)
+	ListAPIKeys(ctx context.Context, userID uuid.UUID) ([]auth.APIKey, error)
+	ListExternalIdentities(ctx context.Context, userID uuid.UUID) ([]auth.ExternalIdentity, error)
+	ListPortfolios(ctx context.Context, userID uuid.UUID) ([]portfolio.Portfolio, error)
+	ListTransactions(ctx context.Context, userID uuid.UUID) ([]portfolio.Transaction, error)
+	ListAuditEvents(ctx context.Context, userID uuid.UUID) ([]audit.Event, error)
+	DeleteUser(ctx context.Context, user *auth.User) error
+}
@@ -0,0 +1,149 @@
+package privacy
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"go-boilerplate/internal/auth"
+	infraAuth "go-boilerplate/internal/infra/auth"
+	"go-boilerplate/pkg/response"
+
+	"github.com/labstack/echo/v5"
+)
+
+type Handler struct {
+	usecase Usecase
+}
+
+// NewHandler registers the account data endpoints. Both act on the whole account, so
+// they need a full-access login rather than an API key, scoped or impersonation token.
+func NewHandler(e *echo.Echo, usecase Usecase, bearerMiddleware echo.MiddlewareFunc) {
+	h := &Handler{usecase: usecase}
+
+	me := e.Group("/me", bearerMiddleware, infraAuth.RequireFullAccess)
+	me.GET("/export", h.Export)
+	me.DELETE("", h.DeleteAccount)
+}
+
+type ExportRequest struct {
+	Format string `query:"format" validate:"omitempty,oneof=zip json"`
+}
+
+// Export sends the user everything stored about them, as a ZIP archive with one JSON
+// file per kind of data or, with format=json, as a single JSON document.
+func (h *Handler) Export(c *echo.Context) error {
+	userID, err := infraAuth.UserIDFromContext(c)
+	if err != nil {
+		return response.InternalServerError(c, "invalid user context")
+	}
+
+	var req ExportRequest
+	if err := echo.BindQueryParams(c, &req); err != nil {
+		return response.BadRequest(c, "invalid query parameters")
+	}
+	if err := c.Validate(&req); err != nil {
+		return response.BadRequest(c, err.Error())
+	}
+
+	export, err := h.usecase.Export(c.Request().Context(), userID)
+	if err != nil {
+		if errors.Is(err, auth.ErrUserNotFound) {
+			return response.NotFound(c, err.Error())
+		}
+		return response.InternalServerError(c, "failed to export account data")
+	}
+
+	filename := fmt.Sprintf("account-export-%s-%s", userID, export.ExportedAt.Format("20060102T150405Z"))
+	header := c.Response().Header()
+	header.Set(echo.HeaderCacheControl, "no-store")
+
+	if req.Format == "json" {
+		header.Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", filename+".json"))
+		return c.JSON(http.StatusOK, export)
+	}
+
+	header.Set(echo.HeaderContentType, "application/zip")
+	header.Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", filename+".zip"))
+	c.Response().WriteHeader(http.StatusOK)
+
+	// The status is already sent, so a failure from here on can only be logged; the
+	// client is left with a truncated archive it cannot open.
+	if err := writeArchive(c.Response(), export); err != nil {
+		c.Logger().Error("failed to write account export", "error", err, "user_id", userID)
+	}
+	return nil
+}
+
+// writeArchive streams the export as a ZIP archive without buffering it.
+func writeArchive(w http.ResponseWriter, export *Export) error {
+	archive := zip.NewWriter(w)
+
+	files := []struct {
+		name string
+		data any
+	}{
+		{"profile.json", export.Profile},
+		{"sessions.json", export.Sessions},
+		{"api_keys.json", export.APIKeys},
+		{"external_identities.json", export.Identities},
+		{"portfolios.json", export.Portfolios},
+		{"transactions.json", export.Transactions},
+		{"audit_events.json", export.AuditEvents},
+	}
+	for _, file := range files {
+		f, err := archive.CreateHeader(&zip.FileHeader{
+			Name:     file.name,
+			Method:   zip.Deflate,
+			Modified: export.ExportedAt,
+		})
+		if err != nil {
+			return err
+		}
+		encoder := json.NewEncoder(f)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(file.data); err != nil {
+			return fmt.Errorf("failed to write %s: %w", file.name, err)
+		}
+	}
+
+	return archive.Close()
+}
+
+// DeleteAccountRequest confirms the deletion with the current password. Accounts
+// created through single sign-on have none and may leave it empty.
+type DeleteAccountRequest struct {
+	Password string `json:"password" validate:"max=72"`
+}
+
+// DeleteAccount permanently erases the user's account and everything they own.
+func (h *Handler) DeleteAccount(c *echo.Context) error {
+	userID, err := infraAuth.UserIDFromContext(c)
+	if err != nil {
+		return response.InternalServerError(c, "invalid user context")
+	}
+
+	var req DeleteAccountRequest
+	if err := c.Bind(&req); err != nil {
+		return response.BadRequest(c, "invalid request body")
+	}
+	if err := c.Validate(&req); err != nil {
+		return response.BadRequest(c, err.Error())
+	}
+
+	if err := h.usecase.DeleteAccount(c.Request().Context(), userID, req.Password); err != nil {
+		switch {
+		case errors.Is(err, ErrInvalidPassword):
+			return response.Forbidden(c, err.Error())
+		case errors.Is(err, auth.ErrUserNotFound):
+			return response.NotFound(c, err.Error())
+		default:
+			c.Logger().Error("failed to delete account", "error", err, "user_id", userID)
+			return response.InternalServerError(c, "failed to delete account")
+		}
+	}
+
+	return response.Success(c, "account deleted", nil)
+}
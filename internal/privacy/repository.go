@@ -0,0 +1,192 @@
+package privacy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go-boilerplate/internal/auth"
+	"go-boilerplate/internal/crypto/portfolio"
+	"go-boilerplate/internal/infra/audit"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) Repository {
+	return &repository{db: db}
+}
+
+func (r *repository) GetUser(ctx context.Context, userID uuid.UUID) (*auth.User, error) {
+	var user auth.User
+	if err := r.db.WithContext(ctx).Where("id = ?", userID).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, auth.ErrUserNotFound
+		}
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	return &user, nil
+}
+
+func (r *repository) ListRefreshTokens(ctx context.Context, userID uuid.UUID) ([]auth.RefreshToken, error) {
+	var tokens []auth.RefreshToken
+	err := r.db.WithContext(ctx).Unscoped().
+		Where("user_id = ?", userID).
+		Order("created_at").
+		Find(&tokens).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list refresh tokens: %w", err)
+	}
+	return tokens, nil
+}
+
+func (r *repository) ListAPIKeys(ctx context.Context, userID uuid.UUID) ([]auth.APIKey, error) {
+	var keys []auth.APIKey
+	err := r.db.WithContext(ctx).
+		Where("user_id = ?", userID).
+		Order("created_at").
+		Find(&keys).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list API keys: %w", err)
+	}
+	return keys, nil
+}
+
+func (r *repository) ListExternalIdentities(ctx context.Context, userID uuid.UUID) ([]auth.ExternalIdentity, error) {
+	var identities []auth.ExternalIdentity
+	err := r.db.WithContext(ctx).
+		Where("user_id = ?", userID).
+		Order("created_at").
+		Find(&identities).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list external identities: %w", err)
+	}
+	return identities, nil
+}
+
+func (r *repository) ListPortfolios(ctx context.Context, userID uuid.UUID) ([]portfolio.Portfolio, error) {
+	var portfolios []portfolio.Portfolio
+	err := r.db.WithContext(ctx).Unscoped().
+		Preload("Holdings", func(db *gorm.DB) *gorm.DB { return db.Unscoped() }).
+		Where("user_id = ?", userID).
+		Order("created_at").
+		Find(&portfolios).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list portfolios: %w", err)
+	}
+	return portfolios, nil
+}
+
+// ListTransactions returns the ledgers of all the user's portfolios, voided entries included.
+func (r *repository) ListTransactions(ctx context.Context, userID uuid.UUID) ([]portfolio.Transaction, error) {
+	var transactions []portfolio.Transaction
+	portfolioIDs := r.db.WithContext(ctx).Unscoped().Model(&portfolio.Portfolio{}).Select("id").Where("user_id = ?", userID)
+	err := r.db.WithContext(ctx).
+		Where("portfolio_id IN (?)", portfolioIDs).
+		Order("executed_at, created_at").
+		Find(&transactions).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list transactions: %w", err)
+	}
+	return transactions, nil
+}
+
+// ListAuditEvents returns the events the user took part in, as actor or as the user
+// acted upon.
+func (r *repository) ListAuditEvents(ctx context.Context, userID uuid.UUID) ([]audit.Event, error) {
+	var events []audit.Event
+	err := r.db.WithContext(ctx).
+		Scopes(auditEventsOf(userID)).
+		Order("occurred_at").
+		Find(&events).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list audit events: %w", err)
+	}
+	return events, nil
+}
+
+// DeleteUser erases the user in a single transaction. Rows owned by the user are
+// hard-deleted, bypassing soft deletes. Audit events are kept, so the trail has no
+// gaps, but lose everything that identifies the user.
+func (r *repository) DeleteUser(ctx context.Context, user *auth.User) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		portfolioIDs := tx.Unscoped().Model(&portfolio.Portfolio{}).Select("id").Where("user_id = ?", user.ID)
+		if err := tx.Unscoped().Where("portfolio_id IN (?)", portfolioIDs).Delete(&portfolio.Holding{}).Error; err != nil {
+			return fmt.Errorf("failed to delete holdings: %w", err)
+		}
+		if err := tx.Where("portfolio_id IN (?)", portfolioIDs).Delete(&portfolio.Transaction{}).Error; err != nil {
+			return fmt.Errorf("failed to delete transactions: %w", err)
+		}
+
+		owned := []struct {
+			name  string
+			model any
+		}{
+			{"portfolios", &portfolio.Portfolio{}},
+			{"refresh tokens", &auth.RefreshToken{}},
+			{"API keys", &auth.APIKey{}},
+			{"action tokens", &auth.ActionToken{}},
+			{"recovery codes", &auth.RecoveryCode{}},
+			{"external identities", &auth.ExternalIdentity{}},
+		}
+		for _, table := range owned {
+			if err := tx.Unscoped().Where("user_id = ?", user.ID).Delete(table.model).Error; err != nil {
+				return fmt.Errorf("failed to delete %s: %w", table.name, err)
+			}
+		}
+
+		if err := tx.Where("key = ?", "account:"+user.Username).Delete(&auth.LoginAttempt{}).Error; err != nil {
+			return fmt.Errorf("failed to delete login attempts: %w", err)
+		}
+
+		if err := anonymiseAuditEvents(tx, user.ID).Error; err != nil {
+			return fmt.Errorf("failed to anonymise audit events: %w", err)
+		}
+
+		if err := tx.Unscoped().Delete(&auth.User{}, "id = ?", user.ID).Error; err != nil {
+			return fmt.Errorf("failed to delete user: %w", err)
+		}
+		return nil
+	})
+}
+
+// anonymiseAuditEvents blanks the user's id wherever an event names them and drops the
+// events' metadata. Client details are only cleared from requests the user made; on
+// events where they were merely acted upon, the address is the other party's.
+func anonymiseAuditEvents(tx *gorm.DB, userID uuid.UUID) *gorm.DB {
+	id := userID.String()
+	clearID := func(column string) clause.Expr {
+		return gorm.Expr("CASE WHEN "+column+" = ? THEN '' ELSE "+column+" END", id)
+	}
+	clearIfMadeByUser := func(column string) clause.Expr {
+		return gorm.Expr("CASE WHEN actor_id = ? OR impersonator_id = ? THEN '' ELSE "+column+" END", id, id)
+	}
+
+	return tx.Model(&audit.Event{}).
+		Scopes(auditEventsOf(userID)).
+		Updates(map[string]any{
+			"actor_id":        clearID("actor_id"),
+			"impersonator_id": clearID("impersonator_id"),
+			"target_id":       clearID("target_id"),
+			"ip_address":      clearIfMadeByUser("ip_address"),
+			"user_agent":      clearIfMadeByUser("user_agent"),
+			"metadata":        nil,
+		})
+}
+
+// auditEventsOf selects the events that name userID, including failed logins for their
+// username and events that only mention them in metadata.
+func auditEventsOf(userID uuid.UUID) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		id := userID.String()
+		return db.Where(
+			"actor_id = ? OR impersonator_id = ? OR (target_type = 'user' AND target_id = ?) OR metadata::jsonb ->> 'user_id' = ? OR metadata::jsonb ->> 'username' = (SELECT username FROM users WHERE id = ?)",
+			id, id, id, id, userID,
+		)
+	}
+}
@@ -0,0 +1,101 @@
+package privacy
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"go-boilerplate/internal/auth"
+	"go-boilerplate/internal/crypto/portfolio"
+	"go-boilerplate/internal/infra/audit"
+	infraAuth "go-boilerplate/internal/infra/auth"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// testDatabase connects to the Postgres database named by TEST_DATABASE_DSN, skipping the
+// test when it is not set. The schema is migrated but not cleaned up, so tests must use
+// rows of their own.
+func testDatabase(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_DSN is not set")
+	}
+
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Discard})
+	require.NoError(t, err)
+	require.NoError(t, auth.Migrate(db, infraAuth.NewHMACTokenHasher("test-pepper")))
+	require.NoError(t, portfolio.Migrate(db))
+	require.NoError(t, audit.Migrate(db))
+	return db
+}
+
+func TestAnonymiseAuditEvents_BindsEveryParameter(t *testing.T) {
+	// Arrange
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost"}), &gorm.Config{
+		DryRun:                 true,
+		SkipDefaultTransaction: true,
+		DisableAutomaticPing:   true,
+		Logger:                 logger.Discard,
+	})
+	require.NoError(t, err)
+	userID := uuid.New()
+
+	// Act
+	stmt := anonymiseAuditEvents(db, userID).Statement
+
+	// Assert
+	sql := stmt.SQL.String()
+	assert.NotContains(t, sql, "@", "named parameters are not bound by gorm.Expr and reach Postgres as text")
+	assert.Equal(t, strings.Count(sql, "$"), len(stmt.Vars), sql)
+	assert.Contains(t, stmt.Vars, userID.String())
+}
+
+func TestDeleteUser(t *testing.T) {
+	// Arrange
+	db := testDatabase(t)
+	repo := NewRepository(db)
+	ctx := context.Background()
+	suffix := uuid.NewString()[:8]
+	user := &auth.User{ID: uuid.New(), Username: "erase-" + suffix, Email: "erase-" + suffix + "@example.com", PasswordHash: "x"}
+	admin := uuid.NewString()
+	require.NoError(t, db.Create(user).Error)
+
+	ownLogin := audit.Event{
+		ID: uuid.New(), OccurredAt: time.Now(), ActorID: user.ID.String(), Action: "auth.login",
+		Outcome: audit.OutcomeSuccess, IPAddress: "203.0.113.7", UserAgent: "curl", Metadata: map[string]any{"method": "password"},
+	}
+	actedUpon := audit.Event{
+		ID: uuid.New(), OccurredAt: time.Now(), ActorID: admin, Action: "auth.role_change",
+		TargetType: "user", TargetID: user.ID.String(), Outcome: audit.OutcomeSuccess, IPAddress: "198.51.100.1",
+	}
+	require.NoError(t, db.Create([]audit.Event{ownLogin, actedUpon}).Error)
+
+	// Act
+	err := repo.DeleteUser(ctx, user)
+
+	// Assert
+	require.NoError(t, err)
+	var remaining int64
+	require.NoError(t, db.Unscoped().Model(&auth.User{}).Where("id = ?", user.ID).Count(&remaining).Error)
+	assert.Zero(t, remaining)
+
+	var own, other audit.Event
+	require.NoError(t, db.First(&own, "id = ?", ownLogin.ID).Error)
+	require.NoError(t, db.First(&other, "id = ?", actedUpon.ID).Error)
+	assert.Empty(t, own.ActorID)
+	assert.Empty(t, own.IPAddress, "client details of the user's own requests are cleared")
+	assert.Empty(t, own.UserAgent)
+	assert.Nil(t, own.Metadata)
+	assert.Equal(t, admin, other.ActorID)
+	assert.Empty(t, other.TargetID)
+	assert.Equal(t, "198.51.100.1", other.IPAddress, "the other party's address is kept")
+}
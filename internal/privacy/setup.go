@@ -0,0 +1,36 @@
+package privacy
+
+import (
+	"go-boilerplate/internal/infra/audit"
+	infraAuth "go-boilerplate/internal/infra/auth"
+
+	"github.com/labstack/echo/v5"
+	"github.com/samber/do"
+	"gorm.io/gorm"
+)
+
+func NewInjector(
+	db *gorm.DB,
+	hasher infraAuth.PasswordHasher,
+	jwtSvc infraAuth.JWTService,
+	revocations infraAuth.RevocationStore,
+	auditLogger audit.AuditLogger,
+) *do.Injector {
+	injector := do.New()
+
+	do.Provide(injector, func(i *do.Injector) (Repository, error) {
+		return NewRepository(db), nil
+	})
+
+	do.Provide(injector, func(i *do.Injector) (Usecase, error) {
+		repo := do.MustInvoke[Repository](i)
+		return NewUsecase(repo, hasher, jwtSvc, revocations, auditLogger), nil
+	})
+
+	return injector
+}
+
+func RegisterHandlers(e *echo.Echo, injector *do.Injector, bearerMiddleware echo.MiddlewareFunc) {
+	usecase := do.MustInvoke[Usecase](injector)
+	NewHandler(e, usecase, bearerMiddleware)
+}
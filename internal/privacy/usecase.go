@@ -0,0 +1,227 @@
+package privacy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go-boilerplate/internal/auth"
+	"go-boilerplate/internal/crypto/portfolio"
+	"go-boilerplate/internal/dto"
+	"go-boilerplate/internal/infra/audit"
+	infraAuth "go-boilerplate/internal/infra/auth"
+
+	"github.com/google/uuid"
+)
+
+var ErrInvalidPassword = errors.New("invalid password")
+
+// Audit actions recorded by the privacy usecase.
+const (
+	ActionExport        = "privacy.export"
+	ActionAccountDelete = "privacy.account_delete"
+)
+
+// revocationGrace matches the auth domain's: access tokens accepted within the
+// validator's clock-skew leeway stay revoked.
+const revocationGrace = 5 * time.Minute
+
+type usecase struct {
+	repo        Repository
+	hasher      infraAuth.PasswordHasher
+	jwtSvc      infraAuth.JWTService
+	revocations infraAuth.RevocationStore
+	auditLogger audit.AuditLogger
+}
+
+func NewUsecase(
+	repo Repository,
+	hasher infraAuth.PasswordHasher,
+	jwtSvc infraAuth.JWTService,
+	revocations infraAuth.RevocationStore,
+	auditLogger audit.AuditLogger,
+) Usecase {
+	return &usecase{
+		repo:        repo,
+		hasher:      hasher,
+		jwtSvc:      jwtSvc,
+		revocations: revocations,
+		auditLogger: auditLogger,
+	}
+}
+
+// Export gathers everything stored about the user. Audit events made by someone else,
+// such as an admin changing the user's role, keep who did it but not from where.
+func (u *usecase) Export(ctx context.Context, userID uuid.UUID) (*Export, error) {
+	user, err := u.repo.GetUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	tokens, err := u.repo.ListRefreshTokens(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := u.repo.ListAPIKeys(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	identities, err := u.repo.ListExternalIdentities(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	portfolios, err := u.repo.ListPortfolios(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	transactions, err := u.repo.ListTransactions(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	events, err := u.repo.ListAuditEvents(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	export := &Export{
+		ExportedAt:   time.Now().UTC(),
+		Profile:      toProfile(user),
+		Sessions:     make([]Session, len(tokens)),
+		APIKeys:      make([]APIKey, len(keys)),
+		Identities:   make([]ExternalIdentity, len(identities)),
+		Portfolios:   make([]dto.PortfolioResponse, len(portfolios)),
+		Transactions: make([]dto.TransactionResponse, len(transactions)),
+		AuditEvents:  make([]audit.Event, len(events)),
+	}
+	for i, token := range tokens {
+		export.Sessions[i] = toSession(token)
+	}
+	for i, key := range keys {
+		export.APIKeys[i] = toAPIKey(key)
+	}
+	for i, identity := range identities {
+		export.Identities[i] = toExternalIdentity(identity)
+	}
+	for i := range portfolios {
+		export.Portfolios[i] = portfolio.ToPortfolioResponse(&portfolios[i])
+	}
+	for i := range transactions {
+		export.Transactions[i] = portfolio.ToTransactionResponse(&transactions[i])
+	}
+	for i, event := range events {
+		if event.ActorID != userID.String() {
+			event.IPAddress, event.UserAgent = "", ""
+		}
+		export.AuditEvents[i] = event
+	}
+
+	u.auditLogger.Log(ctx, audit.Event{
+		ActorID:    userID.String(),
+		Action:     ActionExport,
+		TargetType: "user",
+		TargetID:   userID.String(),
+		Outcome:    audit.OutcomeSuccess,
+	})
+
+	return export, nil
+}
+
+// DeleteAccount erases the user and everything they own. Accounts with a password must
+// confirm it, so a stolen access token alone cannot destroy an account; accounts created
+// through single sign-on have none to give. Access tokens already issued are revoked.
+func (u *usecase) DeleteAccount(ctx context.Context, userID uuid.UUID, password string) error {
+	user, err := u.repo.GetUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if user.PasswordHash != "" {
+		if err := u.hasher.Compare(user.PasswordHash, password); err != nil {
+			if errors.Is(err, infraAuth.ErrPasswordMismatch) {
+				u.auditLogger.Log(ctx, audit.Event{
+					ActorID:    userID.String(),
+					Action:     ActionAccountDelete,
+					TargetType: "user",
+					TargetID:   userID.String(),
+					Outcome:    audit.OutcomeFailure,
+					Metadata:   map[string]any{"reason": "wrong_password"},
+				})
+				return ErrInvalidPassword
+			}
+			return fmt.Errorf("failed to verify password: %w", err)
+		}
+	}
+
+	if err := u.repo.DeleteUser(ctx, user); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(u.jwtSvc.AccessTokenTTL() + revocationGrace)
+	if err := u.revocations.RevokeUser(ctx, userID.String(), now, expiresAt); err != nil {
+		return fmt.Errorf("failed to revoke access tokens: %w", err)
+	}
+
+	// The record that the account was erased must not identify the client either, so
+	// only the request id is kept.
+	info := audit.RequestInfoFromContext(ctx)
+	ctx = audit.WithActorID(audit.WithRequestInfo(ctx, audit.RequestInfo{RequestID: info.RequestID}), "")
+	u.auditLogger.Log(ctx, audit.Event{
+		Action:     ActionAccountDelete,
+		TargetType: "user",
+		TargetID:   userID.String(),
+		Outcome:    audit.OutcomeSuccess,
+	})
+
+	return nil
+}
+
+func toProfile(user *auth.User) Profile {
+	return Profile{
+		ID:              user.ID,
+		Username:        user.Username,
+		Email:           user.Email,
+		EmailVerifiedAt: user.EmailVerifiedAt,
+		Role:            user.Role,
+		MFAEnabledAt:    user.MFAEnabledAt,
+		CreatedAt:       user.CreatedAt,
+		UpdatedAt:       user.UpdatedAt,
+	}
+}
+
+func toSession(token auth.RefreshToken) Session {
+	return Session{
+		ID:               token.ID,
+		SessionID:        token.FamilyID,
+		UserAgent:        token.UserAgent,
+		IPAddress:        token.IPAddress,
+		SessionStartedAt: token.SessionStartedAt,
+		LastUsedAt:       token.LastUsedAt,
+		ExpiresAt:        token.ExpiresAt,
+		RevokedAt:        token.RevokedAt,
+	}
+}
+
+func toAPIKey(key auth.APIKey) APIKey {
+	return APIKey{
+		ID:         key.ID,
+		Name:       key.Name,
+		Prefix:     key.Prefix,
+		Scopes:     key.Scopes,
+		ExpiresAt:  key.ExpiresAt,
+		LastUsedAt: key.LastUsedAt,
+		RevokedAt:  key.RevokedAt,
+		CreatedAt:  key.CreatedAt,
+	}
+}
+
+func toExternalIdentity(identity auth.ExternalIdentity) ExternalIdentity {
+	return ExternalIdentity{
+		Provider:    identity.Provider,
+		Subject:     identity.Subject,
+		Email:       identity.Email,
+		LastLoginAt: identity.LastLoginAt,
+		CreatedAt:   identity.CreatedAt,
+	}
+}
@@ -0,0 +1,230 @@
+package privacy
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go-boilerplate/internal/auth"
+	"go-boilerplate/internal/crypto/portfolio"
+	"go-boilerplate/internal/infra/audit"
+	infraAuth "go-boilerplate/internal/infra/auth"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// MockRepository is a manual mock of the Repository interface.
+type MockRepository struct {
+	mock.Mock
+}
+
+func (m *MockRepository) GetUser(ctx context.Context, userID uuid.UUID) (*auth.User, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*auth.User), args.Error(1)
+}
+
+func (m *MockRepository) ListRefreshTokens(ctx context.Context, userID uuid.UUID) ([]auth.RefreshToken, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).([]auth.RefreshToken), args.Error(1)
+}
+
+func (m *MockRepository) ListAPIKeys(ctx context.Context, userID uuid.UUID) ([]auth.APIKey, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).([]auth.APIKey), args.Error(1)
+}
+
+func (m *MockRepository) ListExternalIdentities(ctx context.Context, userID uuid.UUID) ([]auth.ExternalIdentity, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).([]auth.ExternalIdentity), args.Error(1)
+}
+
+func (m *MockRepository) ListPortfolios(ctx context.Context, userID uuid.UUID) ([]portfolio.Portfolio, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).([]portfolio.Portfolio), args.Error(1)
+}
+
+func (m *MockRepository) ListTransactions(ctx context.Context, userID uuid.UUID) ([]portfolio.Transaction, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).([]portfolio.Transaction), args.Error(1)
+}
+
+func (m *MockRepository) ListAuditEvents(ctx context.Context, userID uuid.UUID) ([]audit.Event, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).([]audit.Event), args.Error(1)
+}
+
+func (m *MockRepository) DeleteUser(ctx context.Context, user *auth.User) error {
+	args := m.Called(ctx, user)
+	return args.Error(0)
+}
+
+type testDeps struct {
+	repo        *MockRepository
+	hasher      infraAuth.PasswordHasher
+	jwtSvc      infraAuth.JWTService
+	revocations infraAuth.RevocationStore
+	audit       *audit.MemoryLogger
+}
+
+func newTestDeps() *testDeps {
+	return &testDeps{
+		repo:        new(MockRepository),
+		hasher:      infraAuth.NewBcryptHasher(bcrypt.MinCost),
+		jwtSvc:      infraAuth.NewJWTService("test-secret", 1),
+		revocations: infraAuth.NewMemoryRevocationStore(),
+		audit:       audit.NewMemoryLogger(),
+	}
+}
+
+func (d *testDeps) usecase() Usecase {
+	return NewUsecase(d.repo, d.hasher, d.jwtSvc, d.revocations, d.audit)
+}
+
+func (d *testDeps) userWithPassword(t *testing.T, password string) *auth.User {
+	hash, err := d.hasher.Hash(password)
+	assert.NoError(t, err)
+	return &auth.User{ID: uuid.New(), Username: "alice", Email: "alice@example.com", PasswordHash: hash}
+}
+
+func TestExport(t *testing.T) {
+	// Arrange
+	d := newTestDeps()
+	user := d.userWithPassword(t, "correct-horse")
+	adminID := uuid.New().String()
+	now := time.Now()
+
+	p := portfolio.Portfolio{ID: uuid.New(), UserID: user.ID, Name: "Main", Currency: "USD"}
+	p.Holdings = []portfolio.Holding{{ID: uuid.New(), PortfolioID: p.ID, Symbol: "BTC"}}
+
+	d.repo.On("GetUser", mock.Anything, user.ID).Return(user, nil)
+	d.repo.On("ListRefreshTokens", mock.Anything, user.ID).Return([]auth.RefreshToken{
+		{ID: uuid.New(), UserID: user.ID, TokenHash: "secret-hash", IPAddress: "203.0.113.7", ExpiresAt: now},
+	}, nil)
+	d.repo.On("ListAPIKeys", mock.Anything, user.ID).Return([]auth.APIKey{
+		{ID: uuid.New(), UserID: user.ID, Name: "ci", Prefix: "gbk_abc", KeyHash: "secret-hash"},
+	}, nil)
+	d.repo.On("ListExternalIdentities", mock.Anything, user.ID).Return([]auth.ExternalIdentity{
+		{UserID: user.ID, Provider: "corp", Subject: "alice-sub"},
+	}, nil)
+	d.repo.On("ListPortfolios", mock.Anything, user.ID).Return([]portfolio.Portfolio{p}, nil)
+	d.repo.On("ListTransactions", mock.Anything, user.ID).Return([]portfolio.Transaction{
+		{ID: uuid.New(), PortfolioID: p.ID, Type: portfolio.TransactionBuy, Symbol: "BTC", Quantity: 1, Price: 30000},
+	}, nil)
+	d.repo.On("ListAuditEvents", mock.Anything, user.ID).Return([]audit.Event{
+		{ActorID: user.ID.String(), Action: auth.ActionLogin, IPAddress: "203.0.113.7", UserAgent: "curl"},
+		{ActorID: adminID, Action: auth.ActionRoleChange, TargetID: user.ID.String(), IPAddress: "198.51.100.1", UserAgent: "admin-ui"},
+	}, nil)
+
+	// Act
+	export, err := d.usecase().Export(context.Background(), user.ID)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, user.ID, export.Profile.ID)
+	assert.Equal(t, "alice@example.com", export.Profile.Email)
+	if assert.Len(t, export.Sessions, 1) {
+		assert.Equal(t, "203.0.113.7", export.Sessions[0].IPAddress)
+	}
+	if assert.Len(t, export.APIKeys, 1) {
+		assert.Equal(t, "gbk_abc", export.APIKeys[0].Prefix)
+	}
+	assert.Len(t, export.Identities, 1)
+	if assert.Len(t, export.Portfolios, 1) {
+		assert.Len(t, export.Portfolios[0].Holdings, 1)
+	}
+	if assert.Len(t, export.Transactions, 1) {
+		assert.Equal(t, "buy", export.Transactions[0].Type)
+	}
+	if assert.Len(t, export.AuditEvents, 2) {
+		assert.Equal(t, "203.0.113.7", export.AuditEvents[0].IPAddress)
+		assert.Equal(t, adminID, export.AuditEvents[1].ActorID)
+		assert.Empty(t, export.AuditEvents[1].IPAddress, "another user's address must not be disclosed")
+		assert.Empty(t, export.AuditEvents[1].UserAgent)
+	}
+
+	events := d.audit.Events()
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, ActionExport, events[0].Action)
+		assert.Equal(t, user.ID.String(), events[0].TargetID)
+	}
+	d.repo.AssertExpectations(t)
+}
+
+func TestDeleteAccount_RevokesAccessTokens(t *testing.T) {
+	// Arrange
+	d := newTestDeps()
+	user := d.userWithPassword(t, "correct-horse")
+
+	accessToken, err := d.jwtSvc.GenerateToken(infraAuth.Subject{UserID: user.ID.String()})
+	assert.NoError(t, err)
+	claims, err := d.jwtSvc.ValidateToken(accessToken)
+	assert.NoError(t, err)
+
+	d.repo.On("GetUser", mock.Anything, user.ID).Return(user, nil)
+	d.repo.On("DeleteUser", mock.Anything, user).Return(nil)
+
+	ctx := audit.WithRequestInfo(context.Background(), audit.RequestInfo{
+		IPAddress: "203.0.113.7",
+		UserAgent: "curl",
+		RequestID: "req-1",
+	})
+
+	// Act
+	err = d.usecase().DeleteAccount(ctx, user.ID, "correct-horse")
+
+	// Assert
+	assert.NoError(t, err)
+	revoked, err := d.revocations.IsRevoked(context.Background(), claims)
+	assert.NoError(t, err)
+	assert.True(t, revoked)
+
+	events := d.audit.Events()
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, ActionAccountDelete, events[0].Action)
+		assert.Equal(t, audit.OutcomeSuccess, events[0].Outcome)
+		assert.Empty(t, events[0].IPAddress)
+		assert.Empty(t, events[0].UserAgent)
+		assert.Equal(t, "req-1", events[0].RequestID)
+	}
+	d.repo.AssertExpectations(t)
+}
+
+func TestDeleteAccount_WrongPassword(t *testing.T) {
+	// Arrange
+	d := newTestDeps()
+	user := d.userWithPassword(t, "correct-horse")
+	d.repo.On("GetUser", mock.Anything, user.ID).Return(user, nil)
+
+	// Act
+	err := d.usecase().DeleteAccount(context.Background(), user.ID, "wrong")
+
+	// Assert
+	assert.ErrorIs(t, err, ErrInvalidPassword)
+	d.repo.AssertNotCalled(t, "DeleteUser", mock.Anything, mock.Anything)
+
+	events := d.audit.Events()
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, audit.OutcomeFailure, events[0].Outcome)
+	}
+}
+
+func TestDeleteAccount_SingleSignOnAccountNeedsNoPassword(t *testing.T) {
+	// Arrange
+	d := newTestDeps()
+	user := &auth.User{ID: uuid.New(), Username: "bob"}
+	d.repo.On("GetUser", mock.Anything, user.ID).Return(user, nil)
+	d.repo.On("DeleteUser", mock.Anything, user).Return(nil)
+
+	// Act
+	err := d.usecase().DeleteAccount(context.Background(), user.ID, "")
+
+	// Assert
+	assert.NoError(t, err)
+	d.repo.AssertExpectations(t)
+}
@@ -1,7 +1,13 @@
 package router
 
 import (
+	"fmt"
+	"net"
+	"strconv"
+
 	"go-boilerplate/internal/config"
+	"go-boilerplate/internal/infra/audit"
+	infraAuth "go-boilerplate/internal/infra/auth"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/labstack/echo/v5"
@@ -16,15 +22,73 @@ func (cv *CustomValidator) Validate(i interface{}) error {
 	return cv.validator.Struct(i)
 }
 
-func NewRouter(cfg *config.Config) *echo.Echo {
+// NewValidator returns the request validator. Besides the built-in tags it knows
+// "permission", which accepts the names of the permissions defined in infra/auth, and
+// "maxbytes", which limits a string's length in bytes where "max" counts characters.
+func NewValidator() (*CustomValidator, error) {
+	v := validator.New()
+	err := v.RegisterValidation("permission", func(fl validator.FieldLevel) bool {
+		return infraAuth.Permission(fl.Field().String()).Valid()
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to register permission validator: %w", err)
+	}
+	err = v.RegisterValidation("maxbytes", func(fl validator.FieldLevel) bool {
+		limit, err := strconv.Atoi(fl.Param())
+		return err == nil && len(fl.Field().String()) <= limit
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to register maxbytes validator: %w", err)
+	}
+	return &CustomValidator{validator: v}, nil
+}
+
+func NewRouter(cfg *config.Config) (*echo.Echo, error) {
+	ipExtractor, err := NewIPExtractor(cfg.TrustedProxies)
+	if err != nil {
+		return nil, err
+	}
+	v, err := NewValidator()
+	if err != nil {
+		return nil, err
+	}
+
 	e := echo.New()
-	e.Validator = &CustomValidator{validator: validator.New()}
+	e.Validator = v
+	e.IPExtractor = ipExtractor
 
+	e.Use(middleware.RequestID())
+	e.Use(audit.RequestInfoMiddleware())
 	e.Use(middleware.RequestLogger())
 	e.Use(middleware.Recover())
 	e.Use(middleware.RateLimiter(middleware.NewRateLimiterMemoryStore(
 		cfg.MaxRequestPerSecond,
 	)))
 
-	return e
+	return e, nil
+}
+
+// NewIPExtractor decides which address a request comes from, as used by login lockouts,
+// rate limits, sessions and the audit log. Without trusted proxies it is the connection's
+// peer, since any client can send X-Forwarded-For. Behind proxies whose CIDR ranges are
+// given, it is the nearest address in X-Forwarded-For that is not one of them.
+func NewIPExtractor(trustedProxies []string) (echo.IPExtractor, error) {
+	if len(trustedProxies) == 0 {
+		return echo.ExtractIPDirect(), nil
+	}
+
+	// Only the listed ranges are trusted, not echo's default of every private address.
+	options := []echo.TrustOption{
+		echo.TrustLoopback(false),
+		echo.TrustLinkLocal(false),
+		echo.TrustPrivateNet(false),
+	}
+	for _, cidr := range trustedProxies {
+		_, ipRange, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy range %q: %w", cidr, err)
+		}
+		options = append(options, echo.TrustIPRange(ipRange))
+	}
+	return echo.ExtractIPFromXFFHeader(options...), nil
 }
@@ -0,0 +1,82 @@
+package router
+
+import (
+	"testing"
+
+	"go-boilerplate/internal/config"
+	infraAuth "go-boilerplate/internal/infra/auth"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewValidator_Permission(t *testing.T) {
+	type request struct {
+		Scopes []string `validate:"dive,permission"`
+	}
+	tests := []struct {
+		name    string
+		scopes  []string
+		wantErr bool
+	}{
+		{name: "no scopes", scopes: nil},
+		{name: "known permissions", scopes: []string{string(infraAuth.PermPortfoliosRead), string(infraAuth.PermPricesWrite)}},
+		{name: "unknown permission", scopes: []string{string(infraAuth.PermPortfoliosRead), "portfolios:delete"}, wantErr: true},
+	}
+
+	v, err := NewValidator()
+	require.NoError(t, err)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			err := v.Validate(&request{Scopes: tt.scopes})
+
+			// Assert
+			if tt.wantErr {
+				assert.ErrorContains(t, err, "permission")
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestNewValidator_MaxBytes(t *testing.T) {
+	type request struct {
+		Password string `validate:"maxbytes=8"`
+	}
+	tests := []struct {
+		name     string
+		password string
+		wantErr  bool
+	}{
+		{name: "ASCII at the limit", password: "abcdefgh"},
+		{name: "ASCII over the limit", password: "abcdefghi", wantErr: true},
+		{name: "multibyte characters within the limit", password: "éééé"},
+		{name: "fewer characters than the limit but more bytes", password: "ééééé", wantErr: true},
+	}
+
+	v, err := NewValidator()
+	require.NoError(t, err)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			err := v.Validate(&request{Password: tt.password})
+
+			// Assert
+			if tt.wantErr {
+				assert.ErrorContains(t, err, "maxbytes")
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestNewRouter_InvalidTrustedProxy(t *testing.T) {
+	// Act
+	_, err := NewRouter(&config.Config{MaxRequestPerSecond: 100, TrustedProxies: []string{"10.0.0.1"}})
+
+	// Assert
+	assert.ErrorContains(t, err, `invalid trusted proxy range "10.0.0.1"`)
+}
@@ -57,6 +57,22 @@ func NotFound(c *echo.Context, message string) error {
 	})
 }
 
+// Conflict sends a conflict error response
+func Conflict(c *echo.Context, message string) error {
+	return c.JSON(http.StatusConflict, dto.BaseResponse{
+		Success: false,
+		Error:   message,
+	})
+}
+
+// TooManyRequests sends a too many requests error response
+func TooManyRequests(c *echo.Context, message string) error {
+	return c.JSON(http.StatusTooManyRequests, dto.BaseResponse{
+		Success: false,
+		Error:   message,
+	})
+}
+
 // InternalServerError sends an internal server error response
 func InternalServerError(c *echo.Context, message string) error {
 	return c.JSON(http.StatusInternalServerError, dto.BaseResponse{